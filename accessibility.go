@@ -0,0 +1,112 @@
+package templatex
+
+import (
+	"context"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// unlabeledInputTypes are input types that don't need a label
+var unlabeledInputTypes = map[string]bool{
+	"hidden": true, "submit": true, "button": true, "image": true, "reset": true,
+}
+
+// checkAccessibility checks the rendered output for common accessibility issues:
+// images without alt text, form controls without labels and a missing lang
+// attribute on the html element. It returns the found issues.
+func checkAccessibility(content string, sources sourceMap) []HTMLIssue {
+	var issues []HTMLIssue
+	report := func(offset int, msg string) {
+		issues = append(issues, HTMLIssue{
+			Template: sources.templateAt(offset),
+			Line:     strings.Count(content[:offset], "\n") + 1,
+			Message:  msg,
+		})
+	}
+
+	type control struct {
+		tag    string
+		id     string
+		offset int
+	}
+	var controls []control
+	labelFor := make(map[string]bool)
+	labelDepth := 0
+
+	z := html.NewTokenizer(strings.NewReader(content))
+	offset := 0
+	for {
+		tt := z.Next()
+		if tt == html.ErrorToken {
+			break
+		}
+		pos := offset
+		offset += len(z.Raw())
+
+		if tt == html.EndTagToken {
+			if name, _ := z.TagName(); string(name) == "label" && labelDepth > 0 {
+				labelDepth--
+			}
+			continue
+		}
+		if tt != html.StartTagToken && tt != html.SelfClosingTagToken {
+			continue
+		}
+
+		name, hasAttr := z.TagName()
+		tag := string(name)
+		attrs := make(map[string]string)
+		for hasAttr {
+			var key, val []byte
+			key, val, hasAttr = z.TagAttr()
+			attrs[string(key)] = string(val)
+		}
+
+		switch tag {
+		case "html":
+			if strings.TrimSpace(attrs["lang"]) == "" {
+				report(pos, "<html> is missing the lang attribute")
+			}
+		case "img":
+			if _, ok := attrs["alt"]; !ok {
+				report(pos, "<img> is missing the alt attribute")
+			}
+		case "label":
+			if attrs["for"] != "" {
+				labelFor[attrs["for"]] = true
+			}
+			if tt == html.StartTagToken {
+				labelDepth++
+			}
+		case "input", "select", "textarea":
+			if tag == "input" && unlabeledInputTypes[strings.ToLower(attrs["type"])] {
+				continue
+			}
+			if labelDepth > 0 || attrs["aria-label"] != "" || attrs["aria-labelledby"] != "" || attrs["title"] != "" {
+				continue
+			}
+			controls = append(controls, control{tag: tag, id: attrs["id"], offset: pos})
+		}
+	}
+
+	// Labels can reference controls defined before them, so check controls at the end
+	for _, c := range controls {
+		if c.id == "" || !labelFor[c.id] {
+			report(c.offset, "<"+c.tag+"> has no associated label")
+		}
+	}
+
+	return issues
+}
+
+// reportAccessibilityIssues logs the accessibility issues found in the rendered output
+func (e *Engine) reportAccessibilityIssues(ctx context.Context, content string, sources sourceMap) {
+	for _, issue := range checkAccessibility(content, sources) {
+		e.logger.WarnContext(ctx, "templatex: accessibility issue",
+			"template", issue.Template,
+			"line", issue.Line,
+			"issue", issue.Message,
+		)
+	}
+}
@@ -0,0 +1,145 @@
+package templatex
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// defaultAddressFormat is the address format of countries without a specific format
+const defaultAddressFormat = "{Name}\n{Organization}\n{Street}\n{City} {Region} {PostalCode}"
+
+// addressFormats are the address formats by country code. Tokens in braces are the
+// address fields; text next to an empty field is dropped with it.
+var addressFormats = map[string]string{
+	"":   defaultAddressFormat,
+	"US": "{Name}\n{Organization}\n{Street}\n{City}, {Region} {PostalCode}",
+	"CA": "{Name}\n{Organization}\n{Street}\n{City} {Region} {PostalCode}",
+	"AU": "{Name}\n{Organization}\n{Street}\n{City} {Region} {PostalCode}",
+	"GB": "{Name}\n{Organization}\n{Street}\n{City}\n{PostalCode}",
+	"IE": "{Name}\n{Organization}\n{Street}\n{City}\n{Region}\n{PostalCode}",
+	"DE": "{Name}\n{Organization}\n{Street}\n{PostalCode} {City}",
+	"AT": "{Name}\n{Organization}\n{Street}\n{PostalCode} {City}",
+	"CH": "{Name}\n{Organization}\n{Street}\n{PostalCode} {City}",
+	"FR": "{Name}\n{Organization}\n{Street}\n{PostalCode} {City}",
+	"ES": "{Name}\n{Organization}\n{Street}\n{PostalCode} {City} {Region}",
+	"IT": "{Name}\n{Organization}\n{Street}\n{PostalCode} {City} {Region}",
+	"NL": "{Name}\n{Organization}\n{Street}\n{PostalCode} {City}",
+	"PL": "{Name}\n{Organization}\n{Street}\n{PostalCode} {City}",
+	"BR": "{Name}\n{Organization}\n{Street}\n{City} - {Region}\n{PostalCode}",
+	"RU": "{Name}\n{Organization}\n{Street}\n{City}\n{Region}\n{PostalCode}",
+	"UA": "{Name}\n{Organization}\n{Street}\n{City}\n{Region}\n{PostalCode}",
+	"IN": "{Name}\n{Organization}\n{Street}\n{City} {PostalCode}\n{Region}",
+	"JP": "〒{PostalCode}\n{Region}{City}\n{Street}\n{Organization}\n{Name}",
+	"CN": "{PostalCode}\n{Region}{City}\n{Street}\n{Organization}\n{Name}",
+}
+
+// addressToken matches the field tokens of address formats
+var addressToken = regexp.MustCompile(`\{(\w+)\}`)
+
+// formatAddress returns a function formatting an address struct or map by the format
+// of its Country field, one line per address line. Lines are separated by "\n" or the
+// separator, and lines of empty fields are left out. Fields are looked up by the token
+// name, e.g. PostalCode, then by its lower camel case form for maps, e.g. postalCode.
+// Slices, e.g. a Street field with several lines, are printed on separate lines.
+// Usage: {{ formatAddress .Address }}, {{ formatAddress .Address ", " }}
+func formatAddress(formats map[string]string) func(addr any, sep ...string) (string, error) {
+	return func(addr any, sep ...string) (string, error) {
+		if addr == nil {
+			return "", nil
+		}
+		v := indirect(reflect.ValueOf(addr))
+		if k := v.Kind(); k != reflect.Struct && k != reflect.Map {
+			return "", fmt.Errorf("formatAddress: unsupported address type %T", addr)
+		}
+
+		format, ok := formats[strings.ToUpper(addressField(v, "Country"))]
+		if !ok {
+			format = formats[""]
+		}
+
+		var lines []string
+		for _, line := range strings.Split(format, "\n") {
+			for _, l := range strings.Split(formatAddressLine(v, line), "\n") {
+				if l = strings.TrimSpace(l); l != "" {
+					lines = append(lines, l)
+				}
+			}
+		}
+		if len(sep) > 0 {
+			return strings.Join(lines, sep[0]), nil
+		}
+		return strings.Join(lines, "\n"), nil
+	}
+}
+
+// formatAddressLine replaces the tokens of the format line with the address fields.
+// Text between an empty field and its neighbor is dropped, preferring the text before
+// the field, so "{City}, {Region}" renders "City" without a region.
+func formatAddressLine(v reflect.Value, line string) string {
+	type part struct {
+		text  string
+		token bool
+		drop  bool
+	}
+	var parts []part
+	last := 0
+	for _, m := range addressToken.FindAllStringSubmatchIndex(line, -1) {
+		if m[0] > last {
+			parts = append(parts, part{text: line[last:m[0]]})
+		}
+		value := addressField(v, line[m[2]:m[3]])
+		parts = append(parts, part{text: value, token: true, drop: value == ""})
+		last = m[1]
+	}
+	if last < len(line) {
+		parts = append(parts, part{text: line[last:]})
+	}
+
+	for i, p := range parts {
+		if !p.token || !p.drop {
+			continue
+		}
+		switch {
+		case i > 0 && !parts[i-1].token && !parts[i-1].drop:
+			parts[i-1].drop = true
+		case i+1 < len(parts) && !parts[i+1].token:
+			parts[i+1].drop = true
+		}
+	}
+
+	var sb strings.Builder
+	for _, p := range parts {
+		if !p.drop {
+			sb.WriteString(p.text)
+		}
+	}
+	return sb.String()
+}
+
+// addressField returns the address field as a string. Slice fields are joined by newlines.
+func addressField(v reflect.Value, name string) string {
+	f, ok := lookupKey(v, name)
+	if !ok && v.Kind() == reflect.Map {
+		r, size := utf8.DecodeRuneInString(name)
+		f, ok = lookupKey(v, string(unicode.ToLower(r))+name[size:])
+	}
+	if !ok {
+		return ""
+	}
+	f = indirect(f)
+	if !f.IsValid() {
+		return ""
+	}
+	if k := f.Kind(); (k == reflect.Slice || k == reflect.Array) && f.Type().Elem().Kind() != reflect.Uint8 {
+		lines := make([]string, 0, f.Len())
+		for i := 0; i < f.Len(); i++ {
+			lines = append(lines, fmt.Sprint(f.Index(i).Interface()))
+		}
+		return strings.TrimSpace(strings.Join(lines, "\n"))
+	}
+	return strings.TrimSpace(fmt.Sprint(f.Interface()))
+}
@@ -0,0 +1,90 @@
+package templatex
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// ArchiveFunc returns a writer that receives a copy of the rendered output for the
+// request with the given ID and the rendered template name. The writer is closed
+// after the output is written.
+type ArchiveFunc func(ctx context.Context, requestID, name string) (io.WriteCloser, error)
+
+// maxArchiveNameLen limits the length of the request ID and template name parts of
+// archive file names
+const maxArchiveNameLen = 100
+
+// ArchiveToDir returns an ArchiveFunc storing rendered output in dir as
+// <request id>_<template name>.html files. Characters other than letters, digits,
+// dots, dashes and underscores are replaced with underscores. Existing files are never
+// overwritten: renders for a request ID and template archived before are stored with
+// a numeric suffix, e.g. <request id>_<template name>_2.html.
+func ArchiveToDir(dir string) ArchiveFunc {
+	return func(ctx context.Context, requestID, name string) (io.WriteCloser, error) {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return nil, err
+		}
+		base := sanitizeFileName(requestID) + "_" + sanitizeFileName(name)
+		file := base + ".html"
+		for n := 2; ; n++ {
+			f, err := os.OpenFile(filepath.Join(dir, file), os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o644)
+			if !errors.Is(err, fs.ErrExist) {
+				return f, err
+			}
+			file = base + "_" + strconv.Itoa(n) + ".html"
+		}
+	}
+}
+
+// sanitizeFileName replaces characters other than ASCII letters, digits, dots, dashes
+// and underscores, so the result is a single path element, and truncates long names
+func sanitizeFileName(s string) string {
+	s = strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_' || r == '.' {
+			return r
+		}
+		return '_'
+	}, s)
+	if len(s) > maxArchiveNameLen {
+		s = s[:maxArchiveNameLen]
+	}
+	// A leading dot would make "." and ".." path elements or hidden files
+	if strings.HasPrefix(s, ".") {
+		s = "_" + s[1:]
+	}
+	return s
+}
+
+// writeOutput writes the body, the rendered content or its encoded form, to out and,
+// if archiving is enabled and ctx carries a request ID, the content to the archive writer
+func (e *Engine) writeOutput(ctx context.Context, out io.Writer, name, content, body string) error {
+	requestID := RequestID(ctx)
+	if e.archive == nil || requestID == "" {
+		_, err := io.WriteString(out, body)
+		return err
+	}
+
+	// Open the archive before writing, so nothing is shown to the user
+	// if the output can't be archived
+	aw, err := e.archive(ctx, requestID, name)
+	if err != nil {
+		return errors.Join(ErrArchiveFailed, err)
+	}
+
+	if _, err := io.WriteString(out, body); err != nil {
+		return errors.Join(err, aw.Close())
+	}
+	if _, err := io.WriteString(aw, content); err != nil {
+		return errors.Join(ErrArchiveFailed, err, aw.Close())
+	}
+	if err := aw.Close(); err != nil {
+		return errors.Join(ErrArchiveFailed, err)
+	}
+	return nil
+}
@@ -0,0 +1,215 @@
+package templatex
+
+import (
+	"html/template"
+	"reflect"
+	"sort"
+	"strings"
+	"text/template/parse"
+)
+
+// safeContentTypes are html/template types whose values are inserted without escaping
+var safeContentTypes = map[reflect.Type]bool{
+	reflect.TypeOf(template.HTML("")):     true,
+	reflect.TypeOf(template.HTMLAttr("")): true,
+	reflect.TypeOf(template.JS("")):       true,
+	reflect.TypeOf(template.JSStr("")):    true,
+	reflect.TypeOf(template.CSS("")):      true,
+	reflect.TypeOf(template.URL("")):      true,
+	reflect.TypeOf(template.Srcset("")):   true,
+}
+
+// Finding is a pipeline passing non-literal data to a function that disables
+// escaping (see Engine.AuditUnsafeSinks)
+type Finding struct {
+	Template string // template name
+	Location string // position in the template source, "name:line:col"
+	Func     string // name of the unsafe function
+	Pipeline string // source of the pipeline
+}
+
+// AuditUnsafeSinks statically locates every pipeline feeding non-literal data
+// (fields, variables, function results) to a function that disables escaping,
+// i.e. htmlSafe and custom functions returning safe content types like template.HTML
+// or template.JS. These are the places where user-controlled data can lead to XSS,
+// so security teams can review and track them:
+//
+//	{{ htmlSafe .Bio }}        <!-- finding -->
+//	{{ .Bio | htmlSafe }}      <!-- finding -->
+//	{{ htmlSafe "<br>" }}      <!-- literal, no finding -->
+//	{{ htmlSafe (sanitize .Bio) }} <!-- sanitized, no finding -->
+//
+// Findings are sorted by template and position.
+func (e *Engine) AuditUnsafeSinks() []Finding {
+	if !e.initialized() {
+		return nil
+	}
+	// Templates failing to parse lazily are left out, as they can't be rendered
+	_ = e.ensureAllParsed()
+
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	sinks := e.unsafeSinks()
+	findings := make([]Finding, 0)
+	seen := make(map[*parse.Tree]bool)
+	for _, t := range e.templates.Templates() {
+		// Aliases, e.g. of versioned components, share the tree
+		if t.Name() == "" || t.Tree == nil || t.Tree.Root == nil || seen[t.Tree] {
+			continue
+		}
+		seen[t.Tree] = true
+		a := &sinkAuditor{name: t.Name(), tree: t.Tree, sinks: sinks}
+		a.walk(t.Tree.Root)
+		findings = append(findings, a.findings...)
+	}
+
+	// Findings of a template are collected in source order
+	sort.SliceStable(findings, func(i, j int) bool {
+		return findings[i].Template < findings[j].Template
+	})
+	return findings
+}
+
+// unsafeSinks returns the names of functions disabling escaping of their arguments:
+// htmlSafe and custom functions with arguments returning a safe content type
+func (e *Engine) unsafeSinks() map[string]bool {
+	sinks := map[string]bool{"htmlSafe": true}
+	for name := range e.customFuncs {
+		fn := reflect.ValueOf(e.funcMap[name])
+		if fn.Kind() != reflect.Func || fn.Type().NumIn() == 0 || fn.Type().NumOut() == 0 {
+			continue
+		}
+		if safeContentTypes[fn.Type().Out(0)] {
+			sinks[name] = true
+		}
+	}
+	return sinks
+}
+
+// sinkAuditor collects unsafe sink findings of a parse tree
+type sinkAuditor struct {
+	name     string
+	tree     *parse.Tree
+	sinks    map[string]bool
+	findings []Finding
+}
+
+// walk visits the node and its children
+func (a *sinkAuditor) walk(node parse.Node) {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return
+		}
+		for _, c := range n.Nodes {
+			a.walk(c)
+		}
+	case *parse.ActionNode:
+		a.pipe(n.Pipe)
+	case *parse.TemplateNode:
+		a.pipe(n.Pipe)
+	case *parse.IfNode:
+		a.branch(&n.BranchNode)
+	case *parse.RangeNode:
+		a.branch(&n.BranchNode)
+	case *parse.WithNode:
+		a.branch(&n.BranchNode)
+	}
+}
+
+// branch visits the pipeline and the lists of an if, range or with action
+func (a *sinkAuditor) branch(n *parse.BranchNode) {
+	a.pipe(n.Pipe)
+	a.walk(n.List)
+	a.walk(n.ElseList)
+}
+
+// pipe checks the commands of the pipeline, including parenthesized pipelines
+func (a *sinkAuditor) pipe(p *parse.PipeNode) {
+	if p == nil {
+		return
+	}
+	for i, cmd := range p.Cmds {
+		for _, arg := range cmd.Args {
+			if sub, ok := arg.(*parse.PipeNode); ok {
+				a.pipe(sub)
+			}
+		}
+
+		fn, ok := cmd.Args[0].(*parse.IdentifierNode)
+		if !ok || !a.sinks[fn.Ident] {
+			continue
+		}
+		// The result of the previous command is passed as the last argument
+		unsafe := i > 0 && !isLiteralCommand(p.Cmds[i-1]) && !isSanitizeCommand(p.Cmds[i-1])
+		for _, arg := range cmd.Args[1:] {
+			if !isLiteral(arg) && !isSanitized(arg) {
+				unsafe = true
+			}
+		}
+		if unsafe {
+			location, _ := a.tree.ErrorContext(cmd)
+			a.findings = append(a.findings, Finding{
+				Template: a.name,
+				Location: location,
+				Func:     fn.Ident,
+				Pipeline: pipelineSource(p),
+			})
+		}
+	}
+}
+
+// pipelineSource returns the source of the pipeline without the escaping
+// commands added by html/template
+func pipelineSource(p *parse.PipeNode) string {
+	cmds := make([]string, 0, len(p.Cmds))
+	for _, cmd := range p.Cmds {
+		if fn, ok := cmd.Args[0].(*parse.IdentifierNode); ok && strings.HasPrefix(fn.Ident, "_html_template_") {
+			continue
+		}
+		cmds = append(cmds, cmd.String())
+	}
+	src := strings.Join(cmds, " | ")
+	if len(p.Decl) > 0 {
+		vars := make([]string, len(p.Decl))
+		for i, v := range p.Decl {
+			vars[i] = v.String()
+		}
+		src = strings.Join(vars, ", ") + " := " + src
+	}
+	return src
+}
+
+// isLiteralCommand reports whether the command is a single literal, e.g. "<br>"
+func isLiteralCommand(cmd *parse.CommandNode) bool {
+	return len(cmd.Args) == 1 && isLiteral(cmd.Args[0])
+}
+
+// isSanitizeCommand reports whether the command calls sanitize
+func isSanitizeCommand(cmd *parse.CommandNode) bool {
+	fn, ok := cmd.Args[0].(*parse.IdentifierNode)
+	return ok && fn.Ident == "sanitize"
+}
+
+// isSanitized reports whether the node is a parenthesized pipeline ending with sanitize
+func isSanitized(node parse.Node) bool {
+	p, ok := node.(*parse.PipeNode)
+	return ok && len(p.Cmds) > 0 && isSanitizeCommand(p.Cmds[len(p.Cmds)-1])
+}
+
+// isLiteral reports whether the node is a constant written in the template
+func isLiteral(node parse.Node) bool {
+	switch n := node.(type) {
+	case *parse.StringNode, *parse.NumberNode, *parse.BoolNode, *parse.NilNode:
+		return true
+	case *parse.PipeNode:
+		for _, cmd := range n.Cmds {
+			if !isLiteralCommand(cmd) {
+				return false
+			}
+		}
+		return len(n.Decl) == 0
+	}
+	return false
+}
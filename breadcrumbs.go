@@ -0,0 +1,135 @@
+package templatex
+
+import (
+	"context"
+	"encoding/json"
+	"html/template"
+	"strings"
+	"sync"
+)
+
+var breadcrumbsKey = &contextKey{"breadcrumbs"}
+
+// Breadcrumb represents a single item of a breadcrumb trail.
+type Breadcrumb struct {
+	Title string
+	URL   string
+}
+
+// BreadcrumbTrail is a thread-safe list of breadcrumbs built during request handling
+// and rendered by the built-in breadcrumbs template function.
+type BreadcrumbTrail struct {
+	mu    sync.RWMutex
+	items []Breadcrumb
+}
+
+// WithBreadcrumbs returns a copy of ctx that carries an empty breadcrumb trail.
+// If ctx already carries a trail, ctx is returned unchanged.
+// The Middleware attaches a trail to every request automatically.
+func WithBreadcrumbs(ctx context.Context) context.Context {
+	if _, ok := ctx.Value(breadcrumbsKey).(*BreadcrumbTrail); ok {
+		return ctx
+	}
+	return context.WithValue(ctx, breadcrumbsKey, &BreadcrumbTrail{})
+}
+
+// Breadcrumbs returns the breadcrumb trail stored in ctx.
+// If ctx doesn't carry a trail (see WithBreadcrumbs), a new detached trail is returned,
+// so it's always safe to call Add on the result.
+//
+// Usage:
+//
+//	templatex.Breadcrumbs(ctx).Add("Home", "/").Add("Settings", "/settings")
+func Breadcrumbs(ctx context.Context) *BreadcrumbTrail {
+	if ctx != nil {
+		if b, ok := ctx.Value(breadcrumbsKey).(*BreadcrumbTrail); ok {
+			return b
+		}
+	}
+	return &BreadcrumbTrail{}
+}
+
+// Add appends a breadcrumb to the trail and returns the trail to allow chaining.
+// Use an empty url for the current page.
+func (b *BreadcrumbTrail) Add(title, url string) *BreadcrumbTrail {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.items = append(b.items, Breadcrumb{Title: title, URL: url})
+	return b
+}
+
+// Items returns a copy of the breadcrumbs in the trail.
+func (b *BreadcrumbTrail) Items() []Breadcrumb {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	items := make([]Breadcrumb, len(b.items))
+	copy(items, b.items)
+	return items
+}
+
+// String returns a string representation of the trail.
+// It's used as a part of the render cache key.
+func (b *BreadcrumbTrail) String() string {
+	var sb strings.Builder
+	for _, item := range b.Items() {
+		sb.WriteString(item.Title)
+		sb.WriteByte('=')
+		sb.WriteString(item.URL)
+		sb.WriteByte(';')
+	}
+	return sb.String()
+}
+
+// renderBreadcrumbs returns a function that renders the breadcrumb trail stored in ctx
+// as an ordered list followed by a schema.org BreadcrumbList JSON-LD script.
+// The last item is marked as the current page. It returns an empty string if the
+// trail is empty.
+// Usage: {{ breadcrumbs }}
+func renderBreadcrumbs(ctx context.Context) func() template.HTML {
+	return func() template.HTML {
+		items := Breadcrumbs(ctx).Items()
+		if len(items) == 0 {
+			return ""
+		}
+
+		var sb strings.Builder
+		sb.WriteString(`<nav aria-label="breadcrumb"><ol class="breadcrumbs">`)
+		for i, item := range items {
+			title := template.HTMLEscapeString(item.Title)
+			if i == len(items)-1 || item.URL == "" {
+				sb.WriteString(`<li aria-current="page">` + title + `</li>`)
+				continue
+			}
+			sb.WriteString(`<li><a href="` + template.HTMLEscapeString(item.URL) + `">` + title + `</a></li>`)
+		}
+		sb.WriteString(`</ol></nav>`)
+
+		type listItem struct {
+			Type     string `json:"@type"`
+			Position int    `json:"position"`
+			Name     string `json:"name"`
+			Item     string `json:"item,omitempty"`
+		}
+		list := struct {
+			Context string     `json:"@context"`
+			Type    string     `json:"@type"`
+			Items   []listItem `json:"itemListElement"`
+		}{
+			Context: "https://schema.org",
+			Type:    "BreadcrumbList",
+			Items:   make([]listItem, len(items)),
+		}
+		for i, item := range items {
+			list.Items[i] = listItem{Type: "ListItem", Position: i + 1, Name: item.Title, Item: item.URL}
+		}
+
+		// json.Marshal escapes <, > and & so the output is safe inside a script tag
+		if b, err := json.Marshal(list); err == nil {
+			sb.WriteString(`<script type="application/ld+json">`)
+			sb.Write(b)
+			sb.WriteString(`</script>`)
+		}
+
+		return template.HTML(sb.String())
+	}
+}
@@ -0,0 +1,585 @@
+package templatex
+
+import (
+	"bytes"
+	"container/list"
+	"encoding"
+	"fmt"
+	"reflect"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxCanonicalDepth limits the depth of values encoded into cache keys
+const maxCanonicalDepth = 32
+
+// CacheKeyer is implemented by bindings that provide their own cache key.
+// The key must change whenever the rendered output would change, and should
+// not contain sensitive data. It's cheaper than hashing the whole binding.
+type CacheKeyer interface {
+	CacheKey() string
+}
+
+// renderCache stores rendered content by cache key. Entries expire after the TTL
+// and the least recently used ones are evicted when the cache is full.
+// A zero TTL or max entries means no limit.
+type renderCache struct {
+	mu        sync.Mutex
+	entries   map[string]*list.Element
+	lru       list.List // most recently used entries first
+	ttl       time.Duration
+	max       int
+	now       func() time.Time
+	lastSweep time.Time
+	onRemove  func(key string, tags []string) // called for removed entries
+
+	hits      int64
+	misses    int64
+	evictions int64
+	bytes     int64 // estimated size of cached entries
+}
+
+// CacheStats are render cache statistics (see Engine.CacheStats)
+type CacheStats struct {
+	Hits      int64 // renders served from the cache
+	Misses    int64 // renders not found in the cache, including expired ones
+	Evictions int64 // entries removed because they expired or the cache was full
+	Entries   int   // number of cached renders
+	Bytes     int64 // estimated memory used by cached renders
+}
+
+// HitRatio returns the share of renders served from the cache, from 0 to 1
+func (s CacheStats) HitRatio() float64 {
+	if s.Hits+s.Misses == 0 {
+		return 0
+	}
+	return float64(s.Hits) / float64(s.Hits+s.Misses)
+}
+
+// cacheEntry is a rendered content stored in the render cache
+type cacheEntry struct {
+	key       string
+	content   string
+	templates []string // names of the rendered template and its layouts
+	tags      []string
+	expires   time.Time
+	variants  map[string]string // encoded content by content encoding
+}
+
+// load returns the cached content for the key, unless it's expired
+func (c *renderCache) load(key string) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.entries[key]
+	if !ok {
+		c.misses++
+		return "", false
+	}
+	entry := el.Value.(*cacheEntry)
+	if c.ttl > 0 && !c.now().Before(entry.expires) {
+		c.removeElement(el, true)
+		c.misses++
+		return "", false
+	}
+	c.lru.MoveToFront(el)
+	c.hits++
+	return entry.content, true
+}
+
+// store adds the content to the cache, evicting the least recently used
+// entries if the cache is full
+func (c *renderCache) store(key, content string, templates, tags []string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.entries == nil {
+		c.entries = make(map[string]*list.Element)
+	}
+
+	now := c.now()
+	entry := &cacheEntry{key: key, content: content, templates: templates, tags: tags}
+	if c.ttl > 0 {
+		entry.expires = now.Add(c.ttl)
+	}
+	if el, ok := c.entries[key]; ok {
+		c.removeElement(el, false)
+	}
+	c.entries[key] = c.lru.PushFront(entry)
+	c.bytes += entry.size()
+
+	for c.max > 0 && c.lru.Len() > c.max {
+		c.removeElement(c.lru.Back(), true)
+	}
+
+	// Entries that are never loaded again are only removed by sweeps
+	if c.ttl > 0 && now.Sub(c.lastSweep) >= c.ttl {
+		c.lastSweep = now
+		for el := c.lru.Back(); el != nil; {
+			prev := el.Prev()
+			if !now.Before(el.Value.(*cacheEntry).expires) {
+				c.removeElement(el, true)
+			}
+			el = prev
+		}
+	}
+}
+
+// delete removes the entry with the key and reports whether it was cached
+func (c *renderCache) delete(key string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.entries[key]
+	if ok {
+		c.removeElement(el, false)
+	}
+	return ok
+}
+
+// deleteTemplate removes the entries rendered with the template, either as the
+// content template or as a layout, and returns the number of removed entries
+func (c *renderCache) deleteTemplate(name string) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	removed := 0
+	for el := c.lru.Front(); el != nil; {
+		next := el.Next()
+		if slices.Contains(el.Value.(*cacheEntry).templates, name) {
+			c.removeElement(el, false)
+			removed++
+		}
+		el = next
+	}
+	return removed
+}
+
+// clear removes all entries
+func (c *renderCache) clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries = nil
+	c.lru.Init()
+	c.bytes = 0
+}
+
+// stats returns the cache statistics
+func (c *renderCache) stats() CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return CacheStats{
+		Hits:      c.hits,
+		Misses:    c.misses,
+		Evictions: c.evictions,
+		Entries:   c.lru.Len(),
+		Bytes:     c.bytes,
+	}
+}
+
+// len returns the number of cached entries, including expired ones not removed yet
+func (c *renderCache) len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.lru.Len()
+}
+
+// removeElement removes the entry from the cache, counting it as an eviction if
+// it expired or the cache was full. The caller must hold the lock.
+func (c *renderCache) removeElement(el *list.Element, evicted bool) {
+	entry := c.lru.Remove(el).(*cacheEntry)
+	delete(c.entries, entry.key)
+	c.bytes -= entry.size()
+	if evicted {
+		c.evictions++
+	}
+	if c.onRemove != nil {
+		c.onRemove(entry.key, entry.tags)
+	}
+}
+
+// size returns the estimated memory used by the entry
+func (e *cacheEntry) size() int64 {
+	n := len(e.key) + len(e.content)
+	for _, s := range e.templates {
+		n += len(s)
+	}
+	for _, s := range e.tags {
+		n += len(s)
+	}
+	for _, s := range e.variants {
+		n += len(s)
+	}
+	return int64(n)
+}
+
+// variant returns the content of the entry with the key in the encoding, encoding
+// and storing it on first use. It reports false if the entry isn't cached.
+func (c *renderCache) variant(key, encoding string, encode func(content string) (string, error)) (string, bool, error) {
+	c.mu.Lock()
+	el, ok := c.entries[key]
+	if !ok {
+		c.mu.Unlock()
+		return "", false, nil
+	}
+	entry := el.Value.(*cacheEntry)
+	if v, ok := entry.variants[encoding]; ok {
+		c.mu.Unlock()
+		return v, true, nil
+	}
+	c.mu.Unlock()
+
+	// Encode without holding the lock, so other renders aren't blocked
+	v, err := encode(entry.content)
+	if err != nil {
+		return "", true, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.entries[key]; ok && el.Value.(*cacheEntry) == entry {
+		if _, ok := entry.variants[encoding]; !ok {
+			if entry.variants == nil {
+				entry.variants = make(map[string]string)
+			}
+			entry.variants[encoding] = v
+			c.bytes += int64(len(v))
+		}
+	}
+	return v, true, nil
+}
+
+// cacheTagIndex maps cache tags to the keys of cached renders
+type cacheTagIndex struct {
+	mu   sync.Mutex
+	keys map[string]map[string]struct{}
+}
+
+// add associates the cache key with the tags
+func (i *cacheTagIndex) add(key string, tags []string) {
+	if len(tags) == 0 {
+		return
+	}
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if i.keys == nil {
+		i.keys = make(map[string]map[string]struct{})
+	}
+	for _, tag := range tags {
+		if i.keys[tag] == nil {
+			i.keys[tag] = make(map[string]struct{})
+		}
+		i.keys[tag][key] = struct{}{}
+	}
+}
+
+// remove removes the cache key from the tags
+func (i *cacheTagIndex) remove(key string, tags []string) {
+	if len(tags) == 0 {
+		return
+	}
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	for _, tag := range tags {
+		delete(i.keys[tag], key)
+		if len(i.keys[tag]) == 0 {
+			delete(i.keys, tag)
+		}
+	}
+}
+
+// take removes the tags from the index and returns the keys associated with them
+func (i *cacheTagIndex) take(tags ...string) []string {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	var keys []string
+	for _, tag := range tags {
+		for key := range i.keys[tag] {
+			keys = append(keys, key)
+		}
+		delete(i.keys, tag)
+	}
+	return keys
+}
+
+// reset removes all tags from the index
+func (i *cacheTagIndex) reset() {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.keys = nil
+}
+
+// InvalidateByTag removes all cached renders associated with any of the tags
+// (see WithCacheTags), e.g. when a CMS publishes changed content.
+// It returns the number of removed cache entries.
+func (e *Engine) InvalidateByTag(tags ...string) int {
+	if e == nil {
+		return 0
+	}
+	removed := 0
+	for _, key := range e.cacheTags.take(tags...) {
+		if e.cache.delete(key) {
+			removed++
+		}
+	}
+	return removed
+}
+
+// CacheStats returns the render cache statistics, e.g. to check whether caching
+// actually helps
+func (e *Engine) CacheStats() CacheStats {
+	if e == nil {
+		return CacheStats{}
+	}
+	return e.cache.stats()
+}
+
+// PurgeCache removes all rendered content from the render cache, e.g. after content
+// rendered with WithHardCache(true) changed. Templates are re-rendered on the next request.
+func (e *Engine) PurgeCache() {
+	if e == nil {
+		return
+	}
+	e.cache.clear()
+	e.cacheTags.reset()
+}
+
+// PurgeTemplate removes the cached renders of the template, including renders using
+// it as a layout, and returns the number of removed cache entries. Renders including
+// the template as a partial are not tracked; use PurgeCache or cache tags
+// (see WithCacheTags) for them.
+func (e *Engine) PurgeTemplate(name string) int {
+	if e == nil {
+		return 0
+	}
+	return e.cache.deleteTemplate(e.canonicalName(name))
+}
+
+// PurgeLayoutCache removes all cached layout chains (see WithLayoutCache),
+// including precompiled ones (see WithPrecompiledChains).
+// Chains are rebuilt on the next render.
+func (e *Engine) PurgeLayoutCache() {
+	if e == nil {
+		return
+	}
+	e.layoutCache.Range(func(key, _ any) bool {
+		e.layoutCache.Delete(key)
+		return true
+	})
+	e.releaseChains(e.chains.purge())
+}
+
+// canonicalName returns the actual name of the template the name refers to
+// (see lookup), or the name itself if there is no such template
+func (e *Engine) canonicalName(name string) string {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	if e.templates == nil {
+		return name
+	}
+	if t := e.lookup(name); t != nil {
+		return t.Name()
+	}
+	return name
+}
+
+// cacheKeyBinding returns the value hashed into the cache key for the binding.
+// Bindings implementing CacheKeyer are used as is. Otherwise, if cache key fields
+// are set (see WithCacheKeyFields), only the values of these fields are used.
+func (e *Engine) cacheKeyBinding(binding any) any {
+	if binding == nil || len(e.cacheFields) == 0 {
+		return binding
+	}
+	if _, ok := binding.(CacheKeyer); ok {
+		return binding
+	}
+	var sb strings.Builder
+	for _, field := range e.cacheFields {
+		v, _ := lookupPath(binding, field)
+		fmt.Fprintf(&sb, "%s=%v\x00", field, v)
+	}
+	return sb.String()
+}
+
+// cacheKeyerType is used to find bindings with their own cache key without
+// converting every value to an interface
+var cacheKeyerType = reflect.TypeOf((*CacheKeyer)(nil)).Elem()
+
+// Types encoded by writeCanonical by their own representation rather than their fields
+var (
+	timeType            = reflect.TypeFor[time.Time]()
+	binaryMarshalerType = reflect.TypeFor[encoding.BinaryMarshaler]()
+	textMarshalerType   = reflect.TypeFor[encoding.TextMarshaler]()
+)
+
+// canonicalVisit is a reference visited while encoding a value, used to detect cycles
+type canonicalVisit struct {
+	ptr uintptr
+	len int
+	typ reflect.Type
+}
+
+// writeCanonical writes a deterministic representation of the value to w.
+// Map entries are sorted by their encoded keys, so equal maps produce equal
+// output regardless of iteration order. Times are encoded as instants with their
+// locations, and values implementing encoding.BinaryMarshaler or
+// encoding.TextMarshaler by their encoded form. Unexported struct fields, which
+// templates can't read, are skipped. References to values that are being encoded
+// are written as "cycle".
+func writeCanonical(w *bytes.Buffer, v reflect.Value, depth int) {
+	encodeCanonical(w, v, depth, make(map[canonicalVisit]bool))
+}
+
+// encodeCanonical writes the value like writeCanonical, tracking the references on
+// the path to the value in visiting
+func encodeCanonical(w *bytes.Buffer, v reflect.Value, depth int, visiting map[canonicalVisit]bool) {
+	if depth > maxCanonicalDepth {
+		w.WriteString("...")
+		return
+	}
+	if !v.IsValid() {
+		w.WriteString("nil")
+		return
+	}
+	if writeMarshaled(w, v) {
+		return
+	}
+
+	// Pointers, maps and slices may refer to values being encoded
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Map, reflect.Slice:
+		if !v.IsNil() {
+			visit := canonicalVisit{ptr: v.Pointer(), typ: v.Type()}
+			if v.Kind() == reflect.Slice {
+				// Slices of the same array with other lengths are different values
+				visit.len = v.Len()
+			}
+			if visiting[visit] {
+				w.WriteString("cycle")
+				return
+			}
+			visiting[visit] = true
+			defer delete(visiting, visit)
+		}
+	}
+
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if v.IsNil() {
+			w.WriteString("nil")
+			return
+		}
+		encodeCanonical(w, v.Elem(), depth+1, visiting)
+	case reflect.Struct:
+		t := v.Type()
+		w.WriteString(t.String())
+		w.WriteByte('{')
+		for i := 0; i < v.NumField(); i++ {
+			if !t.Field(i).IsExported() {
+				continue
+			}
+			w.WriteString(t.Field(i).Name)
+			w.WriteByte(':')
+			encodeCanonical(w, v.Field(i), depth+1, visiting)
+			w.WriteByte(',')
+		}
+		w.WriteByte('}')
+	case reflect.Map:
+		type entry struct{ key, val []byte }
+		entries := make([]entry, 0, v.Len())
+		var key, val bytes.Buffer
+		iter := v.MapRange()
+		for iter.Next() {
+			key.Reset()
+			val.Reset()
+			encodeCanonical(&key, iter.Key(), depth+1, visiting)
+			encodeCanonical(&val, iter.Value(), depth+1, visiting)
+			entries = append(entries, entry{key: bytes.Clone(key.Bytes()), val: bytes.Clone(val.Bytes())})
+		}
+		sort.Slice(entries, func(i, j int) bool { return bytes.Compare(entries[i].key, entries[j].key) < 0 })
+		w.WriteString("map{")
+		for _, e := range entries {
+			w.Write(e.key)
+			w.WriteByte(':')
+			w.Write(e.val)
+			w.WriteByte(',')
+		}
+		w.WriteByte('}')
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			w.WriteString("nil")
+			return
+		}
+		w.WriteByte('[')
+		w.Write(strconv.AppendInt(w.AvailableBuffer(), int64(v.Len()), 10))
+		w.WriteByte(']')
+		if v.Type().Elem().Kind() == reflect.Uint8 && v.Kind() == reflect.Slice {
+			w.Write(v.Bytes())
+			return
+		}
+		for i := 0; i < v.Len(); i++ {
+			encodeCanonical(w, v.Index(i), depth+1, visiting)
+			w.WriteByte(',')
+		}
+	case reflect.String:
+		w.Write(strconv.AppendQuote(w.AvailableBuffer(), v.String()))
+	case reflect.Bool:
+		w.Write(strconv.AppendBool(w.AvailableBuffer(), v.Bool()))
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		w.Write(strconv.AppendInt(w.AvailableBuffer(), v.Int(), 10))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		w.Write(strconv.AppendUint(w.AvailableBuffer(), v.Uint(), 10))
+	case reflect.Float32, reflect.Float64:
+		w.Write(strconv.AppendFloat(w.AvailableBuffer(), v.Float(), 'g', -1, 64))
+	case reflect.Complex64, reflect.Complex128:
+		w.WriteString(strconv.FormatComplex(v.Complex(), 'g', -1, 128))
+	default:
+		// Functions, channels and unsafe pointers have no comparable content
+		w.WriteString(v.Type().String())
+	}
+}
+
+// writeMarshaled writes values with their own representation: cache keys of
+// CacheKeyer values, times and the encoded form of marshalers. It reports whether
+// the value was written.
+func writeMarshaled(w *bytes.Buffer, v reflect.Value) bool {
+	if !v.CanInterface() || (v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface) && v.IsNil() {
+		return false
+	}
+	t := v.Type()
+	switch {
+	case t.Implements(cacheKeyerType):
+		w.WriteString("key:")
+		w.WriteString(v.Interface().(CacheKeyer).CacheKey())
+		return true
+	case t == timeType:
+		// Equal instants in other locations render differently
+		tm := v.Interface().(time.Time)
+		w.WriteString("time:")
+		w.Write(strconv.AppendInt(w.AvailableBuffer(), tm.UnixNano(), 10))
+		w.WriteByte('@')
+		w.WriteString(tm.Location().String())
+		return true
+	case t.Implements(binaryMarshalerType):
+		b, err := v.Interface().(encoding.BinaryMarshaler).MarshalBinary()
+		if err != nil {
+			return false
+		}
+		w.WriteString(t.String())
+		w.WriteString(":bin:")
+		w.Write(strconv.AppendInt(w.AvailableBuffer(), int64(len(b)), 10))
+		w.WriteByte(':')
+		w.Write(b)
+		return true
+	case t.Implements(textMarshalerType):
+		b, err := v.Interface().(encoding.TextMarshaler).MarshalText()
+		if err != nil {
+			return false
+		}
+		w.WriteString(t.String())
+		w.WriteString(":text:")
+		w.Write(strconv.AppendQuote(w.AvailableBuffer(), string(b)))
+		return true
+	}
+	return false
+}
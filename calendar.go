@@ -0,0 +1,133 @@
+package templatex
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// weekdayNames are the abbreviated day names by language, starting with Sunday
+var weekdayNames = map[string][7]string{
+	"":   {"Sun", "Mon", "Tue", "Wed", "Thu", "Fri", "Sat"},
+	"en": {"Sun", "Mon", "Tue", "Wed", "Thu", "Fri", "Sat"},
+	"de": {"So", "Mo", "Di", "Mi", "Do", "Fr", "Sa"},
+	"es": {"dom", "lun", "mar", "mié", "jue", "vie", "sáb"},
+	"fr": {"dim.", "lun.", "mar.", "mer.", "jeu.", "ven.", "sam."},
+	"it": {"dom", "lun", "mar", "mer", "gio", "ven", "sab"},
+	"pt": {"dom.", "seg.", "ter.", "qua.", "qui.", "sex.", "sáb."},
+	"nl": {"zo", "ma", "di", "wo", "do", "vr", "za"},
+	"pl": {"niedz.", "pon.", "wt.", "śr.", "czw.", "pt.", "sob."},
+	"sv": {"sön", "mån", "tis", "ons", "tors", "fre", "lör"},
+	"ru": {"вс", "пн", "вт", "ср", "чт", "пт", "сб"},
+	"uk": {"нд", "пн", "вт", "ср", "чт", "пт", "сб"},
+	"ja": {"日", "月", "火", "水", "木", "金", "土"},
+	"zh": {"周日", "周一", "周二", "周三", "周四", "周五", "周六"},
+}
+
+// firstWeekdays are the first days of the week by region, Monday elsewhere (CLDR)
+var firstWeekdays = map[string]time.Weekday{
+	"AG": time.Sunday, "AS": time.Sunday, "BD": time.Sunday, "BR": time.Sunday, "BS": time.Sunday,
+	"BT": time.Sunday, "BW": time.Sunday, "BZ": time.Sunday, "CA": time.Sunday, "CN": time.Sunday,
+	"CO": time.Sunday, "DM": time.Sunday, "DO": time.Sunday, "ET": time.Sunday, "GT": time.Sunday,
+	"GU": time.Sunday, "HK": time.Sunday, "HN": time.Sunday, "ID": time.Sunday, "IL": time.Sunday,
+	"IN": time.Sunday, "JM": time.Sunday, "JP": time.Sunday, "KE": time.Sunday, "KH": time.Sunday,
+	"KR": time.Sunday, "LA": time.Sunday, "MH": time.Sunday, "MM": time.Sunday, "MO": time.Sunday,
+	"MT": time.Sunday, "MX": time.Sunday, "MZ": time.Sunday, "NI": time.Sunday, "NP": time.Sunday,
+	"PA": time.Sunday, "PE": time.Sunday, "PH": time.Sunday, "PK": time.Sunday, "PR": time.Sunday,
+	"PT": time.Sunday, "PY": time.Sunday, "SA": time.Sunday, "SG": time.Sunday, "SV": time.Sunday,
+	"TH": time.Sunday, "TT": time.Sunday, "TW": time.Sunday, "UM": time.Sunday, "US": time.Sunday,
+	"VE": time.Sunday, "VI": time.Sunday, "WS": time.Sunday, "YE": time.Sunday, "ZA": time.Sunday,
+	"ZW": time.Sunday,
+	"AE": time.Saturday, "AF": time.Saturday, "BH": time.Saturday, "DJ": time.Saturday, "DZ": time.Saturday,
+	"EG": time.Saturday, "IQ": time.Saturday, "IR": time.Saturday, "JO": time.Saturday, "KW": time.Saturday,
+	"LY": time.Saturday, "OM": time.Saturday, "QA": time.Saturday, "SD": time.Saturday, "SY": time.Saturday,
+	"MV": time.Friday,
+}
+
+// CalendarMonth is a month laid out in weeks for calendar markup (see calendarMonth)
+type CalendarMonth struct {
+	Year     int
+	Month    time.Month
+	Weekdays []string        // abbreviated day names in the order of the week columns
+	Weeks    [][]CalendarDay // weeks of 7 days, including days of adjacent months
+}
+
+// CalendarDay is a day of a calendar month grid
+type CalendarDay struct {
+	Date    time.Time
+	Day     int          // day of the month
+	Weekday time.Weekday // day of the week
+	InMonth bool         // the day belongs to the month, not to an adjacent one
+	Today   bool         // the day is today according to the engine clock (see WithClock)
+}
+
+// calendarMonth returns a function building the week rows of a month for the context
+// locale: weeks start on the first day of the week of the locale region, e.g. Sunday for
+// "en-US" and Monday for "de", and day names are in the locale language (see
+// WithWeekdayNames). Dates are in the time zone of the format settings (see
+// WithFormatConfig), or the clock's one. The month is a time.Month or a number.
+// Usage:
+//
+//	{{ with calendarMonth 2024 3 }}
+//	<table>
+//	  <tr>{{ range .Weekdays }}<th>{{ . }}</th>{{ end }}</tr>
+//	  {{ range .Weeks }}<tr>{{ range . }}<td{{ if not .InMonth }} class="muted"{{ end }}>{{ .Day }}</td>{{ end }}</tr>{{ end }}
+//	</table>
+//	{{ end }}
+func (e *Engine) calendarMonth(ctx context.Context) func(year int, month any) (CalendarMonth, error) {
+	tag := localeTag(ctx)
+	first := time.Monday
+	if region, _ := tag.Region(); region.String() != "" {
+		if d, ok := firstWeekdays[region.String()]; ok {
+			first = d
+		}
+	}
+	names := e.weekdayNames[""]
+	base, _ := tag.Base()
+	if n, ok := e.weekdayNames[tag.String()]; ok {
+		names = n
+	} else if n, ok := e.weekdayNames[base.String()]; ok {
+		names = n
+	}
+
+	return func(year int, month any) (CalendarMonth, error) {
+		if tm, ok := month.(time.Month); ok {
+			month = int(tm)
+		}
+		m, err := toFloat(month)
+		if err != nil || m < 1 || m > 12 || m != float64(int(m)) {
+			return CalendarMonth{}, fmt.Errorf("calendarMonth: invalid month %v", month)
+		}
+
+		now := e.clock()
+		loc := e.formatConfig.Location
+		if loc == nil {
+			loc = now.Location()
+		}
+		now = now.In(loc)
+		today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc)
+
+		cal := CalendarMonth{Year: year, Month: time.Month(m), Weekdays: make([]string, 7)}
+		for i := range cal.Weekdays {
+			cal.Weekdays[i] = names[(int(first)+i)%7]
+		}
+
+		start := time.Date(year, cal.Month, 1, 0, 0, 0, 0, loc)
+		day := start.AddDate(0, 0, -((int(start.Weekday()) - int(first) + 7) % 7))
+		for week := 0; week == 0 || day.Month() == cal.Month; week++ {
+			days := make([]CalendarDay, 7)
+			for i := range days {
+				days[i] = CalendarDay{
+					Date:    day,
+					Day:     day.Day(),
+					Weekday: day.Weekday(),
+					InMonth: day.Month() == cal.Month,
+					Today:   day.Equal(today),
+				}
+				day = day.AddDate(0, 0, 1)
+			}
+			cal.Weeks = append(cal.Weeks, days)
+		}
+		return cal, nil
+	}
+}
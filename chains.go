@@ -0,0 +1,347 @@
+package templatex
+
+import (
+	"container/list"
+	"fmt"
+	"html/template"
+	"strings"
+	"sync"
+	"text/template/parse"
+)
+
+// layoutDataFunc is the name of the function returning the binding of a template in a
+// precompiled chain: the page binding for level 0, the binding of layout i-1 for level i
+const layoutDataFunc = "__layoutData"
+
+// maxPrecompiledChains is the number of precompiled chains kept, since a chain is
+// built for every page and layouts combination rendered
+const maxPrecompiledChains = 1000
+
+// chainCache stores precompiled chains by page and layouts, evicting the least
+// recently used ones when full. Chains that can't be precompiled are stored as nil,
+// so they aren't checked again.
+type chainCache struct {
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	lru     list.List // most recently used entries first
+}
+
+// chainEntry is a precompiled chain in the chain cache
+type chainEntry struct {
+	key  string
+	root *template.Template
+}
+
+// load returns the chain stored with the key
+func (c *chainCache) load(key string) (*template.Template, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	c.lru.MoveToFront(el)
+	return el.Value.(*chainEntry).root, true
+}
+
+// loadOrStore returns the chain stored with the key, or stores the given one. It also
+// returns the chains evicted to make room, whose clones must be released.
+func (c *chainCache) loadOrStore(key string, root *template.Template) (actual *template.Template, evicted []*template.Template) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.entries[key]; ok {
+		c.lru.MoveToFront(el)
+		return el.Value.(*chainEntry).root, nil
+	}
+	if c.entries == nil {
+		c.entries = make(map[string]*list.Element)
+	}
+	c.entries[key] = c.lru.PushFront(&chainEntry{key: key, root: root})
+	for c.lru.Len() > maxPrecompiledChains {
+		entry := c.lru.Remove(c.lru.Back()).(*chainEntry)
+		delete(c.entries, entry.key)
+		evicted = append(evicted, entry.root)
+	}
+	return root, evicted
+}
+
+// purge removes all chains and returns them
+func (c *chainCache) purge() []*template.Template {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	roots := make([]*template.Template, 0, len(c.entries))
+	for el := c.lru.Front(); el != nil; el = el.Next() {
+		roots = append(roots, el.Value.(*chainEntry).root)
+	}
+	c.entries = nil
+	c.lru.Init()
+	return roots
+}
+
+// releaseChains removes the clone pools of the chains
+func (e *Engine) releaseChains(roots []*template.Template) {
+	for _, root := range roots {
+		if root != nil {
+			e.clones.Delete(root)
+		}
+	}
+}
+
+// precompiledChain returns the template set combining the page and its layouts, with
+// the embed actions of each layout replaced by a call to the template it wraps, so a
+// single execution of the returned outermost layout renders the page. The sets are
+// built on first use and cached until templates are reloaded or the layout cache is
+// purged, up to maxPrecompiledChains. It returns nil if the chain can't be precompiled (see canPrecompile).
+func (e *Engine) precompiledChain(page string, chain *layoutChain) *template.Template {
+	names := make([]string, len(chain.templates))
+	for i, t := range chain.templates {
+		names[i] = t.Name()
+	}
+	key := page + "\x00" + strings.Join(names, "\x00")
+	if cached, ok := e.chains.load(key); ok {
+		return cached
+	}
+
+	generation := e.loadGeneration()
+	root, err := e.buildChain(page, names)
+	if err != nil {
+		e.logger.Debug("templatex: layout chain not precompiled",
+			"template", page, "layouts", names, "error", err)
+	}
+	// Chains built from templates replaced by a reload are used once, but not cached
+	var evicted []*template.Template
+	e.storeIfCurrent(generation, func() {
+		root, evicted = e.chains.loadOrStore(key, root)
+	})
+	e.releaseChains(evicted)
+	return root
+}
+
+// buildChain builds the combined template set of the page and layouts. It returns
+// a nil template and the reason if the chain can't be precompiled.
+func (e *Engine) buildChain(page string, layouts []string) (*template.Template, error) {
+	if err := e.canPrecompile(page, layouts); err != nil {
+		return nil, err
+	}
+
+	// The set only holds the page, the layouts and the templates they include, since
+	// components and sections are executed from the engine templates
+	deps := e.templateDeps(append([]string{page}, layouts...)...)
+	set := template.New("").Option("missingkey=zero").Funcs(e.funcMap)
+	e.mu.RLock()
+	for _, name := range deps {
+		t := e.lookup(name)
+		if t == nil || t.Tree == nil {
+			continue
+		}
+		if _, err := set.AddParseTree(t.Name(), t.Tree.Copy()); err != nil {
+			e.mu.RUnlock()
+			return nil, err
+		}
+	}
+	e.mu.RUnlock()
+	for _, name := range append([]string{page}, layouts...) {
+		if set.Lookup(name) == nil {
+			return nil, fmt.Errorf("template %s not found", name)
+		}
+	}
+
+	var root *template.Template
+	inner := page
+	for i, layout := range layouts {
+		call, err := embedCall(inner, i)
+		if err != nil {
+			return nil, err
+		}
+		tree := set.Lookup(layout).Tree.Copy()
+		n, ok := replaceEmbed(tree.Root, call)
+		if !ok || n == 0 {
+			return nil, fmt.Errorf("layout %s uses embed in a pipeline or doesn't embed the content", layout)
+		}
+		if root, err = set.AddParseTree(layout, tree); err != nil {
+			return nil, err
+		}
+		inner = layout
+	}
+	return root, nil
+}
+
+// canPrecompile reports why the chain can't be precompiled: the render order changes
+// from the page first to the outermost layout first, so templates of the chain must
+// not use render state functions like setTitle and pageTitle, and the rewritten
+// layouts must only be executed by the chain itself.
+func (e *Engine) canPrecompile(page string, layouts []string) error {
+	inChain := make(map[string]bool, len(layouts))
+	for _, layout := range layouts {
+		if inChain[layout] {
+			return fmt.Errorf("layout %s is used twice", layout)
+		}
+		inChain[layout] = true
+	}
+	state := newRenderState().funcs()
+
+	check := func(self string, deps []string) error {
+		e.mu.RLock()
+		defer e.mu.RUnlock()
+		for _, dep := range deps {
+			if dep != self && inChain[dep] {
+				return fmt.Errorf("template %s includes layout %s", self, dep)
+			}
+			t := e.lookup(dep)
+			if t == nil || t.Tree == nil {
+				continue
+			}
+			used := make(map[string]bool)
+			usedFuncs(t.Tree.Root, used)
+			for name := range used {
+				if _, ok := state[name]; ok {
+					return fmt.Errorf("template %s uses %s", dep, name)
+				}
+			}
+			if used["embed"] && (dep != self || !inChain[dep]) {
+				return fmt.Errorf("template %s uses embed", dep)
+			}
+		}
+		return nil
+	}
+
+	if err := check(page, e.templateDeps(page)); err != nil {
+		return err
+	}
+	for _, layout := range layouts {
+		if err := check(layout, e.templateDeps(layout)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// embedCall returns the action executing the inner template with its binding
+func embedCall(inner string, level int) (*parse.TemplateNode, error) {
+	src := fmt.Sprintf("{{ template %q (%s %d) }}", inner, layoutDataFunc, level)
+	trees, err := parse.Parse("embed", src, "{{", "}}", map[string]any{layoutDataFunc: true})
+	if err != nil {
+		return nil, err
+	}
+	return trees["embed"].Root.Nodes[0].(*parse.TemplateNode), nil
+}
+
+// replaceEmbed replaces {{ embed }} actions of the list with copies of the template
+// call and returns their number. It reports false if embed is used in any other way.
+func replaceEmbed(list *parse.ListNode, call *parse.TemplateNode) (int, bool) {
+	if list == nil {
+		return 0, true
+	}
+	count := 0
+	for i, node := range list.Nodes {
+		switch n := node.(type) {
+		case *parse.ActionNode:
+			if isEmbedAction(n) {
+				c := call.Copy().(*parse.TemplateNode)
+				c.Pos, c.Line = n.Pos, n.Line
+				list.Nodes[i] = c
+				count++
+				continue
+			}
+			used := make(map[string]bool)
+			usedFuncs(n, used)
+			if used["embed"] {
+				return 0, false
+			}
+		case *parse.TemplateNode:
+			used := make(map[string]bool)
+			usedFuncs(n, used)
+			if used["embed"] {
+				return 0, false
+			}
+		case *parse.IfNode:
+			c, ok := replaceEmbedBranch(&n.BranchNode, call)
+			if !ok {
+				return 0, false
+			}
+			count += c
+		case *parse.RangeNode:
+			c, ok := replaceEmbedBranch(&n.BranchNode, call)
+			if !ok {
+				return 0, false
+			}
+			count += c
+		case *parse.WithNode:
+			c, ok := replaceEmbedBranch(&n.BranchNode, call)
+			if !ok {
+				return 0, false
+			}
+			count += c
+		}
+	}
+	return count, true
+}
+
+// replaceEmbedBranch replaces {{ embed }} actions in the lists of an if, range or with action
+func replaceEmbedBranch(b *parse.BranchNode, call *parse.TemplateNode) (int, bool) {
+	used := make(map[string]bool)
+	usedFuncs(b.Pipe, used)
+	if used["embed"] {
+		return 0, false
+	}
+	n, ok := replaceEmbed(b.List, call)
+	if !ok {
+		return 0, false
+	}
+	m, ok := replaceEmbed(b.ElseList, call)
+	return n + m, ok
+}
+
+// isEmbedAction reports whether the action only prints the embedded content
+func isEmbedAction(n *parse.ActionNode) bool {
+	p := n.Pipe
+	if p == nil || len(p.Decl) > 0 || len(p.Cmds) != 1 || len(p.Cmds[0].Args) != 1 {
+		return false
+	}
+	id, ok := p.Cmds[0].Args[0].(*parse.IdentifierNode)
+	return ok && id.Ident == "embed"
+}
+
+// usedFuncs adds the names of the functions called by the node to used
+func usedFuncs(node parse.Node, used map[string]bool) {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return
+		}
+		for _, c := range n.Nodes {
+			usedFuncs(c, used)
+		}
+	case *parse.ActionNode:
+		usedFuncs(n.Pipe, used)
+	case *parse.TemplateNode:
+		usedFuncs(n.Pipe, used)
+	case *parse.IfNode:
+		usedFuncs(n.Pipe, used)
+		usedFuncs(n.List, used)
+		usedFuncs(n.ElseList, used)
+	case *parse.RangeNode:
+		usedFuncs(n.Pipe, used)
+		usedFuncs(n.List, used)
+		usedFuncs(n.ElseList, used)
+	case *parse.WithNode:
+		usedFuncs(n.Pipe, used)
+		usedFuncs(n.List, used)
+		usedFuncs(n.ElseList, used)
+	case *parse.PipeNode:
+		if n == nil {
+			return
+		}
+		for _, cmd := range n.Cmds {
+			usedFuncs(cmd, used)
+		}
+	case *parse.CommandNode:
+		for _, arg := range n.Args {
+			usedFuncs(arg, used)
+		}
+	case *parse.IdentifierNode:
+		used[n.Ident] = true
+	case *parse.ChainNode:
+		usedFuncs(n.Node, used)
+	}
+}
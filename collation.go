@@ -0,0 +1,91 @@
+package templatex
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+
+	"github.com/invopop/ctxi18n"
+	"golang.org/x/text/collate"
+	"golang.org/x/text/language"
+)
+
+// localeTag returns the language tag of the context locale, or English if there is none
+func localeTag(ctx context.Context) language.Tag {
+	if l := ctxi18n.Locale(ctx); l != nil {
+		if tag, err := language.Parse(l.Code().String()); err == nil {
+			return tag
+		}
+	}
+	return language.English
+}
+
+// localeCollator returns a function creating the collator for the context locale.
+// The collator is created on first use and reused within the render; collators
+// are not safe for concurrent use, but a render is executed by a single goroutine.
+func localeCollator(ctx context.Context) func() *collate.Collator {
+	var c *collate.Collator
+	return func() *collate.Collator {
+		if c == nil {
+			c = collate.New(localeTag(ctx))
+		}
+		return c
+	}
+}
+
+// compareLocale returns a function comparing two strings using the collation rules
+// of the context locale. The result is -1, 0 or 1.
+// Usage: {{ if lt (compareLocale .A .B) 0 }}...{{ end }}
+func compareLocale(collator func() *collate.Collator) func(a, b string) int {
+	return func(a, b string) int {
+		return collator().CompareString(a, b)
+	}
+}
+
+// sortLocale returns a function sorting a copy of a slice using the collation rules
+// of the context locale, e.g. "Ängel" sorts after "Zoe" in Swedish but before it in
+// German. Slices of structs or maps are sorted by the value at the field path.
+// Usage: {{ range sortLocale .Names }}...{{ end }}, {{ range sortLocale .Users "Name" }}...{{ end }}
+func sortLocale(collator func() *collate.Collator) func(list any, field ...string) (any, error) {
+	return func(list any, field ...string) (any, error) {
+		v := reflect.ValueOf(list)
+		if !v.IsValid() {
+			return list, nil
+		}
+		if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+			return nil, fmt.Errorf("sortLocale: %T is not a slice", list)
+		}
+		path := ""
+		if len(field) > 0 {
+			path = field[0]
+		}
+
+		sorted := reflect.MakeSlice(reflect.SliceOf(v.Type().Elem()), v.Len(), v.Len())
+		reflect.Copy(sorted, v)
+		keys := make([]string, v.Len())
+		for i := range keys {
+			val, _ := lookupPath(sorted.Index(i).Interface(), path)
+			keys[i] = fmt.Sprint(val)
+		}
+
+		c := collator()
+		swap := reflect.Swapper(sorted.Interface())
+		sort.Stable(collationSorter{keys: keys, swap: swap, c: c})
+		return sorted.Interface(), nil
+	}
+}
+
+// collationSorter sorts a slice by its collation keys
+type collationSorter struct {
+	keys []string
+	swap func(i, j int)
+	c    *collate.Collator
+}
+
+func (s collationSorter) Len() int           { return len(s.keys) }
+func (s collationSorter) Less(i, j int) bool { return s.c.CompareString(s.keys[i], s.keys[j]) < 0 }
+func (s collationSorter) Swap(i, j int) {
+	s.keys[i], s.keys[j] = s.keys[j], s.keys[i]
+	s.swap(i, j)
+}
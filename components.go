@@ -0,0 +1,466 @@
+package templatex
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"html/template"
+	"maps"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// slotProp is the component prop holding the content passed between the component
+// action and endcomponent
+const slotProp = "Slot"
+
+// componentName matches the name of the component in component and endcomponent actions
+var componentName = regexp.MustCompile(`^\{\{-?\s*(?:end)?component\s+("(?:[^"\\]|\\.)*")`)
+
+// propTag is the struct tag of props type fields marking required props: `prop:"required"`
+const propTag = "prop"
+
+// builtinComponent is a component rendered by the engine
+type builtinComponent struct {
+	props  reflect.Type // props type, validated before rendering
+	render func(e *Engine, ctx context.Context, props map[string]any) (template.HTML, error)
+}
+
+// builtinComponents are the components rendered by the engine, unless templates with
+// the same names exist
+var builtinComponents = map[string]builtinComponent{
+	"table":  {reflect.TypeFor[Table](), (*Engine).renderTable},
+	"form":   {reflect.TypeFor[Form](), (*Engine).renderForm},
+	"field":  {reflect.TypeFor[Field](), (*Engine).renderField},
+	"upload": {reflect.TypeFor[Upload](), (*Engine).renderUpload},
+}
+
+// ComponentSpec describes a component with a props type (see Engine.Components)
+type ComponentSpec struct {
+	Name    string
+	Builtin bool       // rendered by the engine, unless a template with the same name exists
+	Props   []PropSpec // props in the order of the type fields
+}
+
+// PropSpec describes a prop of a component
+type PropSpec struct {
+	Name     string
+	Type     string // Go type of the prop, e.g. "string" or "[]templatex.TableColumn"
+	Required bool
+}
+
+// RegisterComponent declares the props type of the named component template, so props
+// are validated at render time: unknown props, values of other types and missing props
+// tagged `prop:"required"` are render errors. The component is executed with a value of
+// the props type instead of the props map, so omitted props are zero values:
+//
+//	type Card struct {
+//		Title string `prop:"required"`
+//		Footer string
+//		Slot template.HTML // content between component and endcomponent
+//	}
+//
+//	engine.RegisterComponent("ui.card", Card{})
+//
+// Registering a component again replaces its props type.
+func (e *Engine) RegisterComponent(name string, propsType any) error {
+	if name == "" {
+		return errors.New("register component: empty name")
+	}
+	t := reflect.TypeOf(propsType)
+	if t != nil && t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		return fmt.Errorf("register component %s: props type must be a struct, got %v", name, reflect.TypeOf(propsType))
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.components == nil {
+		e.components = make(map[string]reflect.Type)
+	}
+	e.components[normalizeTemplateName(name, e.exts)] = t
+	return nil
+}
+
+// Components returns the registered components (see RegisterComponent) and the built-in
+// components with their props, sorted by name
+func (e *Engine) Components() []ComponentSpec {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	specs := make([]ComponentSpec, 0, len(e.components)+len(builtinComponents))
+	for name, t := range e.components {
+		specs = append(specs, ComponentSpec{Name: name, Props: propSpecs(t)})
+	}
+	for name, c := range builtinComponents {
+		if _, ok := e.components[name]; !ok {
+			specs = append(specs, ComponentSpec{Name: name, Builtin: true, Props: propSpecs(c.props)})
+		}
+	}
+	sort.Slice(specs, func(i, j int) bool { return specs[i].Name < specs[j].Name })
+	return specs
+}
+
+// propSpecs returns the props of the props type
+func propSpecs(t reflect.Type) []PropSpec {
+	var specs []PropSpec
+	for _, f := range reflect.VisibleFields(t) {
+		if !f.IsExported() || f.Anonymous {
+			continue
+		}
+		specs = append(specs, PropSpec{Name: f.Name, Type: f.Type.String(), Required: f.Tag.Get(propTag) == "required"})
+	}
+	return specs
+}
+
+// validateProps checks the props against the props type and returns a value of the type
+// with the props set
+func validateProps(t reflect.Type, props map[string]any) (any, error) {
+	v := reflect.New(t)
+	if err := decodeProps(props, v.Interface()); err != nil {
+		return nil, err
+	}
+	var missing []string
+	for _, p := range propSpecs(t) {
+		if _, ok := props[p.Name]; p.Required && !ok {
+			missing = append(missing, p.Name)
+		}
+	}
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("missing required props: %s", strings.Join(missing, ", "))
+	}
+	return v.Elem().Interface(), nil
+}
+
+// slotTemplateName returns the name of the template holding the nth slot of the template
+func slotTemplateName(name string, n int) string {
+	return name + sectionSeparator + "slot:" + strconv.Itoa(n)
+}
+
+// props builds the props of a component from key and value pairs.
+// Usage: {{ component "card" (props "Title" .Title "Footer" .Footer) }}
+func props(pairs ...any) (map[string]any, error) {
+	if len(pairs)%2 != 0 {
+		return nil, errors.New("props: odd number of arguments")
+	}
+	m := make(map[string]any, len(pairs)/2)
+	for i := 0; i < len(pairs); i += 2 {
+		key, ok := pairs[i].(string)
+		if !ok {
+			return nil, fmt.Errorf("props: key %v is not a string", pairs[i])
+		}
+		m[key] = pairs[i+1]
+	}
+	return m, nil
+}
+
+// slotFrame is a component action that may be followed by slot content
+type slotFrame struct {
+	action []byte       // the component action
+	name   string       // the component name, if it's a string constant
+	depth  int          // action nesting depth of the component action
+	buf    bytes.Buffer // content following the component action
+}
+
+// extractSlots moves the content between component actions and their endcomponent
+// actions into define blocks named after the template (see slotTemplateName), and
+// rewrites the component actions to render them as the Slot prop:
+//
+//	{{ component "card" (props "Title" .Title) }}<p>{{ .Body }}</p>{{ endcomponent }}
+//
+// Component actions without endcomponent are kept as they are. An endcomponent closes
+// the innermost component action, or the innermost one of the named component, e.g.
+// {{ endcomponent "card" }}, when components without slots are rendered in the slot.
+// The slot content is executed with the dot of the component action.
+func extractSlots(name string, content []byte) (body, slots []byte, err error) {
+	if !bytes.Contains(content, []byte("endcomponent")) {
+		return content, nil, nil
+	}
+
+	var out, defs bytes.Buffer
+	var frames []*slotFrame
+	target := func() *bytes.Buffer {
+		if len(frames) > 0 {
+			return &frames[len(frames)-1].buf
+		}
+		return &out
+	}
+	// keep keeps the innermost component action as it is, as it has no slot
+	keep := func() {
+		f := frames[len(frames)-1]
+		frames = frames[:len(frames)-1]
+		w := target()
+		w.Write(f.action)
+		w.Write(f.buf.Bytes())
+	}
+	// unwind keeps the component actions opened at the depth or deeper,
+	// since they are closed by an enclosing action instead of endcomponent
+	unwind := func(depth int) {
+		for len(frames) > 0 && frames[len(frames)-1].depth >= depth {
+			keep()
+		}
+	}
+
+	depth, n, last := 0, 0, 0
+	for i := 0; ; {
+		start := bytes.Index(content[i:], []byte("{{"))
+		if start < 0 {
+			break
+		}
+		start += i
+		end, err := actionEnd(content, start)
+		if err != nil {
+			return nil, nil, err
+		}
+		action := content[start:end]
+		i = end
+
+		switch keyword := actionKeyword(action); {
+		case keyword == "component":
+			target().Write(content[last:start])
+			frames = append(frames, &slotFrame{action: action, name: actionComponentName(action), depth: depth})
+			last = end
+		case keyword == "endcomponent":
+			text := content[last:start]
+			if bytes.HasPrefix(action, []byte("{{-")) {
+				text = bytes.TrimRight(text, " \t\r\n")
+			}
+			target().Write(text)
+
+			// Components without slots opened after the named one are kept as they are
+			if closing := actionComponentName(action); closing != "" {
+				open := -1
+				for j := len(frames) - 1; j >= 0 && frames[j].depth == depth; j-- {
+					if frames[j].name == closing {
+						open = j
+						break
+					}
+				}
+				if open < 0 {
+					return nil, nil, fmt.Errorf("endcomponent %q without component", closing)
+				}
+				for len(frames) > open+1 {
+					keep()
+				}
+			}
+			if len(frames) == 0 || frames[len(frames)-1].depth != depth {
+				return nil, nil, errors.New("endcomponent without component")
+			}
+			f := frames[len(frames)-1]
+			frames = frames[:len(frames)-1]
+
+			inner := f.buf.Bytes()
+			if bytes.HasSuffix(f.action, []byte("-}}")) {
+				inner = bytes.TrimLeft(inner, " \t\r\n")
+			}
+			n++
+			slot := slotTemplateName(name, n)
+			defs.WriteString(`{{ define ` + strconv.Quote(slot) + ` }}`)
+			defs.Write(inner)
+			defs.WriteString(`{{ end }}`)
+
+			// {{ component "card" ... }} becomes {{ componentSlot "page#slot:1" . "card" ... }},
+			// trimming the text following it like endcomponent
+			at := bytes.Index(f.action, []byte("component"))
+			args := bytes.TrimSuffix(bytes.TrimSuffix(f.action[at+len("component"):], []byte("}}")), []byte("-"))
+			w := target()
+			w.Write(f.action[:at])
+			w.WriteString("componentSlot " + strconv.Quote(slot) + " .")
+			w.Write(args)
+			if bytes.HasSuffix(action, []byte("-}}")) {
+				w.WriteString("-")
+			}
+			w.WriteString("}}")
+
+			last = end
+			if bytes.HasSuffix(action, []byte("-}}")) {
+				last = end + len(content[end:]) - len(bytes.TrimLeft(content[end:], " \t\r\n"))
+			}
+		case blockKeywords[keyword]:
+			depth++
+		case keyword == "else":
+			target().Write(content[last:start])
+			last = start
+			unwind(depth)
+		case keyword == "end":
+			target().Write(content[last:start])
+			last = start
+			unwind(depth)
+			depth--
+		}
+	}
+	target().Write(content[last:])
+	unwind(0)
+	if n == 0 {
+		return content, nil, nil
+	}
+	return out.Bytes(), defs.Bytes(), nil
+}
+
+// actionComponentName returns the component name of a component or endcomponent
+// action, or an empty string if it's not a string constant
+func actionComponentName(action []byte) string {
+	m := componentName.FindSubmatch(action)
+	if m == nil {
+		return ""
+	}
+	name, err := strconv.Unquote(string(m[1]))
+	if err != nil {
+		return ""
+	}
+	return name
+}
+
+// maxComponentDepth limits the nesting of components, e.g. of a component rendering
+// itself. Components are rendered by separate template executions, so the recursion
+// limit of text/template doesn't apply to them.
+const maxComponentDepth = 100
+
+// componentFuncs returns the component functions rendering component templates with
+// their props and the functions bound to the render, or built-in components
+func (e *Engine) componentFuncs(ctx context.Context, funcs template.FuncMap) template.FuncMap {
+	// Templates of a render are executed sequentially, so the depth needs no lock
+	depth := 0
+	nest := func(name string, fn func() (template.HTML, error)) (template.HTML, error) {
+		if depth >= maxComponentDepth {
+			return "", errors.Join(ErrComponentDepthExceeded, fmt.Errorf("component %s: more than %d nested components", name, maxComponentDepth))
+		}
+		depth++
+		defer func() { depth-- }()
+		return fn()
+	}
+
+	render := func(name string, props map[string]any) (template.HTML, error) {
+		if err := e.ensureParsed(name); err != nil {
+			return "", err
+		}
+		e.mu.RLock()
+		t := e.lookup(name)
+		var typ reflect.Type
+		if t != nil {
+			typ = e.components[t.Name()]
+		}
+		e.mu.RUnlock()
+		if t == nil {
+			builtin, ok := builtinComponents[name]
+			if !ok {
+				return "", errors.Join(ErrTemplateNotFound, fmt.Errorf("component: %s", name))
+			}
+			if _, err := validateProps(builtin.props, props); err != nil {
+				return "", fmt.Errorf("%s: %w", name, err)
+			}
+			return builtin.render(e, ctx, props)
+		}
+
+		var binding any = props
+		if typ != nil {
+			v, err := validateProps(typ, props)
+			if err != nil {
+				return "", fmt.Errorf("component %s: %w", name, err)
+			}
+			binding = v
+		} else if props == nil {
+			binding = make(map[string]any)
+		}
+		var buf bytes.Buffer
+		if err := e.executeTemplateWithFuncs(t, &buf, binding, funcs); err != nil {
+			return "", fmt.Errorf("component %s: %w", name, err)
+		}
+		return template.HTML(buf.String()), nil
+	}
+
+	// renderSlot renders the component with the rendered slot template as the Slot prop
+	renderSlot := func(slot string, dot any, name string, props []map[string]any) (template.HTML, error) {
+		e.mu.RLock()
+		t := e.templates.Lookup(slot)
+		e.mu.RUnlock()
+		if t == nil {
+			return "", errors.Join(ErrTemplateNotFound, fmt.Errorf("slot: %s", slot))
+		}
+		var buf bytes.Buffer
+		if err := e.executeTemplateWithFuncs(t, &buf, dot, funcs); err != nil {
+			return "", err
+		}
+
+		// The props of the action are copied, so the slot doesn't leak into other renders
+		p := make(map[string]any)
+		if len(props) == 1 {
+			p = maps.Clone(props[0])
+		}
+		p[slotProp] = template.HTML(buf.String())
+		return render(name, p)
+	}
+
+	return template.FuncMap{
+		// component renders the component template with the props as its binding.
+		// Content between the component action and endcomponent is passed as the Slot prop.
+		// Usage: {{ component "ui.card" (props "Title" .Title) }}...{{ endcomponent }}
+		"component": func(name string, props ...map[string]any) (template.HTML, error) {
+			if len(props) > 1 {
+				return "", errors.New("component: more than one props argument")
+			}
+			var p map[string]any
+			if len(props) == 1 {
+				p = props[0]
+			}
+			return nest(name, func() (template.HTML, error) { return render(name, p) })
+		},
+		// componentSlot renders the slot template with the dot of the component action
+		// and the component with the slot content as the Slot prop (see extractSlots)
+		"componentSlot": func(slot string, dot any, name string, props ...map[string]any) (template.HTML, error) {
+			if len(props) > 1 {
+				return "", errors.New("component: more than one props argument")
+			}
+			return nest(name, func() (template.HTML, error) { return renderSlot(slot, dot, name, props) })
+		},
+	}
+}
+
+// decodeProps sets the fields of the struct dst points to from the props with the
+// field names. It returns an error for props without a field and values that can't
+// be assigned to their field.
+func decodeProps(props map[string]any, dst any) error {
+	v := reflect.ValueOf(dst).Elem()
+	for key, value := range props {
+		f, ok := v.Type().FieldByName(key)
+		if !ok || !f.IsExported() {
+			return fmt.Errorf("unknown prop %s", key)
+		}
+		field, err := v.FieldByIndexErr(f.Index)
+		if err != nil {
+			return fmt.Errorf("prop %s: %w", key, err)
+		}
+		if value == nil {
+			field.SetZero()
+			continue
+		}
+		val := reflect.ValueOf(value)
+		switch {
+		case val.Type().AssignableTo(field.Type()):
+			field.Set(val)
+		case val.Type().ConvertibleTo(field.Type()) && val.Kind() == field.Kind():
+			field.Set(val.Convert(field.Type()))
+		case isNumber(val.Kind()) && isNumber(field.Kind()) && val.CanConvert(field.Type()):
+			field.Set(val.Convert(field.Type()))
+		default:
+			return fmt.Errorf("prop %s: %s is not assignable to %s", key, val.Type(), field.Type())
+		}
+	}
+	return nil
+}
+
+// isNumber reports whether the kind is an integer or floating point kind
+func isNumber(k reflect.Kind) bool {
+	switch k {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,132 @@
+package templatex
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// EncodeFunc compresses rendered content for a content encoding (see WithCacheEncoding)
+type EncodeFunc func(content []byte) ([]byte, error)
+
+// contentEncoding is a content encoding supported by RenderCompressed
+type contentEncoding struct {
+	name   string
+	encode EncodeFunc
+}
+
+// GzipEncode compresses the content with gzip at the best compression level,
+// which pays off since cached content is compressed only once
+func GzipEncode(content []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	zw, err := gzip.NewWriterLevel(&buf, gzip.BestCompression)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := zw.Write(content); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// RenderCompressed renders the template like Render and writes it to w in the content
+// encoding preferred by the Accept-Encoding header value among the encodings set with
+// WithCacheEncoding, setting the Content-Encoding header. Encoded variants of cached
+// content are cached with it, so identical pages aren't compressed on every request.
+// Content is written unencoded if no encoding is acceptable.
+//
+// Headers are set before the body is written, so set the status code, if any, after
+// RenderCompressed has chosen the encoding; the Content-Type header is left to the caller.
+// Returns ErrEncodingFailed if the content can't be encoded.
+func (e *Engine) RenderCompressed(ctx context.Context, w http.ResponseWriter, acceptEncoding, name string, binding any, layouts ...string) error {
+	if !e.initialized() {
+		return ErrTemplateEngineNotInitialized
+	}
+
+	bindings := make([]LayoutBinding, len(layouts))
+	for i, layout := range layouts {
+		bindings[i] = LayoutBinding{Name: layout}
+	}
+	r, err := e.renderContent(ctx, name, binding, bindings)
+	if err != nil {
+		return err
+	}
+
+	if len(e.encodings) > 0 {
+		w.Header().Add("Vary", "Accept-Encoding")
+	}
+	enc, ok := e.negotiateEncoding(acceptEncoding)
+	if !ok {
+		return e.writeOutput(ctx, w, name, r.content, r.content)
+	}
+
+	encode := func(content string) (string, error) {
+		b, err := enc.encode([]byte(content))
+		return string(b), err
+	}
+	var body string
+	cached := false
+	if r.cacheKey != "" {
+		body, cached, err = e.cache.variant(r.cacheKey, enc.name, encode)
+	}
+	if !cached && err == nil {
+		body, err = encode(r.content)
+	}
+	if err != nil {
+		return errors.Join(ErrEncodingFailed, fmt.Errorf("encoding: %s", enc.name), err)
+	}
+
+	w.Header().Set("Content-Encoding", enc.name)
+	w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+	return e.writeOutput(ctx, w, name, r.content, body)
+}
+
+// negotiateEncoding returns the supported encoding with the highest quality in the
+// Accept-Encoding header value. Encodings with equal quality are preferred in the
+// order they were configured.
+func (e *Engine) negotiateEncoding(acceptEncoding string) (contentEncoding, bool) {
+	if len(e.encodings) == 0 || acceptEncoding == "" {
+		return contentEncoding{}, false
+	}
+
+	accepted := make(map[string]float64)
+	wildcard := -1.0
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		name, params, _ := strings.Cut(part, ";")
+		name = strings.ToLower(strings.TrimSpace(name))
+		q := 1.0
+		for _, param := range strings.Split(params, ";") {
+			if k, v, ok := strings.Cut(strings.TrimSpace(param), "="); ok && strings.TrimSpace(k) == "q" {
+				if f, err := strconv.ParseFloat(strings.TrimSpace(v), 64); err == nil {
+					q = f
+				}
+			}
+		}
+		if name == "*" {
+			wildcard = q
+			continue
+		}
+		accepted[name] = q
+	}
+
+	var best contentEncoding
+	bestQ := 0.0
+	for _, enc := range e.encodings {
+		q, ok := accepted[enc.name]
+		if !ok {
+			q = wildcard
+		}
+		if q > bestQ {
+			best, bestQ = enc, q
+		}
+	}
+	return best, bestQ > 0
+}
@@ -0,0 +1,88 @@
+package templatex
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// ConsentResolver reports whether the visitor consented to the category,
+// e.g. "analytics" or "marketing"
+type ConsentResolver func(ctx context.Context, category string) bool
+
+// errConsentCategoryAdded is returned by renders that checked a consent category
+// unknown when their cache key was generated. Their output is neither cached nor
+// shared with concurrent renders, since the key doesn't reflect that consent.
+var errConsentCategoryAdded = errors.New("consent category added during render")
+
+// consentCategories is the set of consent categories checked by templates so far.
+// The generation is incremented whenever a category is added.
+type consentCategories struct {
+	mu    sync.RWMutex
+	names []string // sorted
+	gen   uint64
+}
+
+// add adds the category to the set
+func (c *consentCategories) add(category string) {
+	c.mu.RLock()
+	_, found := slices.BinarySearch(c.names, category)
+	c.mu.RUnlock()
+	if found {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	i, found := slices.BinarySearch(c.names, category)
+	if !found {
+		c.names = slices.Insert(c.names, i, category)
+		c.gen++
+	}
+}
+
+// snapshot returns the categories and the current generation
+func (c *consentCategories) snapshot() ([]string, uint64) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.names, c.gen
+}
+
+// generation returns the current generation
+func (c *consentCategories) generation() uint64 {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.gen
+}
+
+// consent returns a function reporting whether the visitor consented to the category.
+// Consent is denied if no resolver is set. Checked categories are recorded, so
+// renders are cached separately per set of granted categories.
+// Usage: {{ if consent "analytics" }}<script src="/js/analytics.js"></script>{{ end }}
+func (e *Engine) consent(ctx context.Context) func(category string) bool {
+	return func(category string) bool {
+		if e.consentResolver == nil {
+			return false
+		}
+		e.consentCategories.add(category)
+		return e.consentResolver(ctx, category)
+	}
+}
+
+// consentState returns the checked consent categories granted by the render context,
+// which is added to cache keys, and the generation of the checked categories
+func (e *Engine) consentState(ctx context.Context) (string, uint64) {
+	if e.consentResolver == nil {
+		return "", 0
+	}
+	categories, gen := e.consentCategories.snapshot()
+	granted := make([]string, 0, len(categories))
+	for _, category := range categories {
+		if e.consentResolver(ctx, category) {
+			granted = append(granted, category)
+		}
+	}
+	return "|consent:" + strings.Join(granted, ","), gen
+}
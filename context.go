@@ -0,0 +1,175 @@
+package templatex
+
+import "context"
+
+// contextKey is a private type for context keys defined in this package
+// to avoid collisions with keys defined in other packages.
+type contextKey struct{ name string }
+
+var (
+	requestPathKey = &contextKey{"request_path"}
+	requestIDKey   = &contextKey{"request_id"}
+	correlationKey = &contextKey{"correlation_id"}
+	renderModeKey  = &contextKey{"render_mode"}
+	liteKey        = &contextKey{"lite"}
+	skipCacheKey   = &contextKey{"skip_cache"}
+	skipMinifyKey  = &contextKey{"skip_minify"}
+	unitSystemKey  = &contextKey{"unit_system"}
+	baseURLKey     = &contextKey{"base_url"}
+)
+
+// WithRequestPath returns a copy of ctx that carries the current request path.
+// The path is used by navigation helpers such as isActive and activeClass.
+func WithRequestPath(ctx context.Context, path string) context.Context {
+	return context.WithValue(ctx, requestPathKey, path)
+}
+
+// RequestPath returns the request path stored in ctx by WithRequestPath.
+// It returns an empty string if no path is set.
+func RequestPath(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	if p, ok := ctx.Value(requestPathKey).(string); ok {
+		return p
+	}
+	return ""
+}
+
+// WithRequestID returns a copy of ctx that carries the request ID.
+// The ID is used to key archived renders (see WithArchive).
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey, id)
+}
+
+// RequestID returns the request ID stored in ctx by WithRequestID.
+// It returns an empty string if no ID is set.
+func RequestID(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	if id, ok := ctx.Value(requestIDKey).(string); ok {
+		return id
+	}
+	return ""
+}
+
+// WithCorrelationID returns a copy of ctx that carries the client-supplied ID of the
+// request, e.g. from the X-Request-ID header, used to correlate archived renders with
+// logs of other services. Unlike the request ID, it's not trusted to key archives.
+func WithCorrelationID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, correlationKey, id)
+}
+
+// CorrelationID returns the correlation ID stored in ctx by WithCorrelationID.
+// It returns an empty string if no ID is set.
+func CorrelationID(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	if id, ok := ctx.Value(correlationKey).(string); ok {
+		return id
+	}
+	return ""
+}
+
+// SkipCache returns a copy of ctx that bypasses the render cache: renders with this
+// context neither read cached content nor store their output, even with hard caching
+// enabled. Use it for preview screens and "render fresh" admin actions.
+func SkipCache(ctx context.Context) context.Context {
+	return context.WithValue(ctx, skipCacheKey, true)
+}
+
+// CacheSkipped reports whether ctx bypasses the render cache (see SkipCache)
+func CacheSkipped(ctx context.Context) bool {
+	if ctx == nil {
+		return false
+	}
+	skip, _ := ctx.Value(skipCacheKey).(bool)
+	return skip
+}
+
+// SkipMinify returns a copy of ctx for which rendered output is not minified
+// (see WithMinifyOutput), e.g. for pages with whitespace-sensitive content outside
+// of pre elements.
+func SkipMinify(ctx context.Context) context.Context {
+	return context.WithValue(ctx, skipMinifyKey, true)
+}
+
+// MinifySkipped reports whether ctx disables output minification (see SkipMinify)
+func MinifySkipped(ctx context.Context) bool {
+	if ctx == nil {
+		return false
+	}
+	skip, _ := ctx.Value(skipMinifyKey).(bool)
+	return skip
+}
+
+// WithUnitSystem returns a copy of ctx that carries the user's preferred unit system,
+// which takes precedence over the locale region in formatUnit
+func WithUnitSystem(ctx context.Context, system UnitSystem) context.Context {
+	return context.WithValue(ctx, unitSystemKey, system)
+}
+
+// UnitSystemFromContext returns the unit system stored in ctx by WithUnitSystem.
+// It returns an empty string if no unit system is set.
+func UnitSystemFromContext(ctx context.Context) UnitSystem {
+	if ctx == nil {
+		return ""
+	}
+	s, _ := ctx.Value(unitSystemKey).(UnitSystem)
+	return s
+}
+
+// WithRequestBaseURL returns a copy of ctx that carries the scheme and host of the
+// request, e.g. "https://example.com", used by absURL and canonical if no base URL is
+// set with WithBaseURL. Middleware sets it from the request.
+func WithRequestBaseURL(ctx context.Context, baseURL string) context.Context {
+	return context.WithValue(ctx, baseURLKey, baseURL)
+}
+
+// RequestBaseURL returns the base URL stored in ctx by WithRequestBaseURL.
+// It returns an empty string if no base URL is set.
+func RequestBaseURL(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	u, _ := ctx.Value(baseURLKey).(string)
+	return u
+}
+
+// withRenderMode returns a copy of ctx that carries the render mode, e.g. print
+func withRenderMode(ctx context.Context, mode string) context.Context {
+	return context.WithValue(ctx, renderModeKey, mode)
+}
+
+// renderMode returns the render mode stored in ctx or an empty string for regular renders
+func renderMode(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	if m, ok := ctx.Value(renderModeKey).(string); ok {
+		return m
+	}
+	return ""
+}
+
+// requestScope returns a string representation of the request-scoped values
+// stored in ctx that affect the rendered output. It's used as a part of the cache key.
+func requestScope(ctx context.Context) string {
+	scope := RequestPath(ctx) + "|" + Breadcrumbs(ctx).String() + "|" + renderMode(ctx) + "|" + string(UnitSystemFromContext(ctx)) +
+		"|" + RequestBaseURL(ctx)
+	if w, ok := WizardFromContext(ctx); ok {
+		scope += "|wizard:" + w.String()
+	}
+	if s, ok := FormStateFromContext(ctx); ok {
+		scope += "|form:" + s.String()
+	}
+	if token := CSRFToken(ctx); token != "" {
+		scope += "|csrf:" + token
+	}
+	if MinifySkipped(ctx) {
+		scope += "|raw"
+	}
+	return scope
+}
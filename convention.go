@@ -0,0 +1,31 @@
+package templatex
+
+import "path"
+
+// conventionLayoutName is the name of layout templates picked up by the templates
+// in their directory and its subdirectories (see WithConventionLayouts)
+const conventionLayoutName = "_layout"
+
+// conventionLayouts returns the _layout templates of the template directory and its
+// parent directories, innermost first, e.g. "pages/admin/_layout", "pages/_layout"
+// and "_layout" for "pages/admin/users". A layout doesn't wrap itself.
+func (e *Engine) conventionLayouts(name string) []string {
+	name = normalizeTemplateName(name, e.exts)
+
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	if e.templates == nil {
+		return nil
+	}
+
+	var layouts []string
+	for dir := path.Dir(name); ; dir = path.Dir(dir) {
+		layout := path.Join(dir, conventionLayoutName)
+		if layout != name && e.known(layout) {
+			layouts = append(layouts, layout)
+		}
+		if dir == "." || dir == "/" {
+			return layouts
+		}
+	}
+}
@@ -0,0 +1,482 @@
+package templatex
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// cssDecl is a CSS declaration, e.g. "color: red !important"
+type cssDecl struct {
+	prop      string
+	value     string
+	important bool
+}
+
+// cssRule is a style rule with a single selector that can be inlined
+type cssRule struct {
+	selector    []cssCompound // compound selectors, the subject last
+	specificity [3]int        // ids, classes and attributes, types
+	order       int           // position in the style sheets
+	decls       []cssDecl
+}
+
+// cssCompound is a compound selector, e.g. "p.note[lang]", with the combinator
+// joining it to the previous one: ' ' for descendants, '>' for children
+type cssCompound struct {
+	combinator byte
+	tag        string
+	id         string
+	classes    []string
+	attrs      []cssAttr
+}
+
+// cssAttr is an attribute selector; op is 0 for presence and '=' for equality
+type cssAttr struct {
+	name  string
+	op    byte
+	value string
+}
+
+// unstyledElements are elements that don't get inlined styles
+var unstyledElements = map[string]bool{
+	"html": true, "head": true, "title": true, "meta": true, "link": true, "base": true,
+	"style": true, "script": true,
+}
+
+// inlineCSS moves the rules of the style elements of the content into the style
+// attributes of the elements they match, premailer-style, since many email clients
+// ignore style elements. Inline declarations win over inlined ones unless those are
+// !important. At-rules like @media and rules that can't be inlined, e.g. with pseudo-
+// classes or sibling combinators, are kept in the style element, which is removed
+// if nothing is left. Style elements for media other than screen are left alone.
+//
+// Supported selectors are type, universal, class, id and attribute selectors
+// (presence and equality), combined with descendant and child combinators.
+func inlineCSS(content string) (string, error) {
+	if !strings.Contains(content, "<style") {
+		return content, nil
+	}
+
+	// Fragments are parsed in a body, so no document elements are added
+	document := strings.Contains(strings.ToLower(content), "<html")
+	var nodes []*html.Node
+	if document {
+		doc, err := html.Parse(strings.NewReader(content))
+		if err != nil {
+			return "", err
+		}
+		nodes = []*html.Node{doc}
+	} else {
+		body := &html.Node{Type: html.ElementNode, Data: "body", DataAtom: atom.Body}
+		var err error
+		if nodes, err = html.ParseFragment(strings.NewReader(content), body); err != nil {
+			return "", err
+		}
+		for _, n := range nodes {
+			body.AppendChild(n)
+		}
+		nodes = []*html.Node{body}
+	}
+
+	// Collect the rules and strip them from the style elements
+	var rules []cssRule
+	var styles []*html.Node
+	for _, root := range nodes {
+		walkElements(root, func(n *html.Node) {
+			if n.Data == "style" && inlinableMedia(n) {
+				styles = append(styles, n)
+			}
+		})
+	}
+	for _, style := range styles {
+		var css strings.Builder
+		for c := style.FirstChild; c != nil; c = c.NextSibling {
+			css.WriteString(c.Data)
+		}
+		inlined, kept := parseCSS(css.String(), len(rules))
+		rules = append(rules, inlined...)
+		for style.FirstChild != nil {
+			style.RemoveChild(style.FirstChild)
+		}
+		if strings.TrimSpace(kept) == "" {
+			style.Parent.RemoveChild(style)
+			continue
+		}
+		style.AppendChild(&html.Node{Type: html.TextNode, Data: kept})
+	}
+
+	// Apply the rules by specificity and source order
+	sort.SliceStable(rules, func(i, j int) bool {
+		a, b := rules[i].specificity, rules[j].specificity
+		if a != b {
+			return a[0] < b[0] || (a[0] == b[0] && (a[1] < b[1] || (a[1] == b[1] && a[2] < b[2])))
+		}
+		return rules[i].order < rules[j].order
+	})
+	for _, root := range nodes {
+		walkElements(root, func(n *html.Node) {
+			if !unstyledElements[n.Data] && (n.Parent == nil || n.Parent.Data != "head") {
+				applyRules(n, rules)
+			}
+		})
+	}
+
+	var buf bytes.Buffer
+	if document {
+		if err := html.Render(&buf, nodes[0]); err != nil {
+			return "", err
+		}
+		return buf.String(), nil
+	}
+	for c := nodes[0].FirstChild; c != nil; c = c.NextSibling {
+		if err := html.Render(&buf, c); err != nil {
+			return "", err
+		}
+	}
+	return buf.String(), nil
+}
+
+// inlinableMedia reports whether the rules of the style element apply to screens
+func inlinableMedia(style *html.Node) bool {
+	for _, a := range style.Attr {
+		if a.Key == "media" {
+			media := strings.ToLower(strings.TrimSpace(a.Val))
+			return media == "" || media == "all" || media == "screen"
+		}
+	}
+	return true
+}
+
+// walkElements calls fn for the element nodes of the tree, children before their
+// parents. fn may remove the node it's called with.
+func walkElements(n *html.Node, fn func(*html.Node)) {
+	for c := n.FirstChild; c != nil; {
+		next := c.NextSibling
+		if c.Type == html.ElementNode {
+			walkElements(c, fn)
+			fn(c)
+		} else {
+			walkElements(c, fn)
+		}
+		c = next
+	}
+}
+
+// applyRules sets the style attribute of the element to the declarations of the
+// matching rules, which are sorted by specificity, merged with its own declarations
+func applyRules(n *html.Node, rules []cssRule) {
+	var matched []cssRule
+	for _, r := range rules {
+		if matchSelector(n, r.selector, len(r.selector)-1) {
+			matched = append(matched, r)
+		}
+	}
+	if len(matched) == 0 {
+		return
+	}
+
+	styleAt := -1
+	for i, a := range n.Attr {
+		if a.Key == "style" {
+			styleAt = i
+		}
+	}
+	var own []cssDecl
+	if styleAt >= 0 {
+		own = parseDecls(n.Attr[styleAt].Val)
+	}
+
+	var props []string
+	values := make(map[string]string)
+	set := func(d cssDecl) {
+		if _, ok := values[d.prop]; !ok {
+			props = append(props, d.prop)
+		}
+		values[d.prop] = d.value
+	}
+	for _, r := range matched {
+		for _, d := range r.decls {
+			if !d.important {
+				set(d)
+			}
+		}
+	}
+	for _, d := range own {
+		set(d)
+	}
+	for _, r := range matched {
+		for _, d := range r.decls {
+			if d.important {
+				set(d)
+			}
+		}
+	}
+
+	decls := make([]string, len(props))
+	for i, p := range props {
+		decls[i] = p + ": " + values[p]
+	}
+	style := strings.Join(decls, "; ")
+	if styleAt >= 0 {
+		n.Attr[styleAt].Val = style
+		return
+	}
+	n.Attr = append(n.Attr, html.Attribute{Key: "style", Val: style})
+}
+
+// matchSelector reports whether the element matches the compound selectors up to i,
+// with the compound at i matching the element itself
+func matchSelector(n *html.Node, sel []cssCompound, i int) bool {
+	if !sel[i].matches(n) {
+		return false
+	}
+	if i == 0 {
+		return true
+	}
+	for p := n.Parent; p != nil && p.Type == html.ElementNode; p = p.Parent {
+		if matchSelector(p, sel, i-1) {
+			return true
+		}
+		if sel[i].combinator == '>' {
+			return false
+		}
+	}
+	return false
+}
+
+// matches reports whether the element matches the compound selector
+func (c cssCompound) matches(n *html.Node) bool {
+	if c.tag != "" && c.tag != "*" && c.tag != n.Data {
+		return false
+	}
+	if c.id != "" && nodeAttr(n, "id") != c.id {
+		return false
+	}
+	if len(c.classes) > 0 {
+		classes := strings.Fields(nodeAttr(n, "class"))
+		for _, want := range c.classes {
+			found := false
+			for _, class := range classes {
+				if class == want {
+					found = true
+					break
+				}
+			}
+			if !found {
+				return false
+			}
+		}
+	}
+	for _, a := range c.attrs {
+		found := false
+		for _, attr := range n.Attr {
+			if attr.Key == a.name && (a.op == 0 || attr.Val == a.value) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// nodeAttr returns the value of the attribute of the element
+func nodeAttr(n *html.Node, key string) string {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+// parseCSS returns the inlinable rules of the style sheet, numbered from order, and
+// the CSS that can't be inlined: at-rules and rules with unsupported selectors
+func parseCSS(css string, order int) ([]cssRule, string) {
+	css = stripCSSComments(css)
+
+	var rules []cssRule
+	var kept strings.Builder
+	for i := 0; i < len(css); {
+		open := strings.IndexByte(css[i:], '{')
+		if open < 0 {
+			break
+		}
+		prelude := strings.TrimSpace(css[i : i+open])
+		end := matchingBrace(css, i+open)
+		block := css[i+open+1 : end]
+		next := min(end+1, len(css))
+
+		if strings.HasPrefix(prelude, "@") {
+			kept.WriteString(prelude + " {" + block + "}\n")
+			i = next
+			continue
+		}
+
+		decls := parseDecls(block)
+		for _, s := range strings.Split(prelude, ",") {
+			s = strings.TrimSpace(s)
+			sel, spec, err := parseSelector(s)
+			if err != nil {
+				kept.WriteString(s + " {" + block + "}\n")
+				continue
+			}
+			rules = append(rules, cssRule{selector: sel, specificity: spec, order: order, decls: decls})
+			order++
+		}
+		i = next
+	}
+	return rules, kept.String()
+}
+
+// stripCSSComments removes /* */ comments from the style sheet
+func stripCSSComments(css string) string {
+	for {
+		start := strings.Index(css, "/*")
+		if start < 0 {
+			return css
+		}
+		end := strings.Index(css[start+2:], "*/")
+		if end < 0 {
+			return css[:start]
+		}
+		css = css[:start] + css[start+2+end+2:]
+	}
+}
+
+// matchingBrace returns the index of the brace closing the one at open,
+// or the end of the style sheet if it isn't closed
+func matchingBrace(css string, open int) int {
+	depth := 0
+	for i := open; i < len(css); i++ {
+		switch css[i] {
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return len(css)
+}
+
+// parseDecls parses the declarations of a rule or style attribute
+func parseDecls(block string) []cssDecl {
+	var decls []cssDecl
+	for _, part := range strings.Split(block, ";") {
+		prop, value, ok := strings.Cut(part, ":")
+		prop = strings.ToLower(strings.TrimSpace(prop))
+		value = strings.TrimSpace(value)
+		if !ok || prop == "" || value == "" {
+			continue
+		}
+		d := cssDecl{prop: prop, value: value}
+		if v, ok := strings.CutSuffix(value, "!important"); ok {
+			d.value, d.important = strings.TrimSpace(v), true
+		}
+		decls = append(decls, d)
+	}
+	return decls
+}
+
+// parseSelector parses a selector into compound selectors and computes its
+// specificity. Returns an error for unsupported selectors.
+func parseSelector(s string) ([]cssCompound, [3]int, error) {
+	var (
+		sel  []cssCompound
+		spec [3]int
+		cur  cssCompound
+		comb byte = ' '
+	)
+	empty := func() bool {
+		return cur.tag == "" && cur.id == "" && len(cur.classes) == 0 && len(cur.attrs) == 0
+	}
+	flush := func() {
+		if !empty() {
+			cur.combinator = comb
+			sel = append(sel, cur)
+			cur, comb = cssCompound{}, ' '
+		}
+	}
+	ident := func(i int) (string, int) {
+		j := i
+		for j < len(s) && isIdentChar(s[j]) {
+			j++
+		}
+		return s[i:j], j
+	}
+
+	for i := 0; i < len(s); {
+		switch c := s[i]; {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			flush()
+			i++
+		case c == '>':
+			if empty() && len(sel) == 0 {
+				return nil, spec, fmt.Errorf("selector %q starts with a combinator", s)
+			}
+			flush()
+			comb = '>'
+			i++
+		case c == '*':
+			cur.tag = "*"
+			i++
+		case c == '.' || c == '#':
+			name, j := ident(i + 1)
+			if name == "" {
+				return nil, spec, fmt.Errorf("invalid selector %q", s)
+			}
+			if c == '.' {
+				cur.classes = append(cur.classes, name)
+				spec[1]++
+			} else {
+				cur.id = name
+				spec[0]++
+			}
+			i = j
+		case c == '[':
+			end := strings.IndexByte(s[i:], ']')
+			if end < 0 {
+				return nil, spec, fmt.Errorf("invalid selector %q", s)
+			}
+			name, value, ok := strings.Cut(s[i+1:i+end], "=")
+			attr := cssAttr{name: strings.ToLower(strings.TrimSpace(name))}
+			if ok {
+				if strings.ContainsAny(attr.name, "~|^$*") {
+					return nil, spec, fmt.Errorf("unsupported attribute selector in %q", s)
+				}
+				attr.op, attr.value = '=', strings.Trim(strings.TrimSpace(value), `"'`)
+			}
+			cur.attrs = append(cur.attrs, attr)
+			spec[1]++
+			i += end + 1
+		case isIdentChar(c):
+			name, j := ident(i)
+			cur.tag = strings.ToLower(name)
+			spec[2]++
+			i = j
+		default:
+			// Pseudo-classes, pseudo-elements and sibling combinators can't be inlined
+			return nil, spec, fmt.Errorf("unsupported selector %q", s)
+		}
+	}
+	flush()
+	if len(sel) == 0 || comb == '>' {
+		return nil, spec, fmt.Errorf("invalid selector %q", s)
+	}
+	return sel, spec, nil
+}
+
+// isIdentChar reports whether the byte can be part of a CSS identifier
+func isIdentChar(c byte) bool {
+	return c == '-' || c == '_' || c >= 0x80 || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')
+}
@@ -0,0 +1,249 @@
+package templatex
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// defaultDebugDepth is the max depth used by debug functions if none is provided
+	defaultDebugDepth = 10
+
+	// debugRedacted replaces values of redacted fields
+	debugRedacted = "[REDACTED]"
+
+	// debugTruncated replaces values nested deeper than the max depth
+	debugTruncated = "..."
+)
+
+// debugField is a single key-value pair of a debugObject
+type debugField struct {
+	Key   string
+	Value any
+}
+
+// debugObject is an ordered representation of a struct or map
+type debugObject []debugField
+
+// MarshalJSON encodes the object preserving the order of its fields
+func (o debugObject) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, f := range o {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		key, err := json.Marshal(f.Key)
+		if err != nil {
+			return nil, err
+		}
+		val, err := json.Marshal(f.Value)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(key)
+		buf.WriteByte(':')
+		buf.Write(val)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// debugDumper converts values to a printable tree, limiting the depth
+// and redacting the configured fields
+type debugDumper struct {
+	redact map[string]struct{}
+}
+
+// newDebugDumper creates a dumper redacting the given field names (case-insensitive)
+func newDebugDumper(fields []string) *debugDumper {
+	d := &debugDumper{redact: make(map[string]struct{}, len(fields))}
+	for _, f := range fields {
+		d.redact[strings.ToLower(f)] = struct{}{}
+	}
+	return d
+}
+
+// isRedacted reports whether the field with the given name must be redacted
+func (d *debugDumper) isRedacted(name string) bool {
+	_, ok := d.redact[strings.ToLower(name)]
+	return ok
+}
+
+// funcs returns the debug template functions bound to the dumper
+func (d *debugDumper) funcs() template.FuncMap {
+	return template.FuncMap{
+		"debug":     d.debugJSON,
+		"debugHTML": d.debugHTML,
+	}
+}
+
+// debugJSON returns a pretty-printed JSON representation of the value.
+// The optional max depth limits how deep nested values are printed.
+// Usage: {{ debug . }} or {{ debug . 2 }}
+func (d *debugDumper) debugJSON(v any, maxDepth ...int) string {
+	tree := d.dump(reflect.ValueOf(v), 0, debugDepth(maxDepth))
+	b, err := json.MarshalIndent(tree, "", "  ")
+	if err != nil {
+		return fmt.Sprintf("%+v", tree)
+	}
+	return string(b)
+}
+
+// debugHTML returns a collapsible HTML tree representation of the value.
+// The optional max depth limits how deep nested values are printed.
+// Usage: {{ debugHTML . }} or {{ debugHTML . 2 }}
+func (d *debugDumper) debugHTML(v any, maxDepth ...int) template.HTML {
+	var sb strings.Builder
+	sb.WriteString(`<div class="templatex-debug">`)
+	writeDebugHTML(&sb, d.dump(reflect.ValueOf(v), 0, debugDepth(maxDepth)))
+	sb.WriteString(`</div>`)
+	return template.HTML(sb.String())
+}
+
+// debugDepth returns the first positive depth or the default one
+func debugDepth(maxDepth []int) int {
+	if len(maxDepth) > 0 && maxDepth[0] > 0 {
+		return maxDepth[0]
+	}
+	return defaultDebugDepth
+}
+
+// dump converts a value to a tree of debugObject, []any and scalar values
+func (d *debugDumper) dump(v reflect.Value, depth, maxDepth int) any {
+	// Methods may be declared on the pointer or the value
+	if val, ok := d.dumpOwn(v, depth, maxDepth); ok {
+		return val
+	}
+	v = indirect(v)
+	if !v.IsValid() {
+		return nil
+	}
+	if val, ok := d.dumpOwn(v, depth, maxDepth); ok {
+		return val
+	}
+
+	switch v.Kind() {
+	case reflect.Struct, reflect.Map, reflect.Slice, reflect.Array:
+		if depth >= maxDepth {
+			return debugTruncated
+		}
+	}
+
+	switch v.Kind() {
+	case reflect.Struct:
+		t := v.Type()
+		obj := make(debugObject, 0, v.NumField())
+		for i := 0; i < v.NumField(); i++ {
+			field := t.Field(i)
+			if !field.IsExported() {
+				continue
+			}
+			var val any = debugRedacted
+			if !d.isRedacted(field.Name) {
+				val = d.dump(v.Field(i), depth+1, maxDepth)
+			}
+			obj = append(obj, debugField{Key: field.Name, Value: val})
+		}
+		return obj
+	case reflect.Map:
+		keys := v.MapKeys()
+		obj := make(debugObject, 0, len(keys))
+		for _, k := range keys {
+			name := fmt.Sprint(k.Interface())
+			var val any = debugRedacted
+			if !d.isRedacted(name) {
+				val = d.dump(v.MapIndex(k), depth+1, maxDepth)
+			}
+			obj = append(obj, debugField{Key: name, Value: val})
+		}
+		sort.Slice(obj, func(i, j int) bool { return obj[i].Key < obj[j].Key })
+		return obj
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.Uint8 {
+			return string(v.Bytes())
+		}
+		list := make([]any, v.Len())
+		for i := range list {
+			list[i] = d.dump(v.Index(i), depth+1, maxDepth)
+		}
+		return list
+	case reflect.Func, reflect.Chan, reflect.UnsafePointer:
+		return v.Type().String()
+	}
+
+	if !v.CanInterface() {
+		return nil
+	}
+	return v.Interface()
+}
+
+// dumpOwn returns the representation of values that describe themselves instead of
+// walking their fields, which are often unexported or internal: times are formatted,
+// JSON marshalers are dumped as their JSON, so field names are still redacted, and
+// stringers as their strings. It reports whether the value has such a representation.
+func (d *debugDumper) dumpOwn(v reflect.Value, depth, maxDepth int) (any, bool) {
+	if !v.IsValid() || !v.CanInterface() {
+		return nil, false
+	}
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Interface, reflect.Map, reflect.Slice:
+		if v.IsNil() {
+			return nil, false
+		}
+	}
+
+	switch val := v.Interface().(type) {
+	case time.Time:
+		return val.Format(time.RFC3339Nano), true
+	case json.Marshaler:
+		b, err := val.MarshalJSON()
+		if err != nil {
+			return nil, false
+		}
+		var decoded any
+		if err := json.Unmarshal(b, &decoded); err != nil {
+			return nil, false
+		}
+		return d.dump(reflect.ValueOf(decoded), depth, maxDepth), true
+	case fmt.Stringer:
+		return val.String(), true
+	}
+	return nil, false
+}
+
+// writeDebugHTML writes a dumped value as nested details elements
+func writeDebugHTML(sb *strings.Builder, v any) {
+	switch val := v.(type) {
+	case debugObject:
+		fmt.Fprintf(sb, `<details open><summary>{%d}</summary><ul>`, len(val))
+		for _, f := range val {
+			sb.WriteString(`<li><strong>`)
+			sb.WriteString(template.HTMLEscapeString(f.Key))
+			sb.WriteString(`</strong>: `)
+			writeDebugHTML(sb, f.Value)
+			sb.WriteString(`</li>`)
+		}
+		sb.WriteString(`</ul></details>`)
+	case []any:
+		fmt.Fprintf(sb, `<details open><summary>[%d]</summary><ol start="0">`, len(val))
+		for _, item := range val {
+			sb.WriteString(`<li>`)
+			writeDebugHTML(sb, item)
+			sb.WriteString(`</li>`)
+		}
+		sb.WriteString(`</ol></details>`)
+	case nil:
+		sb.WriteString(`<code>null</code>`)
+	default:
+		sb.WriteString(`<code>`)
+		sb.WriteString(template.HTMLEscapeString(fmt.Sprintf("%#v", val)))
+		sb.WriteString(`</code>`)
+	}
+}
@@ -0,0 +1,58 @@
+package templatex
+
+import (
+	"fmt"
+	"html/template"
+	"strings"
+	"time"
+)
+
+// debugInfo describes a single render and is displayed by the debug toolbar
+type debugInfo struct {
+	start    time.Time
+	name     string
+	layouts  []string
+	binding  any
+	locale   string
+	cacheHit bool
+}
+
+// debugToolbarEnabled reports whether the debug toolbar should be injected
+func (e *Engine) debugToolbarEnabled() bool {
+	return e.debugToolbar && e.env == EnvDevelopment
+}
+
+// injectDebugToolbar inserts a collapsible debug toolbar before the closing body tag.
+// Content without a closing body tag (partials, fragments) is returned unchanged.
+func (e *Engine) injectDebugToolbar(content string, info debugInfo) string {
+	idx := strings.LastIndex(strings.ToLower(content), "</body>")
+	if idx < 0 {
+		return content
+	}
+
+	cacheStatus := "miss"
+	if info.cacheHit {
+		cacheStatus = "hit"
+	}
+
+	templates := append([]string{info.name}, info.layouts...)
+
+	var sb strings.Builder
+	sb.Grow(len(content) + 1024)
+	sb.WriteString(content[:idx])
+	sb.WriteString(`<details id="templatex-debug" style="position:fixed;bottom:0;right:0;z-index:2147483647;max-width:100%;max-height:50vh;overflow:auto;background:#1f2937;color:#f9fafb;font:12px/1.4 monospace;padding:4px 8px;">`)
+	fmt.Fprintf(&sb, `<summary>templatex: %s | %s | cache %s</summary>`,
+		template.HTMLEscapeString(info.name),
+		template.HTMLEscapeString(time.Since(info.start).String()),
+		cacheStatus,
+	)
+	sb.WriteString(`<dl>`)
+	fmt.Fprintf(&sb, `<dt>Render time</dt><dd>%s</dd>`, template.HTMLEscapeString(time.Since(info.start).String()))
+	fmt.Fprintf(&sb, `<dt>Cache</dt><dd>%s</dd>`, cacheStatus)
+	fmt.Fprintf(&sb, `<dt>Locale</dt><dd>%s</dd>`, template.HTMLEscapeString(info.locale))
+	fmt.Fprintf(&sb, `<dt>Templates</dt><dd>%s</dd>`, template.HTMLEscapeString(strings.Join(templates, " → ")))
+	fmt.Fprintf(&sb, `<dt>Binding</dt><dd><pre>%s</pre></dd>`, template.HTMLEscapeString(e.debugDumper.debugJSON(info.binding)))
+	sb.WriteString(`</dl></details>`)
+	sb.WriteString(content[idx:])
+	return sb.String()
+}
@@ -0,0 +1,77 @@
+package templatex
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"sort"
+)
+
+// DiffStatus describes how a template changed between two engines
+type DiffStatus string
+
+// Template diff statuses
+const (
+	DiffAdded   DiffStatus = "added"
+	DiffRemoved DiffStatus = "removed"
+	DiffChanged DiffStatus = "changed"
+)
+
+// TemplateDiff describes a template that differs between two engines.
+// The checksum of the missing side is empty for added and removed templates.
+type TemplateDiff struct {
+	Name        string
+	Status      DiffStatus
+	OldChecksum string
+	NewChecksum string
+}
+
+// Checksums returns a map of template names to checksums of their parsed content.
+// The checksums are stable across engine instances, so they can be stored and
+// compared by deployment tooling.
+func (e *Engine) Checksums() map[string]string {
+	if !e.initialized() {
+		return map[string]string{}
+	}
+	// Templates failing to parse lazily are left out, as they can't be rendered
+	_ = e.ensureAllParsed()
+
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	sums := make(map[string]string)
+	for _, t := range e.templates.Templates() {
+		if t.Name() == "" || t.Tree == nil || t.Tree.Root == nil {
+			continue
+		}
+		sum := sha256.Sum256([]byte(t.Tree.Root.String()))
+		sums[t.Name()] = hex.EncodeToString(sum[:])
+	}
+	return sums
+}
+
+// DiffAgainst compares the templates of the engine with the templates of other,
+// treating other as the previous snapshot. It reports templates added to,
+// removed from and changed in e, sorted by name.
+// Returns an empty slice if the template sets are identical.
+func (e *Engine) DiffAgainst(other *Engine) []TemplateDiff {
+	current, previous := e.Checksums(), other.Checksums()
+
+	diffs := make([]TemplateDiff, 0)
+	for name, sum := range current {
+		prev, ok := previous[name]
+		switch {
+		case !ok:
+			diffs = append(diffs, TemplateDiff{Name: name, Status: DiffAdded, NewChecksum: sum})
+		case prev != sum:
+			diffs = append(diffs, TemplateDiff{Name: name, Status: DiffChanged, OldChecksum: prev, NewChecksum: sum})
+		}
+	}
+	for name, sum := range previous {
+		if _, ok := current[name]; !ok {
+			diffs = append(diffs, TemplateDiff{Name: name, Status: DiffRemoved, OldChecksum: sum})
+		}
+	}
+
+	sort.Slice(diffs, func(i, j int) bool { return diffs[i].Name < diffs[j].Name })
+	return diffs
+}
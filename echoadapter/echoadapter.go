@@ -0,0 +1,38 @@
+// Package echoadapter plugs a templatex engine into Echo as its renderer:
+//
+//	e := echo.New()
+//	e.Renderer = echoadapter.New(engine)
+//
+//	e.GET("/", func(c echo.Context) error {
+//		return c.Render(http.StatusOK, "greeter@app_layout@base_layout", data)
+//	})
+//
+// Layouts are selected by the "name@layout@outer_layout" naming convention
+// (see templatex.Engine.SplitView), and the request context is used for rendering,
+// so locale and request-scoped helpers work as with Engine.Render.
+package echoadapter
+
+import (
+	"io"
+
+	"github.com/dmitrymomot/templatex"
+	"github.com/labstack/echo/v4"
+)
+
+// Renderer implements echo.Renderer using a templatex engine
+type Renderer struct {
+	engine *templatex.Engine
+}
+
+var _ echo.Renderer = (*Renderer)(nil)
+
+// New creates an Echo renderer for the engine
+func New(engine *templatex.Engine) *Renderer {
+	return &Renderer{engine: engine}
+}
+
+// Render renders the view with the layouts encoded in its name
+func (r *Renderer) Render(w io.Writer, name string, data interface{}, c echo.Context) error {
+	view, layouts := r.engine.SplitView(name)
+	return r.engine.Render(c.Request().Context(), w, view, data, layouts...)
+}
@@ -0,0 +1,30 @@
+package echoadapter_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/dmitrymomot/templatex"
+	"github.com/dmitrymomot/templatex/echoadapter"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRenderer(t *testing.T) {
+	engine, err := templatex.New("../example/templates/")
+	require.NoError(t, err)
+
+	e := echo.New()
+	e.Renderer = echoadapter.New(engine)
+	e.GET("/", func(c echo.Context) error {
+		return c.Render(http.StatusOK, "greeter@base_layout", map[string]string{"Title": "Echo", "Username": "John"})
+	})
+
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), "<title>Echo</title>")
+	assert.Contains(t, rec.Body.String(), "John")
+}
@@ -0,0 +1,342 @@
+package templatex
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html"
+	"io/fs"
+	"path"
+	"strconv"
+	"strings"
+	texttemplate "text/template"
+
+	nethtml "golang.org/x/net/html"
+)
+
+// textTemplateExt is the extension of plaintext templates rendered by RenderEmail
+const textTemplateExt = ".txt"
+
+// subjectSuffix is the suffix of email subject templates, e.g. "welcome.subject"
+const subjectSuffix = ".subject"
+
+// Email is a rendered email (see Engine.RenderEmail)
+type Email struct {
+	Subject string // plain text subject
+	HTML    string // HTML part
+	Text    string // plaintext part
+}
+
+// RenderEmail renders the email template with optional layouts into the parts of a
+// multipart email:
+//   - HTML is the rendered template, e.g. "emails/welcome"
+//   - Subject is the rendered sibling "emails/welcome.subject" template, or the title
+//     element of the HTML part, with entities decoded and whitespace collapsed
+//   - Text is the rendered sibling "emails/welcome.txt" plaintext template, or derived
+//     from the HTML part: headings and paragraphs are separated by blank lines, list
+//     items are prefixed with "-" or their number and link URLs follow the link text
+//
+// Plaintext templates are enabled by WithEmailText. They're parsed with text/template, so
+// their output isn't HTML-escaped, and have the same functions as HTML templates. They're
+// rendered without layouts, and their front matter declares publish windows, with a
+// fallback plaintext template, and required permissions.
+// The default layouts of pages (see WithDefaultLayout) are not used for emails, while
+// layouts declared by extends directives and _layout templates of the email directories
+// are (see WithConventionLayouts).
+func (e *Engine) RenderEmail(ctx context.Context, name string, binding any, layouts ...string) (Email, error) {
+	if !e.initialized() {
+		return Email{}, ErrTemplateEngineNotInitialized
+	}
+	if len(layouts) == 0 {
+		extended, err := e.extendsLayouts(name)
+		if err != nil {
+			return Email{}, err
+		}
+		layouts = []string{NoLayout}
+		if len(extended) > 0 {
+			layouts = extended
+		} else if e.layoutConvention {
+			if found := e.conventionLayouts(name); len(found) > 0 {
+				layouts = found
+			}
+		}
+	}
+
+	content, err := e.RenderString(ctx, name, binding, layouts...)
+	if err != nil {
+		return Email{}, err
+	}
+	email := Email{HTML: content}
+
+	base := normalizeTemplateName(name, e.exts)
+	e.mu.RLock()
+	hasSubject := e.known(base + subjectSuffix)
+	textTmpl := e.texts.Lookup(base)
+	e.mu.RUnlock()
+
+	text, title := htmlToText(content)
+	email.Subject = title
+	if hasSubject {
+		subject, err := e.RenderString(ctx, base+subjectSuffix, binding, NoLayout)
+		if err != nil {
+			return Email{}, err
+		}
+		email.Subject = strings.Join(strings.Fields(html.UnescapeString(subject)), " ")
+	}
+
+	email.Text = text
+	if textTmpl != nil {
+		// Plaintext templates outside their publish window fall back to the derived text
+		if err := e.checkAuthorized(ctx, base+textTemplateExt); err != nil {
+			return Email{}, err
+		}
+		if e.checkPublished(base+textTemplateExt) != nil {
+			return email, nil
+		}
+		if email.Text, err = e.renderText(ctx, textTmpl, binding); err != nil {
+			return Email{}, err
+		}
+	}
+	return email, nil
+}
+
+// renderText executes the plaintext template with the functions bound to the context
+func (e *Engine) renderText(ctx context.Context, tmpl *texttemplate.Template, binding any) (string, error) {
+	clone, err := tmpl.Clone()
+	if err != nil {
+		return "", errors.Join(ErrTemplateCloneFailed, err)
+	}
+	var sb strings.Builder
+	if err := clone.Funcs(texttemplate.FuncMap(e.contextFuncs(ctx))).Execute(&sb, binding); err != nil {
+		return "", errors.Join(ErrTemplateExecutionFailed, fmt.Errorf("template: %s%s", tmpl.Name(), textTemplateExt), err)
+	}
+	return sb.String(), nil
+}
+
+// textWalkFunc returns a walk function parsing plaintext templates into texts and
+// passing other files to next. Files with a template extension are left to next.
+func (e *Engine) textWalkFunc(texts *texttemplate.Template, schedules map[string]publishWindow, permissions map[string]string, next fs.WalkDirFunc, manifest sourceManifest, fsys fs.FS, root, prefix string) fs.WalkDirFunc {
+	return func(filePath string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || path.Ext(filePath) != textTemplateExt || e.hasTemplateExt(filePath) {
+			return next(filePath, d, err)
+		}
+
+		relPath := relativePath(root, filePath)
+		content, err := fs.ReadFile(fsys, filePath)
+		if err != nil {
+			return err
+		}
+		if manifest != nil {
+			if err := manifest.verify(relPath, content); err != nil {
+				return err
+			}
+		}
+		if err := e.parseText(texts, schedules, permissions, prefix+strings.TrimSuffix(relPath, textTemplateExt), content); err != nil {
+			return fmt.Errorf("%s: %w", filePath, err)
+		}
+		return nil
+	}
+}
+
+// parseText parses the plaintext template of the email into texts. Publish windows and
+// permissions of its front matter or directory are recorded under the name with the
+// .txt extension, so they don't apply to the HTML template.
+func (e *Engine) parseText(texts *texttemplate.Template, schedules map[string]publishWindow, permissions map[string]string, name string, content []byte) error {
+	key := name + textTemplateExt
+	content, window, fm, err := parseFrontMatter(key, content)
+	if err != nil {
+		return err
+	}
+	if window != nil {
+		schedules[key] = *window
+	}
+	permission := fm.Requires
+	if permission == "" {
+		permission = e.dirPermission(name)
+	}
+	if permission != "" && !hasDefine(content) {
+		permissions[key] = permission
+		content = requirePermission(content, permission)
+	}
+	_, err = texts.New(name).Parse(string(content))
+	return err
+}
+
+// textBlocks are elements rendered on their own lines in plaintext, with the number of
+// line breaks around them
+var textBlocks = map[string]int{
+	"p": 2, "h1": 2, "h2": 2, "h3": 2, "h4": 2, "h5": 2, "h6": 2, "ul": 2, "ol": 2,
+	"table": 2, "blockquote": 2, "pre": 2, "hr": 2, "div": 1, "li": 1, "tr": 1,
+	"section": 1, "article": 1, "header": 1, "footer": 1, "nav": 1, "main": 1,
+	"aside": 1, "address": 1, "dl": 1, "dt": 1, "dd": 1, "figure": 1, "figcaption": 1,
+}
+
+// textSkipped are elements without text content
+var textSkipped = map[string]bool{
+	"head": true, "script": true, "style": true, "template": true, "noscript": true,
+}
+
+// textWriter writes plaintext, collapsing whitespace between words and blocks
+type textWriter struct {
+	sb       strings.Builder
+	newlines int  // line breaks to write before the next text
+	space    bool // space to write before the next text
+}
+
+// write writes the text after the pending line breaks or space
+func (w *textWriter) write(s string) {
+	if s == "" {
+		return
+	}
+	if w.sb.Len() > 0 {
+		if w.newlines > 0 {
+			w.sb.WriteString(strings.Repeat("\n", min(w.newlines, 2)))
+		} else if w.space {
+			w.sb.WriteByte(' ')
+		}
+	}
+	w.newlines, w.space = 0, false
+	w.sb.WriteString(s)
+}
+
+// writeCollapsed writes the text with whitespace collapsed to single spaces
+func (w *textWriter) writeCollapsed(s string) {
+	if s == "" {
+		return
+	}
+	if isHTMLSpace(s[0]) {
+		w.space = true
+	}
+	if words := strings.Fields(s); len(words) > 0 {
+		w.write(strings.Join(words, " "))
+	}
+	if isHTMLSpace(s[len(s)-1]) {
+		w.space = true
+	}
+}
+
+// lineBreak adds line breaks before the next text
+func (w *textWriter) lineBreak(n int) {
+	w.newlines = max(w.newlines, n)
+}
+
+// htmlToText converts rendered HTML to plaintext for the text part of emails, and
+// returns the text of its title element
+func htmlToText(content string) (text, title string) {
+	var w textWriter
+	z := nethtml.NewTokenizer(strings.NewReader(content))
+
+	var (
+		skipped int
+		inTitle bool
+		pre     int
+		lists   []int    // item counters of open lists, -1 for unordered lists
+		links   []string // hrefs of open links
+		linkAt  []int    // output offsets of open links
+	)
+	for {
+		tt := z.Next()
+		if tt == nethtml.ErrorToken {
+			return strings.TrimSpace(w.sb.String()), strings.Join(strings.Fields(title), " ")
+		}
+
+		name, hasAttr := z.TagName()
+		tag := string(name)
+		switch tt {
+		case nethtml.TextToken:
+			switch {
+			case inTitle:
+				title += string(z.Text())
+			case skipped > 0:
+			case pre > 0:
+				w.write(string(z.Text()))
+			default:
+				w.writeCollapsed(string(z.Text()))
+			}
+			continue
+		case nethtml.StartTagToken, nethtml.SelfClosingTagToken:
+			if tag == "title" && tt == nethtml.StartTagToken {
+				inTitle = true
+			}
+			if textSkipped[tag] && tt == nethtml.StartTagToken {
+				skipped++
+			}
+			if skipped > 0 {
+				continue
+			}
+			w.lineBreak(textBlocks[tag])
+			switch tag {
+			case "br":
+				w.lineBreak(1)
+			case "pre":
+				pre++
+			case "ul":
+				lists = append(lists, -1)
+			case "ol":
+				lists = append(lists, 0)
+			case "li":
+				marker := "-"
+				if n := len(lists); n > 0 && lists[n-1] >= 0 {
+					lists[n-1]++
+					marker = strconv.Itoa(lists[n-1]) + "."
+				}
+				w.write(strings.Repeat("  ", max(len(lists)-1, 0)) + marker)
+				w.space = true
+			case "td", "th":
+				w.space = true
+			case "img":
+				for hasAttr {
+					var key, val []byte
+					key, val, hasAttr = z.TagAttr()
+					if string(key) == "alt" {
+						w.writeCollapsed(string(val))
+					}
+				}
+			case "a":
+				href := ""
+				for hasAttr {
+					var key, val []byte
+					key, val, hasAttr = z.TagAttr()
+					if string(key) == "href" {
+						href = string(val)
+					}
+				}
+				links = append(links, href)
+				linkAt = append(linkAt, w.sb.Len())
+			}
+		case nethtml.EndTagToken:
+			if tag == "title" {
+				inTitle = false
+			}
+			if textSkipped[tag] {
+				if skipped > 0 {
+					skipped--
+				}
+				continue
+			}
+			if skipped > 0 {
+				continue
+			}
+			w.lineBreak(textBlocks[tag])
+			switch tag {
+			case "pre":
+				pre = max(pre-1, 0)
+			case "ul", "ol":
+				if len(lists) > 0 {
+					lists = lists[:len(lists)-1]
+				}
+			case "a":
+				if n := len(links); n > 0 {
+					href, at := links[n-1], linkAt[n-1]
+					links, linkAt = links[:n-1], linkAt[:n-1]
+					label := strings.TrimSpace(w.sb.String()[min(at, w.sb.Len()):])
+					href = strings.TrimPrefix(href, "mailto:")
+					if href != "" && !strings.HasPrefix(href, "#") && href != label {
+						w.space = true
+						w.write("(" + href + ")")
+					}
+				}
+			}
+		}
+	}
+}
@@ -10,4 +10,28 @@ var (
 	ErrTemplateEngineNotInitialized = errors.New("template engine not initialized")
 	ErrNoTemplatesParsed            = errors.New("no templates parsed")
 	ErrTemplateCloneFailed          = errors.New("failed to clone template")
+	ErrReservedFuncName             = errors.New("function name is reserved")
+	ErrBuiltinFuncOverride          = errors.New("custom function overrides built-in function")
+	ErrAutoReloadFailed             = errors.New("failed to watch template directory")
+	ErrLayoutNotFound               = errors.New("layout not found")
+	ErrHTMLValidationFailed         = errors.New("rendered HTML is invalid")
+	ErrExportFailed                 = errors.New("static export failed")
+	ErrBrokenLinks                  = errors.New("broken internal links")
+	ErrPrintFailed                  = errors.New("print rendering failed")
+	ErrLiteRenderFailed             = errors.New("lite rendering failed")
+	ErrSizeBudgetExceeded           = errors.New("size budget exceeded")
+	ErrArchiveFailed                = errors.New("failed to archive rendered output")
+	ErrPushFailed                   = errors.New("failed to push rendered fragment")
+	ErrTemplateNotPublished         = errors.New("template is outside its publish window")
+	ErrUntrustedHTML                = errors.New("untrusted HTML passed to htmlSafe")
+	ErrSourceVerificationFailed     = errors.New("template source verification failed")
+	ErrUnauthorized                 = errors.New("not authorized to render template")
+	ErrNoLocales                    = errors.New("no locales configured")
+	ErrFuncPackFailed               = errors.New("function pack initialization failed")
+	ErrEncodingFailed               = errors.New("failed to encode rendered output")
+	ErrInvalidBaseURL               = errors.New("invalid base URL")
+	ErrInvalidURLSigner             = errors.New("invalid URL signer")
+	ErrInvalidURLSignature          = errors.New("invalid URL signature")
+	ErrSignedURLExpired             = errors.New("signed URL expired")
+	ErrComponentDepthExceeded       = errors.New("components nested too deeply")
 )
@@ -0,0 +1,449 @@
+package templatex
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"text/template/parse"
+	"time"
+
+	"golang.org/x/net/html"
+)
+
+// Page describes a page exported by Export
+type Page struct {
+	Path     string   // URL path of the page, e.g. "/" or "/blog/hello"
+	Template string   // name of the content template
+	Data     any      // binding data
+	Layouts  []string // layouts wrapping the content
+
+	// UpdatedAt is a data version hint used by ExportChanged: the page is re-exported
+	// if its data changed after the given time. Zero means the data is unchanged.
+	UpdatedAt time.Time
+}
+
+// ExportOption configures Export
+type ExportOption func(*exportConfig)
+
+// exportConfig holds the Export settings
+type exportConfig struct {
+	checkLinks bool
+	assets     map[string]bool // known asset URL paths
+	assetErr   error
+}
+
+// WithLinkCheck enables broken internal link detection during export.
+// Relative and root-relative links (href, src and form action attributes) must
+// resolve to an exported page or a known asset (see WithExportAssets), otherwise
+// Export returns a *BrokenLinksError and writes nothing.
+func WithLinkCheck(enabled bool) ExportOption {
+	return func(c *exportConfig) {
+		c.checkLinks = enabled
+	}
+}
+
+// WithExportAssets registers the files of fsys as known assets served under the
+// URL prefix, e.g. WithExportAssets(os.DirFS("public"), "/static").
+// Links to these files are not reported as broken.
+func WithExportAssets(fsys fs.FS, prefix string) ExportOption {
+	return func(c *exportConfig) {
+		err := fs.WalkDir(fsys, ".", func(p string, d fs.DirEntry, err error) error {
+			if err != nil || d.IsDir() {
+				return err
+			}
+			c.assets[path.Join("/", prefix, p)] = true
+			return nil
+		})
+		if err != nil {
+			c.assetErr = errors.Join(c.assetErr, err)
+		}
+	}
+}
+
+// BrokenLink describes an internal link that doesn't resolve to an exported page or asset
+type BrokenLink struct {
+	Page     string // URL path of the page containing the link
+	Template string // name of the template that produced the link
+	Line     int    // line number in the rendered page
+	URL      string // link as written in the page
+}
+
+// BrokenLinksError is returned by Export when link checking is enabled and
+// some internal links are broken. It wraps ErrBrokenLinks.
+type BrokenLinksError struct {
+	Links []BrokenLink
+}
+
+// Error returns all broken links separated by a new line
+func (e *BrokenLinksError) Error() string {
+	lines := make([]string, len(e.Links))
+	for i, l := range e.Links {
+		lines[i] = fmt.Sprintf("%s: %s: line %d: %s", l.Page, l.Template, l.Line, l.URL)
+	}
+	return ErrBrokenLinks.Error() + ":\n" + strings.Join(lines, "\n")
+}
+
+// Unwrap allows checking the error with errors.Is(err, ErrBrokenLinks)
+func (e *BrokenLinksError) Unwrap() error {
+	return ErrBrokenLinks
+}
+
+// Export renders the pages and writes them as static HTML files to dir.
+// Page paths are mapped to files as follows: "/" to index.html, "/about" to
+// about/index.html, and paths with an extension (e.g. "/404.html") are kept as is.
+// The request path of each page is stored in the render context, so navigation
+// helpers work as they do for HTTP requests. Pages are validated and transformed like
+// rendered output, e.g. heading anchors and minification are applied, but not cached.
+// Pages are rendered before anything is written, so a failed export doesn't leave
+// a partially updated site behind.
+//
+// Returns an error if a page fails to render, a link is broken (see WithLinkCheck),
+// or a file can't be written.
+func (e *Engine) Export(ctx context.Context, dir string, pages []Page, opts ...ExportOption) error {
+	_, err := e.exportPages(ctx, dir, pages, nil, opts...)
+	return err
+}
+
+// ExportChanged behaves like Export, but only re-renders pages affected by changes
+// made after since: pages whose data changed (see Page.UpdatedAt) and pages whose
+// content template, layouts or any template they include (directly or transitively)
+// were modified. All pages are still used to resolve links when link checking is enabled.
+//
+// Returns the paths of the exported pages.
+func (e *Engine) ExportChanged(ctx context.Context, dir string, pages []Page, since time.Time, opts ...ExportOption) ([]string, error) {
+	return e.exportPages(ctx, dir, pages, func(p Page) bool {
+		return e.pageChanged(p, since)
+	}, opts...)
+}
+
+// exportPages renders the pages accepted by the filter (all pages if filter is nil)
+// and writes them to dir. It returns the paths of the exported pages.
+func (e *Engine) exportPages(ctx context.Context, dir string, pages []Page, filter func(Page) bool, opts ...ExportOption) ([]string, error) {
+	if !e.initialized() {
+		return nil, ErrTemplateEngineNotInitialized
+	}
+
+	cfg := &exportConfig{assets: make(map[string]bool)}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(cfg)
+		}
+	}
+	if cfg.assetErr != nil {
+		return nil, errors.Join(ErrExportFailed, cfg.assetErr)
+	}
+
+	type exportedPage struct {
+		page    Page
+		raw     string // executed output, which the stages map to templates
+		content string // output written to the file
+		stages  []renderStage
+	}
+	rendered := make([]exportedPage, 0, len(pages))
+	routes := make(map[string]bool, len(pages))
+
+	for _, p := range pages {
+		routes[normalizeRoute(p.Path)] = true
+		if filter != nil && !filter(p) {
+			continue
+		}
+
+		resolved, err := e.pageLayouts(p)
+		if err != nil {
+			return nil, errors.Join(ErrExportFailed, fmt.Errorf("page %s", p.Path), err)
+		}
+		pageCtx := WithRequestPath(ctx, p.Path)
+		trackStages := cfg.checkLinks || e.htmlValidation || e.a11yCheck
+		raw, stages, err := e.execute(pageCtx, p.Template, p.Data, resolved, trackStages, nil)
+		if err != nil {
+			return nil, errors.Join(ErrExportFailed, fmt.Errorf("page %s", p.Path), err)
+		}
+		// Pages are written as Render outputs them
+		content, err := e.finishOutput(pageCtx, p.Template, raw, stages)
+		if err != nil {
+			return nil, errors.Join(ErrExportFailed, fmt.Errorf("page %s", p.Path), err)
+		}
+		rendered = append(rendered, exportedPage{page: p, raw: raw, content: content, stages: stages})
+	}
+
+	if cfg.checkLinks {
+		var broken []BrokenLink
+		for _, r := range rendered {
+			broken = append(broken, findBrokenLinks(r.page.Path, r.raw, buildSourceMap(r.stages), routes, cfg.assets)...)
+		}
+		if len(broken) > 0 {
+			return nil, &BrokenLinksError{Links: broken}
+		}
+	}
+
+	exported := make([]string, 0, len(rendered))
+	for _, r := range rendered {
+		file := filepath.Join(dir, filepath.FromSlash(exportFilePath(r.page.Path)))
+		if err := os.MkdirAll(filepath.Dir(file), 0o755); err != nil {
+			return nil, errors.Join(ErrExportFailed, err)
+		}
+		if err := os.WriteFile(file, []byte(r.content), 0o644); err != nil {
+			return nil, errors.Join(ErrExportFailed, err)
+		}
+		exported = append(exported, r.page.Path)
+	}
+
+	return exported, nil
+}
+
+// pageLayouts returns the layouts the page is rendered with (see resolveLayouts)
+func (e *Engine) pageLayouts(p Page) ([]LayoutBinding, error) {
+	layouts := make([]LayoutBinding, len(p.Layouts))
+	for i, l := range p.Layouts {
+		layouts[i] = LayoutBinding{Name: l}
+	}
+	return e.resolveLayouts(p.Template, layouts)
+}
+
+// pageChanged reports whether the page data or any template it depends on
+// changed after the given time
+func (e *Engine) pageChanged(p Page, since time.Time) bool {
+	if p.UpdatedAt.After(since) {
+		return true
+	}
+	// The page is rendered with the resolved layouts, so default, convention and
+	// extends layouts are dependencies too
+	layouts, err := e.pageLayouts(p)
+	if err != nil {
+		// the export reports the error
+		return true
+	}
+	names := []string{p.Template}
+	for _, l := range layouts {
+		names = append(names, l.Name)
+	}
+	deps := e.templateDeps(names...)
+
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	for _, name := range deps {
+		if strings.Contains(name, sectionSeparator) {
+			// slots are defined in the file of the template rendering them
+			continue
+		}
+		if t, ok := e.modTime[name]; !ok || t.After(since) {
+			// unknown templates are treated as changed
+			return true
+		}
+	}
+	return false
+}
+
+// templateDeps returns the given templates and all templates and components they
+// include, directly or transitively. Built-in components aren't templates, so they
+// aren't listed.
+func (e *Engine) templateDeps(names ...string) []string {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	seen := make(map[string]bool)
+	var deps []string
+	var visit func(name string)
+	visit = func(name string) {
+		t := e.lookup(name)
+		if t != nil {
+			name = t.Name()
+		} else if _, ok := builtinComponents[name]; ok {
+			return
+		}
+		if seen[name] {
+			return
+		}
+		seen[name] = true
+		deps = append(deps, name)
+		if t == nil || t.Tree == nil {
+			return
+		}
+		for _, dep := range includedTemplates(t.Tree.Root) {
+			visit(dep)
+		}
+	}
+	for _, name := range names {
+		visit(name)
+	}
+	return deps
+}
+
+// includedTemplates returns the names of templates included by the node with the
+// template action, and of the components and slot templates rendered by component and
+// componentSlot calls with constant names
+func includedTemplates(node parse.Node) []string {
+	var names []string
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return nil
+		}
+		for _, c := range n.Nodes {
+			names = append(names, includedTemplates(c)...)
+		}
+	case *parse.ActionNode:
+		names = append(names, includedTemplates(n.Pipe)...)
+	case *parse.TemplateNode:
+		names = append(names, n.Name)
+		names = append(names, includedTemplates(n.Pipe)...)
+	case *parse.IfNode:
+		names = append(names, includedTemplates(n.Pipe)...)
+		names = append(names, includedTemplates(n.List)...)
+		names = append(names, includedTemplates(n.ElseList)...)
+	case *parse.RangeNode:
+		names = append(names, includedTemplates(n.Pipe)...)
+		names = append(names, includedTemplates(n.List)...)
+		names = append(names, includedTemplates(n.ElseList)...)
+	case *parse.WithNode:
+		names = append(names, includedTemplates(n.Pipe)...)
+		names = append(names, includedTemplates(n.List)...)
+		names = append(names, includedTemplates(n.ElseList)...)
+	case *parse.PipeNode:
+		if n == nil {
+			return nil
+		}
+		for _, cmd := range n.Cmds {
+			names = append(names, includedTemplates(cmd)...)
+		}
+	case *parse.CommandNode:
+		names = append(names, componentCallNames(n.Args)...)
+		for _, arg := range n.Args {
+			names = append(names, includedTemplates(arg)...)
+		}
+	}
+	return names
+}
+
+// componentCallNames returns the constant template names of a component or
+// componentSlot call: {{ component "card" }} and {{ componentSlot "page#slot:1" . "card" }}
+func componentCallNames(args []parse.Node) []string {
+	if len(args) < 2 {
+		return nil
+	}
+	fn, ok := args[0].(*parse.IdentifierNode)
+	if !ok {
+		return nil
+	}
+	var names []string
+	switch fn.Ident {
+	case "component":
+		if name, ok := args[1].(*parse.StringNode); ok {
+			names = append(names, name.Text)
+		}
+	case "componentSlot":
+		if slot, ok := args[1].(*parse.StringNode); ok {
+			names = append(names, slot.Text)
+		}
+		if len(args) > 3 {
+			if name, ok := args[3].(*parse.StringNode); ok {
+				names = append(names, name.Text)
+			}
+		}
+	}
+	return names
+}
+
+// exportFilePath returns the file path of the exported page relative to the export directory
+func exportFilePath(p string) string {
+	p = path.Clean("/" + p)
+	if path.Ext(p) != "" {
+		return strings.TrimPrefix(p, "/")
+	}
+	return strings.TrimPrefix(path.Join(p, "index.html"), "/")
+}
+
+// normalizeRoute returns a cleaned URL path used to compare links with exported pages
+func normalizeRoute(p string) string {
+	p = path.Clean("/" + p)
+	if p = strings.TrimSuffix(p, "/index.html"); p == "" {
+		return "/"
+	}
+	return p
+}
+
+// linkAttrs maps elements to their attributes containing links
+var linkAttrs = map[string]string{
+	"a": "href", "link": "href", "area": "href",
+	"img": "src", "script": "src", "source": "src", "iframe": "src",
+	"video": "src", "audio": "src", "track": "src", "embed": "src",
+	"form": "action",
+}
+
+// findBrokenLinks returns the internal links of the page that don't resolve
+// to an exported route or a known asset
+func findBrokenLinks(pagePath, content string, sources sourceMap, routes, assets map[string]bool) []BrokenLink {
+	var broken []BrokenLink
+
+	z := html.NewTokenizer(strings.NewReader(content))
+	offset := 0
+	for {
+		tt := z.Next()
+		if tt == html.ErrorToken {
+			break
+		}
+		pos := offset
+		offset += len(z.Raw())
+		if tt != html.StartTagToken && tt != html.SelfClosingTagToken {
+			continue
+		}
+
+		name, hasAttr := z.TagName()
+		attr, ok := linkAttrs[string(name)]
+		if !ok {
+			continue
+		}
+		for hasAttr {
+			var key, val []byte
+			key, val, hasAttr = z.TagAttr()
+			if string(key) != attr {
+				continue
+			}
+			link := string(val)
+			target, internal := resolveInternalLink(pagePath, link)
+			if !internal || routes[normalizeRoute(target)] || assets[target] {
+				continue
+			}
+			broken = append(broken, BrokenLink{
+				Page:     pagePath,
+				Template: sources.templateAt(pos),
+				Line:     strings.Count(content[:pos], "\n") + 1,
+				URL:      link,
+			})
+		}
+	}
+
+	return broken
+}
+
+// resolveInternalLink resolves the link relative to the page path.
+// It reports false for external links, fragments and non-HTTP schemes.
+func resolveInternalLink(pagePath, link string) (string, bool) {
+	link = strings.TrimSpace(link)
+	if link == "" || strings.HasPrefix(link, "#") || strings.HasPrefix(link, "//") {
+		return "", false
+	}
+	u, err := url.Parse(link)
+	if err != nil || u.Scheme != "" || u.Host != "" {
+		return "", false
+	}
+	if u.Path == "" {
+		// query-only link points to the page itself
+		return pagePath, true
+	}
+	if strings.HasPrefix(u.Path, "/") {
+		return path.Clean(u.Path), true
+	}
+	base := pagePath
+	if !strings.HasSuffix(base, "/") && path.Ext(base) == "" {
+		// pages without extension are exported as directories
+		base += "/"
+	}
+	return path.Clean(path.Join(path.Dir(base+"x"), u.Path)), true
+}
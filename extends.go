@@ -0,0 +1,89 @@
+package templatex
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// extendsDirective matches the extends directive of a template, a comment declaring
+// the layout wrapping it: {{/* extends "base_layout" */}}
+var extendsDirective = regexp.MustCompile(`\{\{-?\s*/\*\s*extends\s+("(?:[^"\\]|\\.)*")\s*\*/\s*-?\}\}`)
+
+// parseExtends returns the layout declared by the extends directive of the template
+// content, or an empty string if there is none
+func parseExtends(content []byte) (string, error) {
+	m := extendsDirective.FindSubmatch(content)
+	if m == nil {
+		return "", nil
+	}
+	if hasDefine(content) {
+		return "", errors.New("extends is not supported in files with define blocks")
+	}
+	layout, err := strconv.Unquote(string(m[1]))
+	if err != nil || layout == "" {
+		return "", fmt.Errorf("invalid extends directive: %s", m[0])
+	}
+	return layout, nil
+}
+
+// checkExtends reports layouts declared by extends directives that don't exist and
+// templates extending themselves through their layouts
+func checkExtends(extends map[string]string, exists func(name string) bool) error {
+	var missing []string
+	for name, layout := range extends {
+		if !exists(layout) {
+			missing = append(missing, fmt.Sprintf("%s (extended by %s)", layout, name))
+		}
+	}
+	if len(missing) > 0 {
+		slices.Sort(missing)
+		return errors.Join(ErrLayoutNotFound, fmt.Errorf("layouts: %s", strings.Join(missing, ", ")))
+	}
+
+	for name := range extends {
+		seen := map[string]bool{name: true}
+		for layout := extends[name]; layout != ""; layout = extends[layout] {
+			if seen[layout] {
+				return errors.Join(ErrTemplateParsingFailed, fmt.Errorf("template %s: extends cycle through %s", name, layout))
+			}
+			seen[layout] = true
+		}
+	}
+	return nil
+}
+
+// extendsLayouts returns the layouts of the template declared by extends directives,
+// innermost first: the layout it extends, the layout extended by that one, and so on.
+// It returns an error if the template or one of its layouts fails to parse lazily.
+func (e *Engine) extendsLayouts(name string) ([]string, error) {
+	e.mu.RLock()
+	none := len(e.extends) == 0 && len(e.lazy) == 0
+	e.mu.RUnlock()
+	if none {
+		return nil, nil
+	}
+
+	var layouts []string
+	seen := map[string]bool{}
+	for {
+		if err := e.ensureParsed(name); err != nil {
+			return nil, err
+		}
+		e.mu.RLock()
+		var layout string
+		if t := e.lookup(name); t != nil {
+			layout = e.extends[t.Name()]
+		}
+		e.mu.RUnlock()
+		if layout == "" || seen[layout] {
+			return layouts, nil
+		}
+		seen[layout] = true
+		layouts = append(layouts, layout)
+		name = layout
+	}
+}
@@ -0,0 +1,110 @@
+package templatex
+
+import (
+	"fmt"
+	"html/template"
+	"strings"
+)
+
+var loremWords = strings.Fields(`lorem ipsum dolor sit amet consectetur adipiscing elit sed do
+eiusmod tempor incididunt ut labore et dolore magna aliqua ut enim ad minim veniam quis
+nostrud exercitation ullamco laboris nisi ut aliquip ex ea commodo consequat duis aute irure
+dolor in reprehenderit in voluptate velit esse cillum dolore eu fugiat nulla pariatur
+excepteur sint occaecat cupidatat non proident sunt in culpa qui officia deserunt mollit
+anim id est laborum`)
+
+var fakeNames = []string{
+	"Jane Doe", "John Smith", "Maria Garcia", "Wei Chen", "Amara Okafor",
+	"Lukas Müller", "Sofia Rossi", "Hiroshi Tanaka", "Olivia Brown", "Mateo Silva",
+}
+
+// fakeFuncs returns functions generating placeholder content for prototyping.
+// If enabled is false, the functions return empty values, so templates using
+// them still parse and render in production.
+// Usage: {{ lorem "words" 12 }}, {{ fakeName }}, {{ fakeEmail 2 }}, {{ placeholderImage 640 480 }}
+func fakeFuncs(enabled bool) template.FuncMap {
+	if !enabled {
+		return template.FuncMap{
+			"lorem":            func(kind string, n int) string { return "" },
+			"fakeName":         func(i ...int) string { return "" },
+			"fakeEmail":        func(i ...int) string { return "" },
+			"placeholderImage": func(width, height int) string { return "" },
+		}
+	}
+	return template.FuncMap{
+		"lorem":            lorem,
+		"fakeName":         fakeName,
+		"fakeEmail":        fakeEmail,
+		"placeholderImage": placeholderImage,
+	}
+}
+
+// lorem returns n words, sentences or paragraphs of lorem ipsum text.
+// The output is deterministic, so snapshots and cached renders stay stable.
+// Usage: {{ lorem "words" 12 }}, {{ lorem "sentences" 2 }}, {{ lorem "paragraphs" 3 }}
+func lorem(kind string, n int) (string, error) {
+	if n < 0 {
+		n = 0
+	}
+	switch strings.TrimSuffix(strings.ToLower(kind), "s") {
+	case "word":
+		return loremText(0, n), nil
+	case "sentence":
+		sentences := make([]string, n)
+		for i := range sentences {
+			sentences[i] = loremSentence(i)
+		}
+		return strings.Join(sentences, " "), nil
+	case "paragraph":
+		paragraphs := make([]string, n)
+		for i := range paragraphs {
+			sentences := make([]string, 5)
+			for j := range sentences {
+				sentences[j] = loremSentence(i*5 + j)
+			}
+			paragraphs[i] = strings.Join(sentences, " ")
+		}
+		return strings.Join(paragraphs, "\n\n"), nil
+	}
+	return "", fmt.Errorf("lorem: unknown kind %q, expected words, sentences or paragraphs", kind)
+}
+
+// loremText returns n lorem words starting at the given offset
+func loremText(offset, n int) string {
+	words := make([]string, n)
+	for i := range words {
+		words[i] = loremWords[(offset+i)%len(loremWords)]
+	}
+	return strings.Join(words, " ")
+}
+
+// loremSentence returns the i-th lorem sentence
+func loremSentence(i int) string {
+	s := loremText(i*7, 8+i%5)
+	return strings.ToUpper(s[:1]) + s[1:] + "."
+}
+
+// fakeName returns a placeholder person name. The optional index selects
+// a different name, e.g. for items in a list.
+// Usage: {{ fakeName }} or {{ fakeName $i }}
+func fakeName(i ...int) string {
+	idx := 0
+	if len(i) > 0 && i[0] > 0 {
+		idx = i[0]
+	}
+	return fakeNames[idx%len(fakeNames)]
+}
+
+// fakeEmail returns a placeholder email address matching fakeName for the same index.
+// Usage: {{ fakeEmail }} or {{ fakeEmail $i }}
+func fakeEmail(i ...int) string {
+	name := strings.ToLower(strings.ReplaceAll(fakeName(i...), " ", "."))
+	name = strings.ReplaceAll(name, "ü", "ue")
+	return name + "@example.com"
+}
+
+// placeholderImage returns the URL of a placeholder image of the given size.
+// Usage: <img src="{{ placeholderImage 640 480 }}" alt="">
+func placeholderImage(width, height int) string {
+	return fmt.Sprintf("https://placehold.co/%dx%d", width, height)
+}
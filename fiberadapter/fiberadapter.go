@@ -0,0 +1,78 @@
+// Package fiberadapter plugs a templatex engine into Fiber as its views engine:
+//
+//	app := fiber.New(fiber.Config{
+//		Views: fiberadapter.New(engine, fiberadapter.WithLocale("en")),
+//	})
+//
+//	app.Get("/", func(c *fiber.Ctx) error {
+//		return c.Render("greeter", data, "app_layout", "base_layout")
+//	})
+//
+// Fiber's layout arguments are used as templatex layouts, innermost first.
+// Layouts can also be selected by the "name@layout@outer_layout" naming convention
+// (see templatex.Engine.SplitView).
+//
+// Fiber doesn't pass a request context to views, so templates are rendered with a
+// context holding the fallback locale (see WithLocale) instead of a request locale.
+package fiberadapter
+
+import (
+	"context"
+	"io"
+
+	"github.com/dmitrymomot/templatex"
+	"github.com/gofiber/fiber/v2"
+	"github.com/invopop/ctxi18n"
+)
+
+// Views implements fiber.Views using a templatex engine
+type Views struct {
+	engine *templatex.Engine
+	locale string
+}
+
+var _ fiber.Views = (*Views)(nil)
+
+// Option configures Views
+type Option func(*Views)
+
+// WithLocale sets the locale templates are rendered with, since Fiber doesn't
+// pass the request context to views. The default locale of ctxi18n is used if the
+// locale is not loaded.
+func WithLocale(locale string) Option {
+	return func(v *Views) {
+		v.locale = locale
+	}
+}
+
+// New creates Fiber views for the engine
+func New(engine *templatex.Engine, opts ...Option) *Views {
+	v := &Views{engine: engine}
+	for _, opt := range opts {
+		opt(v)
+	}
+	return v
+}
+
+// Load reloads the templates. Fiber calls it once on startup.
+func (v *Views) Load() error {
+	return v.engine.Reload()
+}
+
+// Render renders the template with the layouts, innermost first
+func (v *Views) Render(w io.Writer, name string, binding interface{}, layouts ...string) error {
+	view, viewLayouts := v.engine.SplitView(name)
+	return v.engine.Render(v.context(), w, view, binding, append(viewLayouts, layouts...)...)
+}
+
+// context returns the context templates are rendered with
+func (v *Views) context() context.Context {
+	ctx := context.Background()
+	if v.locale == "" {
+		return ctx
+	}
+	if lctx, err := ctxi18n.WithLocale(ctx, v.locale); err == nil {
+		return lctx
+	}
+	return ctx
+}
@@ -0,0 +1,36 @@
+package fiberadapter_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/dmitrymomot/templatex"
+	"github.com/dmitrymomot/templatex/fiberadapter"
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestViews(t *testing.T) {
+	engine, err := templatex.New("../example/templates/")
+	require.NoError(t, err)
+
+	app := fiber.New(fiber.Config{Views: fiberadapter.New(engine, fiberadapter.WithLocale("en"))})
+	app.Get("/", func(c *fiber.Ctx) error {
+		return c.Render("greeter", fiber.Map{"Title": "Fiber", "Username": "John"}, "base_layout")
+	})
+	app.Get("/convention", func(c *fiber.Ctx) error {
+		return c.Render("greeter@base_layout", fiber.Map{"Title": "Convention", "Username": "Jane"})
+	})
+
+	for path, expected := range map[string]string{"/": "<title>Fiber</title>", "/convention": "<title>Convention</title>"} {
+		resp, err := app.Test(httptest.NewRequest(http.MethodGet, path, nil))
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		assert.Contains(t, string(body), expected)
+	}
+}
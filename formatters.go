@@ -0,0 +1,349 @@
+package templatex
+
+import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// FormatConfig configures the number and date formatters shared by templates
+// and the client-side bundle (see WithFormatConfig and Engine.FormattersJS)
+type FormatConfig struct {
+	DecimalSeparator   string         // separator of the fractional part, "." by default
+	ThousandsSeparator string         // separator of thousands groups, "," by default
+	Decimals           int            // default number of decimals
+	DateLayout         string         // default Go layout used by formatDate, "Jan 2, 2006" by default
+	Location           *time.Location // time zone used for dates; nil keeps the value's own (server) or browser's (client) zone
+}
+
+// defaultFormatConfig returns the default formatter settings
+func defaultFormatConfig() FormatConfig {
+	return FormatConfig{
+		DecimalSeparator:   ".",
+		ThousandsSeparator: ",",
+		DateLayout:         "Jan 2, 2006",
+	}
+}
+
+// formatFuncs returns number, date and humanize functions configured by cfg
+// and bound to the clock.
+// Usage: {{ formatNumber .Total 2 }}, {{ formatDate .CreatedAt }}, {{ humanizeTime .CreatedAt }},
+// {{ humanizeBytes .Size }}, {{ fmtField .Order "Total" }} (see fmtField)
+func formatFuncs(cfg FormatConfig, clock func() time.Time) template.FuncMap {
+	return template.FuncMap{
+		"formatNumber": func(v any, decimals ...int) (string, error) {
+			return cfg.formatNumber(v, decimals...)
+		},
+		"formatDate": func(v any, layout ...string) (string, error) {
+			if len(layout) == 0 || layout[0] == "" {
+				layout = []string{cfg.DateLayout}
+			}
+			return formatTime(cfg.inLocation(v), layout...)
+		},
+		"humanizeTime": func(v any) (string, error) {
+			return cfg.humanizeTime(v, clock())
+		},
+		"humanizeBytes": cfg.humanizeBytes,
+		"fmtField": func(data any, path string) (string, error) {
+			return cfg.fmtField(data, path, clock())
+		},
+	}
+}
+
+// formatNumber rounds the number to the decimals (half away from zero) and
+// formats it with the configured separators
+func (cfg FormatConfig) formatNumber(v any, decimals ...int) (string, error) {
+	f, err := toFloat(v)
+	if err != nil {
+		return "", err
+	}
+	d := cfg.Decimals
+	if len(decimals) > 0 {
+		d = decimals[0]
+	}
+	if d < 0 {
+		d = 0
+	}
+
+	// Round the absolute value, so rounding matches the client-side formatter
+	pow := math.Pow(10, float64(d))
+	rounded := math.Round(math.Abs(f)*pow) / pow
+	s := strconv.FormatFloat(rounded, 'f', d, 64)
+
+	intPart, fracPart, _ := strings.Cut(s, ".")
+	var sb strings.Builder
+	if f < 0 && rounded != 0 {
+		sb.WriteByte('-')
+	}
+	for i, c := range intPart {
+		if i > 0 && (len(intPart)-i)%3 == 0 {
+			sb.WriteString(cfg.ThousandsSeparator)
+		}
+		sb.WriteRune(c)
+	}
+	if fracPart != "" {
+		sb.WriteString(cfg.DecimalSeparator)
+		sb.WriteString(fracPart)
+	}
+	return sb.String(), nil
+}
+
+// byteUnits are the units of humanizeBytes, in powers of 1024
+var byteUnits = []string{"B", "KB", "MB", "GB", "TB", "PB"}
+
+// humanizeBytes returns the size in bytes with the largest binary unit it reaches,
+// e.g. "512 B", "1.5 KB" or "5 MB". Sizes are rounded to one decimal, which is
+// omitted for whole numbers.
+func (cfg FormatConfig) humanizeBytes(v any) (string, error) {
+	f, err := toFloat(v)
+	if err != nil {
+		return "", err
+	}
+	i := 0
+	for math.Abs(f) >= 1024 && i < len(byteUnits)-1 {
+		f /= 1024
+		i++
+	}
+	decimals := 1
+	if i == 0 || math.Round(f*10) == math.Round(f)*10 {
+		decimals = 0
+	}
+	s, err := cfg.formatNumber(f, decimals)
+	if err != nil {
+		return "", err
+	}
+	return s + " " + byteUnits[i], nil
+}
+
+// humanizeTime returns the time relative to now, e.g. "5 minutes ago" or "in 2 hours".
+// Times more than 30 days away are formatted with the date layout.
+func (cfg FormatConfig) humanizeTime(v any, now time.Time) (string, error) {
+	t, err := toTime(v)
+	if err != nil || t.IsZero() {
+		return "", err
+	}
+
+	diff := now.Sub(t)
+	future := diff < 0
+	if future {
+		diff = -diff
+	}
+
+	var n int64
+	var unit string
+	switch {
+	case diff < time.Minute:
+		return "just now", nil
+	case diff < time.Hour:
+		n, unit = int64(diff/time.Minute), "minute"
+	case diff < 24*time.Hour:
+		n, unit = int64(diff/time.Hour), "hour"
+	case diff < 30*24*time.Hour:
+		n, unit = int64(diff/(24*time.Hour)), "day"
+	default:
+		return formatTime(cfg.inLocation(t), cfg.DateLayout)
+	}
+
+	if n != 1 {
+		unit += "s"
+	}
+	if future {
+		return fmt.Sprintf("in %d %s", n, unit), nil
+	}
+	return fmt.Sprintf("%d %s ago", n, unit), nil
+}
+
+// inLocation converts time values to the configured location
+func (cfg FormatConfig) inLocation(v any) any {
+	if cfg.Location == nil {
+		return v
+	}
+	switch t := v.(type) {
+	case time.Time:
+		return t.In(cfg.Location)
+	case *time.Time:
+		if t != nil {
+			return t.In(cfg.Location)
+		}
+	}
+	return v
+}
+
+// toTime converts time.Time and *time.Time values to time.Time
+func toTime(v any) (time.Time, error) {
+	switch t := v.(type) {
+	case time.Time:
+		return t, nil
+	case *time.Time:
+		if t == nil {
+			return time.Time{}, nil
+		}
+		return *t, nil
+	case nil:
+		return time.Time{}, nil
+	}
+	return time.Time{}, fmt.Errorf("unsupported time type %T", v)
+}
+
+// toFloat converts numbers and numeric strings to float64
+func toFloat(v any) (float64, error) {
+	switch n := v.(type) {
+	case float64:
+		return n, nil
+	case float32:
+		return float64(n), nil
+	case int:
+		return float64(n), nil
+	case int8:
+		return float64(n), nil
+	case int16:
+		return float64(n), nil
+	case int32:
+		return float64(n), nil
+	case int64:
+		return float64(n), nil
+	case uint:
+		return float64(n), nil
+	case uint8:
+		return float64(n), nil
+	case uint16:
+		return float64(n), nil
+	case uint32:
+		return float64(n), nil
+	case uint64:
+		return float64(n), nil
+	case json.Number:
+		return n.Float64()
+	case string:
+		return strconv.ParseFloat(strings.TrimSpace(n), 64)
+	case nil:
+		return 0, nil
+	}
+	return 0, fmt.Errorf("unsupported number type %T", v)
+}
+
+// FormattersJS returns a JavaScript bundle implementing formatNumber, formatDate,
+// humanizeTime and humanizeBytes with the engine's format settings (see WithFormatConfig),
+// so values updated client-side render like server-rendered ones. The functions are exposed
+// as window.templatex.formatNumber(value, decimals), formatDate(date, layout),
+// humanizeTime(date) and humanizeBytes(size). Dates accept Date objects, timestamps and ISO strings.
+//
+// Date layouts use Go syntax; the following elements are supported on the client:
+// 2006, 06, January, Jan, 01, 1, 02, 2, _2, 15, 03, 3, 04, 4, 05, 5, PM, pm, Monday, Mon.
+func (e *Engine) FormattersJS() string {
+	cfg := e.formatConfig
+	zone := ""
+	if cfg.Location != nil && cfg.Location != time.Local {
+		zone = cfg.Location.String()
+	}
+	settings, _ := json.Marshal(map[string]any{
+		"decimalSeparator":   cfg.DecimalSeparator,
+		"thousandsSeparator": cfg.ThousandsSeparator,
+		"decimals":           cfg.Decimals,
+		"dateLayout":         cfg.DateLayout,
+		"timeZone":           zone,
+	})
+	return strings.Replace(formattersJS, "/*CONFIG*/", string(settings), 1)
+}
+
+// formattersJS is the client-side implementation of the formatters.
+// It must be kept in sync with formatNumber, formatDate, humanizeTime and humanizeBytes.
+const formattersJS = `(function (global) {
+  "use strict";
+  var cfg = /*CONFIG*/;
+  var months = ["January", "February", "March", "April", "May", "June", "July", "August", "September", "October", "November", "December"];
+  var days = ["Sunday", "Monday", "Tuesday", "Wednesday", "Thursday", "Friday", "Saturday"];
+  var tokens = ["January", "Monday", "2006", "Jan", "Mon", "_2", "01", "02", "03", "04", "05", "06", "15", "PM", "pm", "1", "2", "3", "4", "5"];
+
+  function pad(n) { return n < 10 ? "0" + n : String(n); }
+
+  function formatNumber(value, decimals) {
+    var n = Number(value || 0);
+    var d = decimals === undefined ? cfg.decimals : Math.max(0, decimals);
+    var pow = Math.pow(10, d);
+    var rounded = Math.round(Math.abs(n) * pow) / pow;
+    var parts = rounded.toFixed(d).split(".");
+    var intPart = parts[0].replace(/\B(?=(\d{3})+(?!\d))/g, cfg.thousandsSeparator);
+    var s = (n < 0 && rounded !== 0 ? "-" : "") + intPart;
+    return parts.length > 1 ? s + cfg.decimalSeparator + parts[1] : s;
+  }
+
+  function dateParts(date) {
+    if (!cfg.timeZone) {
+      return { year: date.getFullYear(), month: date.getMonth() + 1, day: date.getDate(), hour: date.getHours(), minute: date.getMinutes(), second: date.getSeconds(), weekday: date.getDay() };
+    }
+    var p = {};
+    new Intl.DateTimeFormat("en-US", { timeZone: cfg.timeZone, hourCycle: "h23", year: "numeric", month: "numeric", day: "numeric", hour: "numeric", minute: "numeric", second: "numeric", weekday: "long" })
+      .formatToParts(date).forEach(function (part) { p[part.type] = part.value; });
+    return { year: +p.year, month: +p.month, day: +p.day, hour: +p.hour % 24, minute: +p.minute, second: +p.second, weekday: days.indexOf(p.weekday) };
+  }
+
+  function formatDate(value, layout) {
+    if (value === null || value === undefined || value === "") { return ""; }
+    var date = value instanceof Date ? value : new Date(value);
+    var p = dateParts(date);
+    var hour12 = p.hour % 12 === 0 ? 12 : p.hour % 12;
+    var values = {
+      "January": months[p.month - 1], "Jan": months[p.month - 1].slice(0, 3),
+      "Monday": days[p.weekday], "Mon": days[p.weekday].slice(0, 3),
+      "2006": String(p.year), "06": pad(p.year % 100),
+      "01": pad(p.month), "1": String(p.month),
+      "02": pad(p.day), "2": String(p.day), "_2": (p.day < 10 ? " " : "") + p.day,
+      "15": pad(p.hour), "03": pad(hour12), "3": String(hour12),
+      "04": pad(p.minute), "4": String(p.minute),
+      "05": pad(p.second), "5": String(p.second),
+      "PM": p.hour < 12 ? "AM" : "PM", "pm": p.hour < 12 ? "am" : "pm"
+    };
+    layout = layout || cfg.dateLayout;
+    var out = "";
+    for (var i = 0; i < layout.length;) {
+      var matched = false;
+      for (var j = 0; j < tokens.length; j++) {
+        if (layout.substr(i, tokens[j].length) === tokens[j]) {
+          out += values[tokens[j]];
+          i += tokens[j].length;
+          matched = true;
+          break;
+        }
+      }
+      if (!matched) { out += layout.charAt(i); i++; }
+    }
+    return out;
+  }
+
+  function humanizeTime(value) {
+    if (value === null || value === undefined || value === "") { return ""; }
+    var date = value instanceof Date ? value : new Date(value);
+    var diff = Date.now() - date.getTime();
+    var future = diff < 0;
+    if (future) { diff = -diff; }
+    var n, unit;
+    if (diff < 60e3) { return "just now"; }
+    else if (diff < 3600e3) { n = Math.floor(diff / 60e3); unit = "minute"; }
+    else if (diff < 86400e3) { n = Math.floor(diff / 3600e3); unit = "hour"; }
+    else if (diff < 30 * 86400e3) { n = Math.floor(diff / 86400e3); unit = "day"; }
+    else { return formatDate(date); }
+    if (n !== 1) { unit += "s"; }
+    return future ? "in " + n + " " + unit : n + " " + unit + " ago";
+  }
+
+  function humanizeBytes(value) {
+    var n = Number(value || 0);
+    var units = ["B", "KB", "MB", "GB", "TB", "PB"];
+    var i = 0;
+    while (Math.abs(n) >= 1024 && i < units.length - 1) { n /= 1024; i++; }
+    var d = i === 0 || Math.round(n * 10) === Math.round(n) * 10 ? 0 : 1;
+    return formatNumber(n, d) + " " + units[i];
+  }
+
+  global.templatex = global.templatex || {};
+  global.templatex.formatNumber = formatNumber;
+  global.templatex.formatDate = formatDate;
+  global.templatex.humanizeTime = humanizeTime;
+  global.templatex.humanizeBytes = humanizeBytes;
+})(typeof window !== "undefined" ? window : this);
+`
@@ -0,0 +1,358 @@
+package templatex
+
+import (
+	"context"
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/url"
+	"slices"
+	"sort"
+	"strings"
+)
+
+var (
+	csrfTokenKey = &contextKey{"csrf-token"}
+	formStateKey = &contextKey{"form-state"}
+)
+
+// defaultCSRFField is the name of the form field holding the CSRF token
+const defaultCSRFField = "csrf_token"
+
+// methodField is the form field carrying the method of forms submitted with
+// methods other than GET and POST, which browsers don't support
+const methodField = "_method"
+
+// FormState is the state of a submitted form: the submitted values, which the field
+// component and the oldInput function repopulate the fields with, and the validation
+// errors, which the form and field components display.
+type FormState struct {
+	Values url.Values          // submitted values by field name
+	Errors map[string][]string // error messages by field name; errors of the whole form use an empty name
+}
+
+// WithFormState returns a copy of ctx that carries the state of a submitted form
+func WithFormState(ctx context.Context, s FormState) context.Context {
+	return context.WithValue(ctx, formStateKey, s)
+}
+
+// FormStateFromContext returns the form state stored in ctx by WithFormState
+func FormStateFromContext(ctx context.Context) (FormState, bool) {
+	if ctx == nil {
+		return FormState{}, false
+	}
+	s, ok := ctx.Value(formStateKey).(FormState)
+	return s, ok
+}
+
+// String returns a string representation of the form state.
+// It's used as a part of the render cache key.
+func (s FormState) String() string {
+	var sb strings.Builder
+	sb.WriteString(s.Values.Encode())
+	keys := make([]string, 0, len(s.Errors))
+	for k := range s.Errors {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		sb.WriteByte(';')
+		sb.WriteString(url.QueryEscape(k))
+		sb.WriteByte('=')
+		for i, msg := range s.Errors[k] {
+			if i > 0 {
+				sb.WriteByte(',')
+			}
+			sb.WriteString(url.QueryEscape(msg))
+		}
+	}
+	return sb.String()
+}
+
+// WithCSRFToken returns a copy of ctx that carries the CSRF token of the request,
+// added to forms by the form component and the csrfField function
+func WithCSRFToken(ctx context.Context, token string) context.Context {
+	return context.WithValue(ctx, csrfTokenKey, token)
+}
+
+// CSRFToken returns the CSRF token stored in ctx by WithCSRFToken, or an empty string
+func CSRFToken(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	token, _ := ctx.Value(csrfTokenKey).(string)
+	return token
+}
+
+// Form is the props of the built-in form component, rendering a form with the CSRF
+// token, the errors of the whole form and the slot content:
+//
+//	{{ component "form" (props "Action" "/signup") }}
+//	  {{ component "field" (props "Name" "email" "Label" "Email" "Type" "email") }}
+//	  <button>Sign up</button>
+//	{{ endcomponent "form" }}
+//
+// Methods other than GET and POST are submitted as POST with a _method field.
+// With WithHTMXForms, the form is submitted by htmx to the action with the method.
+type Form struct {
+	Action  string // relative or http(s) URL
+	Method  string // POST if empty
+	Enctype string // e.g. multipart/form-data
+	ID      string
+	Class   string
+	Target  string // htmx target selector (hx-target)
+	Swap    string // htmx swap strategy (hx-swap)
+	Slot    template.HTML
+}
+
+// FieldOption is an option of a select field
+type FieldOption struct {
+	Value string
+	Label string
+}
+
+// Field is the props of the built-in field component, rendering a labelled input,
+// textarea, select or checkbox with its submitted value and validation errors
+// (see WithFormState):
+//
+//	{{ component "field" (props "Name" "email" "Label" "Email" "Type" "email" "Required" true) }}
+//
+// Submitted values take precedence over Value, except for password and file inputs,
+// which are never repopulated.
+type Field struct {
+	Name        string `prop:"required"`
+	Label       string
+	Type        string // input type, or "textarea" or "select"; "text" if empty
+	Value       string // initial value; the value of a checked checkbox, "on" if empty
+	Checked     bool   // initial state of a checkbox
+	Options     []FieldOption
+	Placeholder string
+	Hint        string // help text below the field
+	Required    bool
+	ID          string // id of the control; derived from the name if empty
+	Class       string // class of the wrapping element
+}
+
+// formFuncs returns the form functions bound to the CSRF token and the form state
+// stored in ctx:
+//   - csrfToken returns the CSRF token
+//   - csrfField renders a hidden input with the CSRF token
+//   - oldInput returns the submitted value of a field
+//   - fieldErrors returns the error messages of a field
+func (e *Engine) formFuncs(ctx context.Context) template.FuncMap {
+	state, _ := FormStateFromContext(ctx)
+	return template.FuncMap{
+		"csrfToken": func() string { return CSRFToken(ctx) },
+		"csrfField": func() template.HTML { return template.HTML(e.csrfInput(ctx)) },
+		"oldInput":  func(name string) string { return state.Values.Get(name) },
+		"fieldErrors": func(name string) []string {
+			return state.Errors[name]
+		},
+	}
+}
+
+// csrfInput returns the hidden input with the CSRF token, or an empty string
+// if ctx doesn't carry a token
+func (e *Engine) csrfInput(ctx context.Context) string {
+	token := CSRFToken(ctx)
+	if token == "" {
+		return ""
+	}
+	name := e.csrfField
+	if name == "" {
+		name = defaultCSRFField
+	}
+	return `<input type="hidden" name="` + template.HTMLEscapeString(name) + `" value="` + template.HTMLEscapeString(token) + `">`
+}
+
+// renderForm renders the form component
+func (e *Engine) renderForm(ctx context.Context, props map[string]any) (template.HTML, error) {
+	var f Form
+	if err := decodeProps(props, &f); err != nil {
+		return "", fmt.Errorf("form: %w", err)
+	}
+	method := strings.ToUpper(f.Method)
+	if method == "" {
+		method = http.MethodPost
+	}
+	formMethod := method
+	switch method {
+	case http.MethodGet, http.MethodPost:
+	case http.MethodPut, http.MethodPatch, http.MethodDelete:
+		formMethod = http.MethodPost
+	default:
+		return "", fmt.Errorf("form: unsupported method %q", f.Method)
+	}
+	// Actions like javascript: URLs would run scripts on submit
+	if !safeURL("action", f.Action) {
+		return "", fmt.Errorf("form: unsafe action %q", f.Action)
+	}
+
+	var sb strings.Builder
+	sb.WriteString(`<form action="` + template.HTMLEscapeString(f.Action) + `" method="` + strings.ToLower(formMethod) + `"`)
+	writeAttr(&sb, "enctype", f.Enctype)
+	writeAttr(&sb, "id", f.ID)
+	writeClass(&sb, f.Class)
+	if e.htmxForms {
+		writeAttr(&sb, "hx-"+strings.ToLower(method), f.Action)
+		writeAttr(&sb, "hx-target", f.Target)
+		writeAttr(&sb, "hx-swap", f.Swap)
+	}
+	sb.WriteString(`>`)
+	if method != http.MethodGet {
+		sb.WriteString(e.csrfInput(ctx))
+	}
+	if formMethod != method {
+		sb.WriteString(`<input type="hidden" name="` + methodField + `" value="` + method + `">`)
+	}
+	if state, ok := FormStateFromContext(ctx); ok && len(state.Errors[""]) > 0 {
+		sb.WriteString(`<div class="form-errors" role="alert">`)
+		writeErrors(&sb, state.Errors[""])
+		sb.WriteString(`</div>`)
+	}
+	sb.WriteString(string(f.Slot))
+	sb.WriteString(`</form>`)
+	return template.HTML(sb.String()), nil
+}
+
+// renderField renders the field component
+func (e *Engine) renderField(ctx context.Context, props map[string]any) (template.HTML, error) {
+	var f Field
+	if err := decodeProps(props, &f); err != nil {
+		return "", fmt.Errorf("field: %w", err)
+	}
+	if f.Type == "" {
+		f.Type = "text"
+	}
+	if f.ID == "" {
+		f.ID = fieldID(f.Name)
+	}
+
+	state, submitted := FormStateFromContext(ctx)
+	errs := state.Errors[f.Name]
+	value, checked := f.Value, f.Checked
+	if submitted && state.Values != nil && f.Type != "password" && f.Type != "file" {
+		switch values, ok := state.Values[f.Name]; {
+		case f.Type == "checkbox":
+			on := f.Value
+			if on == "" {
+				on = "on"
+			}
+			checked = slices.Contains(values, on)
+		case ok:
+			value = state.Values.Get(f.Name)
+		}
+	}
+
+	// Controls are described by the hint and the errors
+	var describedBy []string
+	if f.Hint != "" {
+		describedBy = append(describedBy, f.ID+"-hint")
+	}
+	if len(errs) > 0 {
+		describedBy = append(describedBy, f.ID+"-error")
+	}
+	attrs := func(sb *strings.Builder) {
+		sb.WriteString(` id="` + template.HTMLEscapeString(f.ID) + `" name="` + template.HTMLEscapeString(f.Name) + `"`)
+		if f.Required {
+			sb.WriteString(` required`)
+		}
+		if len(errs) > 0 {
+			sb.WriteString(` aria-invalid="true"`)
+		}
+		writeAttr(sb, "aria-describedby", strings.Join(describedBy, " "))
+	}
+	label := func(sb *strings.Builder) {
+		if f.Label != "" {
+			sb.WriteString(`<label for="` + template.HTMLEscapeString(f.ID) + `">` + template.HTMLEscapeString(f.Label) + `</label>`)
+		}
+	}
+
+	var sb strings.Builder
+	class := strings.TrimSpace("field " + f.Class)
+	if len(errs) > 0 {
+		class += " has-error"
+	}
+	sb.WriteString(`<div`)
+	writeClass(&sb, class)
+	sb.WriteString(`>`)
+	switch f.Type {
+	case "textarea":
+		label(&sb)
+		sb.WriteString(`<textarea`)
+		attrs(&sb)
+		writeAttr(&sb, "placeholder", f.Placeholder)
+		sb.WriteString(`>` + template.HTMLEscapeString(value) + `</textarea>`)
+	case "select":
+		label(&sb)
+		sb.WriteString(`<select`)
+		attrs(&sb)
+		sb.WriteString(`>`)
+		if f.Placeholder != "" {
+			sb.WriteString(`<option value="">` + template.HTMLEscapeString(f.Placeholder) + `</option>`)
+		}
+		for _, o := range f.Options {
+			sb.WriteString(`<option value="` + template.HTMLEscapeString(o.Value) + `"`)
+			if o.Value == value {
+				sb.WriteString(` selected`)
+			}
+			sb.WriteString(`>` + template.HTMLEscapeString(o.Label) + `</option>`)
+		}
+		sb.WriteString(`</select>`)
+	case "checkbox":
+		// Checkboxes are followed by their labels
+		sb.WriteString(`<input type="checkbox"`)
+		attrs(&sb)
+		writeAttr(&sb, "value", f.Value)
+		if checked {
+			sb.WriteString(` checked`)
+		}
+		sb.WriteString(`>`)
+		label(&sb)
+	default:
+		label(&sb)
+		sb.WriteString(`<input type="` + template.HTMLEscapeString(f.Type) + `"`)
+		attrs(&sb)
+		if f.Type != "password" && f.Type != "file" {
+			writeAttr(&sb, "value", value)
+		}
+		writeAttr(&sb, "placeholder", f.Placeholder)
+		sb.WriteString(`>`)
+	}
+	if f.Hint != "" {
+		sb.WriteString(`<p class="field-hint" id="` + template.HTMLEscapeString(f.ID) + `-hint">` + template.HTMLEscapeString(f.Hint) + `</p>`)
+	}
+	if len(errs) > 0 {
+		sb.WriteString(`<div class="field-errors" id="` + template.HTMLEscapeString(f.ID) + `-error">`)
+		writeErrors(&sb, errs)
+		sb.WriteString(`</div>`)
+	}
+	sb.WriteString(`</div>`)
+	return template.HTML(sb.String()), nil
+}
+
+// fieldID returns the id of the control of the named field, e.g. "field-address-city"
+// for "address[city]"
+func fieldID(name string) string {
+	id := strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_' || r == '-' {
+			return r
+		}
+		return '-'
+	}, name)
+	return "field-" + strings.Trim(id, "-")
+}
+
+// writeErrors writes the error messages as paragraphs
+func writeErrors(sb *strings.Builder, errs []string) {
+	for _, msg := range errs {
+		sb.WriteString(`<p class="error">` + template.HTMLEscapeString(msg) + `</p>`)
+	}
+}
+
+// writeAttr writes the attribute, unless its value is empty
+func writeAttr(sb *strings.Builder, name, value string) {
+	if value != "" {
+		sb.WriteString(` ` + name + `="` + template.HTMLEscapeString(value) + `"`)
+	}
+}
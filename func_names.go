@@ -0,0 +1,84 @@
+package templatex
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// contextFuncNames are functions bound to each render. They are replaced on every
+// render, so custom functions with these names would never be called.
+var contextFuncNames = map[string]bool{
+	"embed": true, "T": true, "ctxVal": true, "isActive": true, "activeClass": true,
+	"breadcrumbs": true, "isPrint": true, "isLite": true, "authorized": true, "consent": true,
+	"compareLocale": true, "sortLocale": true, "formatUnit": true, "readingTime": true, "calendarMonth": true,
+	"absURL": true, "canonical": true, "wizardStep": true, "isCurrentStep": true, "stepURL": true,
+	"setTitle": true, "appendTitle": true, "pageTitle": true, "setMeta": true, "meta": true,
+	"metaTags": true, "setRobots": true, "robotsMeta": true, "push": true, "stack": true, "once": true,
+	"yield": true, "hasSection": true, "component": true, "componentSlot": true,
+	"csrfToken": true, "csrfField": true, "oldInput": true, "fieldErrors": true,
+}
+
+// builtinFuncNames returns the names of all built-in template functions
+func builtinFuncNames() map[string]bool {
+	names := make(map[string]bool)
+	for name := range defaultFuncs() {
+		names[name] = true
+	}
+	names["vars"] = true
+	names["isPublished"] = true
+	names["signedURL"] = true
+	for name := range envFuncs("") {
+		names[name] = true
+	}
+	for name := range timeFuncs(time.Now) {
+		names[name] = true
+	}
+	for name := range formatFuncs(defaultFormatConfig(), time.Now) {
+		names[name] = true
+	}
+	for name := range fakeFuncs(false) {
+		names[name] = true
+	}
+	for name := range newDebugDumper(nil).funcs() {
+		names[name] = true
+	}
+	return names
+}
+
+// checkCustomFuncs returns an error if a custom function overrides a render-bound
+// function, or a built-in function not allowed by WithFuncOverride
+func (e *Engine) checkCustomFuncs() error {
+	builtins := builtinFuncNames()
+	if e.markdownFn != nil {
+		builtins["markdown"] = true
+	}
+	var reserved, overridden []string
+	for name := range e.customFuncs {
+		switch {
+		case contextFuncNames[name]:
+			reserved = append(reserved, name)
+		case builtins[name] && !e.funcOverrides[name]:
+			overridden = append(overridden, name)
+		}
+	}
+	if len(reserved) > 0 {
+		sort.Strings(reserved)
+		return fmt.Errorf("%w: %s", ErrReservedFuncName, strings.Join(reserved, ", "))
+	}
+	if len(overridden) > 0 {
+		sort.Strings(overridden)
+		return fmt.Errorf("%w: %s (use WithFuncOverride to replace them)", ErrBuiltinFuncOverride, strings.Join(overridden, ", "))
+	}
+	return nil
+}
+
+// setBuiltinFunc adds an engine-bound built-in function to the function map,
+// unless it's replaced by a custom function (see WithFuncOverride)
+func (e *Engine) setBuiltinFunc(name string, fn any) {
+	if e.customFuncs[name] {
+		return
+	}
+	e.funcMap[name] = fn
+}
@@ -0,0 +1,47 @@
+package templatex
+
+import (
+	"errors"
+	"fmt"
+	"html/template"
+)
+
+// FuncPack is an optional bundle of template functions, e.g. markdown rendering
+// or QR codes. Packs with heavy dependencies live in their own packages and are
+// opted into with WithFuncPacks, so they don't bloat the core dependency graph.
+type FuncPack interface {
+	// Name returns the pack name used in errors and the configuration report
+	Name() string
+	// Funcs returns the template functions of the pack
+	Funcs() template.FuncMap
+	// Init is called once by New and NewFS after the options are applied and
+	// before the templates are parsed, e.g. to read the engine configuration
+	Init(*Engine) error
+}
+
+// initFuncPacks initializes the function packs and adds their functions to the
+// function map. Functions set by WithFunc or WithFuncs take precedence over pack
+// functions, and pack functions replacing built-in ones require WithFuncOverride.
+// It returns an error if a pack fails to initialize or packs define the same function.
+func (e *Engine) initFuncPacks() error {
+	owners := make(map[string]string)
+	for _, pack := range e.funcPacks {
+		if err := pack.Init(e); err != nil {
+			return errors.Join(ErrFuncPackFailed, fmt.Errorf("pack %s: %w", pack.Name(), err))
+		}
+		for name, fn := range pack.Funcs() {
+			if owner, ok := owners[name]; ok {
+				return errors.Join(ErrFuncPackFailed, fmt.Errorf("function %s is defined by packs %s and %s", name, owner, pack.Name()))
+			}
+			owners[name] = pack.Name()
+			if e.customFuncs[name] {
+				continue
+			}
+			e.funcMap[name] = fn
+		}
+	}
+	for name := range owners {
+		e.customFuncs[name] = true
+	}
+	return nil
+}
@@ -1,11 +1,16 @@
 package templatex
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"html/template"
+	"io"
+	"maps"
 	"reflect"
+	"strconv"
 	"strings"
 
 	"github.com/invopop/ctxi18n"
@@ -54,34 +59,103 @@ func defaultFuncs() template.FuncMap {
 		"repeat": func(s string, count int) string {
 			return strings.Repeat(s, count)
 		},
-		"len": func(v interface{}) int {
-			switch val := v.(type) {
-			case string:
-				return len(val)
-			case []interface{}:
-				return len(val)
-			case map[string]interface{}:
-				return len(val)
-			default:
-				return 0
-			}
-		},
-		"htmlSafe": func(html string) template.HTML {
-			return template.HTML(html)
-		},
-		"default":      defaultValue,
-		"safeField":    safeField,
-		"debug":        prettyPrint,
-		"isset":        func(v interface{}) bool { return v != nil },
-		"boolToString": func(b bool) string { return fmt.Sprintf("%t", b) },
-		"printIf":      printIf,
-		"printIfElse":  printIfElse,
+		"len":            length,
+		"htmlSafe":       htmlSafe,
+		"sanitize":       sanitizeHTML,
+		"default":        defaultValue,
+		"safeField":      safeField,
+		"getPath":        getPath,
+		"haveKey":        haveKey,
+		"dig":            dig,
+		"setPath":        setPath,
+		"jsonGet":        jsonGet,
+		"debug":          prettyPrint,
+		"isset":          func(v interface{}) bool { return v != nil },
+		"boolToString":   func(b bool) string { return fmt.Sprintf("%t", b) },
+		"printIf":        printIf,
+		"printIfElse":    printIfElse,
+		"pageBreak":      pageBreak,
+		"printHeader":    printHeader,
+		"printFooter":    printFooter,
+		"formatPhone":    formatPhone,
+		"diffWords":      diffWords,
+		"diffLines":      diffLines,
+		"wordCount":      wordCount,
+		"shareURL":       shareURL,
+		"obfuscateEmail": obfuscateEmail,
+		"maskString":     maskString,
+		"formatAddress":  formatAddress(addressFormats),
+		"props":          props,
+		"acceptTypes":    acceptTypes,
 
 		// Placeholders for context-related functions.
 		// These should be replaced with actual functions in your application
-		"embed":  func() template.HTML { return "" },                  // placeholder function
-		"T":      func(key string, args ...any) string { return key }, // placeholder function with variadic args
-		"ctxVal": func(key string) string { return "" },
+		"embed":         func() template.HTML { return "" },                  // placeholder function
+		"T":             func(key string, args ...any) string { return key }, // placeholder function with variadic args
+		"ctxVal":        func(key string) string { return "" },
+		"isActive":      func(path string) bool { return false },
+		"activeClass":   func(path, class string) string { return "" },
+		"breadcrumbs":   func() template.HTML { return "" },
+		"isPrint":       func() bool { return false },
+		"isLite":        func() bool { return false },
+		"authorized":    func(permission string) bool { return false },
+		"consent":       func(category string) bool { return false },
+		"compareLocale": func(a, b string) int { return strings.Compare(a, b) },
+		"sortLocale":    func(list any, field ...string) (any, error) { return list, nil },
+		"formatUnit":    formatUnit(context.Background(), defaultFormatConfig()),
+		"readingTime":   readingTime(context.Background(), readingSpeeds),
+		"calendarMonth": func(year int, month any) (CalendarMonth, error) { return CalendarMonth{}, nil },
+		"absURL":        func(path any) (string, error) { return fmt.Sprint(path), nil },
+		"canonical":     func() (string, error) { return "", nil },
+		"wizardStep":    func() *WizardProgress { return nil },
+		"isCurrentStep": func(name string) bool { return false },
+		"stepURL":       func(name string) string { return "" },
+		"csrfToken":     func() string { return "" },
+		"csrfField":     func() template.HTML { return "" },
+		"oldInput":      func(name string) string { return "" },
+		"fieldErrors":   func(name string) []string { return nil },
+
+		// Placeholders for render state functions.
+		// These are replaced with functions bound to the current render.
+		"setTitle":    func(title string) string { return "" },
+		"appendTitle": func(title string) string { return "" },
+		"pageTitle":   func(sep ...string) string { return "" },
+		"setMeta":     func(name, content string) string { return "" },
+		"meta":        func(name string) string { return "" },
+		"metaTags":    func() template.HTML { return "" },
+		"setRobots":   func(directives ...string) string { return "" },
+		"robotsMeta":  func() template.HTML { return "" },
+		"push":        func(name string, content any) (string, error) { return "", nil },
+		"stack":       func(name string) template.HTML { return "" },
+		"once":        func(name string) bool { return true },
+		"yield":       func(section string) (template.HTML, error) { return "", nil },
+		"hasSection":  func(section string) bool { return false },
+
+		// Placeholders for component functions, bound to the current render
+		"component": func(name string, props ...map[string]any) (template.HTML, error) { return "", nil },
+		"componentSlot": func(slot string, dot any, name string, props ...map[string]any) (template.HTML, error) {
+			return "", nil
+		},
+	}
+}
+
+// templateVars returns a function that returns a copy of the site-wide template
+// variables, so templates modifying the map, e.g. with setPath, don't change the
+// variables of other renders.
+// Usage: {{ vars.SupportEmail }}
+func templateVars(vars map[string]any) func() map[string]any {
+	return func() map[string]any {
+		return maps.Clone(vars)
+	}
+}
+
+// envFuncs returns functions to check the environment the engine runs in.
+// Usage: {{ env }}, {{ if isProduction }}...{{ end }}, {{ if isDevelopment }}...{{ end }}
+func envFuncs(env string) template.FuncMap {
+	return template.FuncMap{
+		"env":           func() string { return env },
+		"isProduction":  func() bool { return env == EnvProduction },
+		"isDevelopment": func() bool { return env == EnvDevelopment },
 	}
 }
 
@@ -121,14 +195,57 @@ func ctxValue(ctx context.Context) func(key string) string {
 	}
 }
 
-// safeField returns the value of a field from a struct if it exists and is accessible
-func safeField(data interface{}, field string, fallback ...string) string {
-	v := reflect.ValueOf(data)
-	if v.Kind() == reflect.Struct {
-		f := v.FieldByName(field)
-		if f.IsValid() && f.CanInterface() {
-			return f.Interface().(string)
+// isActive returns a function that reports whether the given path matches the
+// request path stored in the context. A path matches if it's equal to the request
+// path or is a parent segment of it, e.g. "/settings" matches "/settings/profile".
+// The root path "/" only matches itself.
+// Usage: {{ if isActive "/settings" }}...{{ end }}
+func isActive(ctx context.Context) func(path string) bool {
+	current := RequestPath(ctx)
+	return func(path string) bool {
+		return pathMatches(current, path)
+	}
+}
+
+// activeClass returns a function that returns the given class name if the path
+// is active (see isActive), otherwise it returns an empty string.
+// Usage: <a href="/settings" class="{{ activeClass "/settings" "active" }}">Settings</a>
+func activeClass(ctx context.Context) func(path, class string) string {
+	current := RequestPath(ctx)
+	return func(path, class string) string {
+		if pathMatches(current, path) {
+			return class
 		}
+		return ""
+	}
+}
+
+// pathMatches reports whether target is equal to current or is a parent of it
+func pathMatches(current, target string) bool {
+	if current == "" || target == "" {
+		return false
+	}
+	current = strings.TrimSuffix(current, "/")
+	target = strings.TrimSuffix(target, "/")
+	if current == target {
+		return true
+	}
+	if target == "" {
+		// root path only matches itself
+		return false
+	}
+	return strings.HasPrefix(current, target+"/")
+}
+
+// safeField returns the value at the given path in data if it exists and is accessible.
+// The path is a dot-separated list of struct field names, map keys or slice indexes,
+// e.g. "User.Profile.Name" or "Items.0.Title". Pointers, interfaces and embedded
+// structs are traversed safely. If the path can't be resolved, the fallback value
+// is returned, or an empty string if no fallback is provided.
+// Usage: {{ safeField . "User.Profile.Name" "Anonymous" }}
+func safeField(data interface{}, path string, fallback ...interface{}) interface{} {
+	if v, ok := lookupPath(data, path); ok {
+		return v
 	}
 	if len(fallback) > 0 {
 		return fallback[0]
@@ -136,6 +253,219 @@ func safeField(data interface{}, field string, fallback ...string) string {
 	return "" // Default if field doesn't exist or isn't accessible
 }
 
+// lookupPath resolves a dot-separated path in data.
+// It returns the value and true if the path exists and is accessible.
+func lookupPath(data interface{}, path string) (interface{}, bool) {
+	v := reflect.ValueOf(data)
+	if path != "" {
+		for _, key := range strings.Split(path, ".") {
+			var ok bool
+			if v, ok = lookupKey(v, key); !ok {
+				return nil, false
+			}
+		}
+	}
+	v = indirect(v)
+	if !v.IsValid() || !v.CanInterface() {
+		return nil, false
+	}
+	return v.Interface(), true
+}
+
+// lookupKey returns the struct field, map value or slice element with the given key
+func lookupKey(v reflect.Value, key string) (reflect.Value, bool) {
+	v = indirect(v)
+	if !v.IsValid() {
+		return reflect.Value{}, false
+	}
+
+	switch v.Kind() {
+	case reflect.Struct:
+		sf, ok := v.Type().FieldByName(key)
+		if !ok || !sf.IsExported() {
+			return reflect.Value{}, false
+		}
+		// Fields promoted through nil embedded pointers are not found
+		f, err := v.FieldByIndexErr(sf.Index)
+		if err != nil || !f.CanInterface() {
+			return reflect.Value{}, false
+		}
+		return f, true
+	case reflect.Map:
+		k, ok := mapKey(v.Type().Key(), key)
+		if !ok {
+			return reflect.Value{}, false
+		}
+		val := v.MapIndex(k)
+		if !val.IsValid() {
+			return reflect.Value{}, false
+		}
+		return val, true
+	case reflect.Slice, reflect.Array:
+		i, err := strconv.Atoi(key)
+		if err != nil || i < 0 || i >= v.Len() {
+			return reflect.Value{}, false
+		}
+		return v.Index(i), true
+	}
+	return reflect.Value{}, false
+}
+
+// mapKey converts a string key to a value of the map key type
+func mapKey(t reflect.Type, key string) (reflect.Value, bool) {
+	switch t.Kind() {
+	case reflect.String:
+		return reflect.ValueOf(key).Convert(t), true
+	case reflect.Interface:
+		if reflect.TypeOf(key).Implements(t) {
+			return reflect.ValueOf(key), true
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if i, err := strconv.ParseInt(key, 10, 64); err == nil {
+			return reflect.ValueOf(i).Convert(t), true
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if i, err := strconv.ParseUint(key, 10, 64); err == nil {
+			return reflect.ValueOf(i).Convert(t), true
+		}
+	}
+	return reflect.Value{}, false
+}
+
+// indirect dereferences pointers and interfaces until it reaches a non-pointer value.
+// It returns an invalid value if a nil pointer or interface is found.
+func indirect(v reflect.Value) reflect.Value {
+	for v.IsValid() && (v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface) {
+		if v.IsNil() {
+			return reflect.Value{}
+		}
+		v = v.Elem()
+	}
+	return v
+}
+
+// getPath returns the value at the given dot-separated path in data,
+// or nil if the path can't be resolved (see safeField for the path syntax).
+// Usage: {{ getPath . "user.profile.name" }}
+func getPath(data interface{}, path string) interface{} {
+	v, _ := lookupPath(data, path)
+	return v
+}
+
+// haveKey reports whether the given dot-separated path exists in data.
+// Usage: {{ if haveKey . "user.profile" }}...{{ end }}
+func haveKey(data interface{}, path string) bool {
+	_, ok := lookupPath(data, path)
+	return ok
+}
+
+// dig traverses nested maps and structs by the given keys and returns the found
+// value, or the default value if any key is missing. The last argument is the data
+// to traverse, the one before it is the default value.
+// Usage: {{ dig "user" "role" "name" "guest" . }}
+func dig(args ...interface{}) (interface{}, error) {
+	if len(args) < 3 {
+		return nil, fmt.Errorf("dig requires at least 3 arguments, got %d", len(args))
+	}
+	data, def := args[len(args)-1], args[len(args)-2]
+	v := reflect.ValueOf(data)
+	for _, arg := range args[:len(args)-2] {
+		key, ok := arg.(string)
+		if !ok {
+			key = fmt.Sprint(arg)
+		}
+		if v, ok = lookupKey(v, key); !ok {
+			return def, nil
+		}
+	}
+	v = indirect(v)
+	if !v.IsValid() || !v.CanInterface() {
+		return def, nil
+	}
+	return v.Interface(), nil
+}
+
+// setPath sets the value at the given dot-separated path in a map[string]interface{},
+// creating intermediate maps as needed. It returns an empty string so it can be used
+// inline in templates.
+// Usage: {{ setPath $data "user.profile.name" "John" }}
+func setPath(data map[string]interface{}, path string, value interface{}) (string, error) {
+	if data == nil {
+		return "", fmt.Errorf("setPath: nil map")
+	}
+	keys := strings.Split(path, ".")
+	m := data
+	for _, key := range keys[:len(keys)-1] {
+		next, ok := m[key].(map[string]interface{})
+		if !ok {
+			if _, exists := m[key]; exists {
+				return "", fmt.Errorf("setPath: %q is not a map", key)
+			}
+			next = make(map[string]interface{})
+			m[key] = next
+		}
+		m = next
+	}
+	m[keys[len(keys)-1]] = value
+	return "", nil
+}
+
+// jsonGet decodes a JSON document and returns the value at the given path, or nil if
+// the path doesn't exist. The document can be a string, []byte or json.RawMessage.
+// Numbers are returned as json.Number to preserve their original representation.
+//
+// The path is a dot-separated list of keys, each selecting a member of an object by
+// its exact, case-sensitive name, or an element of an array by its zero-based decimal
+// index, e.g. "items.0.name". An empty path selects the whole document. Keys can't
+// contain dots and negative indexes aren't supported. Paths selecting keys of values
+// other than objects and arrays, members that don't exist and indexes out of range
+// resolve to nil. Invalid documents, including trailing data after the JSON value,
+// are errors.
+// Usage: {{ jsonGet .RawJSON "items.0.name" }}
+func jsonGet(doc interface{}, path string) (interface{}, error) {
+	var raw []byte
+	switch v := doc.(type) {
+	case string:
+		raw = []byte(v)
+	case []byte:
+		raw = v
+	case json.RawMessage:
+		raw = v
+	case nil:
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("jsonGet: unsupported document type %T", doc)
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	var data interface{}
+	if err := dec.Decode(&data); err != nil {
+		return nil, fmt.Errorf("jsonGet: %w", err)
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return nil, errors.New("jsonGet: unexpected data after the JSON value")
+	}
+
+	v, _ := lookupPath(data, path)
+	return v, nil
+}
+
+// length returns the length of a string, slice, array, map or channel,
+// dereferencing pointers if needed. It returns 0 for other types.
+// Usage: {{ len .Items }}
+func length(v interface{}) int {
+	val := indirect(reflect.ValueOf(v))
+	if !val.IsValid() {
+		return 0
+	}
+	switch val.Kind() {
+	case reflect.String, reflect.Slice, reflect.Array, reflect.Map, reflect.Chan:
+		return val.Len()
+	}
+	return 0
+}
+
 // defaultValue returns the default value if the value is nil, empty, or zero.
 // Usage: {{ .Value | default "default value" }}
 func defaultValue(defaultValue, value interface{}) interface{} {
@@ -144,14 +474,10 @@ func defaultValue(defaultValue, value interface{}) interface{} {
 		return defaultValue
 	}
 
-	v := reflect.ValueOf(value)
-
-	// Handle special case for pointer types
-	if v.Kind() == reflect.Ptr {
-		if v.IsNil() {
-			return defaultValue
-		}
-		v = v.Elem()
+	// Dereference pointers and interfaces
+	v := indirect(reflect.ValueOf(value))
+	if !v.IsValid() {
+		return defaultValue
 	}
 
 	// Check for zero/empty values based on type
@@ -180,10 +506,6 @@ func defaultValue(defaultValue, value interface{}) interface{} {
 		if v.Float() == 0 {
 			return defaultValue
 		}
-	case reflect.Interface:
-		if v.IsNil() {
-			return defaultValue
-		}
 	}
 	return value
 }
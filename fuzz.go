@@ -0,0 +1,44 @@
+package templatex
+
+import (
+	"context"
+	"encoding/json"
+)
+
+// FuzzRender decodes arbitrary bytes into a generic binding and renders the template
+// with the layouts, discarding the output. It's an entry point for go test -fuzz
+// harnesses looking for panics in template functions and helpers:
+//
+//	func FuzzTemplates(f *testing.F) {
+//		engine, _ := templatex.New("templates/")
+//		f.Add([]byte(`{"User":{"Name":"John"}}`))
+//		f.Fuzz(func(t *testing.T, data []byte) {
+//			_ = engine.FuzzRender("profile", data)
+//		})
+//	}
+//
+// Valid JSON is decoded into maps, slices and scalars, anything else is passed as a string.
+// Renders bypass the cache, so fuzzing doesn't grow it. Returns the render error, if any;
+// errors caused by unexpected data are expected and can be ignored by the harness.
+func (e *Engine) FuzzRender(name string, data []byte, layouts ...string) error {
+	if !e.initialized() {
+		return ErrTemplateEngineNotInitialized
+	}
+
+	var binding any
+	if err := json.Unmarshal(data, &binding); err != nil {
+		binding = string(data)
+	}
+
+	bindings := make([]LayoutBinding, len(layouts))
+	for i, layout := range layouts {
+		bindings[i] = LayoutBinding{Name: layout}
+	}
+
+	resolved, err := e.resolveLayouts(name, bindings)
+	if err != nil {
+		return err
+	}
+	_, _, err = e.execute(context.Background(), name, binding, resolved, false, nil)
+	return err
+}
@@ -0,0 +1,112 @@
+// Package ginadapter plugs a templatex engine into Gin as its HTML renderer:
+//
+//	r := gin.New()
+//	r.HTMLRender = ginadapter.New(engine, ginadapter.WithLocale("en"))
+//
+//	r.GET("/", func(c *gin.Context) {
+//		c.HTML(http.StatusOK, "greeter@app_layout@base_layout", ginadapter.Data(c, data))
+//	})
+//
+// Layouts are selected by the "name@layout@outer_layout" naming convention
+// (see templatex.Engine.SplitView).
+//
+// Gin doesn't pass the request to HTML renderers, so the binding has to be wrapped
+// with Data to render with the request context, e.g. to use the request locale in
+// translation functions. Unwrapped bindings are rendered with a context holding the
+// fallback locale (see WithLocale).
+package ginadapter
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/dmitrymomot/templatex"
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/render"
+	"github.com/invopop/ctxi18n"
+)
+
+// HTMLRender implements render.HTMLRender using a templatex engine
+type HTMLRender struct {
+	engine *templatex.Engine
+	locale string
+}
+
+var _ render.HTMLRender = (*HTMLRender)(nil)
+
+// Option configures HTMLRender
+type Option func(*HTMLRender)
+
+// WithLocale sets the locale bindings not wrapped with Data are rendered with.
+// The default locale of ctxi18n is used if the locale is not loaded.
+func WithLocale(locale string) Option {
+	return func(r *HTMLRender) {
+		r.locale = locale
+	}
+}
+
+// New creates a Gin HTML renderer for the engine
+func New(engine *templatex.Engine, opts ...Option) *HTMLRender {
+	r := &HTMLRender{engine: engine}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+// requestData is a binding carrying the request context
+type requestData struct {
+	ctx     context.Context
+	binding any
+}
+
+// Data wraps the binding, so it's rendered with the request context
+func Data(c *gin.Context, binding any) any {
+	return requestData{ctx: c.Request.Context(), binding: binding}
+}
+
+// Instance returns the renderer of the view
+func (r *HTMLRender) Instance(name string, data any) render.Render {
+	ctx, binding := r.context(), data
+	if d, ok := data.(requestData); ok {
+		ctx, binding = d.ctx, d.binding
+	}
+	view, layouts := r.engine.SplitView(name)
+	return &HTML{engine: r.engine, ctx: ctx, name: view, layouts: layouts, binding: binding}
+}
+
+// context returns the context bindings not wrapped with Data are rendered with
+func (r *HTMLRender) context() context.Context {
+	ctx := context.Background()
+	if r.locale == "" {
+		return ctx
+	}
+	if lctx, err := ctxi18n.WithLocale(ctx, r.locale); err == nil {
+		return lctx
+	}
+	return ctx
+}
+
+// HTML implements render.Render for a templatex view
+type HTML struct {
+	engine  *templatex.Engine
+	ctx     context.Context
+	name    string
+	layouts []string
+	binding any
+}
+
+var _ render.Render = (*HTML)(nil)
+
+// Render renders the view to the response
+func (h *HTML) Render(w http.ResponseWriter) error {
+	h.WriteContentType(w)
+	return h.engine.Render(h.ctx, w, h.name, h.binding, h.layouts...)
+}
+
+// WriteContentType sets the HTML content type
+func (h *HTML) WriteContentType(w http.ResponseWriter) {
+	if w.Header().Get("Content-Type") == "" {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	}
+}
@@ -0,0 +1,36 @@
+package ginadapter_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/dmitrymomot/templatex"
+	"github.com/dmitrymomot/templatex/ginadapter"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHTMLRender(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	engine, err := templatex.New("../example/templates/")
+	require.NoError(t, err)
+
+	r := gin.New()
+	r.HTMLRender = ginadapter.New(engine)
+	r.GET("/", func(c *gin.Context) {
+		c.HTML(http.StatusOK, "greeter@base_layout", gin.H{"Title": "Gin", "Username": "John"})
+	})
+	r.GET("/request", func(c *gin.Context) {
+		c.HTML(http.StatusOK, "greeter@base_layout", ginadapter.Data(c, gin.H{"Title": "Request", "Username": "Jane"}))
+	})
+
+	for path, expected := range map[string]string{"/": "<title>Gin</title>", "/request": "<title>Request</title>"} {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "text/html; charset=utf-8", rec.Header().Get("Content-Type"))
+		assert.Contains(t, rec.Body.String(), expected)
+	}
+}
@@ -0,0 +1,27 @@
+module github.com/dmitrymomot/templatex/goldmarkadapter
+
+go 1.22
+
+require (
+	github.com/dmitrymomot/templatex v1.0.0
+	github.com/stretchr/testify v1.9.0
+	github.com/yuin/goldmark v1.7.8
+)
+
+require (
+	github.com/cespare/xxhash/v2 v2.1.2 // indirect
+	github.com/davecgh/go-spew v1.1.1 // indirect
+	github.com/fsnotify/fsnotify v1.8.0 // indirect
+	github.com/invopop/ctxi18n v0.9.0 // indirect
+	github.com/invopop/yaml v0.3.1 // indirect
+	github.com/pmezard/go-difflib v1.0.0 // indirect
+	golang.org/x/net v0.33.0 // indirect
+	golang.org/x/sync v0.10.0 // indirect
+	golang.org/x/sys v0.28.0 // indirect
+	golang.org/x/text v0.21.0 // indirect
+	gopkg.in/yaml.v3 v3.0.1 // indirect
+)
+
+// The adapter is developed and tested against the templatex sources in the
+// repository; consumers get the release required above.
+replace github.com/dmitrymomot/templatex => ../
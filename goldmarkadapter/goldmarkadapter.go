@@ -0,0 +1,31 @@
+// Package goldmarkadapter converts Markdown templates and strings of a templatex engine
+// with goldmark:
+//
+//	engine, err := templatex.New("templates/",
+//		templatex.WithMarkdown(goldmarkadapter.New(goldmark.WithExtensions(extension.GFM))),
+//	)
+//
+//	{{ markdown .Post.Body }}
+//
+// Raw HTML in Markdown is dropped unless goldmark is configured with html.WithUnsafe,
+// which is only safe for trusted sources such as .md template files.
+package goldmarkadapter
+
+import (
+	"bytes"
+
+	"github.com/dmitrymomot/templatex"
+	"github.com/yuin/goldmark"
+)
+
+// New returns a Markdown converter using goldmark with the options
+func New(opts ...goldmark.Option) templatex.MarkdownFunc {
+	md := goldmark.New(opts...)
+	return func(source []byte) ([]byte, error) {
+		var buf bytes.Buffer
+		if err := md.Convert(source, &buf); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	}
+}
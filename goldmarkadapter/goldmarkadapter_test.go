@@ -0,0 +1,30 @@
+package goldmarkadapter_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/dmitrymomot/templatex"
+	"github.com/dmitrymomot/templatex/goldmarkadapter"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMarkdown(t *testing.T) {
+	engine, err := templatex.NewMemory(map[string]string{
+		"layout.gohtml": `<main>{{ embed }}</main>`,
+		"docs/intro.md": "---\ntitle: Intro\n---\n# Hello {{ .Name }}\n\nRead the [docs]({{ .URL }}) *now*.\n",
+		"post.gohtml":   `{{ markdown .Body }}`,
+	}, templatex.WithMarkdown(goldmarkadapter.New()))
+	require.NoError(t, err)
+
+	out, err := engine.RenderString(context.Background(), "docs/intro", map[string]string{"Name": "<Ann>", "URL": "/docs?a=1"}, "layout")
+	require.NoError(t, err)
+	assert.Equal(t, "<main><h1>Hello &lt;Ann&gt;</h1>\n<p>Read the <a href=\"/docs?a=1\">docs</a> <em>now</em>.</p>\n</main>", out)
+
+	// Raw HTML is dropped by default
+	out, err = engine.RenderString(context.Background(), "post", map[string]string{"Body": "**bold** <script>alert(1)</script>"})
+	require.NoError(t, err)
+	assert.Contains(t, out, "<strong>bold</strong>")
+	assert.NotContains(t, out, "<script>")
+}
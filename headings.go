@@ -0,0 +1,184 @@
+package templatex
+
+import (
+	"errors"
+	"html"
+	"io"
+	"strconv"
+	"strings"
+	"unicode"
+
+	nethtml "golang.org/x/net/html"
+)
+
+// HeadingAnchors configures the anchors added to headings (see WithHeadingAnchors)
+type HeadingAnchors struct {
+	Templates []string // template names or directories ("docs") to process; empty means all templates
+	MinLevel  int      // first heading level with an anchor, 2 by default
+	MaxLevel  int      // last heading level with an anchor, 4 by default
+	LinkText  string   // text of the anchor link, "#" by default
+	LinkClass string   // class of the anchor link, "anchor" by default
+}
+
+// withDefaults returns the configuration with default values set
+func (cfg HeadingAnchors) withDefaults() HeadingAnchors {
+	if cfg.MinLevel < 1 || cfg.MinLevel > 6 {
+		cfg.MinLevel = 2
+	}
+	if cfg.MaxLevel < cfg.MinLevel || cfg.MaxLevel > 6 {
+		cfg.MaxLevel = max(cfg.MinLevel, 4)
+	}
+	if cfg.LinkText == "" {
+		cfg.LinkText = "#"
+	}
+	if cfg.LinkClass == "" {
+		cfg.LinkClass = "anchor"
+	}
+	return cfg
+}
+
+// applies reports whether headings of the template get anchors
+func (cfg *HeadingAnchors) applies(name string) bool {
+	if cfg == nil {
+		return false
+	}
+	return len(cfg.Templates) == 0 || inTemplates(name, cfg.Templates)
+}
+
+// heading returns the level of the heading element, or 0 if it's not a heading
+// with an anchor
+func (cfg *HeadingAnchors) heading(tag []byte) int {
+	if len(tag) != 2 || tag[0] != 'h' || tag[1] < '1' || tag[1] > '6' {
+		return 0
+	}
+	level := int(tag[1] - '0')
+	if level < cfg.MinLevel || level > cfg.MaxLevel {
+		return 0
+	}
+	return level
+}
+
+// addHeadingAnchors adds slugified ids to the headings of the content and appends
+// a link to each heading pointing to its id. Existing ids are kept, and generated
+// ids don't collide with any id of the content, e.g. "setup", "setup-2". Headings
+// containing links get an id without an anchor link.
+func (cfg *HeadingAnchors) addHeadingAnchors(content string) (string, error) {
+	used := make(map[string]bool)
+	z := nethtml.NewTokenizer(strings.NewReader(content))
+	for {
+		tt := z.Next()
+		if tt == nethtml.ErrorToken {
+			break
+		}
+		if tt == nethtml.StartTagToken || tt == nethtml.SelfClosingTagToken {
+			if id, ok := tokenAttr(z, "id"); ok {
+				used[id] = true
+			}
+		}
+	}
+
+	var sb strings.Builder
+	sb.Grow(len(content) + len(content)/8)
+	z = nethtml.NewTokenizer(strings.NewReader(content))
+	for {
+		tt := z.Next()
+		if tt == nethtml.ErrorToken {
+			if err := z.Err(); !errors.Is(err, io.EOF) {
+				return "", err
+			}
+			return sb.String(), nil
+		}
+
+		name, _ := z.TagName()
+		if tt != nethtml.StartTagToken || cfg.heading(name) == 0 {
+			sb.Write(z.Raw())
+			continue
+		}
+		tag := string(name)
+		start := string(z.Raw())
+		id, hasID := tokenAttr(z, "id")
+
+		// Buffer the heading content until its end tag
+		var inner, text strings.Builder
+		hasLink := false
+		end := ""
+		for end == "" {
+			tt := z.Next()
+			if tt == nethtml.ErrorToken {
+				break
+			}
+			// Text unescapes the raw bytes in place, so they're copied first
+			raw := string(z.Raw())
+			n, _ := z.TagName()
+			switch {
+			case tt == nethtml.EndTagToken && string(n) == tag:
+				end = raw
+				continue
+			case tt == nethtml.TextToken:
+				text.Write(z.Text())
+			case tt == nethtml.StartTagToken && string(n) == "a":
+				hasLink = true
+			}
+			inner.WriteString(raw)
+		}
+
+		if !hasID {
+			id = uniqueSlug(slugify(text.String()), used)
+			start = strings.TrimSuffix(start, ">") + ` id="` + id + `">`
+		}
+		sb.WriteString(start)
+		sb.WriteString(inner.String())
+		if !hasLink {
+			sb.WriteString(` <a class="` + html.EscapeString(cfg.LinkClass) + `" href="#` + html.EscapeString(id) +
+				`" aria-label="` + html.EscapeString("Link to "+strings.TrimSpace(text.String())) + `">` +
+				html.EscapeString(cfg.LinkText) + `</a>`)
+		}
+		sb.WriteString(end)
+	}
+}
+
+// tokenAttr returns the value of the attribute of the current tag
+func tokenAttr(z *nethtml.Tokenizer, name string) (string, bool) {
+	for {
+		key, val, more := z.TagAttr()
+		if string(key) == name {
+			return string(val), true
+		}
+		if !more {
+			return "", false
+		}
+	}
+}
+
+// slugify converts the text to a lowercase id of letters, digits and dashes,
+// e.g. "Getting Started!" to "getting-started"
+func slugify(s string) string {
+	var sb strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(s) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if dash && sb.Len() > 0 {
+				sb.WriteByte('-')
+			}
+			sb.WriteRune(r)
+			dash = false
+			continue
+		}
+		dash = true
+	}
+	if sb.Len() == 0 {
+		return "section"
+	}
+	return sb.String()
+}
+
+// uniqueSlug returns the slug, or the slug with the lowest number suffix,
+// that isn't used yet, and marks it as used
+func uniqueSlug(slug string, used map[string]bool) string {
+	id := slug
+	for i := 2; used[id]; i++ {
+		id = slug + "-" + strconv.Itoa(i)
+	}
+	used[id] = true
+	return id
+}
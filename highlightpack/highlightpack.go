@@ -0,0 +1,143 @@
+// Package highlightpack provides a templatex function pack highlighting code blocks
+// with chroma, for docs and developer-facing tools rendered by the engine:
+//
+//	engine, err := templatex.New("templates/",
+//		templatex.WithFuncPacks(highlightpack.New(highlightpack.WithStyle("github"))),
+//	)
+//
+//	{{ highlight "go" .Code }}
+//
+// Code is highlighted with inline styles by default. With WithClasses, CSS classes
+// are emitted instead and the stylesheet is served from Pack.CSS.
+package highlightpack
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+
+	"github.com/alecthomas/chroma/v2"
+	chromahtml "github.com/alecthomas/chroma/v2/formatters/html"
+	"github.com/alecthomas/chroma/v2/lexers"
+	"github.com/alecthomas/chroma/v2/styles"
+	"github.com/dmitrymomot/templatex"
+)
+
+// DefaultStyle is the chroma style used if none is set
+const DefaultStyle = "github"
+
+// Pack implements templatex.FuncPack with the highlight function
+type Pack struct {
+	styleName   string
+	classes     bool
+	lineNumbers bool
+	tabWidth    int
+
+	style     *chroma.Style
+	formatter *chromahtml.Formatter
+}
+
+var _ templatex.FuncPack = (*Pack)(nil)
+
+// Option configures Pack
+type Option func(*Pack)
+
+// WithStyle sets the chroma style, e.g. "monokai" or "dracula".
+// Unknown styles fail engine creation with templatex.ErrFuncPackFailed.
+func WithStyle(name string) Option {
+	return func(p *Pack) {
+		p.styleName = name
+	}
+}
+
+// WithClasses emits CSS classes instead of inline styles (see Pack.CSS)
+func WithClasses(enabled bool) Option {
+	return func(p *Pack) {
+		p.classes = enabled
+	}
+}
+
+// WithLineNumbers prefixes highlighted lines with their numbers
+func WithLineNumbers(enabled bool) Option {
+	return func(p *Pack) {
+		p.lineNumbers = enabled
+	}
+}
+
+// WithTabWidth sets the number of spaces tabs are expanded to, 4 by default
+func WithTabWidth(width int) Option {
+	return func(p *Pack) {
+		if width > 0 {
+			p.tabWidth = width
+		}
+	}
+}
+
+// New creates the highlight function pack
+func New(opts ...Option) *Pack {
+	p := &Pack{styleName: DefaultStyle, tabWidth: 4}
+	for _, opt := range opts {
+		opt(p)
+	}
+	return p
+}
+
+// Name returns the pack name
+func (p *Pack) Name() string { return "highlight" }
+
+// Init resolves the style and creates the formatter
+func (p *Pack) Init(*templatex.Engine) error {
+	style, ok := styles.Registry[p.styleName]
+	if !ok {
+		return fmt.Errorf("unknown style %q", p.styleName)
+	}
+	p.style = style
+	p.formatter = chromahtml.New(
+		chromahtml.WithClasses(p.classes),
+		chromahtml.WithLineNumbers(p.lineNumbers),
+		chromahtml.TabWidth(p.tabWidth),
+	)
+	return nil
+}
+
+// Funcs returns the highlight function
+func (p *Pack) Funcs() template.FuncMap {
+	return template.FuncMap{"highlight": p.highlight}
+}
+
+// highlight returns the code highlighted for the language, wrapped in a pre element.
+// Unknown languages are rendered as plain, escaped text.
+// Usage: {{ highlight "go" .Code }}
+func (p *Pack) highlight(lang string, code any) (template.HTML, error) {
+	if p.formatter == nil {
+		return "", fmt.Errorf("highlight: pack is not initialized")
+	}
+
+	lexer := lexers.Get(lang)
+	if lexer == nil {
+		lexer = lexers.Fallback
+	}
+	it, err := chroma.Coalesce(lexer).Tokenise(nil, fmt.Sprint(code))
+	if err != nil {
+		return "", fmt.Errorf("highlight: %w", err)
+	}
+
+	var buf bytes.Buffer
+	if err := p.formatter.Format(&buf, p.style, it); err != nil {
+		return "", fmt.Errorf("highlight: %w", err)
+	}
+	return template.HTML(buf.String()), nil
+}
+
+// CSS returns the stylesheet of the style for code highlighted with classes
+// (see WithClasses). It must be called after the engine is created.
+func (p *Pack) CSS() (string, error) {
+	if p.formatter == nil {
+		return "", fmt.Errorf("highlight: pack is not initialized")
+	}
+	var buf bytes.Buffer
+	if err := p.formatter.WriteCSS(&buf, p.style); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
@@ -0,0 +1,56 @@
+package highlightpack_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/dmitrymomot/templatex"
+	"github.com/dmitrymomot/templatex/highlightpack"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHighlight(t *testing.T) {
+	sources := map[string]string{
+		"code.gohtml": `{{ highlight .Lang .Code }}`,
+	}
+
+	engine, err := templatex.NewMemory(sources, templatex.WithFuncPacks(highlightpack.New()))
+	require.NoError(t, err)
+	assert.Contains(t, engine.Config().FuncPacks, "highlight")
+
+	out, err := engine.RenderString(context.Background(), "code", map[string]string{"Lang": "go", "Code": `fmt.Println("<hi>")`})
+	require.NoError(t, err)
+	assert.Contains(t, out, "<pre")
+	assert.Contains(t, out, `style="`)
+	assert.Contains(t, out, "&lt;hi&gt;")
+	assert.NotContains(t, out, "<hi>")
+
+	// Unknown languages are rendered as plain text
+	out, err = engine.RenderString(context.Background(), "code", map[string]string{"Lang": "nope", "Code": "a < b"})
+	require.NoError(t, err)
+	assert.Contains(t, out, "a &lt; b")
+}
+
+func TestHighlightClasses(t *testing.T) {
+	pack := highlightpack.New(highlightpack.WithStyle("monokai"), highlightpack.WithClasses(true))
+	engine, err := templatex.NewMemory(map[string]string{
+		"code.gohtml": `{{ highlight "go" .Code }}`,
+	}, templatex.WithFuncPacks(pack))
+	require.NoError(t, err)
+
+	out, err := engine.RenderString(context.Background(), "code", map[string]string{"Code": "package main"})
+	require.NoError(t, err)
+	assert.Contains(t, out, `class="`)
+	assert.NotContains(t, out, `style="`)
+
+	css, err := pack.CSS()
+	require.NoError(t, err)
+	assert.Contains(t, css, ".chroma")
+}
+
+func TestUnknownStyle(t *testing.T) {
+	_, err := templatex.NewMemory(map[string]string{"code.gohtml": `{{ highlight "go" "" }}`},
+		templatex.WithFuncPacks(highlightpack.New(highlightpack.WithStyle("nope"))))
+	assert.ErrorIs(t, err, templatex.ErrFuncPackFailed)
+}
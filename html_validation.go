@@ -0,0 +1,212 @@
+package templatex
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// HTMLIssue describes a single problem found in the rendered HTML
+type HTMLIssue struct {
+	Template string // name of the template that produced the offending markup
+	Line     int    // line number in the rendered output
+	Message  string
+}
+
+// String returns a human-readable representation of the issue
+func (i HTMLIssue) String() string {
+	return fmt.Sprintf("%s: line %d: %s", i.Template, i.Line, i.Message)
+}
+
+// HTMLValidationError is returned by Render when HTML validation is enabled
+// and the rendered output contains invalid markup (see WithHTMLValidation).
+// It wraps ErrHTMLValidationFailed.
+type HTMLValidationError struct {
+	Issues []HTMLIssue
+}
+
+// Error returns all issues separated by a new line
+func (e *HTMLValidationError) Error() string {
+	lines := make([]string, len(e.Issues))
+	for i, issue := range e.Issues {
+		lines[i] = issue.String()
+	}
+	return ErrHTMLValidationFailed.Error() + ":\n" + strings.Join(lines, "\n")
+}
+
+// Unwrap allows checking the error with errors.Is(err, ErrHTMLValidationFailed)
+func (e *HTMLValidationError) Unwrap() error {
+	return ErrHTMLValidationFailed
+}
+
+// voidElements can't have content and never have an end tag
+var voidElements = map[string]bool{
+	"area": true, "base": true, "br": true, "col": true, "embed": true, "hr": true,
+	"img": true, "input": true, "link": true, "meta": true, "source": true,
+	"track": true, "wbr": true,
+}
+
+// optionalEndElements may omit their end tag
+var optionalEndElements = map[string]bool{
+	"html": true, "head": true, "body": true, "p": true, "li": true, "dt": true,
+	"dd": true, "option": true, "optgroup": true, "thead": true, "tbody": true,
+	"tfoot": true, "tr": true, "td": true, "th": true, "colgroup": true,
+	"caption": true, "rb": true, "rt": true, "rp": true,
+}
+
+// nonNestableElements must not contain themselves
+var nonNestableElements = map[string]bool{
+	"a": true, "form": true, "button": true, "label": true,
+}
+
+// sourceSegment maps a range of the rendered output to the template that produced it
+type sourceSegment struct {
+	start, end int
+	template   string
+}
+
+// sourceMap resolves output offsets to the templates that produced them.
+// Segments are ordered from the outermost template to the innermost one,
+// so the last matching segment is the most specific.
+type sourceMap []sourceSegment
+
+// templateAt returns the name of the template that produced the byte at the offset
+func (m sourceMap) templateAt(offset int) string {
+	name := ""
+	for _, s := range m {
+		if offset >= s.start && offset < s.end {
+			name = s.template
+		}
+	}
+	return name
+}
+
+// renderStage is the output of a single template in the render chain
+type renderStage struct {
+	template string
+	output   string
+	embedAt  int // offset of the embedded content in the output, -1 if not embedded
+}
+
+// buildSourceMap creates a source map of the final output from the render stages.
+// The first stage is the content template, followed by the layouts.
+func buildSourceMap(stages []renderStage) sourceMap {
+	m := make(sourceMap, 0, len(stages))
+	start := 0
+	for i := len(stages) - 1; i >= 0; i-- {
+		stage := stages[i]
+		m = append(m, sourceSegment{start: start, end: start + len(stage.output), template: stage.template})
+		if stage.embedAt < 0 {
+			break
+		}
+		start += stage.embedAt
+	}
+	return m
+}
+
+// validateHTML checks the rendered output for unclosed tags, unexpected end tags,
+// invalid nesting and duplicate IDs. It returns nil if no issues are found.
+func validateHTML(content string, sources sourceMap) error {
+	var issues []HTMLIssue
+	report := func(offset int, format string, args ...any) {
+		issues = append(issues, HTMLIssue{
+			Template: sources.templateAt(offset),
+			Line:     strings.Count(content[:offset], "\n") + 1,
+			Message:  fmt.Sprintf(format, args...),
+		})
+	}
+
+	type openElement struct {
+		name   string
+		offset int
+	}
+	var stack []openElement
+	ids := make(map[string]int)
+
+	z := html.NewTokenizer(strings.NewReader(content))
+	offset := 0
+	for {
+		tt := z.Next()
+		if tt == html.ErrorToken {
+			if err := z.Err(); err != nil && !errors.Is(err, io.EOF) {
+				report(offset, "tokenizer error: %v", err)
+			}
+			break
+		}
+		pos := offset
+		offset += len(z.Raw())
+
+		switch tt {
+		case html.StartTagToken, html.SelfClosingTagToken:
+			name, hasAttr := z.TagName()
+			tag := string(name)
+			for hasAttr {
+				var key, val []byte
+				key, val, hasAttr = z.TagAttr()
+				if string(key) != "id" {
+					continue
+				}
+				id := string(val)
+				if first, ok := ids[id]; ok {
+					report(pos, "duplicate id %q, first used on line %d", id, strings.Count(content[:first], "\n")+1)
+				} else {
+					ids[id] = pos
+				}
+			}
+			if tt == html.SelfClosingTagToken || voidElements[tag] {
+				continue
+			}
+			if nonNestableElements[tag] {
+				for _, el := range stack {
+					if el.name == tag {
+						report(pos, "<%s> must not be nested inside <%s>", tag, tag)
+						break
+					}
+				}
+			}
+			stack = append(stack, openElement{name: tag, offset: pos})
+		case html.EndTagToken:
+			name, _ := z.TagName()
+			tag := string(name)
+			if voidElements[tag] {
+				report(pos, "void element <%s> must not have an end tag", tag)
+				continue
+			}
+			// Find the matching open element
+			idx := -1
+			for i := len(stack) - 1; i >= 0; i-- {
+				if stack[i].name == tag {
+					idx = i
+					break
+				}
+			}
+			if idx < 0 {
+				report(pos, "unexpected end tag </%s>", tag)
+				continue
+			}
+			// Elements opened after the matching one must be closed first
+			for _, el := range stack[idx+1:] {
+				if !optionalEndElements[el.name] {
+					report(el.offset, "<%s> is not closed before </%s>", el.name, tag)
+				}
+			}
+			stack = stack[:idx]
+		}
+	}
+
+	for _, el := range stack {
+		if !optionalEndElements[el.name] {
+			report(el.offset, "unclosed <%s>", el.name)
+		}
+	}
+
+	if len(issues) == 0 {
+		return nil
+	}
+	sort.SliceStable(issues, func(i, j int) bool { return issues[i].Line < issues[j].Line })
+	return &HTMLValidationError{Issues: issues}
+}
@@ -0,0 +1,221 @@
+package templatex
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"strings"
+)
+
+// RequestIDHeader is the header the Middleware reads the correlation ID from
+const RequestIDHeader = "X-Request-ID"
+
+// Middleware stores request-scoped data used by template helpers in the request
+// context:
+//   - the request URL path, used by navigation helpers like isActive and activeClass
+//   - an empty breadcrumb trail, which handlers can fill using Breadcrumbs(ctx)
+//   - the request scheme and host, used by absURL and canonical without WithBaseURL
+//   - a random request ID, used to key archived renders, unless ctx already carries one
+//   - the X-Request-ID header as the correlation ID, if it's valid (see validRequestID)
+//
+// The request ID is generated on the server, so clients can't file renders under
+// the ID of another request.
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := WithRequestPath(r.Context(), r.URL.Path)
+		ctx = WithRequestBaseURL(ctx, requestBaseURL(r))
+		ctx = WithBreadcrumbs(ctx)
+		if RequestID(ctx) == "" {
+			ctx = WithRequestID(ctx, newRequestID())
+		}
+		if id := r.Header.Get(RequestIDHeader); validRequestID(id) {
+			ctx = WithCorrelationID(ctx, id)
+		}
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// maxRequestIDLen is the maximum length of request IDs taken from the request header
+const maxRequestIDLen = 128
+
+// validRequestID reports whether the client-supplied request ID is safe to use: up to
+// 128 ASCII letters, digits, dashes, underscores and dots, not starting with a dot
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLen || id[0] == '.' {
+		return false
+	}
+	for _, r := range id {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_' || r == '.') {
+			return false
+		}
+	}
+	return true
+}
+
+// newRequestID generates a random request ID
+func newRequestID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// RenderHTTP renders a template with optional layouts as an HTTP response.
+// It sets the Content-Type header (unless already set) and an ETag computed from
+// the rendered content. If the request's If-None-Match header matches the ETag,
+// it responds with 304 Not Modified and no body, so cached pages save bandwidth
+// as well as CPU. Responses to HEAD requests have no body.
+//
+// The request context is used for rendering. Nothing is written if rendering fails,
+// so the caller can still respond with an error page.
+func (e *Engine) RenderHTTP(w http.ResponseWriter, r *http.Request, name string, binding interface{}, layouts ...string) error {
+	buf := bufferPool.Get().(*bytes.Buffer)
+	buf.Reset()
+	defer bufferPool.Put(buf)
+
+	if err := e.Render(r.Context(), buf, name, binding, layouts...); err != nil {
+		return err
+	}
+
+	etag := `"` + generateCacheKey(false, "", "", name, buf.Bytes()) + `"`
+	h := w.Header()
+	h.Set("ETag", etag)
+	if h.Get("Content-Type") == "" {
+		h.Set("Content-Type", "text/html; charset=utf-8")
+	}
+
+	if etagMatches(r.Header.Get("If-None-Match"), etag) {
+		h.Del("Content-Type")
+		w.WriteHeader(http.StatusNotModified)
+		return nil
+	}
+
+	if r.Method == http.MethodHead {
+		w.WriteHeader(http.StatusOK)
+		return nil
+	}
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// etagMatches reports whether the If-None-Match header value matches the ETag.
+// Weak comparison is used, as required for If-None-Match.
+func etagMatches(header, etag string) bool {
+	if header == "" {
+		return false
+	}
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// Response is a builder for rendering a template as an HTTP response
+// with a custom status code and headers. Create it with Engine.Response.
+type Response struct {
+	engine  *Engine
+	name    string
+	binding interface{}
+	status  int
+	header  http.Header
+	layouts []string
+}
+
+// Response returns a response builder for the template and binding, e.g.
+//
+//	err := engine.Response("errors/404", data).Status(http.StatusNotFound).Layouts("base_layout").Write(ctx, w)
+//
+// The status defaults to 200 OK.
+func (e *Engine) Response(name string, binding interface{}) *Response {
+	return &Response{
+		engine:  e,
+		name:    name,
+		binding: binding,
+		status:  http.StatusOK,
+		header:  make(http.Header),
+	}
+}
+
+// Status sets the response status code
+func (r *Response) Status(code int) *Response {
+	r.status = code
+	return r
+}
+
+// Header adds a response header
+func (r *Response) Header(key, value string) *Response {
+	r.header.Add(key, value)
+	return r
+}
+
+// Layouts sets the layouts the template is rendered with
+func (r *Response) Layouts(layouts ...string) *Response {
+	r.layouts = layouts
+	return r
+}
+
+// Write renders the template and writes the headers, status code and body to w.
+// The Content-Type header defaults to HTML. Nothing is written if rendering fails,
+// so the caller can still respond with an error page.
+func (r *Response) Write(ctx context.Context, w http.ResponseWriter) error {
+	buf := bufferPool.Get().(*bytes.Buffer)
+	buf.Reset()
+	defer bufferPool.Put(buf)
+
+	if err := r.engine.Render(ctx, buf, r.name, r.binding, r.layouts...); err != nil {
+		return err
+	}
+
+	h := w.Header()
+	for key, values := range r.header {
+		h.Del(key)
+		for _, v := range values {
+			h.Add(key, v)
+		}
+	}
+	if h.Get("Content-Type") == "" {
+		h.Set("Content-Type", "text/html; charset=utf-8")
+	}
+	w.WriteHeader(r.status)
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// DataFunc provides the binding of a template rendered by a handler (see HandlerWithData)
+type DataFunc func(r *http.Request) (any, error)
+
+// Handler returns an HTTP handler rendering the template with the layouts on each
+// request, e.g. for static pages like about, terms or pricing:
+//
+//	mux.Handle("/about", engine.Handler("pages/about", "app_layout", "base_layout"))
+//
+// The response is written by RenderHTTP, so it supports ETags. Render errors are
+// logged and answered with 500 Internal Server Error.
+func (e *Engine) Handler(name string, layouts ...string) http.Handler {
+	return e.HandlerWithData(name, nil, layouts...)
+}
+
+// HandlerWithData returns an HTTP handler like Handler, with the binding provided
+// by the data func for each request. Data func errors are logged and answered with
+// 500 Internal Server Error.
+func (e *Engine) HandlerWithData(name string, data DataFunc, layouts ...string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var binding any
+		if data != nil {
+			var err error
+			if binding, err = data(r); err != nil {
+				e.logger.ErrorContext(r.Context(), "templatex: failed to get template data", "template", name, "error", err)
+				http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+				return
+			}
+		}
+		if err := e.RenderHTTP(w, r, name, binding, layouts...); err != nil {
+			e.logger.ErrorContext(r.Context(), "templatex: failed to render template", "template", name, "error", err)
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		}
+	})
+}
@@ -0,0 +1,205 @@
+package templatex
+
+import (
+	"errors"
+	"html/template"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// lazyFile is a template file indexed but not parsed yet (see WithLazyParsing)
+type lazyFile struct {
+	fsys     fs.FS
+	root     string
+	prefix   string
+	path     string
+	entry    fs.DirEntry
+	manifest sourceManifest
+	noDefine bool // the file is known to have no define blocks
+}
+
+// hasTemplateExt reports whether the file has one of the template extensions
+func (e *Engine) hasTemplateExt(file string) bool {
+	for _, ext := range e.exts {
+		if path.Ext(file) == ext {
+			return true
+		}
+	}
+	return false
+}
+
+// lazyKey returns the key of the template name in the lazy index
+func (e *Engine) lazyKey(name string) string {
+	name = normalizeTemplateName(name, e.exts)
+	if e.caseInsensitive {
+		return strings.ToLower(name)
+	}
+	return name
+}
+
+// indexFunc returns a walk function adding template files to the lazy index
+// by template name instead of parsing them
+func (e *Engine) indexFunc(index map[string]lazyFile, manifest sourceManifest, fsys fs.FS, root, prefix string) fs.WalkDirFunc {
+	return func(filePath string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || !e.hasTemplateExt(filePath) {
+			return err
+		}
+		relPath := relativePath(root, filePath)
+		name := prefix + strings.TrimSuffix(relPath, path.Ext(relPath))
+		index[e.lazyKey(name)] = lazyFile{fsys: fsys, root: root, prefix: prefix, path: filePath, entry: d, manifest: manifest}
+		return nil
+	}
+}
+
+// parseLazy parses the indexed files of the named templates into tmpl, together with
+// the templates they include. Names that aren't file templates make all remaining
+// files with define blocks parse, and versions of components (e.g. "ui.button@v2"
+// for "ui.button"). Parsed files are removed from the index.
+func (e *Engine) parseLazy(tmpl *template.Template, index map[string]lazyFile, modTime map[string]time.Time, schedules map[string]publishWindow, permissions map[string]string, noindex map[string]bool, extends map[string]string, names ...string) error {
+	pending := append([]string(nil), names...)
+	parsed := false
+	for len(pending) > 0 && len(index) > 0 {
+		name := pending[0]
+		pending = pending[1:]
+		if e.lookupIn(tmpl, nil, name) != nil {
+			continue
+		}
+
+		var files []lazyFile
+		if f, ok := index[e.lazyKey(name)]; ok {
+			files = []lazyFile{f}
+			delete(index, e.lazyKey(name))
+		} else {
+			matched, err := e.lazyDefinitions(index, name)
+			if err != nil {
+				return errors.Join(ErrTemplateParsingFailed, err)
+			}
+			files = matched
+		}
+
+		before := make(map[*template.Template]bool)
+		for _, t := range tmpl.Templates() {
+			before[t] = true
+		}
+		for _, f := range files {
+			walk := e.walkFunc(tmpl, modTime, schedules, permissions, noindex, extends, f.manifest, f.fsys, f.root, f.prefix)
+			if err := walk(f.path, f.entry, nil); err != nil {
+				return errors.Join(ErrTemplateParsingFailed, err)
+			}
+		}
+		parsed = true
+
+		// Parse the templates included by the new ones before they're executed
+		for _, t := range tmpl.Templates() {
+			if !before[t] && t.Tree != nil {
+				pending = append(pending, includedTemplates(t.Tree.Root)...)
+			}
+		}
+	}
+
+	if parsed {
+		if err := resolveComponentVersions(tmpl, e.namespaces); err != nil {
+			return errors.Join(ErrTemplateParsingFailed, err)
+		}
+	}
+	return nil
+}
+
+// lazyDefinitions removes the files that may define the template from the index and
+// returns them in a stable order: files with define blocks and component versions
+func (e *Engine) lazyDefinitions(index map[string]lazyFile, name string) ([]lazyFile, error) {
+	keys := make([]string, 0, len(index))
+	for key := range index {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	versions := e.lazyKey(name) + "@"
+	var files []lazyFile
+	for _, key := range keys {
+		f := index[key]
+		if !strings.HasPrefix(key, versions) {
+			if f.noDefine {
+				continue
+			}
+			content, err := fs.ReadFile(f.fsys, f.path)
+			if err != nil {
+				return nil, err
+			}
+			if !hasDefine(content) {
+				f.noDefine = true
+				index[key] = f
+				continue
+			}
+		}
+		files = append(files, f)
+		delete(index, key)
+	}
+	return files, nil
+}
+
+// ensureParsed parses the named templates if lazy parsing is enabled and
+// they haven't been parsed yet
+func (e *Engine) ensureParsed(names ...string) error {
+	e.mu.RLock()
+	pending := len(e.lazy) > 0
+	if pending {
+		pending = false
+		for _, name := range names {
+			if e.lookup(name) == nil {
+				pending = true
+				break
+			}
+		}
+	}
+	e.mu.RUnlock()
+	if !pending {
+		return nil
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.parseLazyLocked(names...)
+}
+
+// ensureAllParsed parses all templates not parsed yet, for features
+// inspecting the whole template set
+func (e *Engine) ensureAllParsed() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if len(e.lazy) == 0 {
+		return nil
+	}
+	names := make([]string, 0, len(e.lazy))
+	for _, f := range e.lazy {
+		relPath := relativePath(f.root, f.path)
+		names = append(names, f.prefix+strings.TrimSuffix(relPath, path.Ext(relPath)))
+	}
+	return e.parseLazyLocked(names...)
+}
+
+// parseLazyLocked parses the named templates into the engine template set and
+// updates the name index. The caller must hold the write lock.
+func (e *Engine) parseLazyLocked(names ...string) error {
+	if err := e.parseLazy(e.templates, e.lazy, e.modTime, e.schedules, e.permissions, e.noindex, e.extends, names...); err != nil {
+		return err
+	}
+	if e.caseInsensitive {
+		index, err := buildNameIndex(e.templates)
+		if err != nil {
+			return errors.Join(ErrTemplateParsingFailed, err)
+		}
+		e.names = index
+	}
+	return nil
+}
+
+// lazyIndexed reports whether the template file is indexed but not parsed yet.
+// The caller must hold the read lock.
+func (e *Engine) lazyIndexed(name string) bool {
+	_, ok := e.lazy[e.lazyKey(name)]
+	return ok
+}
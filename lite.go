@@ -0,0 +1,154 @@
+package templatex
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+)
+
+// renderModeLite is the render mode used by RenderLite
+const renderModeLite = "lite"
+
+// LiteOption configures RenderLite
+type LiteOption func(*liteConfig)
+
+// liteConfig holds the RenderLite settings
+type liteConfig struct {
+	layouts []string
+	assets  []assetDir
+	budget  int
+}
+
+// WithLiteLayouts sets the layouts wrapping the content in lite mode,
+// e.g. a minimal layout for email previews.
+func WithLiteLayouts(layouts ...string) LiteOption {
+	return func(c *liteConfig) {
+		c.layouts = layouts
+	}
+}
+
+// WithLiteAssets registers the files of fsys served under the URL prefix, e.g.
+// WithLiteAssets(os.DirFS("public"), "/static"). Stylesheets linked from the page
+// and found in fsys are inlined, so the page renders without extra requests.
+func WithLiteAssets(fsys fs.FS, prefix string) LiteOption {
+	return func(c *liteConfig) {
+		c.assets = append(c.assets, assetDir{fsys: fsys, prefix: prefix})
+	}
+}
+
+// WithSizeBudget limits the size of the lite output in bytes.
+// RenderLite returns a *SizeBudgetError if the output exceeds the budget.
+// Zero or a negative value disables the check.
+func WithSizeBudget(bytes int) LiteOption {
+	return func(c *liteConfig) {
+		c.budget = bytes
+	}
+}
+
+// SizeBudgetError is returned by RenderLite when the output exceeds the size budget
+// (see WithSizeBudget). It wraps ErrSizeBudgetExceeded.
+type SizeBudgetError struct {
+	Template string // name of the rendered template
+	Size     int    // output size in bytes
+	Budget   int    // size budget in bytes
+}
+
+// Error returns the template name, output size and budget
+func (e *SizeBudgetError) Error() string {
+	return fmt.Sprintf("%s: %s: %d bytes, budget is %d bytes", ErrSizeBudgetExceeded.Error(), e.Template, e.Size, e.Budget)
+}
+
+// Unwrap allows checking the error with errors.Is(err, ErrSizeBudgetExceeded)
+func (e *SizeBudgetError) Unwrap() error {
+	return ErrSizeBudgetExceeded
+}
+
+// liteStrippedElements are elements running custom code, removed from the lite
+// output together with their content
+var liteStrippedElements = map[string]bool{
+	"script": true, "iframe": true, "object": true, "embed": true, "template": true,
+}
+
+// RenderLite renders a constrained variant of a page sharing the template data with
+// the regular one, e.g. for email previews and low-bandwidth clients. It's a shorthand
+// for Render with a WithLite context and the lite layouts (see WithLiteLayouts).
+// The output is post-processed as follows:
+//   - scripts, iframes, embedded objects and elements marked with the data-no-lite
+//     attribute are removed
+//   - event handler attributes (onclick, onload, etc.) are removed
+//   - linked stylesheets found in the lite assets are inlined (see WithLiteAssets)
+//
+// Whole stylesheets are inlined rather than extracting the critical CSS, which would
+// depend on the viewport the page is shown in. Link a stylesheet with the critical
+// rules only from the lite layout to keep the output small.
+//
+// Templates can check for lite mode with {{ if isLite }}.
+//
+// Returns an error if rendering fails, a stylesheet can't be read or the output
+// exceeds the size budget (see WithSizeBudget). Nothing is written on error.
+func (e *Engine) RenderLite(ctx context.Context, out io.Writer, name string, binding interface{}, opts ...LiteOption) error {
+	cfg := newLiteConfig(opts)
+	return e.Render(withLite(ctx, cfg), out, name, binding, cfg.layouts...)
+}
+
+// WithLite returns a copy of ctx rendering the lite variant of pages (see RenderLite),
+// so it can be selected on the regular render path, e.g. by a handler serving both:
+//
+//	r = r.WithContext(templatex.WithLite(r.Context(), templatex.WithSizeBudget(50*1024)))
+//	err := engine.RenderHTTP(w, r, "article", data)
+//
+// Layouts are passed to the render method, so WithLiteLayouts is ignored. Renders are
+// cached like regular ones, separately from them, and post-processed after caching.
+// RenderStream writes the output as it's executed, so it doesn't post-process it.
+func WithLite(ctx context.Context, opts ...LiteOption) context.Context {
+	return withLite(ctx, newLiteConfig(opts))
+}
+
+// newLiteConfig returns the lite settings of the options
+func newLiteConfig(opts []LiteOption) *liteConfig {
+	cfg := &liteConfig{}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(cfg)
+		}
+	}
+	return cfg
+}
+
+// withLite returns a copy of ctx carrying the lite render mode and settings
+func withLite(ctx context.Context, cfg *liteConfig) context.Context {
+	return context.WithValue(withRenderMode(ctx, renderModeLite), liteKey, cfg)
+}
+
+// liteSettings returns the lite settings stored in ctx, or nil for other renders
+func liteSettings(ctx context.Context) *liteConfig {
+	if ctx == nil {
+		return nil
+	}
+	cfg, _ := ctx.Value(liteKey).(*liteConfig)
+	return cfg
+}
+
+// output post-processes the rendered content of the template for lite mode and
+// checks the size budget
+func (c *liteConfig) output(name, content string) (string, error) {
+	rw := &htmlRewriter{strip: liteStrippedElements, hideAttr: "data-no-lite", assets: c.assets}
+	content, err := rw.rewrite(content)
+	if err != nil {
+		return "", errors.Join(ErrLiteRenderFailed, err)
+	}
+	if c.budget > 0 && len(content) > c.budget {
+		return "", &SizeBudgetError{Template: name, Size: len(content), Budget: c.budget}
+	}
+	return content, nil
+}
+
+// isLite returns a function reporting whether the template is rendered in lite mode.
+// Usage: {{ if isLite }}...{{ end }}
+func isLite(ctx context.Context) func() bool {
+	return func() bool {
+		return renderMode(ctx) == renderModeLite
+	}
+}
@@ -0,0 +1,61 @@
+package templatex
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/invopop/ctxi18n"
+	"github.com/invopop/ctxi18n/i18n"
+)
+
+// RenderAllLocales renders the template in every locale configured with WithLocales
+// concurrently and returns the output by locale code, e.g. for translation QA previews
+// or to prime the per-locale caches after content changes. Locales must be loaded
+// with ctxi18n. The renders are cached as usual.
+//
+// Returns an error if no locales are configured, a locale is not loaded, or any render
+// fails; the errors of all failed locales are joined.
+func (e *Engine) RenderAllLocales(ctx context.Context, name string, binding any, layouts ...string) (map[string]string, error) {
+	if !e.initialized() {
+		return nil, ErrTemplateEngineNotInitialized
+	}
+	if len(e.locales) == 0 {
+		return nil, ErrNoLocales
+	}
+
+	var (
+		mu   sync.Mutex
+		wg   sync.WaitGroup
+		errs []error
+	)
+	out := make(map[string]string, len(e.locales))
+	for _, code := range e.locales {
+		l := ctxi18n.Get(i18n.Code(code))
+		if l == nil {
+			mu.Lock()
+			errs = append(errs, fmt.Errorf("locale %s: %w", code, ctxi18n.ErrMissingLocale))
+			mu.Unlock()
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			content, err := e.RenderString(l.WithContext(ctx), name, binding, layouts...)
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				errs = append(errs, fmt.Errorf("locale %s: %w", code, err))
+				return
+			}
+			out[code] = content
+		}()
+	}
+	wg.Wait()
+
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	return out, nil
+}
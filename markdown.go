@@ -0,0 +1,135 @@
+package templatex
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"html/template"
+	"path"
+	"strconv"
+
+	"gopkg.in/yaml.v3"
+)
+
+// markdownExt is the extension of Markdown templates (see WithMarkdown)
+const markdownExt = ".md"
+
+// MarkdownFunc converts Markdown to HTML (see WithMarkdown). It's responsible for
+// dropping or sanitizing raw HTML in the Markdown if the source isn't trusted.
+type MarkdownFunc func(source []byte) ([]byte, error)
+
+// isMarkdown reports whether the file is a Markdown template
+func (e *Engine) isMarkdown(file string) bool {
+	return e.markdownFn != nil && path.Ext(file) == markdownExt
+}
+
+// markdownTemplate converts the body of a Markdown template file to HTML, keeping the
+// known keys of its front matter. Other keys, like date or tags of posts, are dropped,
+// so Markdown files shared with static site generators load. Template actions are replaced with placeholders during the conversion,
+// so they're neither escaped nor parsed as Markdown, e.g. "{{ .Title }}" or
+// "[Docs]({{ absURL "/docs" }})".
+func (e *Engine) markdownTemplate(content []byte) ([]byte, error) {
+	meta, body, ok, err := splitFrontMatter(content, true)
+	if err != nil {
+		return nil, err
+	}
+	if ok {
+		if meta, err = knownFrontMatter(meta); err != nil {
+			return nil, err
+		}
+	}
+
+	var actions [][]byte
+	var protected bytes.Buffer
+	for {
+		start := bytes.Index(body, []byte("{{"))
+		if start < 0 {
+			protected.Write(body)
+			break
+		}
+		end := bytes.Index(body[start:], []byte("}}"))
+		if end < 0 {
+			return nil, errors.New("unclosed action")
+		}
+		protected.Write(body[:start])
+		protected.WriteString(actionPlaceholder(len(actions)))
+		actions = append(actions, body[start:start+end+2])
+		body = body[start+end+2:]
+	}
+
+	html, err := e.markdownFn(protected.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("markdown: %w", err)
+	}
+	for i, action := range actions {
+		placeholder := []byte(actionPlaceholder(i))
+		if !bytes.Contains(html, placeholder) {
+			return nil, fmt.Errorf("markdown: action %s was dropped by the conversion", action)
+		}
+		html = bytes.Replace(html, placeholder, action, 1)
+	}
+
+	if !ok || len(meta) == 0 {
+		return html, nil
+	}
+	var out bytes.Buffer
+	out.WriteString(frontMatterDelim + "\n")
+	out.Write(meta)
+	out.WriteString(frontMatterDelim + "\n")
+	out.Write(html)
+	return out.Bytes(), nil
+}
+
+// knownFrontMatter returns the front matter with the keys of other tools removed, or
+// nil if it has no known keys. Invalid YAML is returned as is, so parseFrontMatter
+// reports it.
+func knownFrontMatter(meta []byte) ([]byte, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(meta, &doc); err != nil || len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+		return meta, nil
+	}
+	mapping := doc.Content[0]
+	known := mapping.Content[:0]
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if frontMatterKeys[mapping.Content[i].Value] {
+			known = append(known, mapping.Content[i], mapping.Content[i+1])
+		}
+	}
+	if len(known) == 0 {
+		return nil, nil
+	}
+	mapping.Content = known
+	out, err := yaml.Marshal(&doc)
+	if err != nil {
+		return nil, fmt.Errorf("front matter: %w", err)
+	}
+	return out, nil
+}
+
+// actionPlaceholder returns the placeholder of the template action with the index.
+// It's alphanumeric, so Markdown converters keep it as is.
+func actionPlaceholder(i int) string {
+	return "TEMPLATEXACTION" + strconv.Itoa(i) + "X"
+}
+
+// markdown converts a Markdown string to HTML with the converter set by WithMarkdown.
+// The converter must drop or sanitize raw HTML if the string comes from users.
+// Usage: {{ markdown .Post.Body }}
+func (e *Engine) markdown(source any) (template.HTML, error) {
+	var src []byte
+	switch s := source.(type) {
+	case nil:
+		return "", nil
+	case string:
+		src = []byte(s)
+	case []byte:
+		src = s
+	default:
+		src = []byte(fmt.Sprint(s))
+	}
+	html, err := e.markdownFn(src)
+	if err != nil {
+		return "", fmt.Errorf("markdown: %w", err)
+	}
+	return template.HTML(html), nil
+}
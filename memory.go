@@ -0,0 +1,189 @@
+package templatex
+
+import (
+	"bytes"
+	"io"
+	"io/fs"
+	"maps"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// NewMemory creates a new template engine instance from template sources keyed by
+// file name, e.g. {"pages/home.gohtml": "<h1>{{ .Title }}</h1>"}. It doesn't touch
+// the disk, so it also works on WebAssembly (GOOS=js), e.g. to render previews in
+// a browser-based template editor. Create a new engine to apply edited sources.
+// It behaves like NewFS otherwise.
+func NewMemory(files map[string]string, opts ...Option) (*Engine, error) {
+	fsys := memFS{files: make(map[string][]byte, len(files)), modTime: time.Now()}
+	for name, content := range files {
+		name = strings.TrimPrefix(path.Clean("/"+strings.ReplaceAll(name, "\\", "/")), "/")
+		fsys.files[name] = []byte(content)
+	}
+	return NewFS(fsys, ".", opts...)
+}
+
+// memFS is the read-only in-memory file system of the templates of NewMemory.
+// Directories are implied by the file paths.
+type memFS struct {
+	files   map[string][]byte
+	modTime time.Time
+}
+
+// Open opens the named file or directory
+func (m memFS) Open(name string) (fs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+	if data, ok := m.files[name]; ok {
+		info := memFileInfo{name: path.Base(name), size: int64(len(data)), modTime: m.modTime}
+		return &memFile{Reader: bytes.NewReader(data), info: info}, nil
+	}
+	entries := m.readDir(name)
+	if entries == nil && name != "." {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+	info := memFileInfo{name: path.Base(name), dir: true, modTime: m.modTime}
+	return &memDir{info: info, entries: entries}, nil
+}
+
+// readDir returns the entries of the directory sorted by name, or nil if there
+// are no files in it
+func (m memFS) readDir(dir string) []fs.DirEntry {
+	prefix := dir + "/"
+	if dir == "." {
+		prefix = ""
+	}
+	children := make(map[string]memFileInfo)
+	for name, data := range m.files {
+		rest, ok := strings.CutPrefix(name, prefix)
+		if !ok {
+			continue
+		}
+		if child, _, isDir := strings.Cut(rest, "/"); isDir {
+			children[child] = memFileInfo{name: child, dir: true, modTime: m.modTime}
+		} else {
+			children[child] = memFileInfo{name: child, size: int64(len(data)), modTime: m.modTime}
+		}
+	}
+	if len(children) == 0 {
+		return nil
+	}
+	entries := make([]fs.DirEntry, 0, len(children))
+	for _, info := range children {
+		entries = append(entries, fs.FileInfoToDirEntry(info))
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+	return entries
+}
+
+// memFileInfo describes a file or directory of a memFS
+type memFileInfo struct {
+	name    string
+	size    int64
+	dir     bool
+	modTime time.Time
+}
+
+func (i memFileInfo) Name() string       { return i.name }
+func (i memFileInfo) Size() int64        { return i.size }
+func (i memFileInfo) ModTime() time.Time { return i.modTime }
+func (i memFileInfo) IsDir() bool        { return i.dir }
+func (i memFileInfo) Sys() any           { return nil }
+
+func (i memFileInfo) Mode() fs.FileMode {
+	if i.dir {
+		return fs.ModeDir | 0o555
+	}
+	return 0o444
+}
+
+// memFile is an open file of a memFS
+type memFile struct {
+	*bytes.Reader
+	info memFileInfo
+}
+
+func (f *memFile) Stat() (fs.FileInfo, error) { return f.info, nil }
+func (f *memFile) Close() error               { return nil }
+
+// memDir is an open directory of a memFS
+type memDir struct {
+	info    memFileInfo
+	entries []fs.DirEntry
+	offset  int
+}
+
+func (d *memDir) Stat() (fs.FileInfo, error) { return d.info, nil }
+func (d *memDir) Close() error               { return nil }
+
+func (d *memDir) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.info.name, Err: fs.ErrInvalid}
+}
+
+// ReadDir returns the next n entries of the directory, or all remaining ones if n <= 0
+func (d *memDir) ReadDir(n int) ([]fs.DirEntry, error) {
+	rest := d.entries[d.offset:]
+	if n <= 0 {
+		d.offset = len(d.entries)
+		return rest, nil
+	}
+	if len(rest) == 0 {
+		return nil, io.EOF
+	}
+	n = min(n, len(rest))
+	d.offset += n
+	return rest[:n], nil
+}
+
+// templateSource is a template registered in memory
+type templateSource struct {
+	src     string
+	modTime time.Time
+}
+
+// ParseString registers a template from source, e.g. in tests or for templates
+// stored in a database, replacing a template with the same name. The name is
+// normalized like template file paths, so "emails/welcome.gohtml" registers
+// "emails/welcome". Registered templates are kept on Reload.
+//
+// All templates are re-parsed and the caches are cleared, so prefer ParseMap to
+// register several templates. Returns an error if parsing fails, in which case
+// the template is not registered.
+func (e *Engine) ParseString(name, src string) error {
+	return e.ParseMap(map[string]string{name: src})
+}
+
+// ParseMap registers templates from sources keyed by name (see ParseString).
+// Either all templates are registered or none if parsing fails.
+func (e *Engine) ParseMap(templates map[string]string) error {
+	if !e.initialized() {
+		return ErrTemplateEngineNotInitialized
+	}
+
+	e.loadMu.Lock()
+	defer e.loadMu.Unlock()
+
+	e.mu.Lock()
+	previous := e.sources
+	sources := maps.Clone(previous)
+	if sources == nil {
+		sources = make(map[string]templateSource, len(templates))
+	}
+	now := time.Now()
+	for name, src := range templates {
+		sources[normalizeTemplateName(name, e.exts)] = templateSource{src: src, modTime: now}
+	}
+	e.sources = sources
+	e.mu.Unlock()
+
+	if err := e.load(); err != nil {
+		e.mu.Lock()
+		e.sources = previous
+		e.mu.Unlock()
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,82 @@
+package templatex
+
+import (
+	"bytes"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// preservedElements are elements whose content is written unchanged by minifyHTML
+var preservedElements = map[string]bool{
+	"pre": true, "textarea": true, "script": true, "style": true, "code": true,
+}
+
+// minifyHTML removes comments and collapses runs of whitespace in text to a single
+// space, or a newline if the run contains one. Whitespace is never removed entirely,
+// so the rendering of inline elements doesn't change. Content of pre, textarea, code,
+// script and style elements, markup and conditional comments are kept as is.
+func minifyHTML(s string) string {
+	var out bytes.Buffer
+	out.Grow(len(s))
+
+	z := html.NewTokenizer(strings.NewReader(s))
+	preserved := 0
+	for {
+		tt := z.Next()
+		if tt == html.ErrorToken {
+			// The tokenizer stops at the end of the input; anything left is kept
+			out.Write(z.Raw())
+			return out.String()
+		}
+
+		raw := z.Raw()
+		switch tt {
+		case html.CommentToken:
+			if bytes.HasPrefix(raw, []byte("<!--[if")) || bytes.HasPrefix(raw, []byte("<![endif]")) {
+				out.Write(raw)
+			}
+			continue
+		case html.TextToken:
+			if preserved == 0 {
+				out.Write(collapseWhitespace(raw))
+				continue
+			}
+		case html.StartTagToken:
+			if name, _ := z.TagName(); preservedElements[string(name)] {
+				preserved++
+			}
+		case html.EndTagToken:
+			if name, _ := z.TagName(); preservedElements[string(name)] && preserved > 0 {
+				preserved--
+			}
+		}
+		out.Write(raw)
+	}
+}
+
+// collapseWhitespace replaces runs of ASCII whitespace with a single space,
+// or a newline if the run contains one
+func collapseWhitespace(text []byte) []byte {
+	out := make([]byte, 0, len(text))
+	for i := 0; i < len(text); {
+		if !isHTMLSpace(text[i]) {
+			out = append(out, text[i])
+			i++
+			continue
+		}
+		sep := byte(' ')
+		for ; i < len(text) && isHTMLSpace(text[i]); i++ {
+			if text[i] == '\n' {
+				sep = '\n'
+			}
+		}
+		out = append(out, sep)
+	}
+	return out
+}
+
+// isHTMLSpace reports whether the byte is whitespace in HTML
+func isHTMLSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f'
+}
@@ -0,0 +1,34 @@
+package templatex
+
+import (
+	"bytes"
+	"context"
+	"reflect"
+)
+
+// mutationCheckEnabled reports whether bindings should be checked for mutation
+// during renders. The check only runs in the development environment.
+func (e *Engine) mutationCheckEnabled() bool {
+	return e.mutationCheck && e.env == EnvDevelopment
+}
+
+// bindingSnapshot returns a deterministic copy of the binding content
+// used to detect mutations
+func bindingSnapshot(binding any) []byte {
+	var buf bytes.Buffer
+	writeCanonical(&buf, reflect.ValueOf(binding), 0)
+	return buf.Bytes()
+}
+
+// checkBindingMutation compares the binding with the snapshot taken before the render
+// and logs a warning if it changed, either by a template function or by another
+// goroutine sharing the data
+func (e *Engine) checkBindingMutation(ctx context.Context, name string, binding any, snapshot []byte) {
+	if bytes.Equal(snapshot, bindingSnapshot(binding)) {
+		return
+	}
+	e.logger.WarnContext(ctx, "templatex: binding mutated during render",
+		"template", name,
+		"hint", "binding data shared between goroutines must not be modified while it's rendered",
+	)
+}
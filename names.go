@@ -0,0 +1,100 @@
+package templatex
+
+import (
+	"fmt"
+	"html/template"
+	"path"
+	"strings"
+)
+
+// normalizeTemplateName converts a template reference to the form used for template
+// names: forward slashes, no leading "./" or "/", and no template file extension,
+// e.g. "pages\\Home.gohtml" becomes "pages/Home"
+func normalizeTemplateName(name string, exts []string) string {
+	name = strings.ReplaceAll(name, "\\", "/")
+	name = strings.TrimPrefix(path.Clean("/"+name), "/")
+	for _, ext := range exts {
+		if strings.HasSuffix(name, ext) {
+			return strings.TrimSuffix(name, ext)
+		}
+	}
+	return name
+}
+
+// inTemplates reports whether the template is one of the templates or in one of the
+// directories, e.g. "emails/welcome" is in "emails" and "emails/"
+func inTemplates(name string, templates []string) bool {
+	for _, t := range templates {
+		if name == t || strings.HasPrefix(name, strings.TrimSuffix(t, "/")+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// buildNameIndex maps lowercase template names to the actual names for
+// case-insensitive lookups. It returns an error if names differ only in case.
+func buildNameIndex(tmpl *template.Template) (map[string]string, error) {
+	names := make(map[string]string)
+	for _, t := range tmpl.Templates() {
+		if t.Name() == "" {
+			continue
+		}
+		key := strings.ToLower(t.Name())
+		if other, ok := names[key]; ok && other != t.Name() {
+			return nil, fmt.Errorf("template names differ only in case: %s, %s", other, t.Name())
+		}
+		names[key] = t.Name()
+	}
+	return names, nil
+}
+
+// lookup returns the template with the given name. The name is normalized
+// (see normalizeTemplateName) if there is no exact match, and matched ignoring
+// case if case-insensitive names are enabled (see WithCaseInsensitiveNames).
+// The caller must hold the read lock, since templates can be reloaded concurrently.
+func (e *Engine) lookup(name string) *template.Template {
+	return e.lookupIn(e.templates, e.names, name)
+}
+
+// lookupIn looks up the template in the given template set and name index
+func (e *Engine) lookupIn(tmpl *template.Template, names map[string]string, name string) *template.Template {
+	if t := tmpl.Lookup(name); t != nil {
+		return t
+	}
+	normalized := normalizeTemplateName(name, e.exts)
+	if t := tmpl.Lookup(normalized); t != nil {
+		return t
+	}
+	if names != nil {
+		if actual, ok := names[strings.ToLower(normalized)]; ok {
+			return tmpl.Lookup(actual)
+		}
+	}
+	return nil
+}
+
+// known reports whether the template is parsed or indexed for lazy parsing.
+// The caller must hold the read lock.
+func (e *Engine) known(name string) bool {
+	return e.lookup(name) != nil || e.lazyIndexed(name)
+}
+
+// SplitView splits a view name following the "name@layout@outer_layout" convention
+// used by framework adapters into the template name and its layouts, innermost first.
+// Names of versioned components (e.g. "ui.card@v1") are kept intact, and a view name
+// matching a template as a whole is returned without layouts.
+func (e *Engine) SplitView(view string) (string, []string) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	if e.templates == nil || e.known(view) || !strings.Contains(view, "@") {
+		return view, nil
+	}
+
+	parts := strings.Split(view, "@")
+	name, i := parts[0], 1
+	for ; i < len(parts) && e.known(name+"@"+parts[i]); i++ {
+		name += "@" + parts[i]
+	}
+	return name, parts[i:]
+}
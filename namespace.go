@@ -0,0 +1,90 @@
+package templatex
+
+import (
+	"fmt"
+	"html/template"
+	"io/fs"
+	"strconv"
+	"strings"
+)
+
+// componentNamespace is a set of component templates parsed from its own root
+type componentNamespace struct {
+	name string
+	fsys fs.FS
+	root string
+}
+
+// resolveComponentVersions makes unversioned component names resolve to the latest
+// version, e.g. "ui.button" to "ui.button@v2" if there is no button template without
+// a version suffix. Explicitly versioned names keep resolving to their version.
+func resolveComponentVersions(tmpl *template.Template, namespaces []componentNamespace) error {
+	if len(namespaces) == 0 {
+		return nil
+	}
+
+	latest := make(map[string]*template.Template)
+	for _, t := range tmpl.Templates() {
+		base, version, ok := strings.Cut(t.Name(), "@")
+		if !ok || !inNamespaces(base, namespaces) {
+			continue
+		}
+		if cur, exists := latest[base]; !exists || compareVersions(version, componentVersion(cur.Name())) > 0 {
+			latest[base] = t
+		}
+	}
+
+	for base, t := range latest {
+		if tmpl.Lookup(base) != nil || t.Tree == nil {
+			continue
+		}
+		if _, err := tmpl.AddParseTree(base, t.Tree); err != nil {
+			return fmt.Errorf("component %s: %w", base, err)
+		}
+	}
+	return nil
+}
+
+// inNamespaces reports whether the template name belongs to one of the namespaces
+func inNamespaces(name string, namespaces []componentNamespace) bool {
+	for _, ns := range namespaces {
+		if strings.HasPrefix(name, ns.name+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// componentVersion returns the version suffix of the component name
+func componentVersion(name string) string {
+	_, version, _ := strings.Cut(name, "@")
+	return version
+}
+
+// compareVersions compares versions like "v2" or "v1.10" by their numeric parts.
+// Non-numeric parts are compared as strings.
+func compareVersions(a, b string) int {
+	as := strings.Split(strings.TrimPrefix(a, "v"), ".")
+	bs := strings.Split(strings.TrimPrefix(b, "v"), ".")
+	for i := 0; i < len(as) || i < len(bs); i++ {
+		var x, y string
+		if i < len(as) {
+			x = as[i]
+		}
+		if i < len(bs) {
+			y = bs[i]
+		}
+		xn, xerr := strconv.Atoi(x)
+		yn, yerr := strconv.Atoi(y)
+		switch {
+		case xerr == nil && yerr == nil && xn != yn:
+			if xn < yn {
+				return -1
+			}
+			return 1
+		case (xerr != nil || yerr != nil) && x != y:
+			return strings.Compare(x, y)
+		}
+	}
+	return 0
+}
@@ -0,0 +1,79 @@
+package templatex
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Authorizer reports whether the render context (e.g. the current user) has the permission
+type Authorizer func(ctx context.Context, permission string) bool
+
+// requirePermission wraps the template content in a permission check, so a template
+// included by another one renders nothing for unauthorized contexts
+func requirePermission(content []byte, permission string) []byte {
+	if permission == "" {
+		return content
+	}
+	return []byte("{{ if authorized " + strconv.Quote(permission) + " }}" + string(content) + "{{ end }}")
+}
+
+// dirPermission returns the permission required by the closest directory of the
+// template (see WithDirectoryPermission), or an empty string
+func (e *Engine) dirPermission(name string) string {
+	dir, permission := "", ""
+	for d, p := range e.dirPermissions {
+		if strings.HasPrefix(name, d+"/") && len(d) > len(dir) {
+			dir, permission = d, p
+		}
+	}
+	return permission
+}
+
+// authorized returns a function reporting whether the render context has the permission.
+// Permissions are denied if no authorizer is set.
+// Usage: {{ if authorized "admin" }}...{{ end }}
+func (e *Engine) authorized(ctx context.Context) func(permission string) bool {
+	return func(permission string) bool {
+		return e.authorizer != nil && e.authorizer(ctx, permission)
+	}
+}
+
+// checkAuthorized returns ErrUnauthorized if the template requires a permission
+// the render context doesn't have
+func (e *Engine) checkAuthorized(ctx context.Context, name string) error {
+	e.mu.RLock()
+	permission, ok := e.permissions[name]
+	e.mu.RUnlock()
+	if ok && !e.authorized(ctx)(permission) {
+		return errors.Join(ErrUnauthorized, fmt.Errorf("template %s requires %q", name, permission))
+	}
+	return nil
+}
+
+// permissionState returns the permissions required by templates that the render
+// context has, which is added to cache keys, so renders for authorized and
+// unauthorized contexts are cached separately
+func (e *Engine) permissionState(ctx context.Context) string {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	if len(e.permissions) == 0 {
+		return ""
+	}
+	seen := make(map[string]bool)
+	granted := make([]string, 0, len(e.permissions))
+	for _, permission := range e.permissions {
+		if seen[permission] {
+			continue
+		}
+		seen[permission] = true
+		if e.authorized(ctx)(permission) {
+			granted = append(granted, permission)
+		}
+	}
+	sort.Strings(granted)
+	return "|granted:" + strings.Join(granted, ",")
+}
@@ -0,0 +1,160 @@
+package templatex
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// phonePlan is the numbering plan of a region: its country calling code, the trunk
+// prefix dialed before national numbers and the national formats by number length,
+// where each x is a digit of the national significant number. International formats
+// are derived from the national ones by dropping the trunk prefix and parentheses.
+type phonePlan struct {
+	code     string
+	trunk    string
+	patterns []string
+}
+
+// phonePlans are the numbering plans known to formatPhone. Regions with variable
+// length numbers (e.g. DE or JP) have no patterns, so their numbers aren't grouped.
+var phonePlans = map[string]phonePlan{
+	"US": {"1", "", []string{"(xxx) xxx-xxxx"}},
+	"CA": {"1", "", []string{"(xxx) xxx-xxxx"}},
+	"GB": {"44", "0", []string{"0xxxx xxxxxx"}},
+	"FR": {"33", "0", []string{"0x xx xx xx xx"}},
+	"ES": {"34", "", []string{"xxx xx xx xx"}},
+	"PT": {"351", "", []string{"xxx xxx xxx"}},
+	"CH": {"41", "0", []string{"0xx xxx xx xx"}},
+	"PL": {"48", "", []string{"xxx xxx xxx"}},
+	"NL": {"31", "0", []string{"0x xxxxxxxx"}},
+	"DE": {"49", "0", nil},
+	"IT": {"39", "", nil},
+	"AU": {"61", "0", []string{"0x xxxx xxxx"}},
+	"BR": {"55", "0", []string{"(xx) xxxx-xxxx", "(xx) xxxxx-xxxx"}},
+	"RU": {"7", "8", []string{"8 (xxx) xxx-xx-xx"}},
+	"KZ": {"7", "8", []string{"8 (xxx) xxx-xx-xx"}},
+	"UA": {"380", "0", []string{"(0xx) xxx xx xx"}},
+	"IN": {"91", "0", []string{"xxxxx xxxxx"}},
+	"JP": {"81", "0", nil},
+	"CN": {"86", "0", []string{"xxx xxxx xxxx"}},
+}
+
+// formatPhone formats a phone number for display to users in the region: numbers of
+// the region are formatted nationally, e.g. "(415) 555-0123" for "US", other numbers
+// internationally, e.g. "+44 2079 460958". An empty region formats all numbers
+// internationally. Numbers without a leading "+" or "00" are numbers of the region.
+// Usage: {{ formatPhone .Phone "US" }}
+func formatPhone(number any, region string) (string, error) {
+	s := strings.TrimSpace(fmt.Sprint(number))
+	if number == nil || s == "" {
+		return "", nil
+	}
+
+	region = strings.ToUpper(region)
+	local, ok := phonePlans[region]
+	if region != "" && !ok {
+		return "", fmt.Errorf("formatPhone: unknown region %q", region)
+	}
+
+	international := strings.HasPrefix(s, "+")
+	var digits strings.Builder
+	for _, c := range strings.TrimPrefix(s, "+") {
+		switch {
+		case c >= '0' && c <= '9':
+			digits.WriteRune(c)
+		case strings.ContainsRune(" -.()/", c):
+		default:
+			return "", fmt.Errorf("formatPhone: invalid phone number %q", s)
+		}
+	}
+	nsn := digits.String()
+	if !international && strings.HasPrefix(nsn, "00") {
+		international, nsn = true, nsn[2:]
+	}
+	if nsn == "" {
+		return "", fmt.Errorf("formatPhone: invalid phone number %q", s)
+	}
+
+	plan := local
+	switch {
+	case international:
+		if plan, ok = phonePlanByCode(nsn, region); !ok {
+			return "+" + nsn, nil
+		}
+		nsn = nsn[len(plan.code):]
+	case region == "":
+		return "", fmt.Errorf("formatPhone: number %q without country code needs a region", s)
+	case plan.trunk != "" && strings.HasPrefix(nsn, plan.trunk):
+		nsn = nsn[len(plan.trunk):]
+	case strings.HasPrefix(nsn, plan.code) && !plan.matches(nsn) && plan.matches(nsn[len(plan.code):]):
+		// National number dialed with the country code, e.g. 1 415 555 0123 in the US
+		nsn = nsn[len(plan.code):]
+	}
+
+	if region != "" && plan.code == local.code {
+		return plan.format(nsn, false), nil
+	}
+	return "+" + plan.code + " " + plan.format(nsn, true), nil
+}
+
+// phonePlanByCode returns the plan of the country calling code the number starts with.
+// Regions sharing a code (e.g. US and CA) are resolved to the region, if it shares the code.
+func phonePlanByCode(number, region string) (phonePlan, bool) {
+	if plan, ok := phonePlans[region]; ok && strings.HasPrefix(number, plan.code) {
+		return plan, true
+	}
+	regions := make([]string, 0, len(phonePlans))
+	for r := range phonePlans {
+		regions = append(regions, r)
+	}
+	sort.Strings(regions)
+	for _, r := range regions {
+		if plan := phonePlans[r]; strings.HasPrefix(number, plan.code) {
+			return plan, true
+		}
+	}
+	return phonePlan{}, false
+}
+
+// matches reports whether the plan has a format for numbers of the length
+func (p phonePlan) matches(nsn string) bool {
+	for _, pattern := range p.patterns {
+		if strings.Count(pattern, "x") == len(nsn) {
+			return true
+		}
+	}
+	return false
+}
+
+// format groups the digits of the national significant number by the pattern
+// matching its length. International formats separate groups with spaces only.
+// Numbers of other lengths aren't grouped.
+func (p phonePlan) format(nsn string, international bool) string {
+	for _, pattern := range p.patterns {
+		if strings.Count(pattern, "x") != len(nsn) {
+			continue
+		}
+		var sb strings.Builder
+		i := 0
+		for _, c := range pattern {
+			switch {
+			case c == 'x':
+				sb.WriteByte(nsn[i])
+				i++
+			case !international:
+				sb.WriteRune(c)
+			case c == ' ' || c == '-':
+				sb.WriteByte(' ')
+			}
+		}
+		if international {
+			return strings.Join(strings.Fields(sb.String()), " ")
+		}
+		return sb.String()
+	}
+	if international {
+		return nsn
+	}
+	return p.trunk + nsn
+}
@@ -0,0 +1,241 @@
+package templatex
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html/template"
+	"strings"
+	"text/template/parse"
+)
+
+// previewListSize is the number of items generated for ranged fields in previews
+const previewListSize = 3
+
+// previewField describes a field referenced by a template and is used
+// to generate placeholder data for previews
+type previewField struct {
+	children map[string]*previewField
+	list     bool // field is used in a range action
+}
+
+// child returns the child field with the given name, creating it if needed
+func (f *previewField) child(name string) *previewField {
+	if f.children == nil {
+		f.children = make(map[string]*previewField)
+	}
+	c, ok := f.children[name]
+	if !ok {
+		c = &previewField{}
+		f.children[name] = c
+	}
+	return c
+}
+
+// Preview renders a template with placeholder data derived from the fields it
+// references, so designers can view any template without wiring a route and real data.
+// Fields are filled with values guessed from their names (names, emails, URLs,
+// dates, numbers, flags or lorem text) and fields used in range actions get a
+// short list of items. Fields referenced by the given layouts and the templates they
+// include are filled as well.
+//
+// Returns the rendered content or an error if the template or layouts are not found
+// or template execution fails.
+func (e *Engine) Preview(ctx context.Context, name string, layouts ...string) (string, error) {
+	if !e.initialized() {
+		return "", ErrTemplateEngineNotInitialized
+	}
+
+	data, err := e.previewData(name, layouts...)
+	if err != nil {
+		return "", err
+	}
+
+	return e.RenderString(ctx, name, data, layouts...)
+}
+
+// previewData generates placeholder data for the template and its layouts
+func (e *Engine) previewData(name string, layouts ...string) (map[string]any, error) {
+	if err := e.ensureParsed(append([]string{name}, layouts...)...); err != nil {
+		return nil, err
+	}
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	root := &previewField{}
+	w := &previewWalker{tmpl: e.templates, visited: make(map[string]bool)}
+	for _, n := range append([]string{name}, layouts...) {
+		t := e.lookup(n)
+		if t == nil {
+			return nil, errors.Join(ErrTemplateNotFound, fmt.Errorf("template: %s", n))
+		}
+		w.walkTemplate(t, root)
+	}
+
+	data, _ := e.previewValue("", root).(map[string]any)
+	if data == nil {
+		data = make(map[string]any)
+	}
+	return data, nil
+}
+
+// previewWalker walks template parse trees collecting referenced fields
+type previewWalker struct {
+	tmpl    *template.Template
+	visited map[string]bool
+}
+
+// walkTemplate walks the tree of the template with the dot bound to the given field
+func (w *previewWalker) walkTemplate(t *template.Template, dot *previewField) {
+	if t == nil || t.Tree == nil || t.Tree.Root == nil {
+		return
+	}
+	key := fmt.Sprintf("%s:%p", t.Name(), dot)
+	if w.visited[key] {
+		return
+	}
+	w.visited[key] = true
+	w.walk(t.Tree.Root, dot, dot)
+}
+
+// walk collects fields referenced by the node. The root is the field bound to $.
+func (w *previewWalker) walk(node parse.Node, dot, root *previewField) {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return
+		}
+		for _, c := range n.Nodes {
+			w.walk(c, dot, root)
+		}
+	case *parse.ActionNode:
+		w.walkPipe(n.Pipe, dot, root)
+	case *parse.IfNode:
+		w.walkPipe(n.Pipe, dot, root)
+		w.walk(n.List, dot, root)
+		w.walk(n.ElseList, dot, root)
+	case *parse.WithNode:
+		inner := w.walkPipe(n.Pipe, dot, root)
+		if inner == nil {
+			inner = dot
+		}
+		w.walk(n.List, inner, root)
+		w.walk(n.ElseList, dot, root)
+	case *parse.RangeNode:
+		inner := w.walkPipe(n.Pipe, dot, root)
+		elem := dot
+		if inner != nil {
+			inner.list = true
+			elem = inner
+		}
+		w.walk(n.List, elem, root)
+		w.walk(n.ElseList, dot, root)
+	case *parse.TemplateNode:
+		inner := dot
+		if n.Pipe != nil {
+			inner = w.walkPipe(n.Pipe, dot, root)
+		}
+		if inner != nil {
+			w.walkTemplate(w.tmpl.Lookup(n.Name), inner)
+		}
+	}
+}
+
+// walkPipe collects fields referenced by the pipeline and returns the field
+// the pipeline evaluates to, or nil if it's not a plain field reference
+func (w *previewWalker) walkPipe(pipe *parse.PipeNode, dot, root *previewField) *previewField {
+	if pipe == nil {
+		return nil
+	}
+	var last *previewField
+	for _, cmd := range pipe.Cmds {
+		last = nil
+		for _, arg := range cmd.Args {
+			switch a := arg.(type) {
+			case *parse.FieldNode:
+				last = walkIdents(dot, a.Ident)
+			case *parse.VariableNode:
+				if len(a.Ident) > 1 && a.Ident[0] == "$" {
+					last = walkIdents(root, a.Ident[1:])
+				}
+			case *parse.DotNode:
+				last = dot
+			case *parse.PipeNode:
+				w.walkPipe(a, dot, root)
+			}
+		}
+		if len(cmd.Args) > 1 {
+			// the result of a function call is not a field
+			last = nil
+		}
+	}
+	return last
+}
+
+// walkIdents returns the field at the path of identifiers, creating it if needed
+func walkIdents(f *previewField, idents []string) *previewField {
+	for _, ident := range idents {
+		f = f.child(ident)
+	}
+	return f
+}
+
+// previewValue generates a placeholder value for the field with the given name
+func (e *Engine) previewValue(name string, f *previewField) any {
+	if f.list {
+		elem := &previewField{children: f.children}
+		items := make([]any, previewListSize)
+		for i := range items {
+			items[i] = e.previewValue(singular(name), elem)
+		}
+		return items
+	}
+
+	if len(f.children) > 0 {
+		m := make(map[string]any, len(f.children))
+		for childName, child := range f.children {
+			m[childName] = e.previewValue(childName, child)
+		}
+		return m
+	}
+
+	return e.previewScalar(name)
+}
+
+// previewScalar guesses a placeholder value from the field name
+func (e *Engine) previewScalar(name string) any {
+	lower := strings.ToLower(name)
+	switch {
+	case lower == "":
+		return "Lorem ipsum"
+	case strings.HasPrefix(name, "Is") || strings.HasPrefix(name, "Has") ||
+		strings.HasPrefix(name, "Can") || strings.HasSuffix(lower, "enabled"):
+		return true
+	case strings.Contains(lower, "email"):
+		return "jane.doe@example.com"
+	case strings.Contains(lower, "url") || strings.Contains(lower, "link") || strings.Contains(lower, "href"):
+		return "https://example.com"
+	case strings.Contains(lower, "image") || strings.Contains(lower, "avatar") || strings.Contains(lower, "photo"):
+		return "https://placehold.co/640x480"
+	case strings.HasSuffix(name, "At") || strings.Contains(lower, "date") || strings.Contains(lower, "time"):
+		return e.clock()
+	case lower == "id" || strings.HasSuffix(name, "ID") || strings.Contains(lower, "count") ||
+		strings.Contains(lower, "total") || strings.Contains(lower, "amount") ||
+		strings.Contains(lower, "price") || lower == "age" || strings.HasSuffix(name, "Age") ||
+		strings.Contains(lower, "qty") || strings.Contains(lower, "quantity"):
+		return 42
+	case strings.Contains(lower, "username") || strings.Contains(lower, "name") || strings.Contains(lower, "author"):
+		return "Jane Doe"
+	case strings.Contains(lower, "title") || strings.Contains(lower, "heading") || strings.Contains(lower, "subject"):
+		return "Lorem ipsum dolor sit amet"
+	case strings.Contains(lower, "description") || strings.Contains(lower, "body") ||
+		strings.Contains(lower, "content") || strings.Contains(lower, "text"):
+		return "Lorem ipsum dolor sit amet, consectetur adipiscing elit, sed do eiusmod tempor incididunt ut labore et dolore magna aliqua."
+	}
+	return name
+}
+
+// singular returns a naive singular form of the name, used to name list items
+func singular(name string) string {
+	return strings.TrimSuffix(name, "s")
+}
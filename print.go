@@ -0,0 +1,122 @@
+package templatex
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"html/template"
+	"io"
+	"io/fs"
+)
+
+// renderModePrint is the render mode used by RenderPrint
+const renderModePrint = "print"
+
+// PrintOption configures RenderPrint
+type PrintOption func(*printConfig)
+
+// printConfig holds the RenderPrint settings
+type printConfig struct {
+	layouts []string
+	assets  []assetDir
+}
+
+// WithPrintLayouts sets the layouts wrapping the content in print mode,
+// e.g. a dedicated print layout without navigation.
+func WithPrintLayouts(layouts ...string) PrintOption {
+	return func(c *printConfig) {
+		c.layouts = layouts
+	}
+}
+
+// WithPrintAssets registers the files of fsys served under the URL prefix, e.g.
+// WithPrintAssets(os.DirFS("public"), "/static"). Stylesheets linked from the page
+// and found in fsys are inlined, so converters don't have to fetch them.
+func WithPrintAssets(fsys fs.FS, prefix string) PrintOption {
+	return func(c *printConfig) {
+		c.assets = append(c.assets, assetDir{fsys: fsys, prefix: prefix})
+	}
+}
+
+// printStrippedElements are interactive elements removed from the print output
+// together with their content
+var printStrippedElements = map[string]bool{
+	"script": true, "noscript": true, "button": true, "iframe": true, "object": true,
+	"embed": true, "dialog": true, "video": true, "audio": true, "template": true,
+}
+
+// RenderPrint renders a template as HTML tailored for HTML-to-PDF converters.
+// The output is post-processed as follows:
+//   - scripts, buttons, embedded media and other interactive elements are removed,
+//     as well as elements marked with the data-no-print attribute
+//   - event handler attributes (onclick, onload, etc.) are removed
+//   - linked stylesheets found in the print assets are inlined (see WithPrintAssets)
+//
+// Templates can check for print mode with {{ if isPrint }} and use the pageBreak,
+// printHeader and printFooter helpers.
+//
+// Returns an error if rendering fails or a stylesheet can't be read.
+func (e *Engine) RenderPrint(ctx context.Context, out io.Writer, name string, binding interface{}, opts ...PrintOption) error {
+	cfg := &printConfig{}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(cfg)
+		}
+	}
+
+	buf := bufferPool.Get().(*bytes.Buffer)
+	buf.Reset()
+	defer bufferPool.Put(buf)
+
+	if err := e.Render(withRenderMode(ctx, renderModePrint), buf, name, binding, cfg.layouts...); err != nil {
+		return err
+	}
+
+	rw := &htmlRewriter{strip: printStrippedElements, hideAttr: "data-no-print", assets: cfg.assets}
+	content, err := rw.rewrite(buf.String())
+	if err != nil {
+		return errors.Join(ErrPrintFailed, err)
+	}
+
+	_, err = io.WriteString(out, content)
+	return err
+}
+
+// isPrint returns a function reporting whether the template is rendered in print mode.
+// Usage: {{ if isPrint }}...{{ end }}
+func isPrint(ctx context.Context) func() bool {
+	return func() bool {
+		return renderMode(ctx) == renderModePrint
+	}
+}
+
+// pageBreak returns an element forcing a page break in print output.
+// Usage: {{ pageBreak }}
+func pageBreak() template.HTML {
+	return `<div style="break-after:page;page-break-after:always"></div>`
+}
+
+// printHeader returns a header repeated at the top of every printed page.
+// Usage: {{ printHeader "Invoice #42" }}
+func printHeader(content any) template.HTML {
+	return printRunning("templatex-print-header", "top:0", content)
+}
+
+// printFooter returns a footer repeated at the bottom of every printed page.
+// Usage: {{ printFooter "Page footer" }}
+func printFooter(content any) template.HTML {
+	return printRunning("templatex-print-footer", "bottom:0", content)
+}
+
+// printRunning returns a fixed-position element that print engines repeat on each page.
+// Strings are escaped, template.HTML is inserted as is.
+func printRunning(class, position string, content any) template.HTML {
+	var body string
+	switch v := content.(type) {
+	case template.HTML:
+		body = string(v)
+	default:
+		body = template.HTMLEscaper(v)
+	}
+	return template.HTML(`<div class="` + class + `" style="position:fixed;left:0;right:0;` + position + `">` + body + `</div>`)
+}
@@ -0,0 +1,95 @@
+package templatex
+
+import (
+	"fmt"
+	"html/template"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// maskRune replaces hidden characters of masked strings
+const maskRune = '*'
+
+// obfuscateEmail returns a mailto link for the address hidden from simple scrapers.
+// The "entities" mode, the default, encodes every character as a numeric character
+// reference, which browsers display as usual. The "js" mode assembles the link with
+// a script from the reversed address and shows "name [at] example [dot] com" without
+// JavaScript.
+// Usage: {{ obfuscateEmail .Email }}, {{ obfuscateEmail .Email "js" }}
+func obfuscateEmail(email any, mode ...string) (template.HTML, error) {
+	address := strings.TrimSpace(fmt.Sprint(email))
+	if email == nil || !strings.Contains(address, "@") {
+		return "", fmt.Errorf("obfuscateEmail: invalid email address %q", address)
+	}
+
+	m := "entities"
+	if len(mode) > 0 {
+		m = mode[0]
+	}
+	switch m {
+	case "entities":
+		encoded := encodeEntities(address)
+		return template.HTML(`<a href="` + encodeEntities("mailto:") + encoded + `">` + encoded + `</a>`), nil
+	case "js":
+		runes := []rune(address)
+		for i, j := 0, len(runes)-1; i < j; i, j = i+1, j-1 {
+			runes[i], runes[j] = runes[j], runes[i]
+		}
+		reversed := strings.ReplaceAll(template.JSEscapeString(string(runes)), "@", `\u0040`)
+		readable := strings.NewReplacer("@", " [at] ", ".", " [dot] ").Replace(address)
+		return template.HTML(`<script>(function(r){var e=r.split("").reverse().join(""),a=document.createElement("a");` +
+			`a.href="mailto:"+e;a.textContent=e;document.currentScript.replaceWith(a)})("` + reversed + `")</script>` +
+			`<noscript>` + template.HTMLEscapeString(readable) + `</noscript>`), nil
+	default:
+		return "", fmt.Errorf("obfuscateEmail: unknown mode %q, expected entities or js", m)
+	}
+}
+
+// encodeEntities encodes every character of the string as a numeric character reference
+func encodeEntities(s string) string {
+	var sb strings.Builder
+	for _, r := range s {
+		sb.WriteString("&#" + strconv.Itoa(int(r)) + ";")
+	}
+	return sb.String()
+}
+
+// maskString hides the letters and digits of the value except the first keepStart
+// and the last keepEnd ones, keeping separators, e.g. "**** **** **** 4242".
+// Values too short to hide anything are masked entirely.
+// Usage: {{ maskString .CardNumber 0 4 }}, {{ maskString .APIKey 4 4 }}
+func maskString(value any, keepStart, keepEnd int) string {
+	if value == nil {
+		return ""
+	}
+	runes := []rune(fmt.Sprint(value))
+
+	total := 0
+	for _, r := range runes {
+		if isMaskable(r) {
+			total++
+		}
+	}
+	keepStart, keepEnd = max(keepStart, 0), max(keepEnd, 0)
+	if keepStart+keepEnd >= total {
+		keepStart, keepEnd = 0, 0
+	}
+
+	seen := 0
+	for i, r := range runes {
+		if !isMaskable(r) {
+			continue
+		}
+		if seen >= keepStart && seen < total-keepEnd {
+			runes[i] = maskRune
+		}
+		seen++
+	}
+	return string(runes)
+}
+
+// isMaskable reports whether the rune is hidden by maskString
+func isMaskable(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
@@ -0,0 +1,45 @@
+package templatex
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// pseudoChars maps ASCII letters to accented look-alikes used by pseudo-localization
+var pseudoChars = map[rune]rune{
+	'A': 'Á', 'B': 'Ɓ', 'C': 'Ç', 'D': 'Ð', 'E': 'É', 'F': 'Ƒ', 'G': 'Ĝ', 'H': 'Ĥ', 'I': 'Í',
+	'J': 'Ĵ', 'K': 'Ķ', 'L': 'Ļ', 'M': 'Ṁ', 'N': 'Ñ', 'O': 'Ö', 'P': 'Þ', 'Q': 'Ǫ', 'R': 'Ŕ',
+	'S': 'Š', 'T': 'Ţ', 'U': 'Û', 'V': 'Ṽ', 'W': 'Ŵ', 'X': 'Ẋ', 'Y': 'Ý', 'Z': 'Ž',
+	'a': 'á', 'b': 'ƀ', 'c': 'ç', 'd': 'ð', 'e': 'é', 'f': 'ƒ', 'g': 'ĝ', 'h': 'ĥ', 'i': 'í',
+	'j': 'ĵ', 'k': 'ķ', 'l': 'ļ', 'm': 'ṁ', 'n': 'ñ', 'o': 'ö', 'p': 'þ', 'q': 'ǫ', 'r': 'ŕ',
+	's': 'š', 't': 'ţ', 'u': 'û', 'v': 'ṽ', 'w': 'ŵ', 'x': 'ẋ', 'y': 'ý', 'z': 'ž',
+}
+
+// pseudoExpansion is the share of characters added to pseudo-localized strings,
+// simulating languages with longer translations
+const pseudoExpansion = 0.4
+
+// pseudoLocalize replaces the letters of s with accented look-alikes, pads it to
+// simulate longer translations and wraps it in markers, e.g. "Hello" becomes "⟦Ĥéļļö~~⟧"
+func pseudoLocalize(s string) string {
+	var sb strings.Builder
+	sb.Grow(len(s)*2 + 8)
+	sb.WriteString("⟦")
+	for _, r := range s {
+		if p, ok := pseudoChars[r]; ok {
+			r = p
+		}
+		sb.WriteRune(r)
+	}
+	pad := int(float64(utf8.RuneCountInString(s))*pseudoExpansion + 0.5)
+	sb.WriteString(strings.Repeat("~", pad))
+	sb.WriteString("⟧")
+	return sb.String()
+}
+
+// pseudoTranslator wraps the translator so every translated string is pseudo-localized
+func pseudoTranslator(translate func(string, ...string) string) func(string, ...string) string {
+	return func(key string, args ...string) string {
+		return pseudoLocalize(translate(key, args...))
+	}
+}
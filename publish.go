@@ -0,0 +1,253 @@
+package templatex
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// frontMatterDelim delimits the YAML front matter at the start of a template file
+const frontMatterDelim = "---"
+
+// frontMatter is the metadata a template file can start with:
+//
+//	---
+//	publish_at: 2024-11-29T00:00:00Z
+//	unpublish_at: 2024-12-02T00:00:00Z
+//	fallback: banners/default
+//	requires: admin
+//	title: Black Friday
+//	description: Deals of the week
+//	noindex: true
+//	extends: base_layout
+//	---
+type frontMatter struct {
+	PublishAt   *time.Time `yaml:"publish_at"`   // the template is rendered from this time
+	UnpublishAt *time.Time `yaml:"unpublish_at"` // the template is not rendered from this time
+	Fallback    string     `yaml:"fallback"`     // template rendered outside the publish window
+	Requires    string     `yaml:"requires"`     // permission required to render the template
+	Title       string     `yaml:"title"`        // page title set when the template is rendered
+	Description string     `yaml:"description"`  // description meta tag set when the template is rendered
+	Noindex     bool       `yaml:"noindex"`      // the page must not be indexed by search engines
+	Nofollow    bool       `yaml:"nofollow"`     // links of the page must not be followed by search engines
+	Extends     string     `yaml:"extends"`      // layout wrapping the template (see parseExtends)
+}
+
+// publishWindow is the time window a template is rendered in
+type publishWindow struct {
+	publishAt   time.Time
+	unpublishAt time.Time
+	fallback    string
+}
+
+// active reports whether the time is within the window
+func (w publishWindow) active(now time.Time) bool {
+	if !w.publishAt.IsZero() && now.Before(w.publishAt) {
+		return false
+	}
+	return w.unpublishAt.IsZero() || now.Before(w.unpublishAt)
+}
+
+// parseFrontMatter removes the front matter from the template content and returns
+// the publish window it declares, or nil if there is none, and the front matter.
+// Templates with a window are wrapped in a condition, so they render their fallback
+// (or nothing) outside the window even when included by other templates.
+// The layout declared by an extends directive is returned in the front matter too.
+func parseFrontMatter(name string, content []byte) ([]byte, *publishWindow, frontMatter, error) {
+	var fm frontMatter
+	meta, body, ok, err := splitFrontMatter(content, false)
+	if err != nil {
+		return nil, nil, fm, err
+	}
+	if !ok {
+		if fm.Extends, err = parseExtends(content); err != nil {
+			return nil, nil, fm, err
+		}
+		return content, nil, fm, nil
+	}
+
+	dec := yaml.NewDecoder(bytes.NewReader(meta))
+	dec.KnownFields(true)
+	if err := dec.Decode(&fm); err != nil && len(bytes.TrimSpace(meta)) > 0 {
+		return nil, nil, fm, fmt.Errorf("invalid front matter: %w", err)
+	}
+	if fm.Extends == "" {
+		if fm.Extends, err = parseExtends(body); err != nil {
+			return nil, nil, fm, err
+		}
+	} else if hasDefine(body) {
+		return nil, nil, fm, errors.New("extends is not supported in files with define blocks")
+	}
+	if fm.Requires != "" && hasDefine(body) {
+		return nil, nil, fm, errors.New("required permissions are not supported in files with define blocks")
+	}
+	if fm.Title != "" || fm.Description != "" || fm.Noindex || fm.Nofollow {
+		if hasDefine(body) {
+			return nil, nil, fm, errors.New("title, description and robots directives are not supported in files with define blocks")
+		}
+		var page bytes.Buffer
+		if fm.Title != "" {
+			page.WriteString("{{ setTitle " + strconv.Quote(fm.Title) + " }}")
+		}
+		if fm.Description != "" {
+			page.WriteString(`{{ setMeta "description" ` + strconv.Quote(fm.Description) + " }}")
+		}
+		if fm.Noindex {
+			page.WriteString(`{{ setRobots "noindex" }}`)
+		}
+		if fm.Nofollow {
+			page.WriteString(`{{ setRobots "nofollow" }}`)
+		}
+		body = append(page.Bytes(), body...)
+	}
+	if fm.PublishAt == nil && fm.UnpublishAt == nil {
+		return body, nil, fm, nil
+	}
+
+	w := &publishWindow{fallback: fm.Fallback}
+	if fm.PublishAt != nil {
+		w.publishAt = *fm.PublishAt
+	}
+	if fm.UnpublishAt != nil {
+		w.unpublishAt = *fm.UnpublishAt
+	}
+	if !w.publishAt.IsZero() && !w.unpublishAt.IsZero() && !w.unpublishAt.After(w.publishAt) {
+		return nil, nil, fm, errors.New("unpublish_at must be after publish_at")
+	}
+	if hasDefine(body) {
+		return nil, nil, fm, errors.New("publish windows are not supported in files with define blocks")
+	}
+
+	var wrapped bytes.Buffer
+	wrapped.WriteString("{{ if isPublished " + strconv.Quote(name) + " }}")
+	wrapped.Write(body)
+	if w.fallback != "" {
+		wrapped.WriteString("{{ else }}{{ template " + strconv.Quote(w.fallback) + " . }}")
+	}
+	wrapped.WriteString("{{ end }}")
+	return wrapped.Bytes(), w, fm, nil
+}
+
+// frontMatterKeys are the keys of frontMatter
+var frontMatterKeys = map[string]bool{
+	"publish_at": true, "unpublish_at": true, "fallback": true, "requires": true, "title": true,
+	"description": true, "noindex": true, "nofollow": true, "extends": true,
+}
+
+// splitFrontMatter splits the content into the front matter without delimiters and
+// the body. ok is false if the content has no front matter: the block between the
+// "---" lines is only front matter if it's empty or declares a known key, or any key
+// with anyKey, so content starting with a horizontal rule is left as is.
+func splitFrontMatter(content []byte, anyKey bool) (meta, body []byte, ok bool, err error) {
+	rest, ok := bytes.CutPrefix(content, []byte(frontMatterDelim+"\n"))
+	if !ok {
+		if rest, ok = bytes.CutPrefix(content, []byte(frontMatterDelim+"\r\n")); !ok {
+			return nil, content, false, nil
+		}
+	}
+
+	for i := 0; i < len(rest); {
+		end := bytes.IndexByte(rest[i:], '\n')
+		line := rest[i:]
+		if end >= 0 {
+			line = rest[i : i+end+1]
+		}
+		if string(bytes.TrimRight(line, "\r\n")) == frontMatterDelim {
+			if !isFrontMatter(rest[:i], anyKey) {
+				return nil, content, false, nil
+			}
+			return rest[:i], rest[i+len(line):], true, nil
+		}
+		if end < 0 {
+			break
+		}
+		i += end + 1
+	}
+	if isFrontMatter(rest, anyKey) {
+		return nil, nil, false, errors.New("front matter is not closed")
+	}
+	return nil, content, false, nil
+}
+
+// isFrontMatter reports whether the block is empty or a YAML mapping with a known
+// key, or any key with anyKey. Blocks with invalid YAML are front matter if a line
+// starts with a known key, so syntax errors are reported rather than the block rendered.
+func isFrontMatter(meta []byte, anyKey bool) bool {
+	if len(bytes.TrimSpace(meta)) == 0 {
+		return true
+	}
+	var doc yaml.Node
+	if err := yaml.Unmarshal(meta, &doc); err != nil {
+		for _, line := range bytes.Split(meta, []byte("\n")) {
+			if key, _, ok := bytes.Cut(line, []byte(":")); ok && frontMatterKeys[string(key)] {
+				return true
+			}
+		}
+		return false
+	}
+	if len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+		return false
+	}
+	mapping := doc.Content[0].Content
+	if anyKey {
+		return len(mapping) > 0
+	}
+	for i := 0; i < len(mapping); i += 2 {
+		if frontMatterKeys[mapping[i].Value] {
+			return true
+		}
+	}
+	return false
+}
+
+// hasDefine reports whether the template content defines templates
+func hasDefine(content []byte) bool {
+	return bytes.Contains(content, []byte("{{define")) || bytes.Contains(content, []byte("{{ define"))
+}
+
+// isPublished reports whether the template is within its publish window.
+// Templates without front matter are always published.
+// Usage: {{ if isPublished "banners/promo" }}...{{ end }}
+func (e *Engine) isPublished(name string) bool {
+	e.mu.RLock()
+	w, ok := e.schedules[name]
+	e.mu.RUnlock()
+	return !ok || w.active(e.clock())
+}
+
+// checkPublished returns ErrTemplateNotPublished if the template is rendered
+// directly outside its publish window and has no fallback
+func (e *Engine) checkPublished(name string) error {
+	e.mu.RLock()
+	w, ok := e.schedules[name]
+	e.mu.RUnlock()
+	if ok && w.fallback == "" && !w.active(e.clock()) {
+		return errors.Join(ErrTemplateNotPublished, fmt.Errorf("template: %s", name))
+	}
+	return nil
+}
+
+// scheduleState returns the names of scheduled templates currently published,
+// which is added to cache keys, so cached renders change when a window opens or closes
+func (e *Engine) scheduleState() string {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	if len(e.schedules) == 0 {
+		return ""
+	}
+	now := e.clock()
+	active := make([]string, 0, len(e.schedules))
+	for name, w := range e.schedules {
+		if w.active(now) {
+			active = append(active, name)
+		}
+	}
+	sort.Strings(active)
+	return "|published:" + strings.Join(active, ",")
+}
@@ -0,0 +1,148 @@
+package templatex
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"html"
+	"strings"
+)
+
+// PushConn is a connection fragments are pushed to, e.g. a thin wrapper
+// around a WebSocket connection writing text messages
+type PushConn interface {
+	Write([]byte) error
+}
+
+// PushFormat is the framing of pushed fragments
+type PushFormat int
+
+const (
+	// PushTurboStream frames fragments as Turbo Stream elements (Hotwire)
+	PushTurboStream PushFormat = iota
+	// PushHTMX frames fragments as htmx out-of-band swaps (htmx ws extension)
+	PushHTMX
+	// PushJSON frames fragments as JSON messages {"target": ..., "action": ..., "html": ...}
+	// for custom clients
+	PushJSON
+)
+
+// PushAction is the DOM update performed by the client
+type PushAction string
+
+const (
+	PushReplace PushAction = "replace" // replace the target element
+	PushUpdate  PushAction = "update"  // replace the target's content
+	PushAppend  PushAction = "append"  // append to the target's content
+	PushPrepend PushAction = "prepend" // prepend to the target's content
+	PushRemove  PushAction = "remove"  // remove the target element
+)
+
+// htmxSwaps maps push actions to htmx swap strategies
+var htmxSwaps = map[PushAction]string{
+	PushReplace: "outerHTML",
+	PushUpdate:  "innerHTML",
+	PushAppend:  "beforeend",
+	PushPrepend: "afterbegin",
+	PushRemove:  "delete",
+}
+
+// PushOption configures RenderPush
+type PushOption func(*pushConfig)
+
+// pushConfig holds the RenderPush settings
+type pushConfig struct {
+	target string
+	action PushAction
+	format PushFormat
+}
+
+// WithPushTarget sets the DOM ID of the updated element.
+// Defaults to the fragment name with "/" and "." replaced by "-".
+func WithPushTarget(id string) PushOption {
+	return func(c *pushConfig) {
+		c.target = id
+	}
+}
+
+// WithPushAction sets the DOM update performed by the client. Defaults to PushUpdate.
+func WithPushAction(action PushAction) PushOption {
+	return func(c *pushConfig) {
+		c.action = action
+	}
+}
+
+// WithPushFormat sets the framing of the message. Defaults to PushTurboStream.
+func WithPushFormat(format PushFormat) PushOption {
+	return func(c *pushConfig) {
+		c.format = format
+	}
+}
+
+// RenderPush renders a fragment and writes it to the connection framed for
+// live-view-style clients (see PushFormat), enabling server-driven partial
+// updates over WebSockets using existing templates:
+//
+//	err := engine.RenderPush(ctx, conn, "components/cart", cart,
+//		templatex.WithPushTarget("cart"), templatex.WithPushAction(templatex.PushReplace))
+//
+// Nothing is written if rendering fails. Write errors are wrapped with ErrPushFailed.
+func (e *Engine) RenderPush(ctx context.Context, conn PushConn, fragment string, data interface{}, opts ...PushOption) error {
+	cfg := &pushConfig{
+		target: strings.NewReplacer("/", "-", ".", "-").Replace(fragment),
+		action: PushUpdate,
+	}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	var content bytes.Buffer
+	if cfg.action != PushRemove {
+		if err := e.Render(ctx, &content, fragment, data, NoLayout); err != nil {
+			return err
+		}
+	}
+
+	msg, err := framePush(cfg, content.Bytes())
+	if err != nil {
+		return errors.Join(ErrPushFailed, err)
+	}
+	if err := conn.Write(msg); err != nil {
+		return errors.Join(ErrPushFailed, err)
+	}
+	return nil
+}
+
+// framePush frames the rendered content according to the push settings
+func framePush(cfg *pushConfig, content []byte) ([]byte, error) {
+	target := html.EscapeString(cfg.target)
+
+	var buf bytes.Buffer
+	switch cfg.format {
+	case PushHTMX:
+		swap, ok := htmxSwaps[cfg.action]
+		if !ok {
+			return nil, errors.New("unsupported push action: " + string(cfg.action))
+		}
+		buf.WriteString(`<div id="` + target + `" hx-swap-oob="` + swap + `">`)
+		buf.Write(content)
+		buf.WriteString(`</div>`)
+	case PushJSON:
+		return json.Marshal(struct {
+			Target string     `json:"target"`
+			Action PushAction `json:"action"`
+			HTML   string     `json:"html,omitempty"`
+		}{cfg.target, cfg.action, string(content)})
+	default:
+		action := html.EscapeString(string(cfg.action))
+		buf.WriteString(`<turbo-stream action="` + action + `" target="` + target + `">`)
+		if cfg.action != PushRemove {
+			buf.WriteString(`<template>`)
+			buf.Write(content)
+			buf.WriteString(`</template>`)
+		}
+		buf.WriteString(`</turbo-stream>`)
+	}
+	return buf.Bytes(), nil
+}
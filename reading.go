@@ -0,0 +1,143 @@
+package templatex
+
+import (
+	"context"
+	"fmt"
+	"html/template"
+	"strings"
+	"unicode"
+
+	"github.com/invopop/ctxi18n"
+	"golang.org/x/net/html"
+	"golang.org/x/text/language"
+)
+
+// readingSpeeds are the average silent reading speeds in words per minute by language.
+// Chinese and Japanese are counted in characters per minute, as wordCount counts their
+// characters as words. The "" key is the speed of other languages.
+var readingSpeeds = map[string]int{
+	"":   200,
+	"en": 228, "de": 179, "fr": 195, "es": 218, "it": 188, "pt": 181, "nl": 202,
+	"sv": 199, "fi": 161, "pl": 166, "ru": 184, "tr": 166, "ar": 138, "he": 187,
+	"zh": 255, "ja": 357,
+}
+
+// inlineElements are elements that don't separate words, e.g. "<b>re</b>read"
+var inlineElements = map[string]bool{
+	"a": true, "abbr": true, "b": true, "bdi": true, "bdo": true, "cite": true, "code": true,
+	"data": true, "del": true, "dfn": true, "em": true, "i": true, "ins": true, "kbd": true,
+	"mark": true, "q": true, "s": true, "samp": true, "small": true, "span": true,
+	"strong": true, "sub": true, "sup": true, "time": true, "u": true, "var": true,
+}
+
+// wordCount returns the number of words of the text. HTML is reduced to its text
+// first, skipping script and style elements. Chinese and Japanese characters are
+// counted as words.
+// Usage: {{ wordCount .Body }}
+func wordCount(v any) int {
+	return countWords(plainText(v))
+}
+
+// readingTime returns a function estimating the minutes needed to read the text at
+// the reading speed of the context locale language, or the given words per minute.
+// The estimate is rounded up; empty texts take 0 minutes.
+// Usage: {{ readingTime .Body }} min read, {{ readingTime .Body 300 }}
+func readingTime(ctx context.Context, speeds map[string]int) func(v any, wpm ...int) (int, error) {
+	speed := speeds[""]
+	if l := ctxi18n.Locale(ctx); l != nil {
+		if tag, err := language.Parse(l.Code().String()); err == nil {
+			base, _ := tag.Base()
+			if s, ok := speeds[tag.String()]; ok {
+				speed = s
+			} else if s, ok := speeds[base.String()]; ok {
+				speed = s
+			}
+		}
+	}
+	return func(v any, wpm ...int) (int, error) {
+		s := speed
+		if len(wpm) > 0 {
+			s = wpm[0]
+		}
+		if s <= 0 {
+			return 0, fmt.Errorf("readingTime: invalid reading speed %d", s)
+		}
+		words := wordCount(v)
+		return (words + s - 1) / s, nil
+	}
+}
+
+// plainText returns the text content of the value. Strings and template.HTML are
+// parsed as HTML, so entities are decoded and markup is dropped.
+func plainText(v any) string {
+	var s string
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		s = v
+	case template.HTML:
+		s = string(v)
+	default:
+		s = fmt.Sprint(v)
+	}
+	if !strings.ContainsAny(s, "<&") {
+		return s
+	}
+
+	var sb strings.Builder
+	z := html.NewTokenizer(strings.NewReader(s))
+	skip := 0
+	for {
+		tt := z.Next()
+		switch tt {
+		case html.ErrorToken:
+			return sb.String()
+		case html.TextToken:
+			if skip == 0 {
+				sb.Write(z.Text())
+			}
+		case html.StartTagToken, html.SelfClosingTagToken, html.EndTagToken:
+			name, _ := z.TagName()
+			switch tag := string(name); {
+			case tag == "script" || tag == "style":
+				if tt == html.StartTagToken {
+					skip++
+				} else if tt == html.EndTagToken && skip > 0 {
+					skip--
+				}
+			case !inlineElements[tag]:
+				sb.WriteByte(' ')
+			}
+		}
+	}
+}
+
+// countWords counts runs of non-space characters containing at least one letter or
+// digit, and Chinese and Japanese characters individually
+func countWords(s string) int {
+	count := 0
+	inWord, hasAlnum := false, false
+	flush := func() {
+		if inWord && hasAlnum {
+			count++
+		}
+		inWord, hasAlnum = false, false
+	}
+	for _, r := range s {
+		switch {
+		case unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana):
+			flush()
+			count++
+		case unicode.IsSpace(r):
+			flush()
+		default:
+			inWord = true
+			if unicode.IsLetter(r) || unicode.IsDigit(r) {
+				hasAlnum = true
+			}
+		}
+	}
+	flush()
+	return count
+}
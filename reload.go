@@ -0,0 +1,25 @@
+package templatex
+
+// Reload re-parses all templates from the template directory and replaces the
+// current ones, e.g. from an admin endpoint or a SIGHUP handler. Templates are parsed
+// before the swap, so renders are not blocked while parsing and keep using the current
+// templates if parsing fails. The render and layout caches are cleared.
+//
+// Returns an error if the engine is not initialized or parsing fails.
+func (e *Engine) Reload() error {
+	if !e.initialized() {
+		return ErrTemplateEngineNotInitialized
+	}
+	e.loadMu.Lock()
+	defer e.loadMu.Unlock()
+	return e.load()
+}
+
+// Close stops watching the template directory (see WithAutoReload).
+// It's safe to call Close on an engine without auto reload.
+func (e *Engine) Close() error {
+	if e == nil || e.watcher == nil {
+		return nil
+	}
+	return e.watcher.Close()
+}
@@ -0,0 +1,212 @@
+package templatex
+
+import (
+	"fmt"
+	"html/template"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// defaultTitleSeparator is used by pageTitle to join title segments
+const defaultTitleSeparator = " | "
+
+// renderState holds values accumulated during a single Render call.
+// Since the content template is executed before the layouts, values set
+// by the page (e.g. title or meta tags) are readable in the outer layouts.
+type renderState struct {
+	mu        sync.Mutex
+	title     []string
+	meta      map[string]string
+	metaOrder []string
+	robots    []string // robots directives, e.g. noindex
+	noindex   bool     // pages are never indexed, e.g. outside production
+	stacks    map[string][]string
+	once      map[string]struct{}
+}
+
+// newRenderState creates an empty render state
+func newRenderState() *renderState {
+	return &renderState{
+		meta:   make(map[string]string),
+		stacks: make(map[string][]string),
+		once:   make(map[string]struct{}),
+	}
+}
+
+// funcs returns template functions bound to the render state
+func (s *renderState) funcs() template.FuncMap {
+	return template.FuncMap{
+		"setTitle":    s.setTitle,
+		"appendTitle": s.appendTitle,
+		"pageTitle":   s.pageTitle,
+		"setMeta":     s.setMeta,
+		"meta":        s.getMeta,
+		"metaTags":    s.metaTags,
+		"setRobots":   s.setRobots,
+		"robotsMeta":  s.robotsMeta,
+		"push":        s.push,
+		"stack":       s.stack,
+		"once":        s.doOnce,
+	}
+}
+
+// setTitle replaces the page title segments with the given title.
+// Usage: {{ setTitle "Settings" }}
+func (s *renderState) setTitle(title string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.title = []string{title}
+	return ""
+}
+
+// appendTitle appends a segment to the page title.
+// Usage: {{ appendTitle "My App" }}
+func (s *renderState) appendTitle(title string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.title = append(s.title, title)
+	return ""
+}
+
+// pageTitle returns the page title segments joined by the separator.
+// The default separator is " | ".
+// Usage: <title>{{ pageTitle }}</title> or <title>{{ pageTitle " - " }}</title>
+func (s *renderState) pageTitle(sep ...string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	separator := defaultTitleSeparator
+	if len(sep) > 0 {
+		separator = sep[0]
+	}
+	return strings.Join(s.title, separator)
+}
+
+// setMeta sets the content of a meta tag with the given name.
+// Usage: {{ setMeta "description" "Account settings" }}
+func (s *renderState) setMeta(name, content string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.meta[name]; !ok {
+		s.metaOrder = append(s.metaOrder, name)
+	}
+	s.meta[name] = content
+	return ""
+}
+
+// getMeta returns the content of a meta tag with the given name,
+// or an empty string if it's not set.
+// Usage: {{ meta "description" }}
+func (s *renderState) getMeta(name string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.meta[name]
+}
+
+// metaTags renders all meta tags set during the render in the order they were first set.
+// Usage: <head>{{ metaTags }}</head>
+func (s *renderState) metaTags() template.HTML {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var sb strings.Builder
+	for _, name := range s.metaOrder {
+		sb.WriteString(`<meta name="`)
+		sb.WriteString(template.HTMLEscapeString(name))
+		sb.WriteString(`" content="`)
+		sb.WriteString(template.HTMLEscapeString(s.meta[name]))
+		sb.WriteString(`">`)
+	}
+	return template.HTML(sb.String())
+}
+
+// setRobots adds directives to the robots meta tag, e.g. "noindex" or "nofollow".
+// Templates can declare noindex and nofollow in front matter instead.
+// Usage: {{ setRobots "noindex" "nofollow" }}
+func (s *renderState) setRobots(directives ...string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, d := range directives {
+		d = strings.ToLower(strings.TrimSpace(d))
+		if d != "" && !slices.Contains(s.robots, d) {
+			s.robots = append(s.robots, d)
+		}
+	}
+	return ""
+}
+
+// robotsMeta renders the robots meta tag with the directives set during the render,
+// or nothing if there are none. Outside production (see WithEnvironment), pages are
+// always marked noindex and nofollow, so staging sites don't end up in search results.
+// Usage: <head>{{ robotsMeta }}</head>
+func (s *renderState) robotsMeta() template.HTML {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	robots := s.robots
+	if s.noindex {
+		robots = []string{"noindex", "nofollow"}
+		for _, d := range s.robots {
+			if d != "index" && d != "follow" && !slices.Contains(robots, d) {
+				robots = append(robots, d)
+			}
+		}
+	}
+	if len(robots) == 0 {
+		return ""
+	}
+	return template.HTML(`<meta name="robots" content="` + template.HTMLEscapeString(strings.Join(robots, ", ")) + `">`)
+}
+
+// push adds content to the named stack. Identical content is added only once,
+// so components rendered multiple times contribute their assets a single time.
+// Only trusted content is rendered as is: template.HTML, and template.JS and
+// template.CSS wrapped in script and style elements. Strings are HTML-escaped.
+// Usage: {{ push "scripts" (htmlSafe "<script src=\"/js/chart.js\"></script>") }}
+func (s *renderState) push(name string, content any) (string, error) {
+	var str string
+	switch c := content.(type) {
+	case template.HTML:
+		str = string(c)
+	case template.JS:
+		str = "<script>" + string(c) + "</script>"
+	case template.CSS:
+		str = "<style>" + string(c) + "</style>"
+	case string:
+		str = template.HTMLEscapeString(c)
+	default:
+		return "", fmt.Errorf("push: unsupported content type %T", content)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, item := range s.stacks[name] {
+		if item == str {
+			return "", nil
+		}
+	}
+	s.stacks[name] = append(s.stacks[name], str)
+	return "", nil
+}
+
+// stack renders the content pushed to the named stack in the order it was pushed.
+// Since layouts are executed after the content template, the stack should be
+// rendered in a layout to include everything pushed by the page and its components.
+// Usage: <head>{{ stack "styles" }}</head> ... {{ stack "scripts" }}</body>
+func (s *renderState) stack(name string) template.HTML {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return template.HTML(strings.Join(s.stacks[name], "\n"))
+}
+
+// doOnce reports whether the named block is requested for the first time during
+// the render. It allows repeated components to include a shared dependency
+// (script, style, SVG sprite) exactly once per page.
+// Usage: {{ if once "analytics-snippet" }}<script>...</script>{{ end }}
+func (s *renderState) doOnce(name string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.once[name]; ok {
+		return false
+	}
+	s.once[name] = struct{}{}
+	return true
+}
@@ -0,0 +1,196 @@
+package templatex
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ConfigSnapshot is a copy of the engine configuration (see Engine.Config)
+type ConfigSnapshot struct {
+	Root                 string        // template directory passed to New, or the root in the file system passed to NewFS
+	Extensions           []string      // template file extensions
+	Layouts              []string      // common layouts (see WithLayouts)
+	LayoutChains         [][]string    // declared layout chains (see WithLayoutChain)
+	DefaultLayouts       []string      // layouts of renders without layouts (see WithDefaultLayout)
+	ConventionLayouts    bool          // _layout templates wrap the templates of their directories (see WithConventionLayouts)
+	Funcs                []string      // names of all template functions, sorted
+	CustomFuncs          []string      // names of custom functions, including function pack ones, sorted
+	FuncPacks            []string      // names of function packs (see WithFuncPacks)
+	Environment          string        // environment name (see WithEnvironment)
+	Locales              []string      // supported locale codes (see WithLocales)
+	PseudoLocale         bool          // translated strings are pseudo-localized
+	HardCache            bool          // hard caching enabled (see WithHardCache)
+	CacheTTL             time.Duration // render cache entry lifetime, zero if unlimited (see WithCacheTTL)
+	CacheMaxEntries      int           // render cache size limit, zero if unlimited (see WithCacheMaxEntries)
+	CacheEncodings       []string      // content encodings of cached renders (see WithCacheEncoding)
+	LayoutCache          bool          // layout chain caching enabled (see WithLayoutCache)
+	PrecompiledChains    bool          // pages and layouts are executed as one template set (see WithPrecompiledChains)
+	AutoReload           bool          // templates are reloaded on changes (see WithAutoReload)
+	CaseInsensitiveNames bool          // template names are matched ignoring case
+	HTMLValidation       bool          // rendered HTML is validated
+	MinifyOutput         bool          // rendered HTML is minified (see WithMinifyOutput)
+	HTMXForms            bool          // form components are submitted by htmx (see WithHTMXForms)
+	HeadingAnchors       bool          // headings get ids and anchor links (see WithHeadingAnchors)
+	CSSInlining          []string      // directories of templates with inlined CSS (see WithCSSInlining)
+	SignedURLs           bool          // signedURL is enabled (see WithURLSigner)
+	Consent              bool          // consent resolver is set (see WithConsentResolver)
+	Markdown             bool          // Markdown templates are enabled (see WithMarkdown)
+	BaseURL              string        // base URL of absolute URLs, empty for the request host (see WithBaseURL)
+	AccessibilityCheck   bool          // rendered HTML is checked for accessibility issues
+	DebugToolbar         bool          // debug toolbar enabled (only injected in development)
+	FakeFuncs            bool          // fake data functions enabled outside of development
+	SourceVerification   bool          // template sources are verified (see WithSourceVerification)
+}
+
+// Report summarizes the engine state for startup logging and diagnostics
+// (see Engine.Report)
+type Report struct {
+	Config        ConfigSnapshot
+	Templates     int      // number of parsed templates
+	CachedRenders int      // number of cached renders
+	CachedChains  int      // number of cached layout chains
+	Warnings      []string // likely misconfigurations
+	TemplateNames []string // names of parsed templates, sorted
+}
+
+// String returns a single-line summary of the report
+func (r Report) String() string {
+	s := fmt.Sprintf("templatex: root=%s env=%s templates=%d funcs=%d layouts=[%s] hard_cache=%t layout_cache=%t cached_renders=%d",
+		r.Config.Root, r.Config.Environment, r.Templates, len(r.Config.Funcs), strings.Join(r.Config.Layouts, ","),
+		r.Config.HardCache, r.Config.LayoutCache, r.CachedRenders)
+	if len(r.Warnings) > 0 {
+		s += " warnings=[" + strings.Join(r.Warnings, "; ") + "]"
+	}
+	return s
+}
+
+// Config returns a snapshot of the engine configuration
+func (e *Engine) Config() ConfigSnapshot {
+	if e == nil {
+		return ConfigSnapshot{}
+	}
+
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	root := e.root
+	if e.dir != "" {
+		root = e.dir
+	}
+
+	chains := make([][]string, len(e.layoutChains))
+	for i, chain := range e.layoutChains {
+		chains[i] = append([]string(nil), chain...)
+	}
+
+	packs := make([]string, len(e.funcPacks))
+	for i, pack := range e.funcPacks {
+		packs[i] = pack.Name()
+	}
+
+	encodings := make([]string, len(e.encodings))
+	for i, enc := range e.encodings {
+		encodings[i] = enc.name
+	}
+
+	return ConfigSnapshot{
+		Root:                 root,
+		Extensions:           append([]string(nil), e.exts...),
+		Layouts:              append([]string(nil), e.commonLayouts...),
+		LayoutChains:         chains,
+		DefaultLayouts:       append([]string(nil), e.defaultLayouts...),
+		ConventionLayouts:    e.layoutConvention,
+		Funcs:                sortedKeys(e.funcMap),
+		CustomFuncs:          sortedKeys(e.customFuncs),
+		FuncPacks:            packs,
+		Environment:          e.env,
+		Locales:              append([]string(nil), e.locales...),
+		PseudoLocale:         e.pseudoLocale,
+		HardCache:            e.cacheEnable,
+		CacheTTL:             e.cache.ttl,
+		CacheMaxEntries:      e.cache.max,
+		CacheEncodings:       encodings,
+		LayoutCache:          e.layoutCacheEnable,
+		PrecompiledChains:    e.precompileChains,
+		AutoReload:           e.autoReload,
+		CaseInsensitiveNames: e.caseInsensitive,
+		HTMLValidation:       e.htmlValidation,
+		MinifyOutput:         e.minifyOutput,
+		HTMXForms:            e.htmxForms,
+		HeadingAnchors:       e.headingAnchors != nil,
+		CSSInlining:          append([]string(nil), e.cssInlineDirs...),
+		SignedURLs:           e.urlSigner != nil,
+		Consent:              e.consentResolver != nil,
+		Markdown:             e.markdownFn != nil,
+		BaseURL:              e.rawBaseURL,
+		AccessibilityCheck:   e.a11yCheck,
+		DebugToolbar:         e.debugToolbar,
+		FakeFuncs:            e.fakeFuncs,
+		SourceVerification:   e.sourceKey != nil,
+	}
+}
+
+// Report returns a summary of the engine configuration and state, including
+// warnings about likely misconfigurations, e.g. development features enabled
+// in production.
+func (e *Engine) Report() Report {
+	if e == nil {
+		return Report{}
+	}
+
+	r := Report{Config: e.Config()}
+
+	e.mu.RLock()
+	if e.templates != nil {
+		for _, t := range e.templates.Templates() {
+			if t.Name() != "" {
+				r.TemplateNames = append(r.TemplateNames, t.Name())
+			}
+		}
+	}
+	e.mu.RUnlock()
+	sort.Strings(r.TemplateNames)
+	r.Templates = len(r.TemplateNames)
+
+	r.CachedRenders = e.cache.len()
+	e.layoutCache.Range(func(_, _ any) bool {
+		r.CachedChains++
+		return true
+	})
+
+	c := r.Config
+	if c.Environment != EnvDevelopment {
+		if c.AutoReload {
+			r.Warnings = append(r.Warnings, "auto reload is enabled outside of development")
+		}
+		if c.DebugToolbar {
+			r.Warnings = append(r.Warnings, "debug toolbar is enabled but only injected in development")
+		}
+		if c.FakeFuncs {
+			r.Warnings = append(r.Warnings, "fake data functions are enabled outside of development")
+		}
+	}
+	if c.Environment == EnvProduction && c.PseudoLocale {
+		r.Warnings = append(r.Warnings, "pseudo-localization is enabled in production")
+	}
+	if c.Environment == EnvProduction && (c.HTMLValidation || c.AccessibilityCheck) {
+		r.Warnings = append(r.Warnings, "HTML checks add overhead to every uncached render in production")
+	}
+	if c.HardCache && c.AutoReload {
+		r.Warnings = append(r.Warnings, "hard cache is enabled with auto reload")
+	}
+
+	return r
+}
+
+// sortedKeys returns the keys of the map in ascending order
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,153 @@
+package templatex
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"path"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// assetDir is a file system with assets served under the URL prefix
+type assetDir struct {
+	fsys   fs.FS
+	prefix string
+}
+
+// htmlRewriter post-processes rendered HTML for constrained render modes
+// such as print and lite
+type htmlRewriter struct {
+	strip    map[string]bool // elements removed together with their content
+	hideAttr string          // attribute marking elements to remove
+	assets   []assetDir      // assets used to inline linked stylesheets
+}
+
+// rewrite removes stripped and hidden elements and event handler attributes
+// from the content and inlines linked stylesheets found in the assets
+func (rw *htmlRewriter) rewrite(content string) (string, error) {
+	var sb strings.Builder
+	sb.Grow(len(content))
+
+	skipTag := ""
+	skipDepth := 0
+
+	z := html.NewTokenizer(strings.NewReader(content))
+	for {
+		tt := z.Next()
+		if tt == html.ErrorToken {
+			if err := z.Err(); !errors.Is(err, io.EOF) {
+				return "", err
+			}
+			break
+		}
+		raw := string(z.Raw())
+
+		// Skip the content of a removed element until its end tag
+		if skipDepth > 0 {
+			if tt == html.StartTagToken || tt == html.EndTagToken {
+				if name, _ := z.TagName(); string(name) == skipTag {
+					if tt == html.StartTagToken {
+						skipDepth++
+					} else {
+						skipDepth--
+					}
+				}
+			}
+			continue
+		}
+
+		if tt != html.StartTagToken && tt != html.SelfClosingTagToken {
+			if tt == html.EndTagToken {
+				if name, _ := z.TagName(); rw.strip[string(name)] {
+					continue
+				}
+			}
+			sb.WriteString(raw)
+			continue
+		}
+
+		tok := z.Token()
+		if rw.strip[tok.Data] || (rw.hideAttr != "" && hasAttr(tok, rw.hideAttr)) {
+			if tt == html.StartTagToken && !voidElements[tok.Data] {
+				skipTag, skipDepth = tok.Data, 1
+			}
+			continue
+		}
+
+		if tok.Data == "link" && strings.EqualFold(attrValue(tok, "rel"), "stylesheet") {
+			css, found, err := rw.readAsset(attrValue(tok, "href"))
+			if err != nil {
+				return "", err
+			}
+			if found {
+				sb.WriteString("<style>")
+				sb.Write(css)
+				sb.WriteString("</style>")
+				continue
+			}
+		}
+
+		// Drop event handlers, re-encoding the tag only if it changed
+		attrs := tok.Attr[:0]
+		for _, a := range tok.Attr {
+			if !strings.HasPrefix(a.Key, "on") {
+				attrs = append(attrs, a)
+			}
+		}
+		if len(attrs) == len(tok.Attr) {
+			sb.WriteString(raw)
+			continue
+		}
+		tok.Attr = attrs
+		sb.WriteString(tok.String())
+	}
+
+	return sb.String(), nil
+}
+
+// readAsset reads the file linked by the URL from the assets.
+// It reports false if the URL is external or the file is not found.
+func (rw *htmlRewriter) readAsset(href string) ([]byte, bool, error) {
+	target, internal := resolveInternalLink("/", href)
+	if !internal {
+		return nil, false, nil
+	}
+	for _, a := range rw.assets {
+		prefix := path.Join("/", a.prefix)
+		rel := strings.TrimPrefix(target, strings.TrimSuffix(prefix, "/")+"/")
+		if rel == target && prefix != "/" {
+			continue
+		}
+		b, err := fs.ReadFile(a.fsys, strings.TrimPrefix(rel, "/"))
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, false, err
+		}
+		return b, true, nil
+	}
+	return nil, false, nil
+}
+
+// hasAttr reports whether the token has the attribute
+func hasAttr(tok html.Token, key string) bool {
+	for _, a := range tok.Attr {
+		if a.Key == key {
+			return true
+		}
+	}
+	return false
+}
+
+// attrValue returns the value of the token attribute or an empty string
+func attrValue(tok html.Token, key string) string {
+	for _, a := range tok.Attr {
+		if a.Key == key {
+			return a.Val
+		}
+	}
+	return ""
+}
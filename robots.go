@@ -0,0 +1,23 @@
+package templatex
+
+import "sort"
+
+// NoindexTemplates returns the names of the templates declaring noindex in front
+// matter, sorted, e.g. to exclude them from sitemaps or to review the SEO policy.
+// Templates setting robots directives with setRobots are not listed.
+func (e *Engine) NoindexTemplates() []string {
+	if !e.initialized() {
+		return nil
+	}
+	// Templates failing to parse lazily are left out, as they can't be rendered
+	_ = e.ensureAllParsed()
+
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	names := make([]string, 0, len(e.noindex))
+	for name := range e.noindex {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,185 @@
+package templatex
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"html/template"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// sectionSeparator separates the template name and the section name in the names
+// of section templates, e.g. "pages/home#scripts"
+const sectionSeparator = "#"
+
+// sectionAction matches the action opening a named section: {{ section "scripts" }}
+var sectionAction = regexp.MustCompile(`^\{\{-?\s*section\s+("(?:[^"\\]|\\.)*")\s*-?\}\}$`)
+
+// blockKeywords are the actions closed by an end action
+var blockKeywords = map[string]bool{"if": true, "range": true, "with": true, "block": true, "define": true}
+
+// sectionTemplateName returns the name of the template holding the section of the template
+func sectionTemplateName(name, section string) string {
+	return name + sectionSeparator + section
+}
+
+// extractSections removes the named sections from the template content and returns
+// them as define blocks named after the template (see sectionTemplateName), so they
+// aren't rendered in place and layouts can yield them. Sections must be declared
+// outside of other actions and at most once per template.
+func extractSections(name string, content []byte) (body, sections []byte, err error) {
+	if !bytes.Contains(content, []byte("section")) {
+		return content, nil, nil
+	}
+
+	var out, defs bytes.Buffer
+	declared := make(map[string]bool)
+	depth := 0
+	section, sectionDepth, sectionStart := "", 0, 0
+	last := 0
+	for i := 0; ; {
+		start := bytes.Index(content[i:], []byte("{{"))
+		if start < 0 {
+			break
+		}
+		start += i
+		end, err := actionEnd(content, start)
+		if err != nil {
+			return nil, nil, err
+		}
+		action := content[start:end]
+		i = end
+
+		keyword := actionKeyword(action)
+		switch {
+		case keyword == "section":
+			m := sectionAction.FindSubmatch(action)
+			if m == nil {
+				return nil, nil, fmt.Errorf("invalid section action: %s", action)
+			}
+			if depth > 0 {
+				return nil, nil, fmt.Errorf("section %s must not be declared inside other actions", m[1])
+			}
+			if section, err = strconv.Unquote(string(m[1])); err != nil || section == "" {
+				return nil, nil, fmt.Errorf("invalid section action: %s", action)
+			}
+			if declared[section] {
+				return nil, nil, fmt.Errorf("section %q is declared more than once", section)
+			}
+			declared[section] = true
+
+			text := content[last:start]
+			if bytes.HasPrefix(action, []byte("{{-")) {
+				text = bytes.TrimRight(text, " \t\r\n")
+			}
+			out.Write(text)
+			sectionStart = end
+			if bytes.HasSuffix(action, []byte("-}}")) {
+				sectionStart = end + len(content[end:]) - len(bytes.TrimLeft(content[end:], " \t\r\n"))
+			}
+			depth++
+			sectionDepth = depth
+		case blockKeywords[keyword]:
+			depth++
+		case keyword == "end":
+			if depth == sectionDepth && section != "" {
+				inner := content[sectionStart:start]
+				if bytes.HasPrefix(action, []byte("{{-")) {
+					inner = bytes.TrimRight(inner, " \t\r\n")
+				}
+				defs.WriteString(`{{ define ` + strconv.Quote(sectionTemplateName(name, section)) + ` }}`)
+				defs.Write(inner)
+				defs.WriteString(`{{ end }}`)
+
+				last = end
+				if bytes.HasSuffix(action, []byte("-}}")) {
+					last = end + len(content[end:]) - len(bytes.TrimLeft(content[end:], " \t\r\n"))
+				}
+				section, sectionDepth = "", 0
+			}
+			depth--
+		}
+	}
+	if section != "" {
+		return nil, nil, fmt.Errorf("section %q is not closed", section)
+	}
+	if len(declared) == 0 {
+		return content, nil, nil
+	}
+	out.Write(content[last:])
+	return out.Bytes(), defs.Bytes(), nil
+}
+
+// actionEnd returns the position after the action starting at start, skipping
+// comments and quoted strings, which may contain closing delimiters
+func actionEnd(content []byte, start int) (int, error) {
+	i := start + 2
+	if rest := bytes.TrimLeft(bytes.TrimPrefix(content[i:], []byte("-")), " \t\r\n"); bytes.HasPrefix(rest, []byte("/*")) {
+		closing := bytes.Index(rest, []byte("*/"))
+		if closing < 0 {
+			return 0, errors.New("unclosed comment")
+		}
+		i = len(content) - len(rest) + closing + 2
+	}
+	for i < len(content) {
+		switch c := content[i]; c {
+		case '"', '\'', '`':
+			i++
+			for i < len(content) && content[i] != c {
+				if content[i] == '\\' && c != '`' {
+					i++
+				}
+				i++
+			}
+		case '}':
+			if i+1 < len(content) && content[i+1] == '}' {
+				return i + 2, nil
+			}
+		}
+		i++
+	}
+	return 0, errors.New("unclosed action")
+}
+
+// actionKeyword returns the first word of the action, e.g. "if" for {{- if .Show }}
+func actionKeyword(action []byte) string {
+	s := strings.TrimPrefix(string(action[2:len(action)-2]), "-")
+	s = strings.TrimSpace(s)
+	if i := strings.IndexAny(s, " \t\r\n("); i >= 0 {
+		s = s[:i]
+	}
+	return s
+}
+
+// sectionFuncs returns the yield and hasSection functions rendering the sections of
+// the page template with the page binding and the functions bound to the render
+func (e *Engine) sectionFuncs(page string, binding any, funcs template.FuncMap) template.FuncMap {
+	lookup := func(section string) *template.Template {
+		e.mu.RLock()
+		defer e.mu.RUnlock()
+		return e.templates.Lookup(sectionTemplateName(page, section))
+	}
+	return template.FuncMap{
+		// yield renders the named section of the page, or nothing if the page doesn't
+		// declare it or isn't published.
+		// Usage: {{ yield "scripts" }}
+		"yield": func(section string) (template.HTML, error) {
+			t := lookup(section)
+			if t == nil || !e.isPublished(page) {
+				return "", nil
+			}
+			var buf bytes.Buffer
+			if err := e.executeTemplateWithFuncs(t, &buf, binding, funcs); err != nil {
+				return "", err
+			}
+			return template.HTML(buf.String()), nil
+		},
+		// hasSection reports whether the page declares the named section.
+		// Usage: {{ if hasSection "sidebar" }}<aside>{{ yield "sidebar" }}</aside>{{ end }}
+		"hasSection": func(section string) bool {
+			return lookup(section) != nil && e.isPublished(page)
+		},
+	}
+}
@@ -0,0 +1,149 @@
+// Package service exposes a templatex engine as a small render service, so services
+// written in other languages can reuse the same template set for emails and documents.
+//
+// The Service can be mounted as an HTTP handler:
+//
+//	http.Handle("/render", service.New(engine))
+//
+// and called with a JSON request:
+//
+//	POST /render
+//	{"template": "emails/welcome", "layouts": ["email_layout"], "locale": "de", "data": {"Name": "John"}}
+//
+// which responds with {"html": "..."}, or {"error": "..."} and a 4xx/5xx status code.
+// Service.Render can also be wired into gRPC or net/rpc servers.
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/dmitrymomot/templatex"
+	"github.com/invopop/ctxi18n"
+)
+
+// DefaultMaxBodySize is the default limit of the HTTP request body size
+const DefaultMaxBodySize = 1 << 20
+
+var (
+	ErrInvalidRequest = errors.New("invalid render request")
+	ErrUnknownLocale  = errors.New("unknown locale")
+)
+
+// Request is a render request
+type Request struct {
+	Template string   `json:"template"`          // name of the template to render
+	Layouts  []string `json:"layouts,omitempty"` // layouts wrapping the content, innermost first
+	Locale   string   `json:"locale,omitempty"`  // locale used by the translation functions, the default locale if empty
+	Data     any      `json:"data,omitempty"`    // binding data
+}
+
+// Response is a render response
+type Response struct {
+	HTML string `json:"html"`
+}
+
+// errorResponse is the HTTP response body of failed requests
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+// Service renders templates of an engine on request
+type Service struct {
+	engine      *templatex.Engine
+	maxBodySize int64
+}
+
+// Option configures the Service
+type Option func(*Service)
+
+// WithMaxBodySize limits the HTTP request body size. Defaults to DefaultMaxBodySize.
+func WithMaxBodySize(n int64) Option {
+	return func(s *Service) {
+		s.maxBodySize = n
+	}
+}
+
+// New creates a render service for the engine
+func New(engine *templatex.Engine, opts ...Option) *Service {
+	s := &Service{engine: engine, maxBodySize: DefaultMaxBodySize}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// Render renders the requested template.
+// It returns ErrInvalidRequest if no template is given and ErrUnknownLocale if the
+// locale is not loaded.
+func (s *Service) Render(ctx context.Context, req Request) (Response, error) {
+	if req.Template == "" {
+		return Response{}, errors.Join(ErrInvalidRequest, errors.New("template is required"))
+	}
+	switch {
+	case req.Locale == "":
+		if l := ctxi18n.Get(ctxi18n.DefaultLocale); l != nil && ctxi18n.Locale(ctx) == nil {
+			ctx = l.WithContext(ctx)
+		}
+	default:
+		if ctxi18n.Match(req.Locale) == nil {
+			return Response{}, errors.Join(ErrUnknownLocale, errors.New("locale: "+req.Locale))
+		}
+		lctx, err := ctxi18n.WithLocale(ctx, req.Locale)
+		if err != nil {
+			return Response{}, errors.Join(ErrUnknownLocale, err)
+		}
+		ctx = lctx
+	}
+
+	html, err := s.engine.RenderString(ctx, req.Template, req.Data, req.Layouts...)
+	if err != nil {
+		return Response{}, err
+	}
+	return Response{HTML: html}, nil
+}
+
+// ServeHTTP handles JSON render requests sent with the POST method.
+// Numbers in the data are decoded as json.Number, so they are printed as sent.
+func (s *Service) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		writeJSON(w, http.StatusMethodNotAllowed, errorResponse{Error: "method not allowed"})
+		return
+	}
+
+	var req Request
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, s.maxBodySize))
+	dec.UseNumber()
+	if err := dec.Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: errors.Join(ErrInvalidRequest, err).Error()})
+		return
+	}
+
+	resp, err := s.Render(r.Context(), req)
+	if err != nil {
+		writeJSON(w, statusCode(err), errorResponse{Error: err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// statusCode maps render errors to HTTP status codes
+func statusCode(err error) int {
+	switch {
+	case errors.Is(err, ErrInvalidRequest), errors.Is(err, ErrUnknownLocale):
+		return http.StatusBadRequest
+	case errors.Is(err, templatex.ErrTemplateNotFound), errors.Is(err, templatex.ErrLayoutNotFound):
+		return http.StatusNotFound
+	}
+	return http.StatusInternalServerError
+}
+
+// writeJSON writes the value as a JSON response
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,76 @@
+package service_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/invopop/ctxi18n"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/dmitrymomot/templatex"
+	"github.com/dmitrymomot/templatex/service"
+)
+
+func TestService(t *testing.T) {
+	err := ctxi18n.LoadWithDefault(fstest.MapFS{
+		"en.yml": {Data: []byte("en:\n  greeting: \"Hello, %{name}\"\n")},
+		"es.yml": {Data: []byte("es:\n  greeting: \"Hola, %{name}\"\n")},
+	}, "en")
+	require.NoError(t, err)
+
+	engine, err := templatex.NewFS(fstest.MapFS{
+		"emails/welcome.gohtml": {Data: []byte(`<p>{{ T "greeting" "name" .Name }} #{{ .Number }}</p>`)},
+		"email_layout.gohtml":   {Data: []byte(`<html>{{ embed }}</html>`)},
+	}, ".")
+	require.NoError(t, err)
+
+	handler := service.New(engine, service.WithMaxBodySize(1024))
+
+	tests := []struct {
+		name   string
+		method string
+		body   string
+		status int
+		html   string
+		error  string
+	}{
+		{"render", http.MethodPost, `{"template": "emails/welcome", "layouts": ["email_layout"], "data": {"Name": "John", "Number": 1000000}}`, http.StatusOK, `<html><p>Hello, John #1000000</p></html>`, ""},
+		{"locale", http.MethodPost, `{"template": "emails/welcome", "locale": "es", "data": {"Name": "Juan"}}`, http.StatusOK, `<p>Hola, Juan #</p>`, ""},
+		{"unknown locale", http.MethodPost, `{"template": "emails/welcome", "locale": "xx"}`, http.StatusBadRequest, "", "unknown locale"},
+		{"missing template name", http.MethodPost, `{}`, http.StatusBadRequest, "", "template is required"},
+		{"invalid JSON", http.MethodPost, `{`, http.StatusBadRequest, "", "invalid render request"},
+		{"too large", http.MethodPost, `{"template": "` + strings.Repeat("a", 2048) + `"}`, http.StatusBadRequest, "", "too large"},
+		{"template not found", http.MethodPost, `{"template": "missing"}`, http.StatusNotFound, "", "template not found"},
+		{"layout not found", http.MethodPost, `{"template": "emails/welcome", "layouts": ["missing"]}`, http.StatusNotFound, "", "layout not found"},
+		{"method not allowed", http.MethodGet, ``, http.StatusMethodNotAllowed, "", "method not allowed"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest(tt.method, "/render", strings.NewReader(tt.body)))
+			require.Equal(t, tt.status, rec.Code)
+			assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+
+			var resp struct {
+				HTML  string `json:"html"`
+				Error string `json:"error"`
+			}
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+			assert.Equal(t, tt.html, resp.HTML)
+			assert.Contains(t, resp.Error, tt.error)
+		})
+	}
+
+	t.Run("render method", func(t *testing.T) {
+		resp, err := handler.Render(context.Background(), service.Request{Template: "emails/welcome", Data: map[string]any{"Name": "Jane", "Number": 7}})
+		require.NoError(t, err)
+		assert.Equal(t, `<p>Hello, Jane #7</p>`, resp.HTML)
+	})
+}
@@ -0,0 +1,105 @@
+package templatex
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// shareIntents build the share intent URLs of the platforms from the escaped URL
+// and title; the title is empty if not given
+var shareIntents = map[string]func(u, title string) string{
+	"twitter": func(u, title string) string {
+		return "https://x.com/intent/tweet?url=" + u + withParam("text", title)
+	},
+	"facebook": func(u, _ string) string {
+		return "https://www.facebook.com/sharer/sharer.php?u=" + u
+	},
+	"linkedin": func(u, _ string) string {
+		return "https://www.linkedin.com/sharing/share-offsite/?url=" + u
+	},
+	"reddit": func(u, title string) string {
+		return "https://www.reddit.com/submit?url=" + u + withParam("title", title)
+	},
+	"hackernews": func(u, title string) string {
+		return "https://news.ycombinator.com/submitlink?u=" + u + withParam("t", title)
+	},
+	"pinterest": func(u, title string) string {
+		return "https://www.pinterest.com/pin/create/button/?url=" + u + withParam("description", title)
+	},
+	"telegram": func(u, title string) string {
+		return "https://t.me/share/url?url=" + u + withParam("text", title)
+	},
+	"whatsapp": func(u, title string) string {
+		return "https://wa.me/?text=" + joinEscaped(title, u)
+	},
+	"bluesky": func(u, title string) string {
+		return "https://bsky.app/intent/compose?text=" + joinEscaped(title, u)
+	},
+	"email": func(u, title string) string {
+		return "mailto:?body=" + joinEscaped(title, u) + withParam("subject", title)
+	},
+}
+
+// shareAliases are alternative names of share platforms
+var shareAliases = map[string]string{"x": "twitter", "hn": "hackernews", "mail": "email"}
+
+// shareURL returns the share intent URL of the platform for the absolute URL and the
+// optional title. Values are percent-encoded with spaces as %20, which all platforms
+// and mail clients decode. Platforms: twitter (x), facebook, linkedin, reddit,
+// hackernews (hn), pinterest, telegram, whatsapp, bluesky and email (mail).
+// Usage: <a href="{{ shareURL "twitter" .URL .Title }}">Share</a>
+func shareURL(platform string, target any, title ...any) (string, error) {
+	name := strings.ToLower(platform)
+	if alias, ok := shareAliases[name]; ok {
+		name = alias
+	}
+	intent, ok := shareIntents[name]
+	if !ok {
+		platforms := make([]string, 0, len(shareIntents))
+		for p := range shareIntents {
+			platforms = append(platforms, p)
+		}
+		sort.Strings(platforms)
+		return "", fmt.Errorf("shareURL: unknown platform %q, expected one of %s", platform, strings.Join(platforms, ", "))
+	}
+
+	raw := fmt.Sprint(target)
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "", fmt.Errorf("shareURL: %q is not an absolute http(s) URL", raw)
+	}
+
+	t := ""
+	if len(title) > 0 && title[0] != nil {
+		t = fmt.Sprint(title[0])
+	}
+	return intent(escapeShareValue(u.String()), escapeShareValue(t)), nil
+}
+
+// escapeShareValue percent-encodes the value for a query string, encoding spaces as
+// %20 rather than "+", which isn't decoded in mailto URLs and by some platforms
+func escapeShareValue(s string) string {
+	return strings.ReplaceAll(url.QueryEscape(s), "+", "%20")
+}
+
+// withParam returns the query parameter with the escaped value, or nothing if the
+// value is empty
+func withParam(name, value string) string {
+	if value == "" {
+		return ""
+	}
+	return "&" + name + "=" + value
+}
+
+// joinEscaped joins the non-empty escaped values with an encoded space
+func joinEscaped(values ...string) string {
+	parts := make([]string, 0, len(values))
+	for _, v := range values {
+		if v != "" {
+			parts = append(parts, v)
+		}
+	}
+	return strings.Join(parts, "%20")
+}
@@ -0,0 +1,29 @@
+package templatex
+
+import (
+	"context"
+	"time"
+)
+
+// SlowRender describes a render that exceeded the slow render threshold
+// (see WithSlowRenderThreshold)
+type SlowRender struct {
+	Template  string        // name of the content template
+	Layouts   []string      // layouts wrapping the content
+	Duration  time.Duration // time spent executing the templates
+	Threshold time.Duration // configured threshold
+}
+
+// reportSlowRender passes the slow render to the callback or logs it
+func (e *Engine) reportSlowRender(ctx context.Context, r SlowRender) {
+	if e.slowRenderFn != nil {
+		e.slowRenderFn(ctx, r)
+		return
+	}
+	e.logger.WarnContext(ctx, "templatex: slow render",
+		"template", r.Template,
+		"layouts", r.Layouts,
+		"duration", r.Duration,
+		"threshold", r.Threshold,
+	)
+}
@@ -0,0 +1,112 @@
+package templatex
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+)
+
+const (
+	// SourceManifestFile is the checksum manifest of the template sources, stored
+	// in the template root. Each line holds the hex SHA-256 checksum and the path
+	// of a file relative to the root, like sha256sum output.
+	SourceManifestFile = "templates.sum"
+	// SourceSignatureFile is the detached Ed25519 signature of the manifest
+	SourceSignatureFile = "templates.sum.sig"
+)
+
+// sourceManifest maps file paths relative to the template root to their SHA-256 checksums
+type sourceManifest map[string]string
+
+// SignSources builds the checksum manifest of all files under root in fsys and signs it
+// with the private key. Write the results to SourceManifestFile and SourceSignatureFile
+// in the template root when publishing templates to a remote store, and load them with
+// WithSourceVerification(publicKey).
+func SignSources(fsys fs.FS, root string, key ed25519.PrivateKey) (manifest, signature []byte, err error) {
+	var lines []string
+	err = fs.WalkDir(fsys, root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel := relativePath(root, p)
+		if rel == SourceManifestFile || rel == SourceSignatureFile {
+			return nil
+		}
+		content, err := fs.ReadFile(fsys, p)
+		if err != nil {
+			return err
+		}
+		sum := sha256.Sum256(content)
+		lines = append(lines, hex.EncodeToString(sum[:])+"  "+rel+"\n")
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	sort.Strings(lines)
+	manifest = []byte(strings.Join(lines, ""))
+	return manifest, ed25519.Sign(key, manifest), nil
+}
+
+// verifiedManifest reads the manifest from the root of fsys and verifies its
+// signature. It returns nil if source verification is disabled.
+func (e *Engine) verifiedManifest(fsys fs.FS, root string) (sourceManifest, error) {
+	if e.sourceKey == nil {
+		return nil, nil
+	}
+	data, err := fs.ReadFile(fsys, path.Join(root, SourceManifestFile))
+	if err != nil {
+		return nil, errors.Join(ErrSourceVerificationFailed, err)
+	}
+	sig, err := fs.ReadFile(fsys, path.Join(root, SourceSignatureFile))
+	if err != nil {
+		return nil, errors.Join(ErrSourceVerificationFailed, err)
+	}
+	if !ed25519.Verify(e.sourceKey, data, sig) {
+		return nil, errors.Join(ErrSourceVerificationFailed, errors.New("invalid manifest signature"))
+	}
+
+	manifest := make(sourceManifest)
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	for sc.Scan() {
+		if sc.Text() == "" {
+			continue
+		}
+		sum, file, ok := strings.Cut(sc.Text(), "  ")
+		if !ok {
+			return nil, errors.Join(ErrSourceVerificationFailed, fmt.Errorf("malformed manifest line: %q", sc.Text()))
+		}
+		manifest[file] = sum
+	}
+	return manifest, nil
+}
+
+// verify checks the file content against its checksum in the manifest.
+// Files missing from the manifest are rejected.
+func (m sourceManifest) verify(file string, content []byte) error {
+	want, ok := m[file]
+	if !ok {
+		return errors.Join(ErrSourceVerificationFailed, fmt.Errorf("%s: not listed in the manifest", file))
+	}
+	sum := sha256.Sum256(content)
+	if hex.EncodeToString(sum[:]) != want {
+		return errors.Join(ErrSourceVerificationFailed, fmt.Errorf("%s: checksum mismatch", file))
+	}
+	return nil
+}
+
+// relativePath returns the path of the file relative to the root
+func relativePath(root, file string) string {
+	if root == "." {
+		return file
+	}
+	return strings.TrimPrefix(file, root+"/")
+}
@@ -0,0 +1,204 @@
+package templatex
+
+import (
+	"context"
+	"fmt"
+	"html/template"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Query parameters of the links rendered by the table component
+const (
+	tableSortParam = "sort"
+	tableDirParam  = "dir"
+	tablePageParam = "page"
+)
+
+// defaultTableEmpty is the empty state text of tables without rows
+const defaultTableEmpty = "No results"
+
+// TableColumn is a column of the table component
+type TableColumn struct {
+	Key      string // path of the cell value in the row (see safeField), also the sort key
+	Title    string // header text
+	Sortable bool   // the header links to sorting the rows by the column
+	Class    string // class of the header and the cells
+}
+
+// Table is the props of the built-in table component, rendering a data table with
+// sort links in the headers, zebra rows, an empty state and a pager:
+//
+//	{{ component "table" (props "Columns" .Columns "Rows" .Users "Sort" .Sort "Desc" .Desc "Query" .Query) }}
+//
+// Sort and page links keep the other parameters of the query, e.g. filters, and link to
+// the request path (see WithRequestPath). Sorting resets the page.
+type Table struct {
+	Columns []TableColumn
+	Rows    any        // slice or array of structs, maps or pointers to them
+	Sort    string     // key of the column the rows are sorted by
+	Desc    bool       // the rows are sorted in descending order
+	Query   url.Values // query of the current URL, kept in sort and page links
+	Page    int        // current page, starting at 1
+	Pages   int        // number of pages; the pager is rendered if there is more than one
+	Empty   string     // text of the empty state, "No results" by default
+	Class   string     // class of the table element
+}
+
+// renderTable renders the table component
+func (e *Engine) renderTable(ctx context.Context, props map[string]any) (template.HTML, error) {
+	var t Table
+	if err := decodeProps(props, &t); err != nil {
+		return "", fmt.Errorf("table: %w", err)
+	}
+	rows := indirect(reflect.ValueOf(t.Rows))
+	if rows.IsValid() && rows.Kind() != reflect.Slice && rows.Kind() != reflect.Array {
+		return "", fmt.Errorf("table: rows must be a slice, got %s", rows.Type())
+	}
+
+	var sb strings.Builder
+	sb.WriteString(`<table`)
+	writeClass(&sb, t.Class)
+	sb.WriteString(`><thead><tr>`)
+	for _, col := range t.Columns {
+		sb.WriteString(`<th scope="col"`)
+		sorted := col.Sortable && col.Key == t.Sort && t.Sort != ""
+		class := col.Class
+		if sorted {
+			dir, ariaSort := "asc", "ascending"
+			if t.Desc {
+				dir, ariaSort = "desc", "descending"
+			}
+			class = strings.TrimSpace(class + " sorted " + dir)
+			sb.WriteString(` aria-sort="` + ariaSort + `"`)
+		}
+		writeClass(&sb, class)
+		sb.WriteString(`>`)
+		title := template.HTMLEscapeString(col.Title)
+		if !col.Sortable {
+			sb.WriteString(title)
+		} else {
+			// The sorted column toggles the direction, other columns sort ascending
+			dir := "asc"
+			if sorted && !t.Desc {
+				dir = "desc"
+			}
+			href := tableURL(ctx, t.Query, map[string]string{tableSortParam: col.Key, tableDirParam: dir, tablePageParam: ""})
+			sb.WriteString(`<a href="` + template.HTMLEscapeString(href) + `">` + title + `</a>`)
+		}
+		sb.WriteString(`</th>`)
+	}
+	sb.WriteString(`</tr></thead><tbody>`)
+
+	if !rows.IsValid() || rows.Len() == 0 {
+		empty := t.Empty
+		if empty == "" {
+			empty = defaultTableEmpty
+		}
+		sb.WriteString(`<tr class="empty"><td colspan="` + strconv.Itoa(max(len(t.Columns), 1)) + `">`)
+		sb.WriteString(template.HTMLEscapeString(empty))
+		sb.WriteString(`</td></tr>`)
+	} else {
+		for i := 0; i < rows.Len(); i++ {
+			row := rows.Index(i).Interface()
+			// Rows are counted from one, so the first row is odd
+			if i%2 == 0 {
+				sb.WriteString(`<tr class="odd">`)
+			} else {
+				sb.WriteString(`<tr class="even">`)
+			}
+			for _, col := range t.Columns {
+				sb.WriteString(`<td`)
+				writeClass(&sb, col.Class)
+				sb.WriteString(`>`)
+				sb.WriteString(tableCell(row, col.Key))
+				sb.WriteString(`</td>`)
+			}
+			sb.WriteString(`</tr>`)
+		}
+	}
+	sb.WriteString(`</tbody></table>`)
+
+	if t.Pages > 1 {
+		writePager(ctx, &sb, t)
+	}
+	return template.HTML(sb.String()), nil
+}
+
+// tableCell returns the escaped value at the key of the row. Values of type
+// template.HTML are inserted as they are.
+func tableCell(row any, key string) string {
+	v, ok := lookupPath(row, key)
+	if !ok || v == nil {
+		return ""
+	}
+	if h, ok := v.(template.HTML); ok {
+		return string(h)
+	}
+	return template.HTMLEscapeString(fmt.Sprint(v))
+}
+
+// writePager writes the page links of the table: previous and next links, the first
+// and last pages and the pages around the current one
+func writePager(ctx context.Context, sb *strings.Builder, t Table) {
+	page := min(max(t.Page, 1), t.Pages)
+	link := func(n int, text, rel string) {
+		href := tableURL(ctx, t.Query, map[string]string{tablePageParam: strconv.Itoa(n)})
+		sb.WriteString(`<a href="` + template.HTMLEscapeString(href) + `"`)
+		if rel != "" {
+			sb.WriteString(` rel="` + rel + `"`)
+		}
+		sb.WriteString(`>` + text + `</a>`)
+	}
+
+	sb.WriteString(`<nav class="pagination" aria-label="Pagination">`)
+	if page > 1 {
+		link(page-1, "&laquo;", "prev")
+	}
+	gap := false
+	for n := 1; n <= t.Pages; n++ {
+		if n != 1 && n != t.Pages && (n < page-2 || n > page+2) {
+			if !gap {
+				sb.WriteString(`<span class="gap">&hellip;</span>`)
+				gap = true
+			}
+			continue
+		}
+		gap = false
+		if n == page {
+			sb.WriteString(`<span aria-current="page">` + strconv.Itoa(n) + `</span>`)
+			continue
+		}
+		link(n, strconv.Itoa(n), "")
+	}
+	if page < t.Pages {
+		link(page+1, "&raquo;", "next")
+	}
+	sb.WriteString(`</nav>`)
+}
+
+// tableURL returns the request path with the query, with the parameters set, or
+// removed if their value is empty
+func tableURL(ctx context.Context, query url.Values, params map[string]string) string {
+	q := make(url.Values, len(query)+len(params))
+	for k, v := range query {
+		q[k] = append([]string(nil), v...)
+	}
+	for k, v := range params {
+		if v == "" {
+			q.Del(k)
+		} else {
+			q.Set(k, v)
+		}
+	}
+	return RequestPath(ctx) + "?" + q.Encode()
+}
+
+// writeClass writes the class attribute, unless the class is empty
+func writeClass(sb *strings.Builder, class string) {
+	if class != "" {
+		sb.WriteString(` class="` + template.HTMLEscapeString(class) + `"`)
+	}
+}
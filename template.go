@@ -3,19 +3,33 @@ package templatex
 import (
 	"bytes"
 	"context"
-	"encoding/gob"
+	"crypto/ed25519"
 	"errors"
 	"fmt"
-	"hash/fnv"
 	"html/template"
 	"io"
+	"io/fs"
+	"log/slog"
+	"maps"
+	"net/url"
 	"os"
-	"path/filepath"
+	"path"
 	"reflect"
+	"slices"
 	"strings"
 	"sync"
+	texttemplate "text/template"
+	"time"
 
+	"github.com/cespare/xxhash/v2"
 	"github.com/invopop/ctxi18n"
+	"golang.org/x/sync/singleflight"
+)
+
+// Environment names used by WithEnvironment
+const (
+	EnvProduction  = "production"
+	EnvDevelopment = "development"
 )
 
 var bufferPool = sync.Pool{
@@ -44,14 +58,106 @@ type Engine struct {
 	funcMap template.FuncMap
 	exts    []string
 
+	fsys fs.FS  // file system containing the templates
+	root string // templates root directory in fsys
+	dir  string // template directory on disk, set by New
+
+	namespaces  []componentNamespace      // component namespaces with their own roots
+	sources     map[string]templateSource // templates registered by ParseString and ParseMap
+	schedules   map[string]publishWindow  // publish windows declared in front matter
+	permissions map[string]string         // permissions required by templates
+	noindex     map[string]bool           // templates declaring noindex in front matter
+	extends     map[string]string         // layouts declared by extends directives
+	lazy        map[string]lazyFile       // template files not parsed yet (see WithLazyParsing)
+	components  map[string]reflect.Type   // props types of registered components
+	loadMu      sync.Mutex                // serializes template reloads
+
+	autoReload bool      // reload templates on changes
+	watcher    io.Closer // template directory watcher
+
+	customFuncs   map[string]bool // names of functions set by WithFunc and WithFuncs
+	funcOverrides map[string]bool // built-in functions allowed to be replaced
+	funcPacks     []FuncPack      // optional function packs (see WithFuncPacks)
+
 	templates   *template.Template
-	cache       sync.Map // template cache
+	texts       *texttemplate.Template // plaintext templates (.txt) of RenderEmail
+	generation  uint64                 // incremented by each load, guarded by mu
+	cache       renderCache            // rendered content cache
+	renders     singleflight.Group     // deduplicates concurrent renders on cache misses
 	cacheEnable bool
+	cacheTags   cacheTagIndex                           // cache tags to cache keys
+	cacheTagFn  func(name string, binding any) []string // returns tags for cached renders
+	cacheFields []string                                // binding fields used in cache keys
+
+	cacheKeyFn    func(ctx context.Context, name string, data any, layouts []string) string // custom cache keys
+	cacheCallback func(ctx context.Context, name string, hit bool)                          // called on cache lookups
+
+	encodings []contentEncoding // content encodings of RenderCompressed, by preference
+
+	strictSinks bool // htmlSafe only accepts trusted values
+
+	sourceKey ed25519.PublicKey // verifies the signed source manifest (see WithSourceVerification)
+
+	rawBaseURL string   // base URL set by WithBaseURL
+	baseURL    *url.URL // parsed base URL of absURL and canonical, request host if nil
+
+	urlSigner *urlSigner // signs URLs of signedURL (see WithURLSigner)
+
+	markdownFn MarkdownFunc // converts Markdown templates and strings (see WithMarkdown)
+
+	locales      []string // locale codes rendered by RenderAllLocales
+	lazyParsing  bool     // templates are parsed on first use
+	pseudoLocale bool     // translated strings are pseudo-localized
+
+	authorizer     Authorizer        // checks permissions required by templates
+	dirPermissions map[string]string // permissions required by template directories
+
+	consentResolver   ConsentResolver   // checks visitor consent (see WithConsentResolver)
+	consentCategories consentCategories // consent categories checked by templates
 
 	commonLayouts     []string                      // common layout templates to pre-compile
+	defaultLayouts    []string                      // layouts of renders without layouts (see WithDefaultLayout)
+	layoutConvention  bool                          // templates are wrapped in _layout templates of their directories
+	layoutChains      [][]string                    // declared layout combinations to pre-build
 	layouts           map[string]*template.Template // pre-compiled layout templates
 	layoutCache       sync.Map                      // layout chain cache
+	clones            sync.Map                      // pools of executable template clones by parsed template
+	chains            chainCache                    // precompiled page and layout chain template sets
 	layoutCacheEnable bool                          // layout caching enabled
+	precompileChains  bool                          // pages and layouts are executed as one template set
+	layoutDataFuncs   map[string]func(any) any      // layout binding transformers
+
+	caseInsensitive bool              // match template names ignoring case
+	names           map[string]string // lowercase template names to actual names
+
+	logger       *slog.Logger                      // logger for diagnostics
+	slowRender   time.Duration                     // slow render threshold
+	slowRenderFn func(context.Context, SlowRender) // called for slow renders
+	archive      ArchiveFunc                       // archive sink for rendered output
+	modTime      map[string]time.Time              // template name to source file modification time
+
+	vars  map[string]any   // site-wide template variables
+	env   string           // environment name
+	clock func() time.Time // clock used by date and time functions
+
+	formatConfig   FormatConfig         // number and date formatter settings
+	addressFormats map[string]string    // address formats by country code
+	readingSpeeds  map[string]int       // reading speeds in words per minute by language
+	weekdayNames   map[string][7]string // abbreviated day names by language, starting with Sunday
+
+	fakeFuncs         bool            // enable fake data functions outside of development environment
+	htmlValidation    bool            // validate rendered HTML
+	minifyOutput      bool            // minify rendered HTML
+	htmxForms         bool            // form component renders htmx attributes
+	csrfField         string          // name of the CSRF token form field
+	headingAnchors    *HeadingAnchors // adds anchors to headings of rendered HTML
+	cssInlineDirs     []string        // directories of templates with inlined CSS
+	emailText         bool            // .txt files are parsed as plaintext email parts
+	a11yCheck         bool            // check rendered HTML for accessibility issues
+	mutationCheck     bool            // detect binding mutation during renders in development environment
+	debugToolbar      bool            // inject debug toolbar in development environment
+	debugRedactFields []string        // fields redacted by debug functions
+	debugDumper       *debugDumper    // dumper used by debug functions and toolbar
 }
 
 // New creates a new template engine instance with optimized caching and pre-compiled layouts.
@@ -75,6 +181,9 @@ type Engine struct {
 //   - ErrNoTemplateDirectory if root is empty or directory doesn't exist
 //   - ErrTemplateParsingFailed if template parsing fails
 //   - ErrNoTemplatesParsed if no templates were found
+//   - ErrLayoutNotFound if a layout set by WithLayouts or WithLayoutChain doesn't exist
+//   - ErrReservedFuncName if a custom function uses the name of a render-bound function
+//   - ErrBuiltinFuncOverride if a custom function replaces a built-in one without WithFuncOverride
 func New(root string, opts ...Option) (*Engine, error) {
 	if root == "" {
 		return nil, ErrNoTemplateDirectory
@@ -85,11 +194,58 @@ func New(root string, opts ...Option) (*Engine, error) {
 		return nil, errors.Join(ErrNoTemplateDirectory, fmt.Errorf("template directory does not exist: %s", root))
 	}
 
+	e, err := NewFS(os.DirFS(root), ".", opts...)
+	if err != nil {
+		return nil, err
+	}
+	e.dir = root
+
+	// Watch the directory for changes
+	if e.autoReload {
+		if err := e.watch(root); err != nil {
+			return nil, err
+		}
+	}
+
+	return e, nil
+}
+
+// NewFS creates a new template engine instance parsing templates from the root
+// directory of fsys, e.g. an embed.FS, so templates can be compiled into the binary:
+//
+//	//go:embed templates
+//	var templatesFS embed.FS
+//
+//	engine, err := templatex.NewFS(templatesFS, "templates")
+//
+// Use "." as root to parse the whole file system. It behaves like New otherwise.
+func NewFS(fsys fs.FS, root string, opts ...Option) (*Engine, error) {
+	if fsys == nil || root == "" {
+		return nil, ErrNoTemplateDirectory
+	}
+
+	// Check if directory exists
+	if _, err := fs.Stat(fsys, root); err != nil {
+		return nil, errors.Join(ErrNoTemplateDirectory, fmt.Errorf("template directory does not exist: %s", root))
+	}
+
 	// Initialize engine
 	e := &Engine{
-		layouts: make(map[string]*template.Template),
-		funcMap: defaultFuncs(),
-		exts:    []string{".gohtml"},
+		fsys:            fsys,
+		root:            root,
+		layoutDataFuncs: make(map[string]func(any) any),
+		vars:            make(map[string]any),
+		env:             EnvProduction,
+		clock:           time.Now,
+		formatConfig:    defaultFormatConfig(),
+		addressFormats:  maps.Clone(addressFormats),
+		readingSpeeds:   maps.Clone(readingSpeeds),
+		weekdayNames:    maps.Clone(weekdayNames),
+		logger:          slog.Default(),
+		funcMap:         defaultFuncs(),
+		customFuncs:     make(map[string]bool),
+		funcOverrides:   make(map[string]bool),
+		exts:            []string{".gohtml"},
 	}
 
 	// Apply options
@@ -99,73 +255,434 @@ func New(root string, opts ...Option) (*Engine, error) {
 		}
 	}
 
+	// Add functions of the opted-in packs
+	if err := e.initFuncPacks(); err != nil {
+		return nil, err
+	}
+
+	// Expire cached renders with the engine clock and keep the tag index in sync
+	e.cache.now = e.clock
+	e.cache.onRemove = e.cacheTags.remove
+
+	// Absolute URLs are built on the configured base URL
+	if e.rawBaseURL != "" {
+		u, err := parseBaseURL(e.rawBaseURL)
+		if err != nil {
+			return nil, errors.Join(ErrInvalidBaseURL, err)
+		}
+		e.baseURL = u
+	}
+
+	if e.urlSigner != nil {
+		if err := e.urlSigner.validate(); err != nil {
+			return nil, errors.Join(ErrInvalidURLSigner, err)
+		}
+	}
+
+	// Custom functions must not silently replace built-in ones
+	if err := e.checkCustomFuncs(); err != nil {
+		return nil, err
+	}
+
+	// Expose template variables and environment
+	e.setBuiltinFunc("vars", templateVars(e.vars))
+	for name, fn := range envFuncs(e.env) {
+		e.setBuiltinFunc(name, fn)
+	}
+
+	// Bind date and time functions to the clock
+	for name, fn := range timeFuncs(e.clock) {
+		e.setBuiltinFunc(name, fn)
+	}
+
+	// Only trusted values can be inserted unescaped in strict sink mode
+	if e.strictSinks {
+		e.setBuiltinFunc("htmlSafe", strictHTMLSafe)
+	}
+
+	// Bind the publish window check to the engine clock
+	e.setBuiltinFunc("isPublished", e.isPublished)
+
+	// Bind URL signing to the signer and the engine clock
+	e.setBuiltinFunc("signedURL", e.signedURL)
+
+	// Markdown templates are parsed like other templates once converted. The markdown
+	// function is only built in with a converter, so packs can provide their own.
+	if e.markdownFn != nil {
+		e.setBuiltinFunc("markdown", e.markdown)
+		if !slices.Contains(e.exts, markdownExt) {
+			e.exts = append(e.exts, markdownExt)
+		}
+	}
+
+	// Bind formatters to the format settings
+	for name, fn := range formatFuncs(e.formatConfig, e.clock) {
+		e.setBuiltinFunc(name, fn)
+	}
+
+	// Bind the address formatter to the configured formats
+	e.setBuiltinFunc("formatAddress", formatAddress(e.addressFormats))
+
+	// Fake data functions are only enabled in development by default
+	for name, fn := range fakeFuncs(e.fakeFuncs || e.env == EnvDevelopment) {
+		e.setBuiltinFunc(name, fn)
+	}
+
+	// Bind debug functions to the redaction settings
+	e.debugDumper = newDebugDumper(e.debugRedactFields)
+	for name, fn := range e.debugDumper.funcs() {
+		e.setBuiltinFunc(name, fn)
+	}
+
 	// Parse templates
+	if err := e.load(); err != nil {
+		return nil, err
+	}
+
+	return e, nil
+}
+
+// load parses the templates from the engine file system and replaces the current
+// ones. The render and layout caches are cleared and the declared layout chains are
+// rebuilt. The current templates are kept if parsing fails.
+func (e *Engine) load() error {
 	tmpl := template.New("").Option("missingkey=zero").Funcs(e.funcMap)
-	if err := filepath.Walk(root, e.walkFunc(tmpl, root, e.exts)); err != nil {
-		return nil, errors.Join(ErrTemplateParsingFailed, err)
+	modTime := make(map[string]time.Time)
+	schedules := make(map[string]publishWindow)
+	permissions := make(map[string]string)
+	noindex := make(map[string]bool)
+	extends := make(map[string]string)
+	lazy := make(map[string]lazyFile)
+	texts := texttemplate.New("").Option("missingkey=zero").Funcs(texttemplate.FuncMap(e.funcMap))
+	walkFunc := func(manifest sourceManifest, fsys fs.FS, root, prefix string) fs.WalkDirFunc {
+		walk := e.walkFunc(tmpl, modTime, schedules, permissions, noindex, extends, manifest, fsys, root, prefix)
+		if e.lazyParsing {
+			walk = e.indexFunc(lazy, manifest, fsys, root, prefix)
+		}
+		if !e.emailText {
+			return walk
+		}
+		return e.textWalkFunc(texts, schedules, permissions, walk, manifest, fsys, root, prefix)
+	}
+
+	manifest, err := e.verifiedManifest(e.fsys, e.root)
+	if err != nil {
+		return err
+	}
+	if err := fs.WalkDir(e.fsys, e.root, walkFunc(manifest, e.fsys, e.root, "")); err != nil {
+		return errors.Join(ErrTemplateParsingFailed, err)
 	}
 
-	if tmpl.Templates() == nil {
-		return nil, ErrNoTemplatesParsed
+	// Parse component namespaces, each verified with its own manifest
+	for _, ns := range e.namespaces {
+		manifest, err := e.verifiedManifest(ns.fsys, ns.root)
+		if err != nil {
+			return errors.Join(fmt.Errorf("namespace %s", ns.name), err)
+		}
+		if err := fs.WalkDir(ns.fsys, ns.root, walkFunc(manifest, ns.fsys, ns.root, ns.name+".")); err != nil {
+			return errors.Join(ErrTemplateParsingFailed, fmt.Errorf("namespace %s", ns.name), err)
+		}
 	}
 
-	e.templates = tmpl
+	// Parse templates registered in memory
+	e.mu.RLock()
+	sources := e.sources
+	e.mu.RUnlock()
+	for name, source := range sources {
+		if textName, ok := strings.CutSuffix(name, textTemplateExt); ok && e.emailText {
+			if err := e.parseText(texts, schedules, permissions, textName, []byte(source.src)); err != nil {
+				return errors.Join(ErrTemplateParsingFailed, fmt.Errorf("template: %s", name), err)
+			}
+			continue
+		}
+		content, slots, err := extractSlots(name, []byte(source.src))
+		if err != nil {
+			return errors.Join(ErrTemplateParsingFailed, fmt.Errorf("template: %s", name), err)
+		}
+		content, sections, err := extractSections(name, content)
+		if err != nil {
+			return errors.Join(ErrTemplateParsingFailed, fmt.Errorf("template: %s", name), err)
+		}
+		content, window, fm, err := parseFrontMatter(name, content)
+		if err != nil {
+			return errors.Join(ErrTemplateParsingFailed, fmt.Errorf("template: %s", name), err)
+		}
+		if window != nil {
+			schedules[name] = *window
+		}
+		if fm.Noindex {
+			noindex[name] = true
+		}
+		if fm.Extends != "" {
+			extends[name] = fm.Extends
+		}
+		permission := fm.Requires
+		if permission == "" {
+			permission = e.dirPermission(name)
+		}
+		if permission != "" && !hasDefine(content) {
+			permissions[name] = permission
+			content = requirePermission(content, permission)
+		}
+		if _, err := tmpl.New(name).Parse(string(content) + string(sections) + string(slots)); err != nil {
+			return errors.Join(ErrTemplateParsingFailed, fmt.Errorf("template: %s", name), err)
+		}
+		modTime[name] = source.modTime
+	}
+
+	if err := resolveComponentVersions(tmpl, e.namespaces); err != nil {
+		return errors.Join(ErrTemplateParsingFailed, err)
+	}
+
+	// Declared layouts are parsed upfront, so missing ones are reported on load
+	if e.lazyParsing {
+		required := append([]string(nil), e.commonLayouts...)
+		for _, chain := range e.declaredChains() {
+			required = append(required, chain...)
+		}
+		if err := e.parseLazy(tmpl, lazy, modTime, schedules, permissions, noindex, extends, required...); err != nil {
+			return err
+		}
+	}
+
+	if tmpl.Templates() == nil && len(lazy) == 0 {
+		return ErrNoTemplatesParsed
+	}
+
+	var names map[string]string
+	if e.caseInsensitive {
+		var err error
+		if names, err = buildNameIndex(tmpl); err != nil {
+			return errors.Join(ErrTemplateParsingFailed, err)
+		}
+	}
+
+	// Layouts declared by extends directives must exist, either parsed or indexed
+	if err := checkExtends(extends, func(layout string) bool {
+		_, indexed := lazy[e.lazyKey(layout)]
+		return indexed || e.lookupIn(tmpl, names, layout) != nil
+	}); err != nil {
+		return err
+	}
 
 	// Pre-compile common layouts
-	e.precompileCommonLayouts()
+	layouts, err := e.precompileCommonLayouts(tmpl, names)
+	if err != nil {
+		return err
+	}
 
-	return e, nil
+	e.mu.Lock()
+	e.templates = tmpl
+	e.texts = texts
+	e.modTime = modTime
+	e.schedules = schedules
+	e.permissions = permissions
+	e.noindex = noindex
+	e.extends = extends
+	e.lazy = lazy
+	e.names = names
+	e.layouts = layouts
+	e.generation++
+	e.mu.Unlock()
+
+	// Renders of the previous templates don't cache their output once the generation
+	// changed (see storeIfCurrent), so nothing stale is cached after the purge
+	e.clearCaches()
+	e.clones.Range(func(key, _ any) bool {
+		e.clones.Delete(key)
+		return true
+	})
+
+	// Pre-build declared layout chains
+	if e.layoutCacheEnable {
+		for _, chain := range e.declaredChains() {
+			if _, err := e.getLayoutChain(chain...); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// loadGeneration returns the generation of the loaded templates, which renders capture
+// when they start to not cache output of templates replaced by a reload
+func (e *Engine) loadGeneration() uint64 {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.generation
+}
+
+// storeIfCurrent calls store unless the templates were reloaded after the given
+// generation. It holds the read lock, so a reload swapping the templates waits for
+// the store to complete and purges it.
+func (e *Engine) storeIfCurrent(generation uint64, store func()) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	if e.generation == generation {
+		store()
+	}
+}
+
+// initialized reports whether the engine has parsed templates
+func (e *Engine) initialized() bool {
+	if e == nil {
+		return false
+	}
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.templates != nil
+}
+
+// clearCaches removes all rendered content and layout chains from the caches
+func (e *Engine) clearCaches() {
+	e.PurgeCache()
+	e.PurgeLayoutCache()
 }
 
 // walkFunc is now a method of Engine to access its internal state
-func (e *Engine) walkFunc(tmpl *template.Template, root string, exts []string) filepath.WalkFunc {
-	return func(path string, info os.FileInfo, err error) error {
-		if err != nil || info.IsDir() {
+// Template names are prefixed with the prefix, which is used for component namespaces.
+// Files are checked against the manifest unless it's nil (see WithSourceVerification).
+func (e *Engine) walkFunc(tmpl *template.Template, modTime map[string]time.Time, schedules map[string]publishWindow, permissions map[string]string, noindex map[string]bool, extends map[string]string, manifest sourceManifest, fsys fs.FS, root, prefix string) fs.WalkDirFunc {
+	return func(filePath string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
 			return err
 		}
 
 		// Check file extension
-		validExt := false
-		for _, ext := range exts {
-			if filepath.Ext(path) == ext {
-				validExt = true
-				break
-			}
-		}
-		if !validExt {
+		if !e.hasTemplateExt(filePath) {
 			return nil
 		}
 
-		relPath, err := filepath.Rel(root, path)
+		relPath := relativePath(root, filePath)
+
+		info, err := d.Info()
 		if err != nil {
 			return err
 		}
-		relPath = strings.ReplaceAll(relPath, string(os.PathSeparator), "/")
 
-		content, err := os.ReadFile(path)
+		content, err := fs.ReadFile(fsys, filePath)
 		if err != nil {
 			return err
 		}
+		if manifest != nil {
+			if err := manifest.verify(relPath, content); err != nil {
+				return err
+			}
+		}
+		if e.isMarkdown(filePath) {
+			if content, err = e.markdownTemplate(content); err != nil {
+				return fmt.Errorf("%s: %w", filePath, err)
+			}
+		}
 
-		tmplName := strings.TrimSuffix(relPath, filepath.Ext(relPath))
+		tmplName := prefix + strings.TrimSuffix(relPath, path.Ext(relPath))
 
-		if bytes.Contains(content, []byte("{{define")) || bytes.Contains(content, []byte("{{ define")) {
-			_, err = tmpl.ParseFiles(path)
-		} else {
-			_, err = tmpl.New(tmplName).Parse(string(content))
+		// Slots of components and named sections are parsed as templates of their own,
+		// so components can render slots and layouts can yield sections
+		content, slots, err := extractSlots(tmplName, content)
+		if err != nil {
+			return fmt.Errorf("%s: %w", filePath, err)
+		}
+		content, sections, err := extractSections(tmplName, content)
+		if err != nil {
+			return fmt.Errorf("%s: %w", filePath, err)
 		}
 
-		return err
+		// Front matter declares publish windows, permissions, robots directives and layouts
+		content, window, fm, err := parseFrontMatter(tmplName, content)
+		if err != nil {
+			return fmt.Errorf("%s: %w", filePath, err)
+		}
+		if window != nil {
+			schedules[tmplName] = *window
+		}
+		if fm.Noindex {
+			noindex[tmplName] = true
+		}
+		if fm.Extends != "" {
+			extends[tmplName] = fm.Extends
+		}
+		permission := fm.Requires
+		if permission == "" {
+			permission = e.dirPermission(tmplName)
+		}
+
+		if hasDefine(content) {
+			if len(sections) > 0 {
+				return fmt.Errorf("%s: sections are not supported in files with define blocks", filePath)
+			}
+			before := make(map[*template.Template]bool)
+			for _, t := range tmpl.Templates() {
+				before[t] = true
+			}
+			// Parse like template.ParseFiles, naming the file template by its base name
+			if _, err = tmpl.New(path.Base(filePath)).Parse(string(content) + string(slots)); err != nil {
+				return err
+			}
+			// Record the modification time for all templates defined in the file.
+			// Templates defined in protected directories are checked when rendered directly.
+			for _, t := range tmpl.Templates() {
+				if !before[t] {
+					modTime[t.Name()] = info.ModTime()
+					if permission != "" {
+						permissions[t.Name()] = permission
+					}
+				}
+			}
+			modTime[path.Base(filePath)] = info.ModTime()
+			return nil
+		}
+
+		if permission != "" {
+			permissions[tmplName] = permission
+			content = requirePermission(content, permission)
+		}
+		if _, err = tmpl.New(tmplName).Parse(string(content) + string(sections) + string(slots)); err != nil {
+			return err
+		}
+		modTime[tmplName] = info.ModTime()
+		return nil
 	}
 }
 
-// precompileCommonLayouts pre-compiles frequently used layouts
-func (e *Engine) precompileCommonLayouts() {
+// precompileCommonLayouts pre-compiles frequently used layouts of the template set
+// and validates the declared layout chains. It returns an error listing all missing layouts.
+func (e *Engine) precompileCommonLayouts(tmpl *template.Template, names map[string]string) (map[string]*template.Template, error) {
+	layouts := make(map[string]*template.Template, len(e.commonLayouts))
+	var missing []string
+	seen := make(map[string]bool)
+	check := func(layout string) {
+		if e.lookupIn(tmpl, names, layout) == nil && !seen[layout] {
+			seen[layout] = true
+			missing = append(missing, layout)
+		}
+	}
+
 	for _, layout := range e.commonLayouts {
-		if t := e.templates.Lookup(layout); t != nil {
-			e.layouts[layout] = t
+		check(layout)
+		if t := e.lookupIn(tmpl, names, layout); t != nil {
+			layouts[layout] = t
+		}
+	}
+	for _, chain := range e.declaredChains() {
+		for _, layout := range chain {
+			check(layout)
 		}
 	}
+	if len(missing) > 0 {
+		return nil, errors.Join(ErrLayoutNotFound, fmt.Errorf("layouts: %s", strings.Join(missing, ", ")))
+	}
+	return layouts, nil
+}
+
+// declaredChains returns the declared layout chains (see WithLayoutChain) and the
+// default layouts (see WithDefaultLayout)
+func (e *Engine) declaredChains() [][]string {
+	if len(e.defaultLayouts) == 0 {
+		return e.layoutChains
+	}
+	return append(slices.Clip(e.layoutChains), e.defaultLayouts)
 }
 
 // getLayoutChain returns a cached layout chain or creates a new one
@@ -181,15 +698,21 @@ func (e *Engine) getLayoutChain(layouts ...string) (*layoutChain, error) {
 		}
 	}
 
+	if err := e.ensureParsed(layouts...); err != nil {
+		return nil, err
+	}
+
 	chain := &layoutChain{
 		templates: make([]*template.Template, len(layouts)),
 	}
 
+	e.mu.RLock()
+	defer e.mu.RUnlock()
 	for i, layout := range layouts {
-		if t := e.templates.Lookup(layout); t != nil {
+		if t := e.lookup(layout); t != nil {
 			chain.templates[i] = t
 		} else {
-			return nil, fmt.Errorf("layout not found: %s", layout)
+			return nil, errors.Join(ErrLayoutNotFound, fmt.Errorf("layout: %s", layout))
 		}
 	}
 
@@ -200,6 +723,77 @@ func (e *Engine) getLayoutChain(layouts ...string) (*layoutChain, error) {
 	return chain, nil
 }
 
+// LayoutBinding pairs a layout template name with the data passed to it.
+// See Layout and RenderWithLayouts.
+type LayoutBinding struct {
+	Name string // layout template name
+	Data any    // layout binding; nil means the page binding is used
+}
+
+// Layout creates a LayoutBinding for the layout with the given name.
+// The data is passed to the layout instead of the page binding.
+//
+// Usage:
+//
+//	engine.RenderWithLayouts(ctx, w, "greeter", data,
+//		templatex.Layout("app_layout", appData),
+//		templatex.Layout("base_layout", baseData),
+//	)
+func Layout(name string, data any) LayoutBinding {
+	return LayoutBinding{Name: name, Data: data}
+}
+
+// NoLayout is passed as the only layout to render a template without the default
+// layouts (see WithDefaultLayout), e.g. for fragments:
+//
+//	engine.Render(ctx, w, "partials/row", data, templatex.NoLayout)
+const NoLayout = "\x00nolayout"
+
+// resolveLayouts returns the layouts of the template if no layouts are given: the
+// layouts declared by its extends directive, the _layout templates of its directories
+// (see WithConventionLayouts), or the default layouts. It returns no layouts if
+// NoLayout is the only one.
+func (e *Engine) resolveLayouts(name string, layouts []LayoutBinding) ([]LayoutBinding, error) {
+	if len(layouts) == 1 && layouts[0].Name == NoLayout {
+		return nil, nil
+	}
+	if len(layouts) > 0 {
+		return layouts, nil
+	}
+	names, err := e.extendsLayouts(name)
+	if err != nil {
+		return nil, err
+	}
+	if len(names) == 0 {
+		names = e.withoutSelf(name, e.defaultLayouts)
+		if e.layoutConvention {
+			if found := e.conventionLayouts(name); len(found) > 0 {
+				names = found
+			}
+		}
+	}
+	if len(names) == 0 {
+		return nil, nil
+	}
+	resolved := make([]LayoutBinding, len(names))
+	for i, layout := range names {
+		resolved[i] = LayoutBinding{Name: layout}
+	}
+	return resolved, nil
+}
+
+// withoutSelf returns the default layouts wrapping the template: a default layout
+// doesn't wrap itself, nor is it wrapped by the layouts it's wrapped in by default
+func (e *Engine) withoutSelf(name string, layouts []string) []string {
+	name = normalizeTemplateName(name, e.exts)
+	for i, layout := range layouts {
+		if normalizeTemplateName(layout, e.exts) == name {
+			return layouts[i+1:]
+		}
+	}
+	return layouts
+}
+
 // Render executes a template with the given name and binding data, applying optional layouts.
 // It supports caching of rendered content for improved performance.
 //
@@ -208,7 +802,8 @@ func (e *Engine) getLayoutChain(layouts ...string) (*layoutChain, error) {
 //   - out: Writer where the rendered template will be written
 //   - name: Name of the template to render
 //   - binding: Data to be passed to the template
-//   - layouts: Optional list of layout templates to wrap the content
+//   - layouts: Optional list of layout templates to wrap the content; the default
+//     layouts are used if none are given (see WithDefaultLayout and NoLayout)
 //
 // The function performs the following steps:
 //  1. Checks cache for previously rendered content
@@ -218,61 +813,321 @@ func (e *Engine) getLayoutChain(layouts ...string) (*layoutChain, error) {
 //
 // Returns an error if template execution fails or templates are not found.
 func (e *Engine) Render(ctx context.Context, out io.Writer, name string, binding interface{}, layouts ...string) error {
-	if e == nil || e.templates == nil {
+	bindings := make([]LayoutBinding, len(layouts))
+	for i, layout := range layouts {
+		bindings[i] = LayoutBinding{Name: layout}
+	}
+	return e.RenderWithLayouts(ctx, out, name, binding, bindings...)
+}
+
+// RenderWithLayouts behaves like Render, but allows passing distinct data to each
+// layout in the chain (see Layout). Layouts with nil data receive the page binding,
+// transformed by the layout data function if one is registered (see WithLayoutDataFunc).
+//
+// Returns an error if template execution fails or templates are not found.
+func (e *Engine) RenderWithLayouts(ctx context.Context, out io.Writer, name string, binding interface{}, layouts ...LayoutBinding) error {
+	if !e.initialized() {
 		return ErrTemplateEngineNotInitialized
 	}
 
+	r, err := e.renderContent(ctx, name, binding, layouts)
+	if err != nil {
+		return err
+	}
+	return e.writeOutput(ctx, out, name, r.content, r.content)
+}
+
+// renderResult is the output of renderContent
+type renderResult struct {
+	content  string // rendered content, including the debug toolbar
+	cacheKey string // key of the cached content; empty if it's not cached or decorated
+}
+
+// renderContent renders the template with the layouts, using the render cache
+func (e *Engine) renderContent(ctx context.Context, name string, binding interface{}, layouts []LayoutBinding) (renderResult, error) {
+	generation := e.loadGeneration()
+
 	// Get locale from context
 	locale := "en"
 	if l := ctxi18n.Locale(ctx); l != nil {
 		locale = l.Code().String()
 	}
 
+	layouts, err := e.resolveLayouts(name, layouts)
+	if err != nil {
+		return renderResult{}, err
+	}
+	layoutNames := make([]string, len(layouts))
+	for i, layout := range layouts {
+		layoutNames[i] = layout.Name
+	}
+
 	// Generate unique cache key
-	cacheKey := generateCacheKey(e.cacheEnable, locale, name, binding, layouts...)
+	consent, consentGen := e.consentState(ctx)
+	scope := requestScope(ctx) + e.scheduleState() + e.permissionState(ctx) + consent
+	var cacheKey string
+	if e.cacheKeyFn != nil {
+		cacheKey = generateCacheKey(false, locale, scope, name, e.cacheKeyFn(ctx, name, binding, layoutNames), layoutNames...)
+	} else {
+		cacheKey = generateCacheKey(e.cacheEnable, locale, scope, name, e.cacheKeyBinding(binding), layoutNames...)
+	}
 
-	// Try to get from cache first
-	if cached, ok := e.cache.Load(cacheKey); ok {
-		if cachedContent, ok := cached.(string); ok {
-			_, err := io.WriteString(out, cachedContent)
-			return err
+	// Add per-layout data to the cache key
+	if !e.cacheEnable {
+		for _, layout := range layouts {
+			if layout.Data != nil {
+				cacheKey = generateCacheKey(false, locale, cacheKey, layout.Name, e.cacheKeyBinding(layout.Data))
+			}
 		}
 	}
 
+	// Collect render details for the debug toolbar
+	var debug *debugInfo
+	if e.debugToolbarEnabled() {
+		debug = &debugInfo{start: time.Now(), name: name, layouts: layoutNames, binding: binding, locale: locale}
+	}
+
+	// Try to get from cache first, unless the caller asked for a fresh render
+	skipCache := CacheSkipped(ctx)
+	if !skipCache {
+		cachedContent, hit := e.cache.load(cacheKey)
+		if e.cacheCallback != nil {
+			e.cacheCallback(ctx, name, hit)
+		}
+		if hit {
+			if debug != nil {
+				debug.cacheHit = true
+			}
+			return e.decorateOutput(ctx, name, renderResult{content: cachedContent, cacheKey: cacheKey}, debug)
+		}
+	}
+
+	render := func() (string, error) {
+		// Execute the templates, tracking the output of each template
+		// to map validation issues to templates
+		var snapshot []byte
+		if e.mutationCheckEnabled() {
+			snapshot = bindingSnapshot(binding)
+		}
+		start := time.Now()
+		trackStages := e.htmlValidation || e.a11yCheck
+		content, stages, err := e.execute(ctx, name, binding, layouts, trackStages, nil)
+		if err != nil {
+			return "", err
+		}
+		if snapshot != nil {
+			e.checkBindingMutation(ctx, name, binding, snapshot)
+		}
+		if e.slowRender > 0 {
+			if elapsed := time.Since(start); elapsed > e.slowRender {
+				e.reportSlowRender(ctx, SlowRender{Template: name, Layouts: layoutNames, Duration: elapsed, Threshold: e.slowRender})
+			}
+		}
+
+		if content, err = e.finishOutput(ctx, name, content, stages); err != nil {
+			return "", err
+		}
+
+		if skipCache {
+			return content, nil
+		}
+
+		// The cache key doesn't reflect consent to categories checked for the first time
+		if e.consentCategories.generation() != consentGen {
+			return "", errConsentCategoryAdded
+		}
+
+		// Store the final rendered content in cache
+		var tags []string
+		if e.cacheTagFn != nil {
+			tags = e.cacheTagFn(name, binding)
+		}
+		templates := make([]string, 0, len(layoutNames)+1)
+		for _, n := range append([]string{name}, layoutNames...) {
+			templates = append(templates, e.canonicalName(n))
+		}
+		e.storeIfCurrent(generation, func() {
+			e.cache.store(cacheKey, content, templates, tags)
+			e.cacheTags.add(cacheKey, tags)
+		})
+		return content, nil
+	}
+
+	// Concurrent renders with the same cache key (e.g. on a cold cache) are
+	// executed once, the other callers wait and reuse the result
+	var content string
+	if skipCache {
+		content, err = render()
+	} else {
+		// Panics of the shared render are propagated to all callers
+		var v any
+		v, err, _ = e.renders.Do(cacheKey, func() (any, error) { return render() })
+		content, _ = v.(string)
+		if errors.Is(err, errConsentCategoryAdded) {
+			// The shared render may not match the consent of this context
+			skipCache = true
+			content, err = render()
+		}
+	}
+	if err != nil {
+		return renderResult{}, err
+	}
+	if skipCache {
+		cacheKey = ""
+	}
+	return e.decorateOutput(ctx, name, renderResult{content: content, cacheKey: cacheKey}, debug)
+}
+
+// decorateOutput applies the lite post-processing (see WithLite) and injects the debug
+// toolbar. Both are applied after caching, so cached content stays clean and doesn't
+// depend on the lite settings. Decorated content has no cache key.
+func (e *Engine) decorateOutput(ctx context.Context, name string, r renderResult, debug *debugInfo) (renderResult, error) {
+	if lite := liteSettings(ctx); lite != nil {
+		content, err := lite.output(name, r.content)
+		if err != nil {
+			return renderResult{}, err
+		}
+		r = renderResult{content: content}
+	}
+	if debug != nil {
+		r = renderResult{content: e.injectDebugToolbar(r.content, *debug)}
+	}
+	return r, nil
+}
+
+// finishOutput validates the executed output of the template and applies the output
+// transforms: heading anchors, CSS inlining and minification. The stages of the
+// render are required for HTML validation and accessibility checks.
+func (e *Engine) finishOutput(ctx context.Context, name, content string, stages []renderStage) (string, error) {
+	// Validate the output before caching, so invalid markup is reported on every render
+	if e.htmlValidation || e.a11yCheck {
+		sources := buildSourceMap(stages)
+		if e.htmlValidation {
+			if err := validateHTML(content, sources); err != nil {
+				return "", err
+			}
+		}
+		if e.a11yCheck {
+			e.reportAccessibilityIssues(ctx, content, sources)
+		}
+	}
+
+	// Transform after validation, so issues map to the executed templates
+	var err error
+	if e.headingAnchors.applies(e.canonicalName(name)) {
+		if content, err = e.headingAnchors.addHeadingAnchors(content); err != nil {
+			return "", errors.Join(ErrTemplateExecutionFailed, err)
+		}
+	}
+	if len(e.cssInlineDirs) > 0 && inTemplates(e.canonicalName(name), e.cssInlineDirs) {
+		if content, err = inlineCSS(content); err != nil {
+			return "", errors.Join(ErrTemplateExecutionFailed, err)
+		}
+	}
+	if e.minifyOutput && !MinifySkipped(ctx) {
+		content = minifyHTML(content)
+	}
+	return content, nil
+}
+
+// execute renders the template with the given name and wraps it into the layouts.
+// If trackStages is true, it also returns the output of each template in the chain,
+// starting with the content template, which is used to build source maps.
+// If out is not nil, the outermost template is executed directly into out and the
+// returned content is empty; stages are not tracked in this case.
+func (e *Engine) execute(ctx context.Context, name string, binding interface{}, layouts []LayoutBinding, trackStages bool, out io.Writer) (string, []renderStage, error) {
 	// Get buffer from pool
 	buf := bufferPool.Get().(*bytes.Buffer)
 	buf.Reset()
 	defer bufferPool.Put(buf)
 
 	// Get the base template
+	if err := e.ensureParsed(name); err != nil {
+		return "", nil, err
+	}
 	e.mu.RLock()
-	baseTmpl := e.templates.Lookup(name)
+	baseTmpl := e.lookup(name)
 	e.mu.RUnlock()
 
 	if baseTmpl == nil {
-		return errors.Join(ErrTemplateNotFound, fmt.Errorf("template: %s", name))
+		return "", nil, errors.Join(ErrTemplateNotFound, fmt.Errorf("template: %s", name))
+	}
+	if err := e.checkPublished(baseTmpl.Name()); err != nil {
+		return "", nil, err
+	}
+
+	// Get layout chain before executing anything, so nothing is streamed
+	// if a layout is missing
+	layoutNames := make([]string, len(layouts))
+	for i, layout := range layouts {
+		layoutNames[i] = layout.Name
+	}
+	chain, err := e.getLayoutChain(layoutNames...)
+	if err != nil {
+		return "", nil, err
+	}
+
+	// Templates and layouts requiring a permission are not rendered for unauthorized contexts
+	if err := e.checkAuthorized(ctx, baseTmpl.Name()); err != nil {
+		return "", nil, err
+	}
+	for _, layoutTmpl := range chain.templates {
+		if err := e.checkAuthorized(ctx, layoutTmpl.Name()); err != nil {
+			return "", nil, err
+		}
 	}
 
 	// Create a new template with context-specific functions
-	contextFuncs := template.FuncMap{
-		"T":      getTranslator(ctx),
-		"ctxVal": ctxValue(ctx),
+	contextFuncs := e.contextFuncs(withBindingWizard(ctx, binding))
+	for name, fn := range e.sectionFuncs(baseTmpl.Name(), binding, contextFuncs) {
+		contextFuncs[name] = fn
+	}
+	for name, fn := range e.componentFuncs(ctx, contextFuncs) {
+		contextFuncs[name] = fn
 	}
 
-	// Execute the base template
-	if err := executeTemplateWithFuncs(baseTmpl, buf, binding, contextFuncs); err != nil {
-		return errors.Join(ErrTemplateExecutionFailed, err)
+	// Execute the page and layouts at once if the chain is precompiled
+	if e.precompileChains && len(chain.templates) > 0 && !trackStages {
+		if root := e.precompiledChain(baseTmpl.Name(), chain); root != nil {
+			levels := make([]any, len(chain.templates))
+			levels[0] = binding
+			for i := range chain.templates[:len(chain.templates)-1] {
+				levels[i+1] = e.layoutData(layouts[i].Data, chain.templates[i].Name(), binding)
+			}
+			contextFuncs[layoutDataFunc] = func(level int) any { return levels[level] }
+
+			data := e.layoutData(layouts[len(layouts)-1].Data, root.Name(), binding)
+			w := io.Writer(buf)
+			if out != nil {
+				w = out
+			}
+			if err := e.executeTemplateWithFuncs(root, w, data, contextFuncs); err != nil {
+				return "", nil, errors.Join(ErrTemplateExecutionFailed, err)
+			}
+			return buf.String(), nil, nil
+		}
 	}
 
-	// Get layout chain
-	chain, err := e.getLayoutChain(layouts...)
-	if err != nil {
-		return err
+	// Execute the base template
+	if out != nil && len(chain.templates) == 0 {
+		if err := e.executeTemplateWithFuncs(baseTmpl, out, binding, contextFuncs); err != nil {
+			return "", nil, errors.Join(ErrTemplateExecutionFailed, err)
+		}
+		return "", nil, nil
+	}
+	if err := e.executeTemplateWithFuncs(baseTmpl, buf, binding, contextFuncs); err != nil {
+		return "", nil, errors.Join(ErrTemplateExecutionFailed, err)
 	}
 
 	// Process layout chain
 	content := buf.String()
-	for _, layoutTmpl := range chain.templates {
+
+	var stages []renderStage
+	if trackStages && out == nil {
+		stages = append(stages, renderStage{template: name, output: content, embedAt: -1})
+	}
+
+	for i, layoutTmpl := range chain.templates {
 		buf.Reset()
 
 		layoutFuncs := template.FuncMap{
@@ -286,84 +1141,181 @@ func (e *Engine) Render(ctx context.Context, out io.Writer, name string, binding
 			layoutFuncs[name] = fn
 		}
 
-		if err := executeTemplateWithFuncs(layoutTmpl, buf, binding, layoutFuncs); err != nil {
-			return errors.Join(ErrTemplateExecutionFailed, err)
+		layoutData := e.layoutData(layouts[i].Data, layoutTmpl.Name(), binding)
+
+		// Stream the outermost layout
+		if out != nil && i == len(chain.templates)-1 {
+			if err := e.executeTemplateWithFuncs(layoutTmpl, out, layoutData, layoutFuncs); err != nil {
+				return "", nil, errors.Join(ErrTemplateExecutionFailed, err)
+			}
+			return "", nil, nil
+		}
+
+		if err := e.executeTemplateWithFuncs(layoutTmpl, buf, layoutData, layoutFuncs); err != nil {
+			return "", nil, errors.Join(ErrTemplateExecutionFailed, err)
+		}
+
+		if trackStages {
+			stages = append(stages, renderStage{
+				template: layoutTmpl.Name(),
+				output:   buf.String(),
+				embedAt:  strings.Index(buf.String(), content),
+			})
 		}
 
 		content = buf.String()
 	}
 
-	// Store the final rendered content in cache
-	e.cache.Store(cacheKey, content)
+	return content, stages, nil
+}
 
-	// Write final output
-	_, err = io.WriteString(out, content)
-	return err
+// contextFuncs returns the functions bound to the render context: translations,
+// navigation, locale-aware helpers and a fresh render state
+func (e *Engine) contextFuncs(ctx context.Context) template.FuncMap {
+	translate := getTranslator(ctx)
+	if e.pseudoLocale {
+		translate = pseudoTranslator(translate)
+	}
+	contextFuncs := template.FuncMap{
+		"T":           translate,
+		"ctxVal":      ctxValue(ctx),
+		"isActive":    isActive(ctx),
+		"activeClass": activeClass(ctx),
+		"breadcrumbs": renderBreadcrumbs(ctx),
+		"isPrint":     isPrint(ctx),
+		"isLite":      isLite(ctx),
+		"authorized":  e.authorized(ctx),
+		"consent":     e.consent(ctx),
+	}
+	collator := localeCollator(ctx)
+	contextFuncs["compareLocale"] = compareLocale(collator)
+	contextFuncs["sortLocale"] = sortLocale(collator)
+	contextFuncs["formatUnit"] = formatUnit(ctx, e.formatConfig)
+	contextFuncs["readingTime"] = readingTime(ctx, e.readingSpeeds)
+	contextFuncs["calendarMonth"] = e.calendarMonth(ctx)
+	contextFuncs["absURL"] = e.absURL(ctx)
+	contextFuncs["canonical"] = e.canonical(ctx)
+	for name, fn := range wizardFuncs(ctx) {
+		contextFuncs[name] = fn
+	}
+	for name, fn := range e.formFuncs(ctx) {
+		contextFuncs[name] = fn
+	}
+
+	// Add functions bound to the render state, so values set by the content
+	// template are readable in the layouts. Pages are never indexed outside production.
+	state := newRenderState()
+	state.noindex = e.env != EnvProduction
+	for name, fn := range state.funcs() {
+		contextFuncs[name] = fn
+	}
+	return contextFuncs
 }
 
-// generateCacheKey creates a unique cache key based on template name, layouts, and binding data
-func generateCacheKey(hardCache bool, locale, name string, binding interface{}, layouts ...string) string {
-	baseKey := fmt.Sprintf("%s:%s:", locale, name)
+// layoutData returns the layout's own binding, or transforms the page binding
+// if a data function is registered for the layout
+func (e *Engine) layoutData(data any, layout string, binding any) any {
+	if data != nil {
+		return data
+	}
+	if fn, ok := e.layoutDataFuncs[layout]; ok {
+		return fn(binding)
+	}
+	return binding
+}
+
+// generateCacheKey creates a unique cache key based on template name, layouts, and binding data.
+// The scope contains request-scoped values that affect the output (see requestScope).
+func generateCacheKey(hardCache bool, locale, scope, name string, binding interface{}, layouts ...string) string {
+	baseKey := fmt.Sprintf("%s:%s:%s:", locale, scope, name)
 
 	// If hard caching is enabled, only use the template name and layouts
 	if hardCache {
 		return fmt.Sprintf("%s:%s", baseKey, strings.Join(layouts, ":"))
 	}
 
-	h := fnv.New64a()
+	h := xxhash.New()
 
 	// Add template name
-	h.Write([]byte(baseKey))
+	h.WriteString(baseKey)
 
 	// Add layouts
 	if len(layouts) > 0 {
-		h.Write([]byte(strings.Join(layouts, ":")))
+		h.WriteString(strings.Join(layouts, ":"))
 	}
 
 	// Add hash of binding data
 	if binding != nil {
 		// Handle different types of binding data
 		switch v := binding.(type) {
+		case CacheKeyer:
+			h.WriteString(v.CacheKey())
 		case string:
-			h.Write([]byte(v))
+			h.WriteString(v)
 		case []byte:
 			h.Write(v)
 		case fmt.Stringer:
-			h.Write([]byte(v.String()))
+			h.WriteString(v.String())
 		default:
-			// For other types, use reflection to get a string representation
-			val := reflect.ValueOf(binding)
-			switch val.Kind() {
-			case reflect.Struct, reflect.Map, reflect.Slice, reflect.Array:
-				// Use gob encoding for complex types
-				var buf bytes.Buffer
-				enc := gob.NewEncoder(&buf)
-				// Ignore encoding errors and just use what we can get
-				_ = enc.Encode(binding)
-				h.Write(buf.Bytes())
-			default:
-				// For simple types, use fmt.Sprintf
-				h.Write([]byte(fmt.Sprintf("%v", binding)))
-			}
+			// For other types, use an order-independent encoding,
+			// so equal maps produce equal keys
+			buf := bufferPool.Get().(*bytes.Buffer)
+			buf.Reset()
+			writeCanonical(buf, reflect.ValueOf(binding), 0)
+			h.Write(buf.Bytes())
+			bufferPool.Put(buf)
 		}
 	}
 
 	return fmt.Sprintf("%x", h.Sum64())
 }
 
-// executeTemplateWithFuncs safely executes a template with additional functions
-func executeTemplateWithFuncs(tmpl *template.Template, buf *bytes.Buffer, data interface{}, fns template.FuncMap) error {
-	// Create a new template
-	newTmpl, err := tmpl.Clone()
-	if err != nil {
-		return err
+// templateClone is a clone of a parsed template executed with render-bound functions
+type templateClone struct {
+	tmpl  *template.Template
+	funcs map[string]bool // names of the functions set by the last execution
+}
+
+// executeTemplateWithFuncs safely executes a template with additional functions.
+// The parsed templates are never executed: cloning copies the whole template set and
+// each clone is escaped on its first execution, so clones are pooled and reused across
+// renders, with the functions replaced before every execution.
+func (e *Engine) executeTemplateWithFuncs(tmpl *template.Template, w io.Writer, data interface{}, fns template.FuncMap) error {
+	p, ok := e.clones.Load(tmpl)
+	if !ok {
+		p, _ = e.clones.LoadOrStore(tmpl, &sync.Pool{})
 	}
+	pool := p.(*sync.Pool)
 
-	// Add the functions
-	newTmpl = newTmpl.Funcs(fns)
+	c, _ := pool.Get().(*templateClone)
+	if c == nil {
+		clone, err := tmpl.Clone()
+		if err != nil {
+			return err
+		}
+		c = &templateClone{tmpl: clone, funcs: make(map[string]bool, len(fns))}
+	}
+	defer pool.Put(c)
 
-	// Execute the template
-	return newTmpl.Execute(buf, data)
+	// Restore functions bound to a previous render, so nothing leaks between renders
+	reset := make(template.FuncMap)
+	for name := range c.funcs {
+		if _, ok := fns[name]; !ok {
+			if fn, ok := e.funcMap[name]; ok {
+				reset[name] = fn
+			}
+			delete(c.funcs, name)
+		}
+	}
+	if len(reset) > 0 {
+		c.tmpl.Funcs(reset)
+	}
+	c.tmpl.Funcs(fns)
+	for name := range fns {
+		c.funcs[name] = true
+	}
+
+	return c.tmpl.Execute(w, data)
 }
 
 // RenderString renders a template to a string with optional layouts.
@@ -416,6 +1368,34 @@ func (e *Engine) RenderHTML(ctx context.Context, name string, binding interface{
 	return template.HTML(buf.String()), nil
 }
 
+// RenderStream renders a template with optional layouts, executing the outermost
+// template directly into out instead of buffering the whole page, so large pages don't
+// hold the full output in memory. Inner templates are still buffered, since each
+// layout embeds the output of the template it wraps.
+//
+// Streamed renders bypass the cache, HTML checks, heading anchors, CSS inlining,
+// minification, the debug toolbar and archiving.
+// If template execution fails, part of the output may already be written to out.
+//
+// Returns an error if template execution fails or templates are not found.
+func (e *Engine) RenderStream(ctx context.Context, out io.Writer, name string, binding interface{}, layouts ...string) error {
+	if !e.initialized() {
+		return ErrTemplateEngineNotInitialized
+	}
+
+	bindings := make([]LayoutBinding, len(layouts))
+	for i, layout := range layouts {
+		bindings[i] = LayoutBinding{Name: layout}
+	}
+
+	resolved, err := e.resolveLayouts(name, bindings)
+	if err != nil {
+		return err
+	}
+	_, _, err = e.execute(ctx, name, binding, resolved, false, out)
+	return err
+}
+
 // GetFuncMap returns the function map used by the template engine.
 //
 // The function performs the following:
@@ -3,6 +3,7 @@ package templatex_test
 import (
 	"context"
 	"embed"
+	"strconv"
 	"testing"
 
 	"github.com/dmitrymomot/templatex"
@@ -293,3 +294,95 @@ func BenchmarkTemplateRenderHTML(b *testing.B) {
 		})
 	}
 }
+
+// productPage is a binding with nested data, hashed by reflection unless it
+// provides its own cache key
+type productPage struct {
+	ID      string
+	Version int
+	Tags    []string
+	Attrs   map[string]string
+	Related []pageData
+}
+
+// keyedProductPage provides a cheap cache key
+type keyedProductPage struct{ productPage }
+
+func (p keyedProductPage) CacheKey() string { return p.ID + ":" + strconv.Itoa(p.Version) }
+
+func BenchmarkCacheKey(b *testing.B) {
+	page := productPage{
+		ID:      "sku-42",
+		Version: 7,
+		Tags:    []string{"new", "sale", "featured"},
+		Attrs:   map[string]string{"color": "red", "size": "M", "material": "cotton"},
+		Related: []pageData{{Title: "A", Username: "x"}, {Title: "B", Username: "y"}},
+	}
+
+	benchmarks := []struct {
+		name    string
+		binding any
+	}{
+		{"Reflection", page},
+		{"CacheKeyer", keyedProductPage{page}},
+	}
+
+	for _, bm := range benchmarks {
+		b.Run(bm.name, func(b *testing.B) {
+			templ, err := templatex.NewMemory(map[string]string{"product.gohtml": `{{ .ID }}`})
+			if err != nil {
+				b.Fatal(err)
+			}
+			w := &mockWriter{}
+			ctx := context.Background()
+
+			// Every iteration is a cache hit, so the cost is dominated by the cache key
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if err := templ.Render(ctx, w, "product", bm.binding); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkTemplateRenderUncached(b *testing.B) {
+	if err := ctxi18n.LoadWithDefault(translations, "en"); err != nil {
+		b.Fatal(err)
+	}
+	ctx, err := ctxi18n.WithLocale(context.Background(), "en")
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	// Every render executes the page and its layouts
+	ctx = templatex.SkipCache(ctx)
+	data := pageData{Title: "Contacts", Username: "John Doe", Test: "Test message"}
+
+	for _, precompiled := range []bool{false, true} {
+		name := "Embedded"
+		if precompiled {
+			name = "PrecompiledChains"
+		}
+		b.Run(name, func(b *testing.B) {
+			templ, err := templatex.New("example/templates/",
+				templatex.WithLayouts("app_layout", "base_layout"),
+				templatex.WithPrecompiledChains(precompiled),
+			)
+			if err != nil {
+				b.Fatal(err)
+			}
+			w := &mockWriter{}
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if err := templ.Render(ctx, w, "greeter", data, "app_layout", "base_layout"); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -1,6 +1,18 @@
 package templatex
 
-import "html/template"
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ed25519"
+	"html/template"
+	"io/fs"
+	"log/slog"
+	"strings"
+	"time"
+
+	"golang.org/x/text/language"
+)
 
 // Option is a function type that takes a pointer to an Engine as its argument.
 // It represents a functional option pattern for configuring the Engine instance.
@@ -12,12 +24,14 @@ type Option func(*Engine)
 // It accepts a template.FuncMap containing the mapping of function names to their
 // implementations. If the provided FuncMap is not empty, these functions will be
 // added to the Engine's function map, making them accessible within templates.
-// Existing functions with the same names will be overwritten.
+// Replacing a built-in function requires WithFuncOverride, and functions bound to
+// each render (embed, T, ctxVal, etc.) can't be replaced; New returns an error otherwise.
 func WithFuncs(fns template.FuncMap) Option {
 	return func(e *Engine) {
 		if len(fns) > 0 {
 			for name, fn := range fns {
 				e.funcMap[name] = fn
+				e.customFuncs[name] = true
 			}
 		}
 	}
@@ -26,11 +40,121 @@ func WithFuncs(fns template.FuncMap) Option {
 // WithFunc sets a single template function that will be available in all templates.
 // It accepts a name string for the function and the implementation function itself.
 // The provided function will be added to the Engine's function map, making it
-// accessible within templates. An existing function with the same name will be
-// overwritten.
+// accessible within templates. The same restrictions as for WithFuncs apply to
+// built-in function names.
 func WithFunc(name string, fn interface{}) Option {
 	return func(e *Engine) {
 		e.funcMap[name] = fn
+		e.customFuncs[name] = true
+	}
+}
+
+// WithSourceVerification verifies the template sources against a signed checksum
+// manifest before parsing, so templates injected or modified in a compromised
+// remote store (HTTP, S3, database file systems passed to NewFS) are rejected.
+// The template root and each component namespace root must contain SourceManifestFile
+// and SourceSignatureFile created by SignSources with the matching private key.
+// Template files missing from the manifest or with a different checksum fail
+// the load with ErrSourceVerificationFailed. Templates registered with ParseString
+// and ParseMap come from Go code and are not verified.
+func WithSourceVerification(publicKey ed25519.PublicKey) Option {
+	return func(e *Engine) {
+		e.sourceKey = publicKey
+	}
+}
+
+// WithLocales sets the locale codes the application supports, e.g. WithLocales("en", "es").
+// The locales must be loaded with ctxi18n. See Engine.RenderAllLocales.
+func WithLocales(codes ...string) Option {
+	return func(e *Engine) {
+		e.locales = codes
+	}
+}
+
+// WithPseudoLocale enables pseudo-localization for QA: every string translated with T
+// is rendered with accented letters, padded by 40% and wrapped in markers, e.g. "Hello"
+// becomes "⟦Ĥéļļö~~⟧". Hardcoded strings stay plain and layouts breaking on long
+// translations become visible. Don't enable it in production.
+func WithPseudoLocale(enabled bool) Option {
+	return func(e *Engine) {
+		e.pseudoLocale = enabled
+	}
+}
+
+// WithLazyParsing enables lazy parsing: New only indexes the template files, and each
+// template is parsed the first time it's rendered, together with the templates it
+// includes. This reduces startup time for CLIs and serverless functions rendering
+// only a few templates. Declared layouts (see WithLayouts and WithLayoutChain) are
+// still parsed on load, and looking up a template that isn't named after its file
+// parses all files with define blocks. Parse errors are returned by the first render.
+func WithLazyParsing(enabled bool) Option {
+	return func(e *Engine) {
+		e.lazyParsing = enabled
+	}
+}
+
+// WithAuthorizer sets the function checking permissions required by templates,
+// declared in front matter (requires: admin) or by WithDirectoryPermission.
+// Rendering a protected template or layout directly for an unauthorized context
+// fails with ErrUnauthorized, and protected templates included by other templates
+// render nothing. Without an authorizer, all permissions are denied.
+// Templates can check permissions with {{ if authorized "admin" }}.
+func WithAuthorizer(fn Authorizer) Option {
+	return func(e *Engine) {
+		e.authorizer = fn
+	}
+}
+
+// WithDirectoryPermission requires the permission for all templates in the directory
+// and its subdirectories, e.g. WithDirectoryPermission("admin", "admin"). The closest
+// directory wins, and front matter permissions take precedence. Templates defined with
+// define blocks are only checked when rendered directly. See WithAuthorizer.
+func WithDirectoryPermission(dir, permission string) Option {
+	return func(e *Engine) {
+		if e.dirPermissions == nil {
+			e.dirPermissions = make(map[string]string)
+		}
+		e.dirPermissions[normalizeTemplateName(dir, nil)] = permission
+	}
+}
+
+// WithConsentResolver sets the function checking whether the visitor consented to a
+// category, so analytics and marketing snippets are only rendered with consent:
+// {{ if consent "analytics" }}...{{ end }}. Renders are cached separately per set of
+// granted categories checked by the templates. Without a resolver, consent is denied.
+func WithConsentResolver(fn ConsentResolver) Option {
+	return func(e *Engine) {
+		e.consentResolver = fn
+	}
+}
+
+// WithStrictSinks enables the strict sink mode: htmlSafe only accepts trusted values,
+// i.e. TrustedHTML produced by the sanitize function or TrustHTML, and template.HTML
+// produced by engine components or Go code. Passing a string fails the render with
+// ErrUntrustedHTML, so raw user data can't be inserted unescaped by mistake.
+func WithStrictSinks(enabled bool) Option {
+	return func(e *Engine) {
+		e.strictSinks = enabled
+	}
+}
+
+// WithFuncPacks opts into function packs (see FuncPack). Pack functions follow the
+// same rules as custom functions: functions set by WithFunc or WithFuncs take
+// precedence, and replacing built-in functions requires WithFuncOverride.
+func WithFuncPacks(packs ...FuncPack) Option {
+	return func(e *Engine) {
+		e.funcPacks = append(e.funcPacks, packs...)
+	}
+}
+
+// WithFuncOverride allows custom functions set by WithFunc or WithFuncs to replace
+// the built-in functions with the given names, e.g. WithFuncOverride("default").
+// Functions bound to each render (embed, T, ctxVal, etc.) can't be overridden.
+func WithFuncOverride(names ...string) Option {
+	return func(e *Engine) {
+		for _, name := range names {
+			e.funcOverrides[name] = true
+		}
 	}
 }
 
@@ -47,12 +171,57 @@ func WithExtensions(exts ...string) Option {
 	}
 }
 
+// WithCaseInsensitiveNames enables matching template and layout names passed to the
+// engine ignoring case, e.g. "Pages/Home" finds the template parsed from pages/home.gohtml.
+// This avoids failures when templates are developed on a case-insensitive file system
+// (macOS) and deployed to Linux. References between templates ({{ template "name" }})
+// are resolved by html/template and remain case-sensitive. New returns an error if
+// template names differ only in case.
+func WithCaseInsensitiveNames(enabled bool) Option {
+	return func(e *Engine) {
+		e.caseInsensitive = enabled
+	}
+}
+
+// WithAutoReload enables reloading templates when files in the template directory
+// change, so edits are visible without restarting the process. Changed templates are
+// re-parsed, the templates are swapped atomically and the render and layout caches
+// are cleared. If parsing fails, the error is logged (see WithLogger) and the current
+// templates are kept. It's intended for development and only works with New, since
+// file systems passed to NewFS can't be watched. Use Engine.Close to stop watching.
+func WithAutoReload(enabled bool) Option {
+	return func(e *Engine) {
+		e.autoReload = enabled
+	}
+}
+
+// WithNamespace registers component templates from the root directory of fsys under
+// the namespace, e.g. WithNamespace("ui", os.DirFS("design-system"), "components").
+// Templates are named "<namespace>.<path>", so button.gohtml is available as
+// {{ template "ui.button" . }}. Templates defined with {{ define }} keep their names.
+//
+// Components can ship several versions during migrations: button@v2.gohtml is
+// available as "ui.button@v2", and "ui.button" resolves to the latest version unless
+// an unversioned button.gohtml exists. The option can be used multiple times.
+func WithNamespace(name string, fsys fs.FS, root string) Option {
+	return func(e *Engine) {
+		if name == "" || fsys == nil {
+			return
+		}
+		if root == "" {
+			root = "."
+		}
+		e.namespaces = append(e.namespaces, componentNamespace{name: name, fsys: fsys, root: root})
+	}
+}
+
 // WithLayouts sets the layout templates that will be used as base templates for all pages.
 // It accepts a variadic number of string arguments representing layout template file paths
 // (e.g., "layouts/base.gohtml", "layouts/main.gohtml"). These layouts are used as common
 // templates that wrap content templates. Setting layouts explicitly can optimize template
 // processing by pre-defining the layout chain computation. If no layouts are provided,
-// the current layout settings remain unchanged.
+// the current layout settings remain unchanged. New returns ErrLayoutNotFound if any of
+// the layouts doesn't exist.
 func WithLayouts(layouts ...string) Option {
 	return func(e *Engine) {
 		if len(layouts) > 0 {
@@ -61,6 +230,30 @@ func WithLayouts(layouts ...string) Option {
 	}
 }
 
+// WithDefaultLayout sets the layouts wrapping templates rendered without layouts, listed
+// in the same order as passed to Render, e.g. WithDefaultLayout("app_layout", "base_layout"),
+// so handlers don't repeat them. Pass NoLayout to Render to opt out, e.g. for fragments.
+// A default layout rendered by itself is only wrapped in the layouts following it.
+// Emails (see RenderEmail) and pushed fragments (see RenderPush) don't use the default
+// layouts. New returns ErrLayoutNotFound if any of the layouts doesn't exist.
+func WithDefaultLayout(layouts ...string) Option {
+	return func(e *Engine) {
+		e.defaultLayouts = layouts
+	}
+}
+
+// WithConventionLayouts enables layouts by directory convention: templates rendered
+// without layouts are wrapped in the _layout templates of their directory and its parent
+// directories, innermost first, e.g. pages/admin/users.gohtml in pages/admin/_layout.gohtml,
+// pages/_layout.gohtml and _layout.gohtml, whichever exist. Templates without _layout
+// templates use the default layouts (see WithDefaultLayout). Pass NoLayout to Render
+// to render fragments without layouts.
+func WithConventionLayouts(enabled bool) Option {
+	return func(e *Engine) {
+		e.layoutConvention = enabled
+	}
+}
+
 // WithHardCache sets the hard caching behavior of the template engine.
 // When hard caching is enabled, rendered templates are cached permanently and only
 // re-rendered if the cache is manually cleared. This can significantly improve
@@ -73,13 +266,434 @@ func WithHardCache(enabled bool) Option {
 	}
 }
 
+// WithCacheTTL sets how long rendered templates stay in the render cache.
+// Expired renders are re-rendered on the next request. Zero (default) means no expiry.
+func WithCacheTTL(ttl time.Duration) Option {
+	return func(e *Engine) {
+		e.cache.ttl = ttl
+	}
+}
+
+// WithCacheMaxEntries limits the number of renders in the render cache. The least
+// recently used renders are evicted when the limit is reached, so long-running servers
+// rendering varied data don't leak memory. Zero (default) means no limit.
+func WithCacheMaxEntries(n int) Option {
+	return func(e *Engine) {
+		e.cache.max = n
+	}
+}
+
+// WithCacheCallback sets a function called on every render cache lookup with the
+// template name and whether the render was served from the cache, e.g. to export
+// hit and miss metrics per template. See also Engine.CacheStats.
+func WithCacheCallback(fn func(ctx context.Context, name string, hit bool)) Option {
+	return func(e *Engine) {
+		e.cacheCallback = fn
+	}
+}
+
 // WithLayoutCache sets the layout caching behavior of the template engine.
-// When layout caching is enabled, computed layouts for templates are cached and reused.
-// This can improve performance by avoiding layout chain computation on subsequent renders.
-// When disabled, layouts are recomputed for each template render. Layout caching is
-// recommended for templates with stable layout relationships.
+// When layout caching is enabled, computed layout chains (the templates of a layout
+// combination passed to Render) are cached and reused. This can improve performance
+// by avoiding layout chain computation on subsequent renders. When disabled (default),
+// layouts are looked up for each template render. Layout caching is recommended for
+// templates with stable layout relationships. Chains declared with WithLayoutChain
+// are built when the engine is created.
 func WithLayoutCache(enabled bool) Option {
 	return func(e *Engine) {
 		e.layoutCacheEnable = enabled
 	}
 }
+
+// WithLayoutChain declares a layout combination used by renders, listed in the same
+// order as passed to Render, e.g. WithLayoutChain("app_layout", "base_layout").
+// The option can be used multiple times. New returns ErrLayoutNotFound if any of the
+// layouts doesn't exist, so typos are caught at startup rather than on the first request.
+// If layout caching is enabled, the chains are pre-built (see WithLayoutCache).
+func WithLayoutChain(layouts ...string) Option {
+	return func(e *Engine) {
+		if len(layouts) > 0 {
+			e.layoutChains = append(e.layoutChains, layouts)
+		}
+	}
+}
+
+// WithPrecompiledChains enables precompiled layout chains: instead of rendering the page
+// and passing its output to each layout through embed, the page and its layouts are
+// combined into one template set on first use, with {{ embed }} calling the wrapped
+// template, so a single execution renders the whole page. Content is escaped in the
+// context of the embed action rather than embedded as HTML.
+// Chains using render state functions (setTitle, pageTitle, push, stack, once, etc.),
+// using embed other than as {{ embed }}, or rendered with HTML validation or
+// accessibility checks use the regular rendering path. The sets hold the templates of the
+// chain and the ones they include, and the least recently used sets are evicted beyond 1000.
+func WithPrecompiledChains(enabled bool) Option {
+	return func(e *Engine) {
+		e.precompileChains = enabled
+	}
+}
+
+// WithLayoutDataFunc sets a function that transforms the binding data passed to
+// the given layout. Layouts often need a different shape of data (navigation model,
+// footer links) than the page binding. The function receives the original page
+// binding and its result is used as the layout's binding. The content template and
+// other layouts still receive the original binding.
+// If fn is nil, the option has no effect.
+func WithLayoutDataFunc(layout string, fn func(binding any) any) Option {
+	return func(e *Engine) {
+		if fn != nil {
+			e.layoutDataFuncs[layout] = fn
+		}
+	}
+}
+
+// WithTemplateVars sets site-wide constants available in all templates through the
+// vars function, e.g. {{ vars.SupportEmail }}. Unlike per-request data, the variables
+// are defined once for the engine, so they don't affect the render cache keys.
+// Multiple calls are merged, with later values overwriting earlier ones.
+func WithTemplateVars(vars map[string]any) Option {
+	return func(e *Engine) {
+		for name, v := range vars {
+			e.vars[name] = v
+		}
+	}
+}
+
+// WithEnvironment sets the environment name the engine runs in (e.g. "production",
+// "development", "staging"). The name is available in templates through the env,
+// isProduction and isDevelopment functions, so environment-specific markup like
+// analytics snippets or debug banners can be toggled consistently.
+// The name is case-insensitive. If empty, the default EnvProduction is kept.
+func WithEnvironment(env string) Option {
+	return func(e *Engine) {
+		if env != "" {
+			e.env = strings.ToLower(env)
+		}
+	}
+}
+
+// WithDebugToolbar enables a collapsible debug toolbar injected into rendered HTML
+// pages. The toolbar shows the render time, cache status, rendered templates,
+// locale and a dump of the binding data. It's only injected when the engine runs in
+// the development environment (see WithEnvironment) and only into documents with a
+// closing body tag, so partials and fragments are never affected.
+func WithDebugToolbar(enabled bool) Option {
+	return func(e *Engine) {
+		e.debugToolbar = enabled
+	}
+}
+
+// WithDebugRedactFields sets the names of struct fields and map keys whose values
+// are replaced with "[REDACTED]" by the debug and debugHTML functions and the debug
+// toolbar, so accidental dumps don't leak secrets. Names are case-insensitive.
+// Multiple calls are merged.
+func WithDebugRedactFields(fields ...string) Option {
+	return func(e *Engine) {
+		e.debugRedactFields = append(e.debugRedactFields, fields...)
+	}
+}
+
+// WithClock sets the clock used by the now and since template functions and by
+// the render cache expiry (see WithCacheTTL).
+// A fixed clock makes snapshot tests and previews deterministic.
+// If clock is nil, time.Now is used.
+func WithClock(clock func() time.Time) Option {
+	return func(e *Engine) {
+		if clock != nil {
+			e.clock = clock
+		}
+	}
+}
+
+// WithFormatConfig sets the number and date formatter settings used by the formatNumber,
+// formatDate and humanizeTime functions and the client-side bundle (see Engine.FormattersJS).
+// Empty separators and date layout keep their defaults.
+func WithFormatConfig(cfg FormatConfig) Option {
+	return func(e *Engine) {
+		def := defaultFormatConfig()
+		if cfg.DecimalSeparator == "" {
+			cfg.DecimalSeparator = def.DecimalSeparator
+		}
+		if cfg.ThousandsSeparator == "" {
+			cfg.ThousandsSeparator = def.ThousandsSeparator
+		}
+		if cfg.DateLayout == "" {
+			cfg.DateLayout = def.DateLayout
+		}
+		e.formatConfig = cfg
+	}
+}
+
+// WithAddressFormats sets the address formats used by formatAddress by country code,
+// e.g. "DE": "{Name}\n{Street}\n{PostalCode} {City}". Tokens in braces are address
+// fields and lines are separated by "\n". The "" key sets the format of countries
+// without a format. Formats of other countries keep their defaults.
+func WithAddressFormats(formats map[string]string) Option {
+	return func(e *Engine) {
+		for country, format := range formats {
+			e.addressFormats[strings.ToUpper(country)] = format
+		}
+	}
+}
+
+// WithReadingSpeeds sets the reading speeds used by readingTime in words per minute by
+// locale or language, e.g. "de": 200 or "pt-BR": 190. The "" key sets the speed of
+// languages without one. Speeds of other languages keep their defaults.
+func WithReadingSpeeds(speeds map[string]int) Option {
+	return func(e *Engine) {
+		for locale, wpm := range speeds {
+			if wpm <= 0 {
+				continue
+			}
+			if tag, err := language.Parse(locale); err == nil {
+				locale = tag.String()
+			}
+			e.readingSpeeds[locale] = wpm
+		}
+	}
+}
+
+// WithWeekdayNames sets the abbreviated day names used by calendarMonth by locale or
+// language, starting with Sunday, e.g. "da": {"søn", "man", "tir", "ons", "tor", "fre", "lør"}.
+// The "" key sets the names of languages without them. Names of other languages keep
+// their defaults.
+func WithWeekdayNames(names map[string][7]string) Option {
+	return func(e *Engine) {
+		for locale, n := range names {
+			if tag, err := language.Parse(locale); err == nil {
+				locale = tag.String()
+			}
+			e.weekdayNames[locale] = n
+		}
+	}
+}
+
+// WithBaseURL sets the absolute base URL of absURL and canonical, e.g.
+// "https://example.com" or "https://example.com/docs". Without it, the scheme and host
+// of the request stored by Middleware are used, which are unavailable outside of
+// requests, e.g. in emails rendered by workers, and come from the Host header.
+// Invalid URLs fail engine creation with ErrInvalidBaseURL.
+func WithBaseURL(baseURL string) Option {
+	return func(e *Engine) {
+		e.rawBaseURL = baseURL
+	}
+}
+
+// WithURLSigner enables the signedURL template function, which signs links to protected
+// resources with an HMAC of the secret using the hash function, e.g.
+// WithURLSigner(secret, crypto.SHA256). Handlers check the links with
+// Engine.VerifySignedURL. An empty secret or unavailable hash function fails engine
+// creation with ErrInvalidURLSigner.
+func WithURLSigner(secret []byte, algorithm crypto.Hash) Option {
+	return func(e *Engine) {
+		e.urlSigner = &urlSigner{secret: bytes.Clone(secret), hash: algorithm}
+	}
+}
+
+// WithMarkdown enables Markdown templates: .md files are converted to HTML with fn when
+// parsed and rendered like other templates, e.g. wrapped by layouts. Template actions
+// and front matter work as in other templates. It also enables the markdown function
+// for Markdown strings from data, which then can't be provided by a function pack.
+// See the goldmarkadapter module for a converter.
+func WithMarkdown(fn MarkdownFunc) Option {
+	return func(e *Engine) {
+		e.markdownFn = fn
+	}
+}
+
+// WithMinifyOutput enables minification of rendered HTML before it's cached and written:
+// comments are removed and runs of whitespace are collapsed. Content of pre, textarea,
+// code, script and style elements is kept as is. Minification can be skipped per render
+// with SkipMinify. Streamed renders (see RenderStream) are not minified.
+func WithMinifyOutput(enabled bool) Option {
+	return func(e *Engine) {
+		e.minifyOutput = enabled
+	}
+}
+
+// WithHTMXForms makes the form component render htmx attributes, so forms are submitted
+// by htmx with their method (e.g. hx-post) and swap the response into the Target prop
+// with the Swap prop strategy.
+func WithHTMXForms(enabled bool) Option {
+	return func(e *Engine) {
+		e.htmxForms = enabled
+	}
+}
+
+// WithCSRFFieldName sets the name of the hidden form field holding the CSRF token
+// (see WithCSRFToken). The default is "csrf_token".
+func WithCSRFFieldName(name string) Option {
+	return func(e *Engine) {
+		e.csrfField = name
+	}
+}
+
+// WithHeadingAnchors adds slugified, collision-safe ids and anchor links to the h2–h4
+// elements of pages rendered from the configured templates or directories, so sections
+// can be deep-linked. Existing ids are kept. Levels and the link are configurable.
+// Anchors are added before the output is cached.
+func WithHeadingAnchors(cfg HeadingAnchors) Option {
+	return func(e *Engine) {
+		cfg = cfg.withDefaults()
+		cfg.Templates = append([]string(nil), cfg.Templates...)
+		e.headingAnchors = &cfg
+	}
+}
+
+// WithCSSInlining inlines the rules of style elements into style attributes of the
+// pages rendered from templates in the directories, "emails" by default, since many
+// email clients ignore style elements. Rules that can't be inlined, like @media
+// queries and :hover, stay in the style element. CSS is inlined before the output
+// is cached.
+func WithCSSInlining(dirs ...string) Option {
+	return func(e *Engine) {
+		if len(dirs) == 0 {
+			dirs = []string{"emails"}
+		}
+		e.cssInlineDirs = append(e.cssInlineDirs, dirs...)
+	}
+}
+
+// WithEmailText parses .txt files as plaintext templates of the emails rendered by
+// RenderEmail, e.g. emails/welcome.txt for emails/welcome. Their front matter declares
+// publish windows and permissions like for HTML templates. Disabled by default, so
+// .txt files of the template directories are ignored.
+func WithEmailText(enabled bool) Option {
+	return func(e *Engine) {
+		e.emailText = enabled
+	}
+}
+
+// WithCacheEncoding adds a content encoding used by RenderCompressed, e.g.
+// WithCacheEncoding("gzip", templatex.GzipEncode). Encoded variants of cached content
+// are cached with it. Brotli and other encodings can be added with an encoder from
+// their package. Encodings accepted with equal quality are preferred in the order
+// they are added; adding an encoding again replaces its encoder.
+func WithCacheEncoding(encoding string, fn EncodeFunc) Option {
+	return func(e *Engine) {
+		if encoding == "" || fn == nil {
+			return
+		}
+		encoding = strings.ToLower(encoding)
+		for i, enc := range e.encodings {
+			if enc.name == encoding {
+				e.encodings[i].encode = fn
+				return
+			}
+		}
+		e.encodings = append(e.encodings, contentEncoding{name: encoding, encode: fn})
+	}
+}
+
+// WithFakeFuncs enables the lorem, fakeName, fakeEmail and placeholderImage
+// functions outside of the development environment. By default, these functions
+// generate placeholder content only in development and return empty values
+// in other environments (see WithEnvironment).
+func WithFakeFuncs(enabled bool) Option {
+	return func(e *Engine) {
+		e.fakeFuncs = enabled
+	}
+}
+
+// WithHTMLValidation enables validation of the rendered HTML. The final output is
+// parsed with an HTML tokenizer and checked for unclosed tags, unexpected end tags,
+// invalid nesting and duplicate IDs. Issues are reported as an *HTMLValidationError
+// naming the template that produced the offending markup, and the output is not
+// written. Validation adds overhead to every uncached render, so it's intended
+// for development and tests.
+func WithHTMLValidation(enabled bool) Option {
+	return func(e *Engine) {
+		e.htmlValidation = enabled
+	}
+}
+
+// WithLogger sets the logger used to report diagnostics, such as accessibility
+// issues. If logger is nil, slog.Default() is used.
+func WithLogger(logger *slog.Logger) Option {
+	return func(e *Engine) {
+		if logger != nil {
+			e.logger = logger
+		}
+	}
+}
+
+// WithAccessibilityCheck enables an accessibility lint pass on rendered pages.
+// It reports images without alt text, form controls without labels and a missing
+// lang attribute on the html element as warnings through the logger (see WithLogger),
+// naming the template that produced the markup. Rendering is not affected.
+// The check adds overhead to every uncached render, so it's intended for development.
+func WithAccessibilityCheck(enabled bool) Option {
+	return func(e *Engine) {
+		e.a11yCheck = enabled
+	}
+}
+
+// WithMutationCheck enables detection of binding data mutated during uncached renders,
+// e.g. by a handler goroutine modifying a map that is being rendered. The binding is
+// copied before the render and compared afterwards; changes are logged as warnings
+// through the logger (see WithLogger). The check is expensive and only runs in the
+// development environment (see WithEnvironment).
+func WithMutationCheck(enabled bool) Option {
+	return func(e *Engine) {
+		e.mutationCheck = enabled
+	}
+}
+
+// WithCacheTags sets a function that returns tags for a rendered template, e.g. the IDs
+// of the content it references. Cached renders can then be removed by tag using
+// Engine.InvalidateByTag, so publish events purge exactly the affected pages.
+func WithCacheTags(fn func(name string, binding any) []string) Option {
+	return func(e *Engine) {
+		e.cacheTagFn = fn
+	}
+}
+
+// WithCacheKeyFields sets the binding fields used to build cache keys, e.g.
+// WithCacheKeyFields("User.ID", "Page.Version"). Fields support dotted paths through
+// structs and maps. Only these fields are hashed instead of the whole binding, so
+// keys are cheap and never derived from other, possibly sensitive, data.
+// Bindings implementing CacheKeyer take precedence.
+func WithCacheKeyFields(fields ...string) Option {
+	return func(e *Engine) {
+		e.cacheFields = fields
+	}
+}
+
+// WithCacheKeyFunc sets a function returning the cache key part derived from the
+// render, replacing the binding hash. Use it to include request state like the
+// user role or tenant ID, or to exclude volatile binding fields:
+//
+//	templatex.WithCacheKeyFunc(func(ctx context.Context, name string, data any, layouts []string) string {
+//		return tenantID(ctx) + ":" + data.(Page).Slug
+//	})
+//
+// The locale, template and layout names are still part of the key, so keys of
+// different pages never collide. It takes precedence over WithCacheKeyFields,
+// CacheKeyer bindings and hard caching.
+func WithCacheKeyFunc(fn func(ctx context.Context, name string, data any, layouts []string) string) Option {
+	return func(e *Engine) {
+		e.cacheKeyFn = fn
+	}
+}
+
+// WithArchive enables archiving of rendered output for compliance, e.g. to keep
+// exactly what was shown to a user on invoice or consent pages. For each render
+// with a request ID in the context (see WithRequestID and Middleware), the output
+// is written both to the destination writer and to the writer returned by fn.
+// Use ArchiveToDir to store the output on disk.
+func WithArchive(fn ArchiveFunc) Option {
+	return func(e *Engine) {
+		e.archive = fn
+	}
+}
+
+// WithSlowRenderThreshold reports uncached renders taking longer than d, so performance
+// regressions in templates surface before users notice them. The callback is called
+// synchronously after the templates are executed; if it's nil, slow renders are logged
+// as warnings through the logger (see WithLogger).
+func WithSlowRenderThreshold(d time.Duration, fn func(ctx context.Context, r SlowRender)) Option {
+	return func(e *Engine) {
+		e.slowRender = d
+		e.slowRenderFn = fn
+	}
+}
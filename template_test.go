@@ -2,13 +2,34 @@ package templatex_test
 
 import (
 	"bytes"
+	"compress/gzip"
 	"context"
+	"crypto"
+	"crypto/ed25519"
 	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
 	"html/template"
+	"io"
+	"log/slog"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"net/url"
 	"os"
 	"path/filepath"
+	"runtime"
+	"slices"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"testing/fstest"
+	"time"
 
 	"github.com/dmitrymomot/templatex"
 	"github.com/invopop/ctxi18n"
@@ -44,10 +65,28 @@ func TestNew(t *testing.T) {
 			name: "Valid directory with custom functions",
 			root: "example/templates/",
 			fns: template.FuncMap{
-				"upper": strings.ToUpper,
+				"shout": strings.ToUpper,
 			},
 			wantErr: false,
 		},
+		{
+			name: "Custom function overriding built-in",
+			root: "example/templates/",
+			fns: template.FuncMap{
+				"upper": strings.ToUpper,
+			},
+			wantErr:  true,
+			errorMsg: "custom function overrides built-in function: upper",
+		},
+		{
+			name: "Custom function with reserved name",
+			root: "example/templates/",
+			fns: template.FuncMap{
+				"embed": func() string { return "" },
+			},
+			wantErr:  true,
+			errorMsg: "function name is reserved: embed",
+		},
 		{
 			name:    "Valid directory with custom extensions",
 			root:    "example/templates/",
@@ -191,7 +230,7 @@ func TestTemplateWithCustomFunctions(t *testing.T) {
 		"lower": strings.ToLower,
 	}
 
-	_, err := templatex.New("example/templates/", templatex.WithExtensions(".gohtml"), templatex.WithFuncs(customFuncs))
+	_, err := templatex.New("example/templates/", templatex.WithExtensions(".gohtml"), templatex.WithFuncs(customFuncs), templatex.WithFuncOverride("upper", "lower"))
 	require.NoError(t, err)
 
 	// Create a test template file with custom functions
@@ -202,7 +241,7 @@ func TestTemplateWithCustomFunctions(t *testing.T) {
 	require.NoError(t, err)
 
 	// Create a new engine with the temp directory
-	engine, err := templatex.New(tempDir, templatex.WithExtensions(".gohtml"), templatex.WithFuncs(customFuncs))
+	engine, err := templatex.New(tempDir, templatex.WithExtensions(".gohtml"), templatex.WithFuncs(customFuncs), templatex.WithFuncOverride("upper", "lower"))
 	require.NoError(t, err)
 
 	var buf bytes.Buffer
@@ -474,6 +513,18 @@ func TestTemplateFunctions(t *testing.T) {
 			data:     []interface{}{1, 2, 3},
 			expected: "3",
 		},
+		{
+			name:     "len function with typed map",
+			template: `{{ len . }}`,
+			data:     map[string]int{"a": 1},
+			expected: "1",
+		},
+		{
+			name:     "len function with pointer to slice",
+			template: `{{ len . }}`,
+			data:     &[]string{"a", "b"},
+			expected: "2",
+		},
 		{
 			name:     "htmlSafe function",
 			template: `{{ "<p>hello</p>" | htmlSafe }}`,
@@ -535,6 +586,12 @@ func TestDefaultValue(t *testing.T) {
 			data:     interface{}(nil),
 			expected: "default",
 		},
+		{
+			name:     "default with pointer to empty string",
+			template: `{{ . | default "default" }}`,
+			data:     func() **string { s := ""; p := &s; return &p }(),
+			expected: "default",
+		},
 		{
 			name:     "default with zero int",
 			template: `{{ . | default "default" }}`,
@@ -611,9 +668,23 @@ func TestTemplateWithLayouts(t *testing.T) {
 }
 
 func TestSafeFieldFunction(t *testing.T) {
-	type TestStruct struct {
+	type Profile struct {
 		Name string
-		Age  int
+	}
+	type Base struct {
+		ID int
+	}
+	type Audit struct {
+		Author string
+	}
+	type TestStruct struct {
+		Base
+		*Audit
+		Name    string
+		Age     int
+		Profile *Profile
+		Tags    []string
+		Meta    map[string]interface{}
 	}
 
 	tests := []struct {
@@ -640,6 +711,66 @@ func TestSafeFieldFunction(t *testing.T) {
 			data:     TestStruct{Name: "John"},
 			expected: "fallback",
 		},
+		{
+			name:     "non-string field",
+			template: `{{ safeField . "Age" }}`,
+			data:     TestStruct{Age: 42},
+			expected: "42",
+		},
+		{
+			name:     "pointer to struct",
+			template: `{{ safeField . "Name" }}`,
+			data:     &TestStruct{Name: "John"},
+			expected: "John",
+		},
+		{
+			name:     "embedded struct field",
+			template: `{{ safeField . "ID" }}`,
+			data:     TestStruct{Base: Base{ID: 7}},
+			expected: "7",
+		},
+		{
+			name:     "field of embedded pointer",
+			template: `{{ safeField . "Author" }}`,
+			data:     TestStruct{Audit: &Audit{Author: "Ann"}},
+			expected: "Ann",
+		},
+		{
+			name:     "field of nil embedded pointer",
+			template: `{{ safeField . "Author" "none" }}`,
+			data:     TestStruct{},
+			expected: "none",
+		},
+		{
+			name:     "dotted path through pointer",
+			template: `{{ safeField . "Profile.Name" }}`,
+			data:     TestStruct{Profile: &Profile{Name: "Jane"}},
+			expected: "Jane",
+		},
+		{
+			name:     "dotted path through nil pointer",
+			template: `{{ safeField . "Profile.Name" "none" }}`,
+			data:     TestStruct{},
+			expected: "none",
+		},
+		{
+			name:     "slice index",
+			template: `{{ safeField . "Tags.1" }}`,
+			data:     TestStruct{Tags: []string{"a", "b"}},
+			expected: "b",
+		},
+		{
+			name:     "map with non-string values",
+			template: `{{ safeField . "Meta.count" }}`,
+			data:     TestStruct{Meta: map[string]interface{}{"count": 3}},
+			expected: "3",
+		},
+		{
+			name:     "nil data",
+			template: `{{ safeField . "Name" "fallback" }}`,
+			data:     nil,
+			expected: "fallback",
+		},
 	}
 
 	engine, err := templatex.New("example/templates/")
@@ -819,3 +950,4141 @@ func TestTranslationInLayout(t *testing.T) {
 		})
 	}
 }
+
+func TestNavigationHelpers(t *testing.T) {
+	tempDir := t.TempDir()
+	content := `{{ if isActive "/settings" }}active{{ end }}|{{ activeClass "/" "home" }}`
+	err := os.WriteFile(filepath.Join(tempDir, "nav.gohtml"), []byte(content), 0644)
+	require.NoError(t, err)
+
+	engine, err := templatex.New(tempDir)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name     string
+		path     string
+		expected string
+	}{
+		{"No path in context", "", "|"},
+		{"Exact match", "/settings", "active|"},
+		{"Nested path", "/settings/profile", "active|"},
+		{"Similar prefix", "/settingsx", "|"},
+		{"Root path", "/", "|home"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := templatex.WithRequestPath(context.Background(), tt.path)
+			result, err := engine.RenderString(ctx, "nav", nil)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, result)
+		})
+	}
+}
+
+func TestBreadcrumbs(t *testing.T) {
+	tempDir := t.TempDir()
+	err := os.WriteFile(filepath.Join(tempDir, "page.gohtml"), []byte(`{{ breadcrumbs }}`), 0644)
+	require.NoError(t, err)
+
+	engine, err := templatex.New(tempDir)
+	require.NoError(t, err)
+
+	t.Run("Empty trail", func(t *testing.T) {
+		result, err := engine.RenderString(context.Background(), "page", nil)
+		require.NoError(t, err)
+		assert.Empty(t, result)
+	})
+
+	t.Run("Trail with items", func(t *testing.T) {
+		ctx := templatex.WithBreadcrumbs(context.Background())
+		templatex.Breadcrumbs(ctx).Add("Home", "/").Add("<Settings>", "/settings")
+
+		result, err := engine.RenderString(ctx, "page", nil)
+		require.NoError(t, err)
+		assert.Contains(t, result, `<li><a href="/">Home</a></li>`)
+		assert.Contains(t, result, `<li aria-current="page">&lt;Settings&gt;</li>`)
+		assert.Contains(t, result, `"@type":"BreadcrumbList"`)
+		assert.Contains(t, result, `"position":2`)
+		assert.NotContains(t, result, `"<Settings>"`)
+	})
+}
+
+func TestWizardHelpers(t *testing.T) {
+	engine, err := templatex.NewMemory(map[string]string{
+		"wizard.gohtml": `{{ with wizardStep }}{{ range .Steps }}[{{ .Number }}.{{ .Title }}{{ if .Current }}*{{ end }}{{ if .Done }} {{ .URL }}{{ end }}]{{ end }}` +
+			` {{ .Number }}/{{ .Total }} prev={{ .Prev }} next={{ .Next }}{{ else }}none{{ end }}` +
+			`{{ if isCurrentStep "profile" }} on-profile{{ end }} {{ stepURL "plan" }}`,
+	})
+	require.NoError(t, err)
+
+	w := templatex.Wizard{
+		Steps: []templatex.WizardStep{
+			{Name: "account", Title: "Account"},
+			{Name: "profile", Title: "Profile"},
+			{Name: "plan", Title: "Plan"},
+		},
+		Current: "profile",
+		URL:     "/signup/{step}",
+	}
+	want := "[1.Account /signup/account][2.Profile*][3.Plan] 2/3 prev=account next=plan on-profile /signup/plan"
+
+	out, err := engine.RenderString(templatex.WithWizard(context.Background(), w), "wizard", nil)
+	require.NoError(t, err)
+	assert.Equal(t, want, out)
+
+	// The binding state is used if the context doesn't carry one
+	out, err = engine.RenderString(context.Background(), "wizard", map[string]any{"Wizard": &w})
+	require.NoError(t, err)
+	assert.Equal(t, want, out)
+
+	// Renders with different steps are cached separately
+	w.Current, w.URL = "account", ""
+	out, err = engine.RenderString(templatex.WithWizard(context.Background(), w), "wizard", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "[1.Account*][2.Profile][3.Plan] 1/3 prev= next=profile ?step=plan", out)
+
+	out, err = engine.RenderString(context.Background(), "wizard", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "none ?step=plan", out)
+}
+
+func TestCalendarMonth(t *testing.T) {
+	require.NoError(t, ctxi18n.LoadWithDefault(testTranslations, "en"))
+
+	clock := func() time.Time { return time.Date(2024, time.March, 15, 10, 0, 0, 0, time.UTC) }
+	engine, err := templatex.NewMemory(map[string]string{
+		"calendar.gohtml": `{{ with calendarMonth now.Year now.Month }}{{ range .Weekdays }}{{ . }} {{ end }}` +
+			`{{ range .Weeks }}|{{ range . }}{{ if .Today }}*{{ end }}{{ if .InMonth }}{{ .Day }}{{ else }}.{{ end }} {{ end }}{{ end }}{{ end }}`,
+		"february.gohtml": `{{ $cal := calendarMonth 2026 2 }}{{ len $cal.Weeks }}`,
+	}, templatex.WithClock(clock))
+	require.NoError(t, err)
+
+	// English weeks start on Sunday, March 1, 2024 is a Friday
+	en, err := ctxi18n.WithLocale(context.Background(), "en")
+	require.NoError(t, err)
+	out, err := engine.RenderString(en, "calendar", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "Sun Mon Tue Wed Thu Fri Sat "+
+		"|. . . . . 1 2 |3 4 5 6 7 8 9 |10 11 12 13 14 *15 16 |17 18 19 20 21 22 23 |24 25 26 27 28 29 30 |31 . . . . . . ", out)
+
+	// Spanish weeks start on Monday
+	es, err := ctxi18n.WithLocale(context.Background(), "es")
+	require.NoError(t, err)
+	out, err = engine.RenderString(es, "calendar", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "lun mar mié jue vie sáb dom "+
+		"|. . . . 1 2 3 |4 5 6 7 8 9 10 |11 12 13 14 *15 16 17 |18 19 20 21 22 23 24 |25 26 27 28 29 30 31 ", out)
+
+	// February 2026 starts on Sunday and fits in four weeks
+	out, err = engine.RenderString(en, "february", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "4", out)
+}
+
+func TestTitleAndMetaAccumulation(t *testing.T) {
+	tempDir := t.TempDir()
+	files := map[string]string{
+		"page.gohtml":   `{{ setTitle "Settings" }}{{ appendTitle "App" }}{{ setMeta "description" "Page <desc>" }}content`,
+		"layout.gohtml": `<title>{{ pageTitle }}</title>{{ metaTags }}<p>{{ meta "description" }}</p>{{ embed }}`,
+	}
+	for name, content := range files {
+		err := os.WriteFile(filepath.Join(tempDir, name), []byte(content), 0644)
+		require.NoError(t, err)
+	}
+
+	engine, err := templatex.New(tempDir)
+	require.NoError(t, err)
+
+	result, err := engine.RenderString(context.Background(), "page", nil, "layout")
+	require.NoError(t, err)
+	assert.Equal(t, `<title>Settings | App</title><meta name="description" content="Page &lt;desc&gt;"><p>Page &lt;desc&gt;</p>content`, result)
+}
+
+func TestRobotsMeta(t *testing.T) {
+	files := map[string]string{
+		"pages/home.gohtml":   `home`,
+		"pages/search.gohtml": "---\nnoindex: true\n---\nsearch",
+		"pages/tags.gohtml":   `{{ setRobots "NoFollow" "noarchive" }}tags`,
+		"layout.gohtml":       `<head>{{ robotsMeta }}</head>{{ embed }}`,
+	}
+	engine, err := templatex.NewMemory(files)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"pages/search"}, engine.NoindexTemplates())
+
+	tests := map[string]string{
+		"pages/home":   `<head></head>home`,
+		"pages/search": `<head><meta name="robots" content="noindex"></head>search`,
+		"pages/tags":   `<head><meta name="robots" content="nofollow, noarchive"></head>tags`,
+	}
+	for name, want := range tests {
+		out, err := engine.RenderString(context.Background(), name, nil, "layout")
+		require.NoError(t, err)
+		assert.Equal(t, want, out, name)
+	}
+
+	t.Run("outside production", func(t *testing.T) {
+		engine, err := templatex.NewMemory(files, templatex.WithEnvironment("staging"))
+		require.NoError(t, err)
+		out, err := engine.RenderString(context.Background(), "pages/tags", nil, "layout")
+		require.NoError(t, err)
+		assert.Equal(t, `<head><meta name="robots" content="noindex, nofollow, noarchive"></head>tags`, out)
+	})
+}
+
+func TestAssetStacks(t *testing.T) {
+	tempDir := t.TempDir()
+	files := map[string]string{
+		"page.gohtml":   `{{ push "scripts" (htmlSafe "<script src=\"/a.js\"></script>") }}{{ push "scripts" (htmlSafe "<script src=\"/b.js\"></script>") }}{{ push "scripts" (htmlSafe "<script src=\"/a.js\"></script>") }}content`,
+		"user.gohtml":   `{{ push "scripts" .Name }}{{ push "styles" .CSS }}{{ push "scripts" .JS }}content`,
+		"bad.gohtml":    `{{ push "scripts" 42 }}`,
+		"layout.gohtml": `{{ embed }}{{ stack "scripts" }}{{ stack "styles" }}`,
+	}
+	for name, content := range files {
+		err := os.WriteFile(filepath.Join(tempDir, name), []byte(content), 0644)
+		require.NoError(t, err)
+	}
+
+	engine, err := templatex.New(tempDir)
+	require.NoError(t, err)
+
+	result, err := engine.RenderString(context.Background(), "page", nil, "layout")
+	require.NoError(t, err)
+	assert.Equal(t, "content<script src=\"/a.js\"></script>\n<script src=\"/b.js\"></script>", result)
+
+	// Strings are escaped, so user input can't inject markup
+	result, err = engine.RenderString(context.Background(), "user", map[string]any{
+		"Name": `<script>alert(1)</script>`,
+		"CSS":  template.CSS("p{color:red}"),
+		"JS":   template.JS("init()"),
+	}, "layout")
+	require.NoError(t, err)
+	assert.Equal(t, "content&lt;script&gt;alert(1)&lt;/script&gt;\n<script>init()</script><style>p{color:red}</style>", result)
+
+	_, err = engine.RenderString(context.Background(), "bad", nil, "layout")
+	assert.ErrorContains(t, err, "push: unsupported content type int")
+}
+
+func TestSections(t *testing.T) {
+	files := map[string]string{
+		"layout.gohtml": `<head>{{ yield "styles" }}</head><body>{{ embed }}{{ if hasSection "sidebar" }}<aside>{{ yield "sidebar" }}</aside>{{ end }}{{ yield "scripts" }}</body>`,
+		"pages/home.gohtml": `{{- section "scripts" -}}
+<script>var user = "{{ .Name }}";</script>
+{{- end -}}
+{{ section "sidebar" }}{{ if .Name }}{{ .Name }}{{ end }}{{ end -}}
+<h1>{{ .Name }}</h1>`,
+		"pages/plain.gohtml": `plain`,
+	}
+	engine, err := templatex.NewMemory(files)
+	require.NoError(t, err)
+
+	out, err := engine.RenderString(context.Background(), "pages/home", map[string]any{"Name": "<Ann>"}, "layout")
+	require.NoError(t, err)
+	assert.Equal(t, `<head></head><body><h1>&lt;Ann&gt;</h1><aside>&lt;Ann&gt;</aside><script>var user = "\u003cAnn\u003e";</script></body>`, out)
+
+	out, err = engine.RenderString(context.Background(), "pages/plain", nil, "layout")
+	require.NoError(t, err)
+	assert.Equal(t, `<head></head><body>plain</body>`, out)
+
+	t.Run("precompiled chains", func(t *testing.T) {
+		engine, err := templatex.NewMemory(files, templatex.WithPrecompiledChains(true))
+		require.NoError(t, err)
+		out, err := engine.RenderString(context.Background(), "pages/home", map[string]any{"Name": "Ann"}, "layout")
+		require.NoError(t, err)
+		assert.Equal(t, `<head></head><body><h1>Ann</h1><aside>Ann</aside><script>var user = "Ann";</script></body>`, out)
+	})
+
+	invalid := map[string]string{
+		"nested":    `{{ if .A }}{{ section "scripts" }}x{{ end }}{{ end }}`,
+		"duplicate": `{{ section "a" }}x{{ end }}{{ section "a" }}y{{ end }}`,
+		"unclosed":  `{{ section "a" }}x`,
+		"define":    `{{ section "a" }}x{{ end }}{{ define "partial" }}y{{ end }}`,
+		"unnamed":   `{{ section .Name }}x{{ end }}`,
+	}
+	for name, content := range invalid {
+		_, err := templatex.NewMemory(map[string]string{"page.gohtml": content})
+		assert.ErrorIs(t, err, templatex.ErrTemplateParsingFailed, name)
+	}
+}
+
+func TestComponents(t *testing.T) {
+	files := map[string]string{
+		"components/card.gohtml":  `<div class="card"><h2>{{ .Title }}</h2>{{ .Slot }}</div>`,
+		"components/icon.gohtml":  `<i class="icon-{{ .Name }}"></i>`,
+		"components/modal.gohtml": `<dialog>{{ T "close" }}{{ .Slot }}</dialog>`,
+		"pages/flat.gohtml":       `{{ component "components/card" (props "Title" .Title "Slot" (htmlSafe "<p>flat</p>")) }}`,
+		"pages/slot.gohtml": `{{ range .Items }}{{ component "components/card" (props "Title" .) -}}
+  <p>{{ . }} &amp; {{ upper . }}</p>
+{{- endcomponent }}{{ end }}`,
+		"pages/nested.gohtml":    `{{ component "components/modal" }}{{ component "components/card" (props "Title" .Title) }}{{ component "components/icon" (props "Name" "x") }}!{{ endcomponent "components/card" }}{{ endcomponent }}`,
+		"pages/branch.gohtml":    `{{ if .Title }}{{ component "components/icon" (props "Name" .Title) }}{{ else }}none{{ end }}`,
+		"pages/missing.gohtml":   `{{ component "components/missing" }}`,
+		"components/node.gohtml": `<li>{{ .Name }}{{ with .Children }}<ul>{{ range . }}{{ component "components/node" . }}{{ end }}</ul>{{ end }}</li>`,
+		"components/loop.gohtml": `{{ component "components/loop" . }}`,
+		"components/wrap.gohtml": `{{ component "components/wrap" }}x{{ endcomponent }}`,
+		"pages/tree.gohtml":      `<ul>{{ component "components/node" . }}</ul>`,
+		"pages/loop.gohtml":      `{{ component "components/loop" }}`,
+		"pages/wrap.gohtml":      `{{ component "components/wrap" }}`,
+	}
+	engine, err := templatex.NewMemory(files)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name string
+		data any
+		want string
+	}{
+		{name: "pages/flat", data: map[string]any{"Title": "A"}, want: `<div class="card"><h2>A</h2><p>flat</p></div>`},
+		{name: "pages/slot", data: map[string]any{"Items": []string{"a", "<b>"}}, want: `<div class="card"><h2>a</h2><p>a &amp; A</p></div><div class="card"><h2>&lt;b&gt;</h2><p>&lt;b&gt; &amp; &lt;B&gt;</p></div>`},
+		{name: "pages/nested", data: map[string]any{"Title": "N"}, want: `<dialog>close<div class="card"><h2>N</h2><i class="icon-x"></i>!</div></dialog>`},
+		{name: "pages/branch", data: map[string]any{"Title": "y"}, want: `<i class="icon-y"></i>`},
+		{name: "pages/branch", data: map[string]any{}, want: `none`},
+	}
+	for _, tt := range tests {
+		out, err := engine.RenderString(context.Background(), tt.name, tt.data)
+		require.NoError(t, err, tt.name)
+		assert.Equal(t, tt.want, out, tt.name)
+	}
+
+	_, err = engine.RenderString(context.Background(), "pages/missing", nil)
+	assert.ErrorIs(t, err, templatex.ErrTemplateNotFound)
+
+	// Components can render themselves, up to a nesting limit
+	tree := map[string]any{"Name": "a", "Children": []map[string]any{{"Name": "b", "Children": []map[string]any{{"Name": "c"}}}, {"Name": "d"}}}
+	out, err := engine.RenderString(context.Background(), "pages/tree", tree)
+	require.NoError(t, err)
+	assert.Equal(t, `<ul><li>a<ul><li>b<ul><li>c</li></ul></li><li>d</li></ul></li></ul>`, out)
+	for _, name := range []string{"pages/loop", "pages/wrap"} {
+		_, err = engine.RenderString(context.Background(), name, nil)
+		assert.ErrorIs(t, err, templatex.ErrComponentDepthExceeded, name)
+	}
+
+	invalid := map[string]string{
+		"unmatched": `{{ endcomponent }}`,
+		"unnamed":   `{{ component "a" }}x{{ endcomponent "b" }}`,
+		"depth":     `{{ component "a" }}{{ if .A }}{{ endcomponent }}{{ end }}`,
+	}
+	for name, content := range invalid {
+		_, err := templatex.NewMemory(map[string]string{"page.gohtml": content})
+		assert.ErrorIs(t, err, templatex.ErrTemplateParsingFailed, name)
+	}
+}
+
+func TestComponentRegistry(t *testing.T) {
+	type card struct {
+		Title string `prop:"required"`
+		Count int
+		Slot  template.HTML
+	}
+	engine, err := templatex.NewMemory(map[string]string{
+		"ui/card.gohtml":       `<div>{{ .Title }} {{ .Count }} {{ .Slot }}</div>`,
+		"pages/ok.gohtml":      `{{ component "ui/card" (props "Title" "Hi" "Count" 3) }}`,
+		"pages/slot.gohtml":    `{{ component "ui/card" (props "Title" "Hi") }}body{{ endcomponent }}`,
+		"pages/missing.gohtml": `{{ component "ui/card" (props "Count" 3) }}`,
+		"pages/unknown.gohtml": `{{ component "ui/card" (props "Title" "Hi" "Color" "red") }}`,
+		"pages/type.gohtml":    `{{ component "ui/card" (props "Title" 42) }}`,
+	})
+	require.NoError(t, err)
+	require.NoError(t, engine.RegisterComponent("ui/card.gohtml", card{}))
+
+	out, err := engine.RenderString(context.Background(), "pages/ok", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "<div>Hi 3 </div>", out)
+
+	out, err = engine.RenderString(context.Background(), "pages/slot", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "<div>Hi 0 body</div>", out)
+
+	_, err = engine.RenderString(context.Background(), "pages/missing", nil)
+	assert.ErrorContains(t, err, "component ui/card: missing required props: Title")
+	_, err = engine.RenderString(context.Background(), "pages/unknown", nil)
+	assert.ErrorContains(t, err, "component ui/card: unknown prop Color")
+	_, err = engine.RenderString(context.Background(), "pages/type", nil)
+	assert.ErrorContains(t, err, "component ui/card: prop Title: int is not assignable to string")
+
+	assert.Error(t, engine.RegisterComponent("", card{}))
+	assert.Error(t, engine.RegisterComponent("ui/card", "not a struct"))
+
+	specs := engine.Components()
+	names := make([]string, 0, len(specs))
+	for _, s := range specs {
+		names = append(names, s.Name)
+	}
+	assert.Equal(t, []string{"field", "form", "table", "ui/card", "upload"}, names)
+	assert.Equal(t, templatex.ComponentSpec{Name: "ui/card", Props: []templatex.PropSpec{
+		{Name: "Title", Type: "string", Required: true},
+		{Name: "Count", Type: "int"},
+		{Name: "Slot", Type: "template.HTML"},
+	}}, specs[3])
+	assert.True(t, specs[0].Builtin)
+	assert.Contains(t, specs[0].Props, templatex.PropSpec{Name: "Name", Type: "string", Required: true})
+}
+
+func TestTableComponent(t *testing.T) {
+	type user struct {
+		Name  string
+		Email string
+		Admin bool
+	}
+	engine, err := templatex.NewMemory(map[string]string{
+		"pages/users.gohtml": `{{ component "table" (props "Columns" .Columns "Rows" .Rows "Sort" .Sort "Desc" .Desc "Query" .Query "Page" .Page "Pages" .Pages "Class" "users") }}`,
+		"pages/empty.gohtml": `{{ component "table" (props "Columns" .Columns "Rows" .Rows "Empty" "No users yet") }}`,
+		"pages/bad.gohtml":   `{{ component "table" (props "Rows" 42) }}`,
+	})
+	require.NoError(t, err)
+
+	columns := []templatex.TableColumn{
+		{Key: "Name", Title: "Name", Sortable: true},
+		{Key: "Email", Title: "E-mail", Sortable: true, Class: "email"},
+		{Key: "Admin", Title: "Admin"},
+	}
+	ctx := templatex.WithRequestPath(context.Background(), "/users")
+	out, err := engine.RenderString(ctx, "pages/users", map[string]any{
+		"Columns": columns,
+		"Rows":    []user{{Name: "Ann", Email: "ann@example.com", Admin: true}, {Name: "<Bob>", Email: "bob@example.com"}},
+		"Sort":    "Name",
+		"Query":   url.Values{"q": {"a b"}, "page": {"2"}},
+		"Page":    2,
+		"Pages":   7,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, `<table class="users"><thead><tr>`+
+		`<th scope="col" aria-sort="ascending" class="sorted asc"><a href="/users?dir=desc&amp;q=a+b&amp;sort=Name">Name</a></th>`+
+		`<th scope="col" class="email"><a href="/users?dir=asc&amp;q=a+b&amp;sort=Email">E-mail</a></th>`+
+		`<th scope="col">Admin</th></tr></thead><tbody>`+
+		`<tr class="odd"><td>Ann</td><td class="email">ann@example.com</td><td>true</td></tr>`+
+		`<tr class="even"><td>&lt;Bob&gt;</td><td class="email">bob@example.com</td><td>false</td></tr>`+
+		`</tbody></table>`+
+		`<nav class="pagination" aria-label="Pagination"><a href="/users?page=1&amp;q=a+b" rel="prev">&laquo;</a>`+
+		`<a href="/users?page=1&amp;q=a+b">1</a><span aria-current="page">2</span>`+
+		`<a href="/users?page=3&amp;q=a+b">3</a><a href="/users?page=4&amp;q=a+b">4</a>`+
+		`<span class="gap">&hellip;</span><a href="/users?page=7&amp;q=a+b">7</a>`+
+		`<a href="/users?page=3&amp;q=a+b" rel="next">&raquo;</a></nav>`, out)
+
+	out, err = engine.RenderString(ctx, "pages/empty", map[string]any{"Columns": columns, "Rows": []user{}})
+	require.NoError(t, err)
+	assert.Contains(t, out, `<tbody><tr class="empty"><td colspan="3">No users yet</td></tr></tbody>`)
+
+	_, err = engine.RenderString(ctx, "pages/bad", nil)
+	assert.ErrorContains(t, err, "table: rows must be a slice")
+
+	t.Run("overridden by templates", func(t *testing.T) {
+		engine, err := templatex.NewMemory(map[string]string{
+			"table.gohtml":      `custom {{ len .Rows }}`,
+			"pages/list.gohtml": `{{ component "table" (props "Rows" .) }}`,
+		})
+		require.NoError(t, err)
+		out, err := engine.RenderString(context.Background(), "pages/list", []int{1, 2})
+		require.NoError(t, err)
+		assert.Equal(t, "custom 2", out)
+	})
+}
+
+func TestFormComponents(t *testing.T) {
+	engine, err := templatex.NewMemory(map[string]string{
+		"pages/signup.gohtml": `{{ component "form" (props "Action" "/signup" "Class" "signup") -}}
+{{ component "field" (props "Name" "email" "Label" "Email" "Type" "email" "Required" true "Hint" "We never share it") }}
+{{- component "field" (props "Name" "password" "Type" "password" "Value" "secret") }}
+{{- component "field" (props "Name" "plan" "Type" "select" "Options" .Plans "Value" "free") }}
+{{- component "field" (props "Name" "terms" "Label" "I agree" "Type" "checkbox") }}
+{{- component "field" (props "Name" "bio" "Type" "textarea") -}}
+{{ endcomponent "form" }}`,
+		"pages/search.gohtml": `{{ component "form" (props "Action" "/search" "Method" "get") }}<button>Search</button>{{ endcomponent }}`,
+		"pages/custom.gohtml": `{{ csrfToken }}|{{ csrfField }}|{{ oldInput "q" }}|{{ range fieldErrors "q" }}{{ . }};{{ end }}`,
+		"pages/bad.gohtml":    `{{ component "field" (props "Label" "No name") }}`,
+		"pages/action.gohtml": `{{ component "form" (props "Action" .) }}{{ endcomponent }}`,
+	})
+	require.NoError(t, err)
+	plans := []templatex.FieldOption{{Value: "free", Label: "Free"}, {Value: "pro", Label: "Pro"}}
+
+	ctx := templatex.WithCSRFToken(context.Background(), "tok<1>")
+	out, err := engine.RenderString(ctx, "pages/signup", map[string]any{"Plans": plans})
+	require.NoError(t, err)
+	assert.Equal(t, `<form action="/signup" method="post" class="signup">`+
+		`<input type="hidden" name="csrf_token" value="tok&lt;1&gt;">`+
+		`<div class="field"><label for="field-email">Email</label><input type="email" id="field-email" name="email" required aria-describedby="field-email-hint"><p class="field-hint" id="field-email-hint">We never share it</p></div>`+
+		`<div class="field"><input type="password" id="field-password" name="password"></div>`+
+		`<div class="field"><select id="field-plan" name="plan"><option value="free" selected>Free</option><option value="pro">Pro</option></select></div>`+
+		`<div class="field"><input type="checkbox" id="field-terms" name="terms"><label for="field-terms">I agree</label></div>`+
+		`<div class="field"><textarea id="field-bio" name="bio"></textarea></div>`+
+		`</form>`, out)
+
+	// Submitted values repopulate the fields, except passwords, and errors are displayed
+	ctx = templatex.WithFormState(ctx, templatex.FormState{
+		Values: url.Values{"email": {"ann@example"}, "password": {"hunter2"}, "plan": {"pro"}, "terms": {"on"}, "bio": {"<b>Hi</b>"}},
+		Errors: map[string][]string{"": {"Please fix the errors below"}, "email": {"Email is invalid"}},
+	})
+	out, err = engine.RenderString(ctx, "pages/signup", map[string]any{"Plans": plans})
+	require.NoError(t, err)
+	assert.Contains(t, out, `<div class="form-errors" role="alert"><p class="error">Please fix the errors below</p></div>`)
+	assert.Contains(t, out, `<div class="field has-error"><label for="field-email">Email</label><input type="email" id="field-email" name="email" required aria-invalid="true" aria-describedby="field-email-hint field-email-error" value="ann@example">`+
+		`<p class="field-hint" id="field-email-hint">We never share it</p><div class="field-errors" id="field-email-error"><p class="error">Email is invalid</p></div></div>`)
+	assert.Contains(t, out, `<input type="password" id="field-password" name="password">`)
+	assert.Contains(t, out, `<option value="free">Free</option><option value="pro" selected>Pro</option>`)
+	assert.Contains(t, out, `<input type="checkbox" id="field-terms" name="terms" checked>`)
+	assert.Contains(t, out, `<textarea id="field-bio" name="bio">&lt;b&gt;Hi&lt;/b&gt;</textarea>`)
+
+	// GET forms don't carry the token
+	out, err = engine.RenderString(templatex.WithCSRFToken(context.Background(), "tok"), "pages/search", nil)
+	require.NoError(t, err)
+	assert.Equal(t, `<form action="/search" method="get"><button>Search</button></form>`, out)
+
+	out, err = engine.RenderString(ctx, "pages/custom", nil)
+	require.NoError(t, err)
+	assert.Equal(t, `tok&lt;1&gt;|<input type="hidden" name="csrf_token" value="tok&lt;1&gt;">||`, out)
+
+	_, err = engine.RenderString(ctx, "pages/bad", nil)
+	assert.ErrorContains(t, err, "field: missing required props: Name")
+
+	// Actions must be relative or http(s) URLs
+	for _, action := range []string{"javascript:alert(1)", " JavaScript:alert(1)", "data:text/html,x", "mailto:a@example.com"} {
+		_, err = engine.RenderString(ctx, "pages/action", action)
+		assert.ErrorContains(t, err, "form: unsafe action", action)
+	}
+	for _, action := range []string{"", "?page=2", "../items", "https://example.com/signup"} {
+		_, err = engine.RenderString(ctx, "pages/action", action)
+		assert.NoError(t, err, action)
+	}
+
+	t.Run("method override and htmx", func(t *testing.T) {
+		engine, err := templatex.NewMemory(map[string]string{
+			"pages/delete.gohtml": `{{ component "form" (props "Action" "/items/1" "Method" "delete" "Target" "#items" "Swap" "outerHTML") }}<button>Delete</button>{{ endcomponent }}`,
+		}, templatex.WithHTMXForms(true), templatex.WithCSRFFieldName("_token"))
+		require.NoError(t, err)
+		out, err := engine.RenderString(templatex.WithCSRFToken(context.Background(), "abc"), "pages/delete", nil)
+		require.NoError(t, err)
+		assert.Equal(t, `<form action="/items/1" method="post" hx-delete="/items/1" hx-target="#items" hx-swap="outerHTML">`+
+			`<input type="hidden" name="_token" value="abc"><input type="hidden" name="_method" value="DELETE"><button>Delete</button></form>`, out)
+	})
+
+	t.Run("form state is a part of the cache key", func(t *testing.T) {
+		engine, err := templatex.NewMemory(map[string]string{
+			"pages/q.gohtml": `{{ oldInput "q" }}`,
+		}, templatex.WithHardCache(true))
+		require.NoError(t, err)
+		for _, q := range []string{"a", "b"} {
+			ctx := templatex.WithFormState(context.Background(), templatex.FormState{Values: url.Values{"q": {q}}})
+			out, err := engine.RenderString(ctx, "pages/q", nil)
+			require.NoError(t, err)
+			assert.Equal(t, q, out)
+		}
+	})
+}
+
+func TestUploadComponent(t *testing.T) {
+	engine, err := templatex.NewMemory(map[string]string{
+		"pages/avatar.gohtml": `{{ component "upload" (props "Name" "avatar" "Label" "Avatar" "Accept" (split "PNG,image/jpeg,png" ",") "MaxSize" 5242880 "Required" true) }}`,
+		"pages/docs.gohtml":   `{{ component "upload" (props "Name" "docs[]" "Accept" .Accept "Multiple" true "Hint" "Scanned contracts") }}`,
+		"pages/accept.gohtml": `<input type="file" accept="{{ acceptTypes "image/*" "PDF" .Extra }}">`,
+	})
+	require.NoError(t, err)
+
+	out, err := engine.RenderString(context.Background(), "pages/avatar", nil)
+	require.NoError(t, err)
+	assert.Equal(t, `<div class="field upload"><label for="field-avatar">Avatar</label>`+
+		`<input type="file" id="field-avatar" name="avatar" accept=".png,image/jpeg" data-max-size="5242880" required aria-describedby="field-avatar-hint">`+
+		`<p class="field-hint" id="field-avatar-hint">PNG, JPEG, up to 5 MB</p></div>`, out)
+
+	ctx := templatex.WithFormState(context.Background(), templatex.FormState{Errors: map[string][]string{"docs[]": {"File is too large"}}})
+	out, err = engine.RenderString(ctx, "pages/docs", map[string]any{"Accept": []string{"application/pdf", "image/*"}})
+	require.NoError(t, err)
+	assert.Equal(t, `<div class="field upload has-error">`+
+		`<input type="file" id="field-docs" name="docs[]" accept="application/pdf,image/*" multiple aria-invalid="true" aria-describedby="field-docs-hint field-docs-error">`+
+		`<p class="field-hint" id="field-docs-hint">Scanned contracts</p>`+
+		`<div class="field-errors" id="field-docs-error"><p class="error">File is too large</p></div></div>`, out)
+
+	out, err = engine.RenderString(context.Background(), "pages/accept", map[string]any{"Extra": []string{"docx", ".pdf"}})
+	require.NoError(t, err)
+	assert.Equal(t, `<input type="file" accept="image/*,.pdf,.docx">`, out)
+}
+
+func TestOnceHelper(t *testing.T) {
+	tempDir := t.TempDir()
+	files := map[string]string{
+		"page.gohtml":   `{{ template "widget" }}{{ template "widget" }}`,
+		"widget.gohtml": `{{ define "widget" }}{{ if once "sprite" }}[sprite]{{ end }}w{{ end }}`,
+	}
+	for name, content := range files {
+		err := os.WriteFile(filepath.Join(tempDir, name), []byte(content), 0644)
+		require.NoError(t, err)
+	}
+
+	engine, err := templatex.New(tempDir)
+	require.NoError(t, err)
+
+	for i := 0; i < 2; i++ {
+		result, err := engine.RenderString(context.Background(), "page", i)
+		require.NoError(t, err)
+		assert.Equal(t, "[sprite]ww", result)
+	}
+}
+
+func TestLayoutDataFunc(t *testing.T) {
+	tempDir := t.TempDir()
+	files := map[string]string{
+		"page.gohtml":   `{{ .Username }}`,
+		"layout.gohtml": `[{{ .Nav }}]{{ embed }}`,
+	}
+	for name, content := range files {
+		err := os.WriteFile(filepath.Join(tempDir, name), []byte(content), 0644)
+		require.NoError(t, err)
+	}
+
+	engine, err := templatex.New(tempDir, templatex.WithLayoutDataFunc("layout", func(binding any) any {
+		data := binding.(pageData)
+		return map[string]string{"Nav": "nav for " + data.Username}
+	}))
+	require.NoError(t, err)
+
+	result, err := engine.RenderString(context.Background(), "page", pageData{Username: "John"}, "layout")
+	require.NoError(t, err)
+	assert.Equal(t, "[nav for John]John", result)
+}
+
+func TestRenderWithLayouts(t *testing.T) {
+	tempDir := t.TempDir()
+	files := map[string]string{
+		"page.gohtml": `{{ .Username }}`,
+		"app.gohtml":  `<app>{{ . }}:{{ embed }}</app>`,
+		"base.gohtml": `<base>{{ .Title }}:{{ embed }}</base>`,
+	}
+	for name, content := range files {
+		err := os.WriteFile(filepath.Join(tempDir, name), []byte(content), 0644)
+		require.NoError(t, err)
+	}
+
+	engine, err := templatex.New(tempDir)
+	require.NoError(t, err)
+
+	for _, appData := range []string{"one", "two"} {
+		var buf bytes.Buffer
+		err = engine.RenderWithLayouts(context.Background(), &buf, "page", pageData{Username: "John", Title: "Page"},
+			templatex.Layout("app", appData),
+			templatex.Layout("base", nil),
+		)
+		require.NoError(t, err)
+		assert.Equal(t, "<base>Page:<app>"+appData+":John</app></base>", buf.String())
+	}
+}
+
+func TestPathHelpers(t *testing.T) {
+	data := map[string]interface{}{
+		"user": map[string]interface{}{
+			"role":  map[string]interface{}{"name": "admin"},
+			"items": []interface{}{"a", "b"},
+		},
+	}
+
+	tests := []struct {
+		name     string
+		template string
+		expected string
+	}{
+		{"getPath nested", `{{ getPath . "user.role.name" }}`, "admin"},
+		{"getPath slice index", `{{ getPath . "user.items.1" }}`, "b"},
+		{"getPath missing", `{{ getPath . "user.missing.name" }}`, ""},
+		{"haveKey existing", `{{ haveKey . "user.role" }}`, "true"},
+		{"haveKey missing", `{{ haveKey . "user.email" }}`, "false"},
+		{"dig existing", `{{ dig "user" "role" "name" "guest" . }}`, "admin"},
+		{"dig missing", `{{ dig "user" "group" "name" "guest" . }}`, "guest"},
+		{"jsonGet nested", `{{ jsonGet "{\"items\":[{\"name\":\"first\",\"qty\":2}]}" "items.0.name" }}`, "first"},
+		{"jsonGet number", `{{ jsonGet "{\"items\":[{\"name\":\"first\",\"qty\":2}]}" "items.0.qty" }}`, "2"},
+		{"jsonGet missing", `{{ jsonGet "{\"items\":[]}" "items.0.name" }}`, ""},
+		{"jsonGet array index", `{{ jsonGet "[[1,2],[3,4]]" "1.0" }}`, "3"},
+		{"jsonGet index out of range", `{{ jsonGet "[1,2]" "2" }}`, ""},
+		{"jsonGet negative index", `{{ jsonGet "[1,2]" "-1" }}`, ""},
+		{"jsonGet missing key", `{{ jsonGet "{\"a\":{\"b\":1}}" "a.c" }}`, ""},
+		{"jsonGet case-sensitive key", `{{ jsonGet "{\"Name\":\"x\"}" "name" }}`, ""},
+		{"jsonGet numeric object key", `{{ jsonGet "{\"0\":\"zero\"}" "0" }}`, "zero"},
+		{"jsonGet key of string", `{{ jsonGet "{\"a\":\"text\"}" "a.b" }}`, ""},
+		{"jsonGet key of number", `{{ jsonGet "{\"a\":1}" "a.0" }}`, ""},
+		{"jsonGet key of null", `{{ jsonGet "{\"a\":null}" "a.b" }}`, ""},
+		{"jsonGet empty path", `{{ jsonGet "42" "" }}`, "42"},
+		{"setPath", `{{ $m := dict }}{{ setPath $m "a.b" "c" }}{{ getPath $m "a.b" }}`, "c"},
+	}
+
+	engine, err := templatex.New("example/templates/")
+	require.NoError(t, err)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpl, err := template.New("test").
+				Funcs(engine.GetFuncMap()).
+				Funcs(template.FuncMap{"dict": func() map[string]interface{} { return map[string]interface{}{} }}).
+				Parse(tt.template)
+			require.NoError(t, err)
+
+			var buf bytes.Buffer
+			err = tmpl.Execute(&buf, data)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, buf.String())
+		})
+	}
+
+	t.Run("jsonGet invalid documents", func(t *testing.T) {
+		for doc, msg := range map[string]string{
+			`{"a":`:      "unexpected EOF",
+			`{a:1}`:      "invalid character",
+			``:           "EOF",
+			`{"a":1} {}`: "unexpected data after the JSON value",
+		} {
+			tmpl, err := template.New("test").Funcs(engine.GetFuncMap()).Parse(`{{ jsonGet . "a" }}`)
+			require.NoError(t, err)
+			err = tmpl.Execute(io.Discard, doc)
+			assert.ErrorContains(t, err, msg, doc)
+		}
+
+		tmpl, err := template.New("test").Funcs(engine.GetFuncMap()).Parse(`{{ jsonGet . "a" }}`)
+		require.NoError(t, err)
+		assert.ErrorContains(t, tmpl.Execute(io.Discard, 42), "unsupported document type int")
+	})
+}
+
+func TestTemplateVars(t *testing.T) {
+	tempDir := t.TempDir()
+	err := os.WriteFile(filepath.Join(tempDir, "page.gohtml"), []byte(`{{ vars.SupportEmail }}|{{ vars.Missing }}`), 0644)
+	require.NoError(t, err)
+
+	engine, err := templatex.New(tempDir, templatex.WithTemplateVars(map[string]any{
+		"SupportEmail": "support@example.com",
+	}))
+	require.NoError(t, err)
+
+	result, err := engine.RenderString(context.Background(), "page", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "support@example.com|", result)
+
+	// Templates get a copy of the variables
+	require.NoError(t, engine.ParseString("mutate", `{{ setPath vars "SupportEmail" "evil@example.com" }}{{ $v := vars }}{{ setPath $v "Missing" "x" }}{{ $v.Missing }}`))
+	result, err = engine.RenderString(context.Background(), "mutate", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "x", result)
+	result, err = engine.RenderString(context.Background(), "page", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "support@example.com|", result)
+}
+
+func TestEnvironmentHelpers(t *testing.T) {
+	tempDir := t.TempDir()
+	content := `{{ env }}|{{ if isProduction }}prod{{ end }}|{{ if isDevelopment }}dev{{ end }}`
+	err := os.WriteFile(filepath.Join(tempDir, "page.gohtml"), []byte(content), 0644)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name     string
+		env      string
+		expected string
+	}{
+		{"Default environment", "", "production|prod|"},
+		{"Development", "Development", "development||dev"},
+		{"Custom environment", "staging", "staging||"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			engine, err := templatex.New(tempDir, templatex.WithEnvironment(tt.env))
+			require.NoError(t, err)
+
+			result, err := engine.RenderString(context.Background(), "page", nil)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, result)
+		})
+	}
+}
+
+func TestDebugToolbar(t *testing.T) {
+	tempDir := t.TempDir()
+	files := map[string]string{
+		"page.gohtml":     `<html><body>{{ .Username }}</body></html>`,
+		"fragment.gohtml": `<p>{{ .Username }}</p>`,
+	}
+	for name, content := range files {
+		err := os.WriteFile(filepath.Join(tempDir, name), []byte(content), 0644)
+		require.NoError(t, err)
+	}
+
+	t.Run("Development environment", func(t *testing.T) {
+		engine, err := templatex.New(tempDir,
+			templatex.WithEnvironment(templatex.EnvDevelopment),
+			templatex.WithDebugToolbar(true),
+		)
+		require.NoError(t, err)
+
+		data := pageData{Username: "<John>"}
+		first, err := engine.RenderString(context.Background(), "page", data)
+		require.NoError(t, err)
+		assert.Contains(t, first, `<details id="templatex-debug"`)
+		assert.Contains(t, first, "cache miss")
+		assert.Contains(t, first, "&lt;John&gt;")
+		assert.True(t, strings.HasSuffix(first, "</details></body></html>"))
+
+		second, err := engine.RenderString(context.Background(), "page", data)
+		require.NoError(t, err)
+		assert.Contains(t, second, "cache hit")
+		assert.Equal(t, 1, strings.Count(second, `id="templatex-debug"`))
+
+		fragment, err := engine.RenderString(context.Background(), "fragment", data)
+		require.NoError(t, err)
+		assert.Equal(t, "<p>&lt;John&gt;</p>", fragment)
+	})
+
+	t.Run("Production environment", func(t *testing.T) {
+		engine, err := templatex.New(tempDir, templatex.WithDebugToolbar(true))
+		require.NoError(t, err)
+
+		result, err := engine.RenderString(context.Background(), "page", pageData{Username: "John"})
+		require.NoError(t, err)
+		assert.NotContains(t, result, "templatex-debug")
+	})
+}
+
+func TestDebugFunctions(t *testing.T) {
+	type Credentials struct {
+		Login    string
+		Password string
+	}
+	type User struct {
+		Name        string
+		Credentials Credentials
+		Meta        map[string]interface{}
+		Created     time.Time
+		Account     jsonAccount
+		Status      *debugStatus
+	}
+
+	engine, err := templatex.New("example/templates/", templatex.WithDebugRedactFields("password", "Token"))
+	require.NoError(t, err)
+
+	status := debugStatus(2)
+	data := User{
+		Name:        "John",
+		Credentials: Credentials{Login: "john", Password: "secret"},
+		Meta:        map[string]interface{}{"token": "abc", "nested": map[string]interface{}{"deep": 1}},
+		Created:     time.Date(2024, 12, 31, 23, 59, 0, 0, time.UTC),
+		Account:     jsonAccount{id: 7, password: "hunter2"},
+		Status:      &status,
+	}
+
+	tests := []struct {
+		name        string
+		template    string
+		contains    []string
+		notContains []string
+	}{
+		{
+			name:        "redacted fields",
+			template:    `{{ debug . }}`,
+			contains:    []string{"[REDACTED]", "john"},
+			notContains: []string{"secret", "abc"},
+		},
+		{
+			name:        "max depth",
+			template:    `{{ debug . 1 }}`,
+			contains:    []string{"John", "&#34;Credentials&#34;: &#34;...&#34;"},
+			notContains: []string{"john"},
+		},
+		{
+			name:        "self-describing values",
+			template:    `{{ debug . }}`,
+			contains:    []string{"&#34;Created&#34;: &#34;2024-12-31T23:59:00Z&#34;", "&#34;id&#34;: 7", "&#34;Status&#34;: &#34;active&#34;"},
+			notContains: []string{"hunter2", "wall"},
+		},
+		{
+			name:        "html tree",
+			template:    `{{ debugHTML . }}`,
+			contains:    []string{"<details open>", "<strong>Name</strong>: <code>&#34;John&#34;</code>", "[REDACTED]"},
+			notContains: []string{"secret"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpl, err := template.New("test").Funcs(engine.GetFuncMap()).Parse(tt.template)
+			require.NoError(t, err)
+
+			var buf bytes.Buffer
+			err = tmpl.Execute(&buf, data)
+			require.NoError(t, err)
+			for _, s := range tt.contains {
+				assert.Contains(t, buf.String(), s)
+			}
+			for _, s := range tt.notContains {
+				assert.NotContains(t, buf.String(), s)
+			}
+		})
+	}
+}
+
+// jsonAccount has unexported fields and describes itself as JSON
+type jsonAccount struct {
+	id       int
+	password string
+}
+
+func (a jsonAccount) MarshalJSON() ([]byte, error) {
+	return json.Marshal(map[string]any{"id": a.id, "password": a.password})
+}
+
+// debugStatus is a stringer with a pointer receiver
+type debugStatus int
+
+func (s *debugStatus) String() string {
+	if *s == 2 {
+		return "active"
+	}
+	return "inactive"
+}
+
+func TestTimeFunctions(t *testing.T) {
+	fixed := time.Date(2024, 12, 31, 23, 59, 0, 0, time.UTC)
+	engine, err := templatex.New("example/templates/", templatex.WithClock(func() time.Time { return fixed }))
+	require.NoError(t, err)
+
+	tests := []struct {
+		name     string
+		template string
+		data     interface{}
+		expected string
+	}{
+		{"now", `{{ now.Year }}`, nil, "2024"},
+		{"formatTime default layout", `{{ formatTime now }}`, nil, "2024-12-31T23:59:00Z"},
+		{"formatTime custom layout", `{{ formatTime . "Jan 2, 2006" }}`, fixed.AddDate(0, 0, -1), "Dec 30, 2024"},
+		{"formatTime zero time", `{{ formatTime . }}`, time.Time{}, ""},
+		{"since", `{{ since . }}`, fixed.Add(-90 * time.Second), "1m30s"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpl, err := template.New("test").Funcs(engine.GetFuncMap()).Parse(tt.template)
+			require.NoError(t, err)
+
+			var buf bytes.Buffer
+			err = tmpl.Execute(&buf, tt.data)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, buf.String())
+		})
+	}
+}
+
+func TestFormatters(t *testing.T) {
+	fixed := time.Date(2024, 12, 31, 23, 59, 0, 0, time.UTC)
+	engine, err := templatex.New("example/templates/",
+		templatex.WithClock(func() time.Time { return fixed }),
+		templatex.WithFormatConfig(templatex.FormatConfig{
+			DecimalSeparator:   ",",
+			ThousandsSeparator: ".",
+			Decimals:           2,
+		}),
+	)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name     string
+		template string
+		data     interface{}
+		expected string
+	}{
+		{"formatNumber default decimals", `{{ formatNumber . }}`, 1234567.891, "1.234.567,89"},
+		{"formatNumber custom decimals", `{{ formatNumber . 0 }}`, 1234.5, "1.235"},
+		{"formatNumber negative", `{{ formatNumber . 1 }}`, -1234.25, "-1.234,3"},
+		{"formatNumber rounds to zero", `{{ formatNumber . 1 }}`, -0.01, "0,0"},
+		{"formatNumber string", `{{ formatNumber . 0 }}`, "999", "999"},
+		{"formatDate default layout", `{{ formatDate . }}`, fixed, "Dec 31, 2024"},
+		{"formatDate custom layout", `{{ formatDate . "2006-01-02" }}`, fixed, "2024-12-31"},
+		{"humanizeTime just now", `{{ humanizeTime . }}`, fixed.Add(-30 * time.Second), "just now"},
+		{"humanizeTime minute", `{{ humanizeTime . }}`, fixed.Add(-time.Minute), "1 minute ago"},
+		{"humanizeTime hours", `{{ humanizeTime . }}`, fixed.Add(-3 * time.Hour), "3 hours ago"},
+		{"humanizeTime future", `{{ humanizeTime . }}`, fixed.Add(49 * time.Hour), "in 2 days"},
+		{"humanizeTime old", `{{ humanizeTime . }}`, fixed.AddDate(0, -2, 0), "Oct 31, 2024"},
+		{"humanizeBytes bytes", `{{ humanizeBytes . }}`, 512, "512 B"},
+		{"humanizeBytes fraction", `{{ humanizeBytes . }}`, 1536, "1,5 KB"},
+		{"humanizeBytes whole", `{{ humanizeBytes . }}`, int64(5 << 20), "5 MB"},
+		{"humanizeBytes large", `{{ humanizeBytes . }}`, uint64(3 << 40), "3 TB"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpl, err := template.New("test").Funcs(engine.GetFuncMap()).Parse(tt.template)
+			require.NoError(t, err)
+
+			var buf bytes.Buffer
+			err = tmpl.Execute(&buf, tt.data)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, buf.String())
+		})
+	}
+
+	t.Run("client bundle", func(t *testing.T) {
+		js := engine.FormattersJS()
+		assert.Contains(t, js, `"decimalSeparator":","`)
+		assert.Contains(t, js, `"thousandsSeparator":"."`)
+		assert.Contains(t, js, `"decimals":2`)
+		assert.Contains(t, js, `"dateLayout":"Jan 2, 2006"`)
+		assert.Contains(t, js, "global.templatex.formatNumber = formatNumber")
+		assert.Contains(t, js, "global.templatex.humanizeBytes = humanizeBytes")
+		assert.NotContains(t, js, "/*CONFIG*/")
+	})
+}
+
+func TestPreview(t *testing.T) {
+	tempDir := t.TempDir()
+	files := map[string]string{
+		"page.gohtml":   `<h1>{{ .Title }}</h1>{{ with .User }}<a href="mailto:{{ .Email }}">{{ .Name }}</a>{{ end }}<ul>{{ range .Items }}<li>{{ .Price }}</li>{{ end }}</ul>{{ if .IsAdmin }}admin{{ end }}`,
+		"layout.gohtml": `<footer>{{ .Company }}</footer>{{ embed }}`,
+	}
+	for name, content := range files {
+		err := os.WriteFile(filepath.Join(tempDir, name), []byte(content), 0644)
+		require.NoError(t, err)
+	}
+
+	engine, err := templatex.New(tempDir)
+	require.NoError(t, err)
+
+	result, err := engine.Preview(context.Background(), "page", "layout")
+	require.NoError(t, err)
+	assert.Contains(t, result, "<footer>Company</footer>")
+	assert.Contains(t, result, "<h1>Lorem ipsum dolor sit amet</h1>")
+	assert.Contains(t, result, `<a href="mailto:jane.doe@example.com">Jane Doe</a>`)
+	assert.Equal(t, 3, strings.Count(result, "<li>42</li>"))
+	assert.Contains(t, result, "admin")
+
+	_, err = engine.Preview(context.Background(), "nonexistent")
+	assert.ErrorIs(t, err, templatex.ErrTemplateNotFound)
+}
+
+func TestFakeFunctions(t *testing.T) {
+	tests := []struct {
+		name     string
+		opts     []templatex.Option
+		template string
+		expected string
+	}{
+		{"lorem words", []templatex.Option{templatex.WithEnvironment(templatex.EnvDevelopment)}, `{{ lorem "words" 3 }}`, "lorem ipsum dolor"},
+		{"fakeName", []templatex.Option{templatex.WithEnvironment(templatex.EnvDevelopment)}, `{{ fakeName }}|{{ fakeName 1 }}`, "Jane Doe|John Smith"},
+		{"fakeEmail", []templatex.Option{templatex.WithFakeFuncs(true)}, `{{ fakeEmail 1 }}`, "john.smith@example.com"},
+		{"placeholderImage", []templatex.Option{templatex.WithFakeFuncs(true)}, `{{ placeholderImage 640 480 }}`, "https://placehold.co/640x480"},
+		{"disabled in production", nil, `{{ lorem "words" 3 }}{{ fakeName }}{{ fakeEmail }}{{ placeholderImage 1 1 }}`, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			engine, err := templatex.New("example/templates/", tt.opts...)
+			require.NoError(t, err)
+
+			tmpl, err := template.New("test").Funcs(engine.GetFuncMap()).Parse(tt.template)
+			require.NoError(t, err)
+
+			var buf bytes.Buffer
+			err = tmpl.Execute(&buf, nil)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, buf.String())
+		})
+	}
+}
+
+func TestDiffAgainst(t *testing.T) {
+	writeFiles := func(dir string, files map[string]string) {
+		for name, content := range files {
+			err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644)
+			require.NoError(t, err)
+		}
+	}
+
+	oldDir, newDir := t.TempDir(), t.TempDir()
+	writeFiles(oldDir, map[string]string{
+		"same.gohtml":    `same`,
+		"changed.gohtml": `old`,
+		"removed.gohtml": `removed`,
+	})
+	writeFiles(newDir, map[string]string{
+		"same.gohtml":    `same`,
+		"changed.gohtml": `new`,
+		"added.gohtml":   `added`,
+	})
+
+	oldEngine, err := templatex.New(oldDir)
+	require.NoError(t, err)
+	newEngine, err := templatex.New(newDir)
+	require.NoError(t, err)
+
+	diffs := newEngine.DiffAgainst(oldEngine)
+	require.Len(t, diffs, 3)
+	assert.Equal(t, "added", diffs[0].Name)
+	assert.Equal(t, templatex.DiffAdded, diffs[0].Status)
+	assert.Empty(t, diffs[0].OldChecksum)
+	assert.Equal(t, "changed", diffs[1].Name)
+	assert.Equal(t, templatex.DiffChanged, diffs[1].Status)
+	assert.NotEqual(t, diffs[1].OldChecksum, diffs[1].NewChecksum)
+	assert.Equal(t, "removed", diffs[2].Name)
+	assert.Equal(t, templatex.DiffRemoved, diffs[2].Status)
+
+	assert.Empty(t, newEngine.DiffAgainst(newEngine))
+}
+
+func TestHTMLValidation(t *testing.T) {
+	tempDir := t.TempDir()
+	files := map[string]string{
+		"valid.gohtml":     `<ul><li>one<li>two</ul><p>text<br><img src="/a.png" alt=""></p>`,
+		"unclosed.gohtml":  `<div><span id="main">text</div>`,
+		"duplicate.gohtml": `<p id="main">text</p>`,
+		"nested.gohtml":    `<a href="/"><a href="/x">x</a></a>`,
+		"stray.gohtml":     `<p>text</p></div>`,
+		"layout.gohtml":    "<html>\n<body>\n<div id=\"main\">{{ embed }}</div>\n</body>\n</html>",
+	}
+	for name, content := range files {
+		err := os.WriteFile(filepath.Join(tempDir, name), []byte(content), 0644)
+		require.NoError(t, err)
+	}
+
+	engine, err := templatex.New(tempDir, templatex.WithHTMLValidation(true))
+	require.NoError(t, err)
+
+	tests := []struct {
+		name     string
+		template string
+		layouts  []string
+		issues   []string
+	}{
+		{"Valid markup", "valid", []string{"layout"}, nil},
+		{"Unclosed tag", "unclosed", nil, []string{"unclosed: line 1: <span> is not closed before </div>"}},
+		{"Duplicate id", "duplicate", []string{"layout"}, []string{`duplicate: line 3: duplicate id "main", first used on line 3`}},
+		{"Unexpected end tag", "stray", nil, []string{"stray: line 1: unexpected end tag </div>"}},
+		{"Invalid nesting", "nested", nil, []string{"nested: line 1: <a> must not be nested inside <a>"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := engine.RenderString(context.Background(), tt.template, nil, tt.layouts...)
+			if tt.issues == nil {
+				assert.NoError(t, err)
+				return
+			}
+			require.ErrorIs(t, err, templatex.ErrHTMLValidationFailed)
+
+			var validationErr *templatex.HTMLValidationError
+			require.True(t, errors.As(err, &validationErr))
+			issues := make([]string, len(validationErr.Issues))
+			for i, issue := range validationErr.Issues {
+				issues[i] = issue.String()
+			}
+			assert.Equal(t, tt.issues, issues)
+		})
+	}
+}
+
+func TestAccessibilityCheck(t *testing.T) {
+	tempDir := t.TempDir()
+	files := map[string]string{
+		"page.gohtml": `<img src="/a.png"><img src="/b.png" alt="">` +
+			`<label for="email">Email</label><input id="email" type="email">` +
+			`<label>Name <input type="text"></label><input type="hidden"><textarea></textarea>`,
+		"layout.gohtml": "<html>\n<body>{{ embed }}</body></html>",
+	}
+	for name, content := range files {
+		err := os.WriteFile(filepath.Join(tempDir, name), []byte(content), 0644)
+		require.NoError(t, err)
+	}
+
+	var logs bytes.Buffer
+	engine, err := templatex.New(tempDir,
+		templatex.WithAccessibilityCheck(true),
+		templatex.WithLogger(slog.New(slog.NewTextHandler(&logs, nil))),
+	)
+	require.NoError(t, err)
+
+	_, err = engine.RenderString(context.Background(), "page", nil, "layout")
+	require.NoError(t, err)
+
+	output := logs.String()
+	assert.Equal(t, 3, strings.Count(output, "accessibility issue"))
+	assert.Contains(t, output, `template=layout line=1 issue="<html> is missing the lang attribute"`)
+	assert.Contains(t, output, `template=page line=2 issue="<img> is missing the alt attribute"`)
+	assert.Contains(t, output, `template=page line=2 issue="<textarea> has no associated label"`)
+}
+
+func TestExport(t *testing.T) {
+	tempDir := t.TempDir()
+	files := map[string]string{
+		"home.gohtml":   `<a href="/about">About</a><a href="about/">About</a><img src="/static/logo.png" alt="">`,
+		"about.gohtml":  `<a href="/">Home</a><a href="/index.html">Home</a><a href="../missing">Missing</a><a href="https://example.com">Ext</a><a href="#top">Top</a>`,
+		"layout.gohtml": "<html lang=\"en\">\n<body>\n{{ embed }}<link href=\"/static/app.css\"></body></html>",
+	}
+	for name, content := range files {
+		err := os.WriteFile(filepath.Join(tempDir, name), []byte(content), 0644)
+		require.NoError(t, err)
+	}
+
+	engine, err := templatex.New(tempDir)
+	require.NoError(t, err)
+
+	pages := []templatex.Page{
+		{Path: "/", Template: "home", Layouts: []string{"layout"}},
+		{Path: "/about", Template: "about", Layouts: []string{"layout"}},
+	}
+	assets := fstest.MapFS{"logo.png": {}, "app.css": {}}
+
+	t.Run("Broken links", func(t *testing.T) {
+		outDir := t.TempDir()
+		err := engine.Export(context.Background(), outDir, pages,
+			templatex.WithLinkCheck(true),
+			templatex.WithExportAssets(assets, "/static"),
+		)
+		require.ErrorIs(t, err, templatex.ErrBrokenLinks)
+
+		var linksErr *templatex.BrokenLinksError
+		require.True(t, errors.As(err, &linksErr))
+		require.Len(t, linksErr.Links, 1)
+		assert.Equal(t, templatex.BrokenLink{Page: "/about", Template: "about", Line: 3, URL: "../missing"}, linksErr.Links[0])
+
+		_, err = os.Stat(filepath.Join(outDir, "index.html"))
+		assert.True(t, os.IsNotExist(err))
+	})
+
+	t.Run("Valid links", func(t *testing.T) {
+		outDir := t.TempDir()
+		err := engine.Export(context.Background(), outDir, pages[:1],
+			templatex.WithLinkCheck(true),
+			templatex.WithExportAssets(assets, "/static"),
+		)
+		require.Error(t, err) // "/about" is not exported
+
+		err = engine.Export(context.Background(), outDir, append(pages, templatex.Page{Path: "/missing", Template: "about"}),
+			templatex.WithLinkCheck(true),
+			templatex.WithExportAssets(assets, "/static"),
+		)
+		require.NoError(t, err)
+
+		for _, file := range []string{"index.html", "about/index.html", "missing/index.html"} {
+			_, err := os.Stat(filepath.Join(outDir, file))
+			assert.NoError(t, err, file)
+		}
+	})
+
+	t.Run("output transforms", func(t *testing.T) {
+		engine, err := templatex.NewMemory(map[string]string{
+			"docs/guide.gohtml": "<h2>Hello World</h2>   <p>x</p>\n<a href=\"/\">Home</a>",
+		},
+			templatex.WithHeadingAnchors(templatex.HeadingAnchors{Templates: []string{"docs"}}),
+			templatex.WithMinifyOutput(true),
+		)
+		require.NoError(t, err)
+		rendered, err := engine.RenderString(context.Background(), "docs/guide", nil)
+		require.NoError(t, err)
+		assert.Contains(t, rendered, `<h2 id="hello-world">`)
+
+		// Exported pages match rendered ones
+		outDir := t.TempDir()
+		err = engine.Export(context.Background(), outDir, []templatex.Page{{Path: "/", Template: "docs/guide"}}, templatex.WithLinkCheck(true))
+		require.NoError(t, err)
+		exported, err := os.ReadFile(filepath.Join(outDir, "index.html"))
+		require.NoError(t, err)
+		assert.Equal(t, rendered, string(exported))
+	})
+}
+
+func TestExportChanged(t *testing.T) {
+	tempDir := t.TempDir()
+	files := map[string]string{
+		"home.gohtml":    `home`,
+		"about.gohtml":   `about {{ template "footer" }}`,
+		"contact.gohtml": `contact`,
+		"footer.gohtml":  `{{ define "footer" }}footer{{ end }}`,
+	}
+	old := time.Now().Add(-time.Hour)
+	for name, content := range files {
+		file := filepath.Join(tempDir, name)
+		require.NoError(t, os.WriteFile(file, []byte(content), 0644))
+		require.NoError(t, os.Chtimes(file, old, old))
+	}
+	since := time.Now().Add(-time.Minute)
+
+	// The footer changed after the last export
+	require.NoError(t, os.Chtimes(filepath.Join(tempDir, "footer.gohtml"), time.Now(), time.Now()))
+
+	engine, err := templatex.New(tempDir)
+	require.NoError(t, err)
+
+	outDir := t.TempDir()
+	exported, err := engine.ExportChanged(context.Background(), outDir, []templatex.Page{
+		{Path: "/", Template: "home"},
+		{Path: "/about", Template: "about"},
+		{Path: "/contact", Template: "contact", UpdatedAt: time.Now()},
+	}, since)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"/about", "/contact"}, exported)
+
+	_, err = os.Stat(filepath.Join(outDir, "index.html"))
+	assert.True(t, os.IsNotExist(err))
+
+	t.Run("resolved layouts", func(t *testing.T) {
+		tempDir := t.TempDir()
+		files := map[string]string{
+			"base.gohtml":    `base {{ embed }}`,
+			"blog.gohtml":    `blog {{ embed }}`,
+			"home.gohtml":    `home`,
+			"post.gohtml":    `{{/* extends "blog" */}}post`,
+			"partial.gohtml": `partial`,
+		}
+		for name, content := range files {
+			file := filepath.Join(tempDir, name)
+			require.NoError(t, os.WriteFile(file, []byte(content), 0644))
+			require.NoError(t, os.Chtimes(file, old, old))
+		}
+		pages := []templatex.Page{
+			{Path: "/", Template: "home"},
+			{Path: "/post", Template: "post"},
+			{Path: "/partial", Template: "partial", Layouts: []string{templatex.NoLayout}},
+		}
+
+		// The default layout changed, so pages rendered with it are exported
+		require.NoError(t, os.Chtimes(filepath.Join(tempDir, "base.gohtml"), time.Now(), time.Now()))
+		engine, err := templatex.New(tempDir, templatex.WithDefaultLayout("base"))
+		require.NoError(t, err)
+		exported, err := engine.ExportChanged(context.Background(), t.TempDir(), pages, since)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"/"}, exported)
+
+		// The extended layout changed
+		require.NoError(t, os.Chtimes(filepath.Join(tempDir, "base.gohtml"), old, old))
+		require.NoError(t, os.Chtimes(filepath.Join(tempDir, "blog.gohtml"), time.Now(), time.Now()))
+		engine, err = templatex.New(tempDir, templatex.WithDefaultLayout("base"))
+		require.NoError(t, err)
+		exported, err = engine.ExportChanged(context.Background(), t.TempDir(), pages, since)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"/post"}, exported)
+	})
+
+	t.Run("components", func(t *testing.T) {
+		tempDir := t.TempDir()
+		files := map[string]string{
+			"card.gohtml":   `<div>{{ .Slot }}</div>`,
+			"icon.gohtml":   `icon`,
+			"cards.gohtml":  `{{ component "card" }}{{ component "icon" }}{{ endcomponent "card" }}`,
+			"direct.gohtml": `{{ if true }}{{ component "card" (props "Slot" "x") }}{{ end }}`,
+			"form.gohtml":   `{{ component "form" (props "Action" "/") }}{{ endcomponent }}`,
+		}
+		for name, content := range files {
+			file := filepath.Join(tempDir, name)
+			require.NoError(t, os.WriteFile(file, []byte(content), 0644))
+			require.NoError(t, os.Chtimes(file, old, old))
+		}
+		pages := []templatex.Page{
+			{Path: "/cards", Template: "cards"},
+			{Path: "/direct", Template: "direct"},
+			{Path: "/form", Template: "form"},
+		}
+
+		// Components rendered in slots are dependencies too
+		require.NoError(t, os.Chtimes(filepath.Join(tempDir, "icon.gohtml"), time.Now(), time.Now()))
+		engine, err := templatex.New(tempDir)
+		require.NoError(t, err)
+		exported, err := engine.ExportChanged(context.Background(), t.TempDir(), pages, since)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"/cards"}, exported)
+
+		require.NoError(t, os.Chtimes(filepath.Join(tempDir, "card.gohtml"), time.Now(), time.Now()))
+		engine, err = templatex.New(tempDir)
+		require.NoError(t, err)
+		exported, err = engine.ExportChanged(context.Background(), t.TempDir(), pages, since)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"/cards", "/direct"}, exported)
+	})
+}
+
+func TestInvalidateByTag(t *testing.T) {
+	engine, err := templatex.New("example/templates/",
+		templatex.WithHardCache(true),
+		templatex.WithCacheTags(func(name string, binding any) []string {
+			return []string{"template:" + name, "user:" + binding.(pageData).Username}
+		}),
+	)
+	require.NoError(t, err)
+
+	render := func(data pageData) string {
+		result, err := engine.RenderString(context.Background(), "greeter", data)
+		require.NoError(t, err)
+		return result
+	}
+
+	assert.Contains(t, render(pageData{Username: "John"}), "John")
+	// Hard cache returns the stale render
+	assert.Contains(t, render(pageData{Username: "Jane"}), "John")
+
+	assert.Equal(t, 0, engine.InvalidateByTag("user:Jane"))
+	assert.Equal(t, 1, engine.InvalidateByTag("user:John"))
+	assert.Contains(t, render(pageData{Username: "Jane"}), "Jane")
+
+	assert.Equal(t, 1, engine.InvalidateByTag("template:greeter"))
+	assert.Equal(t, 0, engine.InvalidateByTag("template:greeter"))
+}
+
+func TestArchive(t *testing.T) {
+	archiveDir := t.TempDir()
+	engine, err := templatex.New("example/templates/", templatex.WithArchive(templatex.ArchiveToDir(archiveDir)))
+	require.NoError(t, err)
+
+	// Renders without a request ID are not archived
+	_, err = engine.RenderString(context.Background(), "greeter", pageData{Username: "John"})
+	require.NoError(t, err)
+	entries, err := os.ReadDir(archiveDir)
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+
+	ctx := templatex.WithRequestID(context.Background(), "req-1")
+	// The second render is served from cache and archived without overwriting the first one
+	for _, file := range []string{"req-1_greeter.html", "req-1_greeter_2.html"} {
+		var buf bytes.Buffer
+		err = engine.Render(ctx, &buf, "greeter", pageData{Username: "John"}, "app_layout")
+		require.NoError(t, err)
+
+		archived, err := os.ReadFile(filepath.Join(archiveDir, file))
+		require.NoError(t, err)
+		assert.Equal(t, buf.String(), string(archived))
+	}
+
+	// Request IDs can't escape the archive directory
+	ctx = templatex.WithRequestID(context.Background(), "../../etc/passwd")
+	_, err = engine.RenderString(ctx, "greeter", pageData{Username: "John"})
+	require.NoError(t, err)
+	_, err = os.Stat(filepath.Join(archiveDir, "_._.._etc_passwd_greeter.html"))
+	assert.NoError(t, err)
+
+	t.Run("middleware request id", func(t *testing.T) {
+		var id, correlationID string
+		handler := templatex.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			id = templatex.RequestID(r.Context())
+			correlationID = templatex.CorrelationID(r.Context())
+		}))
+		for header, kept := range map[string]bool{
+			"req-42.a_b":             true,
+			"":                       false,
+			"../escape":              false,
+			".hidden":                false,
+			"a b":                    false,
+			strings.Repeat("x", 129): false,
+		} {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Header.Set(templatex.RequestIDHeader, header)
+			handler.ServeHTTP(httptest.NewRecorder(), req)
+
+			// The request ID keying archives is always generated on the server
+			assert.NotEqual(t, header, id)
+			assert.Len(t, id, 32)
+			if kept {
+				assert.Equal(t, header, correlationID)
+			} else {
+				assert.Empty(t, correlationID)
+			}
+		}
+	})
+}
+
+func TestRenderPrint(t *testing.T) {
+	tempDir := t.TempDir()
+	files := map[string]string{
+		"invoice.gohtml":      `{{ if isPrint }}print{{ else }}screen{{ end }}<button onclick="pay()">Pay</button><p onclick="x()" class="total">{{ .Total }}</p><div data-no-print><div>Ad</div></div>{{ pageBreak }}`,
+		"print_layout.gohtml": `<html><head><link rel="stylesheet" href="/static/print.css"><link rel="stylesheet" href="https://cdn.example.com/x.css"><script>alert(1)</script></head><body>{{ printHeader "Invoice" }}{{ embed }}</body></html>`,
+	}
+	for name, content := range files {
+		err := os.WriteFile(filepath.Join(tempDir, name), []byte(content), 0644)
+		require.NoError(t, err)
+	}
+
+	engine, err := templatex.New(tempDir)
+	require.NoError(t, err)
+
+	data := map[string]any{"Total": "$42"}
+	var buf bytes.Buffer
+	err = engine.RenderPrint(context.Background(), &buf, "invoice", data,
+		templatex.WithPrintLayouts("print_layout"),
+		templatex.WithPrintAssets(fstest.MapFS{"print.css": {Data: []byte("body{margin:0}")}}, "/static"),
+	)
+	require.NoError(t, err)
+
+	out := buf.String()
+	assert.Contains(t, out, "<style>body{margin:0}</style>")
+	assert.Contains(t, out, `href="https://cdn.example.com/x.css"`)
+	assert.Contains(t, out, `<div class="templatex-print-header" style="position:fixed;left:0;right:0;top:0">Invoice</div>`)
+	assert.Contains(t, out, `print<p class="total">$42</p><div style="break-after:page;page-break-after:always"></div>`)
+	assert.NotContains(t, out, "script")
+	assert.NotContains(t, out, "Pay")
+	assert.NotContains(t, out, "Ad")
+
+	// Regular renders are cached separately
+	screen, err := engine.RenderString(context.Background(), "invoice", data)
+	require.NoError(t, err)
+	assert.Contains(t, screen, "screen")
+}
+
+func TestRenderLite(t *testing.T) {
+	tempDir := t.TempDir()
+	files := map[string]string{
+		"article.gohtml":     `{{ if isLite }}lite{{ end }}<p onclick="x()">{{ .Body }}</p><div data-no-lite>Comments</div><script>track()</script>`,
+		"lite_layout.gohtml": `<html><head><link rel="stylesheet" href="/static/critical.css"></head><body>{{ embed }}</body></html>`,
+	}
+	for name, content := range files {
+		err := os.WriteFile(filepath.Join(tempDir, name), []byte(content), 0644)
+		require.NoError(t, err)
+	}
+
+	engine, err := templatex.New(tempDir)
+	require.NoError(t, err)
+
+	data := map[string]any{"Body": "Hello"}
+	opts := []templatex.LiteOption{
+		templatex.WithLiteLayouts("lite_layout"),
+		templatex.WithLiteAssets(fstest.MapFS{"critical.css": {Data: []byte("p{margin:0}")}}, "/static"),
+	}
+
+	var buf bytes.Buffer
+	err = engine.RenderLite(context.Background(), &buf, "article", data, opts...)
+	require.NoError(t, err)
+	assert.Equal(t, `<html><head><style>p{margin:0}</style></head><body>lite<p>Hello</p></body></html>`, buf.String())
+
+	t.Run("Size budget", func(t *testing.T) {
+		var buf bytes.Buffer
+		err := engine.RenderLite(context.Background(), &buf, "article", data, append(opts, templatex.WithSizeBudget(10))...)
+		require.ErrorIs(t, err, templatex.ErrSizeBudgetExceeded)
+
+		var budgetErr *templatex.SizeBudgetError
+		require.True(t, errors.As(err, &budgetErr))
+		assert.Equal(t, 10, budgetErr.Budget)
+		assert.Equal(t, 81, budgetErr.Size)
+		assert.Empty(t, buf.String())
+	})
+
+	t.Run("Context", func(t *testing.T) {
+		ctx := templatex.WithLite(context.Background(), opts...)
+		out, err := engine.RenderString(ctx, "article", data, "lite_layout")
+		require.NoError(t, err)
+		assert.Equal(t, `<html><head><style>p{margin:0}</style></head><body>lite<p>Hello</p></body></html>`, out)
+
+		// Lite and regular renders are cached separately
+		out, err = engine.RenderString(context.Background(), "article", data)
+		require.NoError(t, err)
+		assert.Equal(t, `<p onclick="x()">Hello</p><div data-no-lite>Comments</div><script>track()</script>`, out)
+
+		_, err = engine.RenderString(templatex.WithLite(ctx, templatex.WithSizeBudget(10)), "article", data)
+		assert.ErrorIs(t, err, templatex.ErrSizeBudgetExceeded)
+	})
+}
+
+type keyedPage struct {
+	ID     string
+	Secret string
+}
+
+func (p keyedPage) CacheKey() string { return p.ID }
+
+func TestCacheKeys(t *testing.T) {
+	tempDir := t.TempDir()
+	err := os.WriteFile(filepath.Join(tempDir, "page.gohtml"), []byte(`{{ .ID }}:{{ .Secret }}`), 0644)
+	require.NoError(t, err)
+
+	t.Run("CacheKeyer", func(t *testing.T) {
+		engine, err := templatex.New(tempDir)
+		require.NoError(t, err)
+
+		out, err := engine.RenderString(context.Background(), "page", keyedPage{ID: "1", Secret: "a"})
+		require.NoError(t, err)
+		assert.Equal(t, "1:a", out)
+
+		// Same key, so the cached render is returned
+		out, err = engine.RenderString(context.Background(), "page", keyedPage{ID: "1", Secret: "b"})
+		require.NoError(t, err)
+		assert.Equal(t, "1:a", out)
+
+		out, err = engine.RenderString(context.Background(), "page", keyedPage{ID: "2", Secret: "b"})
+		require.NoError(t, err)
+		assert.Equal(t, "2:b", out)
+	})
+
+	t.Run("Cache key fields", func(t *testing.T) {
+		engine, err := templatex.New(tempDir, templatex.WithCacheKeyFields("ID"))
+		require.NoError(t, err)
+
+		out, err := engine.RenderString(context.Background(), "page", map[string]any{"ID": "1", "Secret": "a"})
+		require.NoError(t, err)
+		assert.Equal(t, "1:a", out)
+
+		out, err = engine.RenderString(context.Background(), "page", map[string]any{"ID": "1", "Secret": "b"})
+		require.NoError(t, err)
+		assert.Equal(t, "1:a", out)
+
+		out, err = engine.RenderString(context.Background(), "page", map[string]any{"ID": "2", "Secret": "b"})
+		require.NoError(t, err)
+		assert.Equal(t, "2:b", out)
+	})
+}
+
+func TestCacheKeyMapBinding(t *testing.T) {
+	tempDir := t.TempDir()
+	err := os.WriteFile(filepath.Join(tempDir, "page.gohtml"), []byte(`{{ call .Render }}`), 0644)
+	require.NoError(t, err)
+
+	engine, err := templatex.New(tempDir)
+	require.NoError(t, err)
+
+	calls := 0
+	newBinding := func(reverse bool) map[string]any {
+		m := map[string]any{"Render": func() int { calls++; return calls }}
+		for i := 0; i < 50; i++ {
+			k := i
+			if reverse {
+				k = 49 - i
+			}
+			m[fmt.Sprintf("key%d", k)] = map[string]int{"a": k, "b": k * 2}
+		}
+		return m
+	}
+
+	for _, reverse := range []bool{false, true, false} {
+		out, err := engine.RenderString(context.Background(), "page", newBinding(reverse))
+		require.NoError(t, err)
+		assert.Equal(t, "1", out)
+	}
+	assert.Equal(t, 1, calls)
+
+	// Different data produces a different key
+	b := newBinding(false)
+	b["key0"] = map[string]int{"a": 1}
+	out, err := engine.RenderString(context.Background(), "page", b)
+	require.NoError(t, err)
+	assert.Equal(t, "2", out)
+}
+
+func TestCacheKeyCanonicalValues(t *testing.T) {
+	engine, err := templatex.NewMemory(map[string]string{
+		"page.gohtml": `{{ call .Render }}`,
+	})
+	require.NoError(t, err)
+
+	calls := 0
+	render := func(binding map[string]any) int {
+		binding["Render"] = func() int { calls++; return calls }
+		out, err := engine.RenderString(context.Background(), "page", binding)
+		require.NoError(t, err)
+		n, err := strconv.Atoi(out)
+		require.NoError(t, err)
+		return n
+	}
+
+	t.Run("cycles", func(t *testing.T) {
+		type node struct {
+			Name string
+			Next *node
+		}
+		a := &node{Name: "a"}
+		a.Next = &node{Name: "b", Next: a}
+		cyclic := map[string]any{"Name": "x"}
+		cyclic["Self"] = cyclic
+		first := render(map[string]any{"Node": a, "Map": cyclic})
+		assert.Equal(t, first, render(map[string]any{"Node": a, "Map": cyclic}))
+	})
+
+	t.Run("times", func(t *testing.T) {
+		now := time.Now()
+		first := render(map[string]any{"Time": now})
+		// The monotonic clock reading doesn't change the key, the location does
+		assert.Equal(t, first, render(map[string]any{"Time": now.Round(0)}))
+		assert.NotEqual(t, first, render(map[string]any{"Time": now.UTC()}))
+	})
+
+	t.Run("marshalers", func(t *testing.T) {
+		first := render(map[string]any{"Addr": netip.MustParseAddr("10.0.0.1")})
+		assert.Equal(t, first, render(map[string]any{"Addr": netip.MustParseAddr("10.0.0.1")}))
+		assert.NotEqual(t, first, render(map[string]any{"Addr": netip.MustParseAddr("10.0.0.2")}))
+	})
+
+	t.Run("unexported fields", func(t *testing.T) {
+		first := render(map[string]any{"Page": unexportedState{Title: "Home", hits: 1}})
+		assert.Equal(t, first, render(map[string]any{"Page": unexportedState{Title: "Home", hits: 2}}))
+		assert.NotEqual(t, first, render(map[string]any{"Page": unexportedState{Title: "About", hits: 2}}))
+	})
+}
+
+// unexportedState has a field templates can't read
+type unexportedState struct {
+	Title string
+	hits  int
+}
+
+func TestSlowRenderThreshold(t *testing.T) {
+	tempDir := t.TempDir()
+	err := os.WriteFile(filepath.Join(tempDir, "page.gohtml"), []byte(`{{ call .Work }}`), 0644)
+	require.NoError(t, err)
+
+	data := map[string]any{"Work": func() string { time.Sleep(20 * time.Millisecond); return "done" }}
+
+	t.Run("Callback", func(t *testing.T) {
+		var reports []templatex.SlowRender
+		engine, err := templatex.New(tempDir, templatex.WithSlowRenderThreshold(10*time.Millisecond,
+			func(ctx context.Context, r templatex.SlowRender) { reports = append(reports, r) }))
+		require.NoError(t, err)
+
+		_, err = engine.RenderString(context.Background(), "page", data)
+		require.NoError(t, err)
+		require.Len(t, reports, 1)
+		assert.Equal(t, "page", reports[0].Template)
+		assert.Equal(t, 10*time.Millisecond, reports[0].Threshold)
+		assert.GreaterOrEqual(t, reports[0].Duration, 20*time.Millisecond)
+
+		// Cached renders are not reported
+		_, err = engine.RenderString(context.Background(), "page", data)
+		require.NoError(t, err)
+		assert.Len(t, reports, 1)
+	})
+
+	t.Run("Logger", func(t *testing.T) {
+		var logs bytes.Buffer
+		engine, err := templatex.New(tempDir,
+			templatex.WithSlowRenderThreshold(10*time.Millisecond, nil),
+			templatex.WithLogger(slog.New(slog.NewTextHandler(&logs, nil))),
+		)
+		require.NoError(t, err)
+
+		_, err = engine.RenderString(context.Background(), "page", data)
+		require.NoError(t, err)
+		assert.Contains(t, logs.String(), "templatex: slow render")
+		assert.Contains(t, logs.String(), "template=page")
+	})
+}
+
+func FuzzRender(f *testing.F) {
+	tempDir := f.TempDir()
+	content := `{{ safeField . "User.Name" "guest" }}|{{ getPath . "Items.0" }}|{{ len .Items }}|{{ default "x" .Title }}|{{ dig "User" "Role" "none" . }}`
+	err := os.WriteFile(filepath.Join(tempDir, "page.gohtml"), []byte(content), 0644)
+	require.NoError(f, err)
+
+	engine, err := templatex.New(tempDir)
+	require.NoError(f, err)
+
+	f.Add([]byte(`{"User":{"Name":"John","Role":"admin"},"Items":[1,2],"Title":"Hi"}`))
+	f.Add([]byte(`{"User":null,"Items":"abc"}`))
+	f.Add([]byte(`[1,2,3]`))
+	f.Add([]byte(`not json`))
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		// Errors are expected for unexpected data, only panics fail the test
+		_ = engine.FuzzRender("page", data)
+	})
+}
+
+func TestMutationCheck(t *testing.T) {
+	tempDir := t.TempDir()
+	err := os.WriteFile(filepath.Join(tempDir, "page.gohtml"), []byte(`{{ setPath . "Seen" true }}{{ .Name }}`), 0644)
+	require.NoError(t, err)
+
+	for _, env := range []string{templatex.EnvDevelopment, templatex.EnvProduction} {
+		t.Run(env, func(t *testing.T) {
+			var logs bytes.Buffer
+			engine, err := templatex.New(tempDir,
+				templatex.WithEnvironment(env),
+				templatex.WithMutationCheck(true),
+				templatex.WithLogger(slog.New(slog.NewTextHandler(&logs, nil))),
+			)
+			require.NoError(t, err)
+
+			_, err = engine.RenderString(context.Background(), "page", map[string]interface{}{"Name": "John"})
+			require.NoError(t, err)
+			if env == templatex.EnvDevelopment {
+				assert.Contains(t, logs.String(), "templatex: binding mutated during render")
+				assert.Contains(t, logs.String(), "template=page")
+			} else {
+				assert.Empty(t, logs.String())
+			}
+		})
+	}
+}
+
+func TestLayoutValidation(t *testing.T) {
+	t.Run("Missing layouts", func(t *testing.T) {
+		_, err := templatex.New("example/templates/",
+			templatex.WithLayouts("base_layout", "missing_layout"),
+			templatex.WithLayoutChain("app_layout", "other_layout"),
+		)
+		require.ErrorIs(t, err, templatex.ErrLayoutNotFound)
+		assert.Contains(t, err.Error(), "missing_layout, other_layout")
+	})
+
+	t.Run("Declared chain", func(t *testing.T) {
+		engine, err := templatex.New("example/templates/",
+			templatex.WithLayoutCache(true),
+			templatex.WithLayoutChain("app_layout", "base_layout"),
+		)
+		require.NoError(t, err)
+
+		out, err := engine.RenderString(context.Background(), "greeter", pageData{Username: "John"}, "app_layout", "base_layout")
+		require.NoError(t, err)
+		assert.Contains(t, out, "container")
+	})
+
+	t.Run("Unknown layout on render", func(t *testing.T) {
+		engine, err := templatex.New("example/templates/")
+		require.NoError(t, err)
+
+		_, err = engine.RenderString(context.Background(), "greeter", pageData{}, "missing_layout")
+		require.ErrorIs(t, err, templatex.ErrLayoutNotFound)
+	})
+}
+
+func TestDefaultLayout(t *testing.T) {
+	files := map[string]string{
+		"page.gohtml":          `page`,
+		"emails/hello.gohtml":  `<title>Hi</title>hello`,
+		"app_layout.gohtml":    `<main>{{ embed }}</main>`,
+		"base_layout.gohtml":   `<body>{{ embed }}</body>`,
+		"bare_layout.gohtml":   `[{{ embed }}]`,
+		"partials/row.gohtml":  `row`,
+		"partials/cell.gohtml": `cell`,
+	}
+	engine, err := templatex.NewMemory(files, templatex.WithDefaultLayout("app_layout", "base_layout"))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"app_layout", "base_layout"}, engine.Config().DefaultLayouts)
+
+	out, err := engine.RenderString(context.Background(), "page", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "<body><main>page</main></body>", out)
+
+	// Explicit layouts replace the default ones
+	out, err = engine.RenderString(context.Background(), "page", nil, "bare_layout")
+	require.NoError(t, err)
+	assert.Equal(t, "[page]", out)
+
+	out, err = engine.RenderString(context.Background(), "partials/row", nil, templatex.NoLayout)
+	require.NoError(t, err)
+	assert.Equal(t, "row", out)
+
+	var buf bytes.Buffer
+	require.NoError(t, engine.RenderStream(context.Background(), &buf, "partials/cell", nil, templatex.NoLayout))
+	assert.Equal(t, "cell", buf.String())
+
+	email, err := engine.RenderEmail(context.Background(), "emails/hello", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "<title>Hi</title>hello", email.HTML)
+
+	// Default layouts don't wrap themselves
+	out, err = engine.RenderString(context.Background(), "app_layout", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "<body><main></main></body>", out)
+	out, err = engine.RenderString(context.Background(), "base_layout", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "<body></body>", out)
+
+	t.Run("missing layout", func(t *testing.T) {
+		_, err := templatex.NewMemory(files, templatex.WithDefaultLayout("missing_layout"))
+		require.ErrorIs(t, err, templatex.ErrLayoutNotFound)
+	})
+
+	t.Run("no default layout", func(t *testing.T) {
+		engine, err := templatex.NewMemory(files)
+		require.NoError(t, err)
+		out, err := engine.RenderString(context.Background(), "page", nil, templatex.NoLayout)
+		require.NoError(t, err)
+		assert.Equal(t, "page", out)
+	})
+}
+
+func TestConventionLayouts(t *testing.T) {
+	files := map[string]string{
+		"_layout.gohtml":             `<body>{{ embed }}</body>`,
+		"pages/_layout.gohtml":       `<main>{{ embed }}</main>`,
+		"pages/admin/_layout.gohtml": `<nav>admin</nav>{{ embed }}`,
+		"pages/admin/users.gohtml":   `users`,
+		"pages/about.gohtml":         `about`,
+		"emails/_layout.gohtml":      `<table>{{ embed }}</table>`,
+		"emails/hello.gohtml":        `<title>Hi</title>hello`,
+		"base_layout.gohtml":         `[{{ embed }}]`,
+	}
+	engine, err := templatex.NewMemory(files,
+		templatex.WithConventionLayouts(true),
+		templatex.WithDefaultLayout("base_layout"),
+	)
+	require.NoError(t, err)
+	assert.True(t, engine.Config().ConventionLayouts)
+
+	tests := []struct {
+		name    string
+		layouts []string
+		want    string
+	}{
+		{name: "pages/admin/users", want: "<body><main><nav>admin</nav>users</main></body>"},
+		{name: "pages/about", want: "<body><main>about</main></body>"},
+		{name: "pages/admin/_layout", want: "<body><main><nav>admin</nav></main></body>"},
+		{name: "pages/about", layouts: []string{"base_layout"}, want: "[about]"},
+		{name: "pages/about", layouts: []string{templatex.NoLayout}, want: "about"},
+	}
+	for _, tt := range tests {
+		out, err := engine.RenderString(context.Background(), tt.name, nil, tt.layouts...)
+		require.NoError(t, err, tt.name)
+		assert.Equal(t, tt.want, out, tt.name)
+	}
+
+	email, err := engine.RenderEmail(context.Background(), "emails/hello", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "<body><table><title>Hi</title>hello</table></body>", email.HTML)
+
+	t.Run("default layout", func(t *testing.T) {
+		engine, err := templatex.NewMemory(map[string]string{
+			"pages/about.gohtml": `about`,
+			"base_layout.gohtml": `[{{ embed }}]`,
+		}, templatex.WithConventionLayouts(true), templatex.WithDefaultLayout("base_layout"))
+		require.NoError(t, err)
+		out, err := engine.RenderString(context.Background(), "pages/about", nil)
+		require.NoError(t, err)
+		assert.Equal(t, "[about]", out)
+	})
+}
+
+func TestExtends(t *testing.T) {
+	files := map[string]string{
+		"base_layout.gohtml":  `<body>{{ embed }}</body>`,
+		"app_layout.gohtml":   `{{/* extends "base_layout" */}}<main>{{ embed }}</main>`,
+		"pages/home.gohtml":   `{{/* extends "app_layout" */}}home`,
+		"pages/about.gohtml":  "---\nextends: base_layout\ntitle: About\n---\nabout",
+		"pages/plain.gohtml":  `plain`,
+		"emails/hello.gohtml": `{{- /* extends "email_layout" */ -}}hello`,
+		"email_layout.gohtml": `<table>{{ embed }}</table>`,
+		"other_layout.gohtml": `[{{ embed }}]`,
+	}
+	engine, err := templatex.NewMemory(files)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name    string
+		layouts []string
+		want    string
+	}{
+		{name: "pages/home", want: "<body><main>home</main></body>"},
+		{name: "pages/about", want: "<body>about</body>"},
+		{name: "pages/plain", want: "plain"},
+		{name: "pages/home", layouts: []string{"other_layout"}, want: "[home]"},
+		{name: "pages/home", layouts: []string{templatex.NoLayout}, want: "home"},
+	}
+	for _, tt := range tests {
+		out, err := engine.RenderString(context.Background(), tt.name, nil, tt.layouts...)
+		require.NoError(t, err, tt.name)
+		assert.Equal(t, tt.want, out, tt.name)
+	}
+
+	email, err := engine.RenderEmail(context.Background(), "emails/hello", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "<table>hello</table>", email.HTML)
+
+	t.Run("precedes default layouts", func(t *testing.T) {
+		engine, err := templatex.NewMemory(files, templatex.WithDefaultLayout("other_layout"))
+		require.NoError(t, err)
+		out, err := engine.RenderString(context.Background(), "pages/home", nil)
+		require.NoError(t, err)
+		assert.Equal(t, "<body><main>home</main></body>", out)
+		out, err = engine.RenderString(context.Background(), "pages/plain", nil)
+		require.NoError(t, err)
+		assert.Equal(t, "[plain]", out)
+	})
+
+	t.Run("missing layout", func(t *testing.T) {
+		_, err := templatex.NewMemory(map[string]string{
+			"pages/home.gohtml": `{{/* extends "missing_layout" */}}home`,
+		})
+		require.ErrorIs(t, err, templatex.ErrLayoutNotFound)
+		assert.Contains(t, err.Error(), "missing_layout (extended by pages/home)")
+	})
+
+	t.Run("cycle", func(t *testing.T) {
+		_, err := templatex.NewMemory(map[string]string{
+			"a_layout.gohtml":   `{{/* extends "b_layout" */}}a{{ embed }}`,
+			"b_layout.gohtml":   `{{/* extends "a_layout" */}}b{{ embed }}`,
+			"pages/home.gohtml": `{{/* extends "a_layout" */}}home`,
+		})
+		require.ErrorIs(t, err, templatex.ErrTemplateParsingFailed)
+		assert.Contains(t, err.Error(), "extends cycle")
+	})
+
+	t.Run("define blocks", func(t *testing.T) {
+		_, err := templatex.NewMemory(map[string]string{
+			"base_layout.gohtml": `{{ embed }}`,
+			"pages/home.gohtml":  `{{/* extends "base_layout" */}}{{ define "title" }}Home{{ end }}`,
+		})
+		require.ErrorIs(t, err, templatex.ErrTemplateParsingFailed)
+		assert.Contains(t, err.Error(), "extends is not supported in files with define blocks")
+	})
+
+	t.Run("lazy parsing", func(t *testing.T) {
+		fsys := fstest.MapFS{}
+		for name, content := range files {
+			fsys[name] = &fstest.MapFile{Data: []byte(content)}
+		}
+		fsys["pages/broken.gohtml"] = &fstest.MapFile{Data: []byte(`{{/* extends "base_layout" */}}{{ if }}`)}
+		engine, err := templatex.NewFS(fsys, ".", templatex.WithLazyParsing(true))
+		require.NoError(t, err)
+
+		out, err := engine.RenderString(context.Background(), "pages/home", nil)
+		require.NoError(t, err)
+		assert.Equal(t, "<body><main>home</main></body>", out)
+
+		_, err = engine.RenderString(context.Background(), "pages/broken", nil)
+		assert.ErrorIs(t, err, templatex.ErrTemplateParsingFailed)
+	})
+}
+
+func TestTemplateNameNormalization(t *testing.T) {
+	tempDir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(tempDir, "pages"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(tempDir, "pages", "home.gohtml"), []byte(`home`), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(tempDir, "Layout.gohtml"), []byte(`[{{ embed }}]`), 0644))
+
+	engine, err := templatex.New(tempDir)
+	require.NoError(t, err)
+
+	for _, name := range []string{"pages/home", "pages/home.gohtml", "./pages/home", `pages\home`} {
+		out, err := engine.RenderString(context.Background(), name, nil, "Layout")
+		require.NoError(t, err, name)
+		assert.Equal(t, "[home]", out)
+	}
+
+	_, err = engine.RenderString(context.Background(), "Pages/Home", nil, "layout")
+	require.ErrorIs(t, err, templatex.ErrTemplateNotFound)
+
+	t.Run("Case-insensitive", func(t *testing.T) {
+		engine, err := templatex.New(tempDir, templatex.WithCaseInsensitiveNames(true), templatex.WithLayouts("LAYOUT"))
+		require.NoError(t, err)
+
+		out, err := engine.RenderString(context.Background(), "Pages/Home.gohtml", nil, "layout")
+		require.NoError(t, err)
+		assert.Equal(t, "[home]", out)
+	})
+
+	t.Run("Ambiguous names", func(t *testing.T) {
+		require.NoError(t, os.WriteFile(filepath.Join(tempDir, "layout.gohtml"), []byte(`{{ embed }}`), 0644))
+		_, err := templatex.New(tempDir, templatex.WithCaseInsensitiveNames(true))
+		require.ErrorIs(t, err, templatex.ErrTemplateParsingFailed)
+	})
+}
+
+func TestNewFS(t *testing.T) {
+	fsys := fstest.MapFS{
+		"views/pages/home.gohtml":   {Data: []byte(`home {{ template "widget" }}`)},
+		"views/layout.gohtml":       {Data: []byte(`[{{ embed }}]`)},
+		"views/partials.gohtml":     {Data: []byte(`{{ define "widget" }}widget{{ end }}`)},
+		"views/readme.txt":          {Data: []byte(`ignored`)},
+		"other/ignored_page.gohtml": {Data: []byte(`ignored`)},
+	}
+
+	engine, err := templatex.NewFS(fsys, "views", templatex.WithLayouts("layout"))
+	require.NoError(t, err)
+
+	out, err := engine.RenderString(context.Background(), "pages/home", nil, "layout")
+	require.NoError(t, err)
+	assert.Equal(t, "[home widget]", out)
+
+	_, err = engine.RenderString(context.Background(), "ignored_page", nil)
+	require.ErrorIs(t, err, templatex.ErrTemplateNotFound)
+
+	_, err = templatex.NewFS(fsys, "missing")
+	require.ErrorIs(t, err, templatex.ErrNoTemplateDirectory)
+}
+
+func TestFuncOverride(t *testing.T) {
+	tempDir := t.TempDir()
+	err := os.WriteFile(filepath.Join(tempDir, "page.gohtml"), []byte(`{{ default "x" "y" }}|{{ env }}`), 0644)
+	require.NoError(t, err)
+
+	_, err = templatex.New(tempDir, templatex.WithFunc("default", func(a, b string) string { return "custom" }))
+	require.ErrorIs(t, err, templatex.ErrBuiltinFuncOverride)
+
+	_, err = templatex.New(tempDir, templatex.WithFunc("T", func(key string) string { return key }), templatex.WithFuncOverride("T"))
+	require.ErrorIs(t, err, templatex.ErrReservedFuncName)
+
+	engine, err := templatex.New(tempDir,
+		templatex.WithFunc("default", func(a, b string) string { return "custom" }),
+		templatex.WithFunc("env", func() string { return "staging" }),
+		templatex.WithFuncOverride("default", "env"),
+	)
+	require.NoError(t, err)
+
+	out, err := engine.RenderString(context.Background(), "page", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "custom|staging", out)
+}
+
+func TestAutoReload(t *testing.T) {
+	if runtime.GOOS == "js" || runtime.GOOS == "wasip1" {
+		t.Skip("file watching is not supported on WebAssembly")
+	}
+	tempDir := t.TempDir()
+	file := filepath.Join(tempDir, "page.gohtml")
+	require.NoError(t, os.WriteFile(file, []byte(`v1`), 0644))
+
+	engine, err := templatex.New(tempDir,
+		templatex.WithAutoReload(true),
+		templatex.WithLogger(slog.New(slog.NewTextHandler(io.Discard, nil))),
+	)
+	require.NoError(t, err)
+	defer engine.Close()
+
+	out, err := engine.RenderString(context.Background(), "page", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "v1", out)
+
+	require.NoError(t, os.WriteFile(file, []byte(`v2`), 0644))
+	assert.Eventually(t, func() bool {
+		out, err := engine.RenderString(context.Background(), "page", nil)
+		return err == nil && out == "v2"
+	}, 2*time.Second, 20*time.Millisecond)
+
+	// Invalid templates are not loaded
+	require.NoError(t, os.WriteFile(file, []byte(`{{ if }}`), 0644))
+	time.Sleep(300 * time.Millisecond)
+	out, err = engine.RenderString(context.Background(), "page", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "v2", out)
+
+	// New templates in new subdirectories are loaded
+	require.NoError(t, os.WriteFile(file, []byte(`v3`), 0644))
+	require.NoError(t, os.MkdirAll(filepath.Join(tempDir, "pages"), 0755))
+	time.Sleep(50 * time.Millisecond)
+	require.NoError(t, os.WriteFile(filepath.Join(tempDir, "pages", "new.gohtml"), []byte(`new`), 0644))
+	assert.Eventually(t, func() bool {
+		out, err := engine.RenderString(context.Background(), "pages/new", nil)
+		return err == nil && out == "new"
+	}, 2*time.Second, 20*time.Millisecond)
+}
+
+func TestConfigAndReport(t *testing.T) {
+	engine, err := templatex.New("example/templates/",
+		templatex.WithLayouts("app_layout", "base_layout"),
+		templatex.WithLayoutChain("app_layout", "base_layout"),
+		templatex.WithFunc("shout", strings.ToUpper),
+		templatex.WithFakeFuncs(true),
+	)
+	require.NoError(t, err)
+
+	cfg := engine.Config()
+	assert.Equal(t, "example/templates/", cfg.Root)
+	assert.Equal(t, []string{".gohtml"}, cfg.Extensions)
+	assert.Equal(t, []string{"app_layout", "base_layout"}, cfg.Layouts)
+	assert.Equal(t, [][]string{{"app_layout", "base_layout"}}, cfg.LayoutChains)
+	assert.Equal(t, []string{"shout"}, cfg.CustomFuncs)
+	assert.Contains(t, cfg.Funcs, "embed")
+	assert.Equal(t, templatex.EnvProduction, cfg.Environment)
+
+	_, err = engine.RenderString(context.Background(), "greeter", pageData{Username: "John"}, "app_layout")
+	require.NoError(t, err)
+
+	report := engine.Report()
+	assert.Equal(t, []string{"app_layout", "base_layout", "footer", "footer.gohtml", "greeter", "trans_layout"}, report.TemplateNames)
+	assert.Equal(t, 6, report.Templates)
+	assert.Equal(t, 1, report.CachedRenders)
+	assert.Equal(t, []string{"fake data functions are enabled outside of development"}, report.Warnings)
+	assert.Contains(t, report.String(), "templates=6")
+}
+
+func TestReload(t *testing.T) {
+	tempDir := t.TempDir()
+	file := filepath.Join(tempDir, "page.gohtml")
+	require.NoError(t, os.WriteFile(file, []byte(`v1`), 0644))
+
+	engine, err := templatex.New(tempDir, templatex.WithHardCache(true), templatex.WithLayoutCache(true))
+	require.NoError(t, err)
+
+	out, err := engine.RenderString(context.Background(), "page", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "v1", out)
+
+	require.NoError(t, os.WriteFile(file, []byte(`v2`), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(tempDir, "other.gohtml"), []byte(`other`), 0644))
+	require.NoError(t, engine.Reload())
+
+	out, err = engine.RenderString(context.Background(), "page", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "v2", out)
+
+	out, err = engine.RenderString(context.Background(), "other", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "other", out)
+
+	// Parse errors keep the current templates
+	require.NoError(t, os.WriteFile(file, []byte(`{{ if }}`), 0644))
+	require.ErrorIs(t, engine.Reload(), templatex.ErrTemplateParsingFailed)
+
+	out, err = engine.RenderString(context.Background(), "page", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "v2", out)
+}
+
+func TestNamespaces(t *testing.T) {
+	ui := fstest.MapFS{
+		"components/button.gohtml":      {Data: []byte(`<button>{{ . }}</button>`)},
+		"components/forms/input.gohtml": {Data: []byte(`<input value="{{ . }}">`)},
+		"components/card@v1.gohtml":     {Data: []byte(`<div class="card-v1">{{ . }}</div>`)},
+		"components/card@v2.gohtml":     {Data: []byte(`<div class="card-v2">{{ . }}</div>`)},
+		"components/card@v10.gohtml":    {Data: []byte(`<div class="card-v10">{{ . }}</div>`)},
+		"components/button@beta.gohtml": {Data: []byte(`<button class="beta">{{ . }}</button>`)},
+	}
+	pages := fstest.MapFS{
+		"page.gohtml": {Data: []byte(`{{ template "ui.button" "Save" }}{{ template "ui.forms/input" "x" }}{{ template "ui.card" "new" }}{{ template "ui.card@v1" "old" }}{{ template "admin.table" }}`)},
+	}
+	admin := fstest.MapFS{"table.gohtml": {Data: []byte(`<table></table>`)}}
+
+	engine, err := templatex.NewFS(pages, ".",
+		templatex.WithNamespace("ui", ui, "components"),
+		templatex.WithNamespace("admin", admin, ""),
+	)
+	require.NoError(t, err)
+
+	out, err := engine.RenderString(context.Background(), "page", nil)
+	require.NoError(t, err)
+	assert.Equal(t, `<button>Save</button><input value="x"><div class="card-v10">new</div><div class="card-v1">old</div><table></table>`, out)
+
+	out, err = engine.RenderString(context.Background(), "ui.button@beta", "Try")
+	require.NoError(t, err)
+	assert.Equal(t, `<button class="beta">Try</button>`, out)
+}
+
+func TestRenderStream(t *testing.T) {
+	engine, err := templatex.New("example/templates/")
+	require.NoError(t, err)
+
+	data := pageData{Title: "Stream", Username: "John"}
+	expected, err := engine.RenderString(context.Background(), "greeter", data, "app_layout", "base_layout")
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	err = engine.RenderStream(context.Background(), &buf, "greeter", data, "app_layout", "base_layout")
+	require.NoError(t, err)
+	assert.Equal(t, expected, buf.String())
+
+	buf.Reset()
+	err = engine.RenderStream(context.Background(), &buf, "greeter", data)
+	require.NoError(t, err)
+	assert.Contains(t, buf.String(), "John")
+
+	// Nothing is written if a layout is missing
+	buf.Reset()
+	err = engine.RenderStream(context.Background(), &buf, "greeter", data, "missing_layout")
+	require.ErrorIs(t, err, templatex.ErrLayoutNotFound)
+	assert.Empty(t, buf.String())
+}
+
+func TestRenderHTTP(t *testing.T) {
+	engine, err := templatex.New("example/templates/")
+	require.NoError(t, err)
+
+	data := pageData{Title: "HTTP", Username: "John"}
+
+	rec := httptest.NewRecorder()
+	err = engine.RenderHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil), "greeter", data, "base_layout")
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "text/html; charset=utf-8", rec.Header().Get("Content-Type"))
+	assert.Contains(t, rec.Body.String(), "John")
+	etag := rec.Header().Get("ETag")
+	require.NotEmpty(t, etag)
+
+	for _, header := range []string{etag, "W/" + etag, `"other", ` + etag, "*"} {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("If-None-Match", header)
+		rec = httptest.NewRecorder()
+		err = engine.RenderHTTP(rec, req, "greeter", data, "base_layout")
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusNotModified, rec.Code, header)
+		assert.Empty(t, rec.Body.String())
+	}
+
+	// Different content produces a different ETag
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("If-None-Match", etag)
+	rec = httptest.NewRecorder()
+	err = engine.RenderHTTP(rec, req, "greeter", pageData{Title: "HTTP", Username: "Jane"}, "base_layout")
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.NotEqual(t, etag, rec.Header().Get("ETag"))
+
+	// HEAD requests have no body
+	rec = httptest.NewRecorder()
+	err = engine.RenderHTTP(rec, httptest.NewRequest(http.MethodHead, "/", nil), "greeter", data, "base_layout")
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Empty(t, rec.Body.String())
+}
+
+func TestResponse(t *testing.T) {
+	engine, err := templatex.New("example/templates/")
+	require.NoError(t, err)
+
+	data := pageData{Title: "Not Found", Username: "John"}
+
+	rec := httptest.NewRecorder()
+	err = engine.Response("greeter", data).
+		Status(http.StatusNotFound).
+		Header("X-Foo", "bar").
+		Layouts("base_layout").
+		Write(context.Background(), rec)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	assert.Equal(t, "bar", rec.Header().Get("X-Foo"))
+	assert.Equal(t, "text/html; charset=utf-8", rec.Header().Get("Content-Type"))
+	assert.Contains(t, rec.Body.String(), "<title>Not Found</title>")
+	assert.Contains(t, rec.Body.String(), "John")
+
+	// Default status and custom content type
+	rec = httptest.NewRecorder()
+	err = engine.Response("greeter", data).Header("Content-Type", "text/plain").Write(context.Background(), rec)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "text/plain", rec.Header().Get("Content-Type"))
+
+	// Nothing is written if rendering fails
+	rec = httptest.NewRecorder()
+	err = engine.Response("missing", data).Status(http.StatusNotFound).Write(context.Background(), rec)
+	require.Error(t, err)
+	assert.Empty(t, rec.Body.String())
+	assert.Empty(t, rec.Header())
+}
+
+type pushConn struct {
+	messages []string
+	err      error
+}
+
+func (c *pushConn) Write(b []byte) error {
+	if c.err != nil {
+		return c.err
+	}
+	c.messages = append(c.messages, string(b))
+	return nil
+}
+
+func TestRenderPush(t *testing.T) {
+	engine, err := templatex.NewFS(fstest.MapFS{
+		"components/cart.gohtml": {Data: []byte(`<span>{{ . }} items</span>`)},
+	}, ".")
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	tests := []struct {
+		name     string
+		opts     []templatex.PushOption
+		expected string
+	}{
+		{"turbo stream defaults", nil, `<turbo-stream action="update" target="components-cart"><template><span>3 items</span></template></turbo-stream>`},
+		{"turbo stream replace", []templatex.PushOption{templatex.WithPushTarget("cart"), templatex.WithPushAction(templatex.PushReplace)}, `<turbo-stream action="replace" target="cart"><template><span>3 items</span></template></turbo-stream>`},
+		{"turbo stream remove", []templatex.PushOption{templatex.WithPushTarget("cart"), templatex.WithPushAction(templatex.PushRemove)}, `<turbo-stream action="remove" target="cart"></turbo-stream>`},
+		{"htmx", []templatex.PushOption{templatex.WithPushFormat(templatex.PushHTMX), templatex.WithPushTarget("cart"), templatex.WithPushAction(templatex.PushAppend)}, `<div id="cart" hx-swap-oob="beforeend"><span>3 items</span></div>`},
+		{"json", []templatex.PushOption{templatex.WithPushFormat(templatex.PushJSON), templatex.WithPushTarget("cart")}, `{"target":"cart","action":"update","html":"\u003cspan\u003e3 items\u003c/span\u003e"}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn := &pushConn{}
+			err := engine.RenderPush(ctx, conn, "components/cart", 3, tt.opts...)
+			require.NoError(t, err)
+			require.Len(t, conn.messages, 1)
+			assert.Equal(t, tt.expected, conn.messages[0])
+		})
+	}
+
+	t.Run("render error", func(t *testing.T) {
+		conn := &pushConn{}
+		err := engine.RenderPush(ctx, conn, "missing", nil)
+		require.Error(t, err)
+		assert.Empty(t, conn.messages)
+	})
+
+	t.Run("write error", func(t *testing.T) {
+		conn := &pushConn{err: io.ErrClosedPipe}
+		err := engine.RenderPush(ctx, conn, "components/cart", 3)
+		require.ErrorIs(t, err, templatex.ErrPushFailed)
+		require.ErrorIs(t, err, io.ErrClosedPipe)
+	})
+}
+
+func TestSplitView(t *testing.T) {
+	ui := fstest.MapFS{
+		"card@v1.gohtml": {Data: []byte(`v1`)},
+		"card@v2.gohtml": {Data: []byte(`v2`)},
+	}
+	pages := fstest.MapFS{
+		"page.gohtml":       {Data: []byte(`page`)},
+		"layout.gohtml":     {Data: []byte(`{{ embed }}`)},
+		"mail@admin.gohtml": {Data: []byte(`mail`)},
+	}
+	engine, err := templatex.NewFS(pages, ".", templatex.WithNamespace("ui", ui, ""))
+	require.NoError(t, err)
+
+	tests := []struct {
+		view    string
+		name    string
+		layouts []string
+	}{
+		{"page", "page", nil},
+		{"page@layout", "page", []string{"layout"}},
+		{"page@app_layout@base_layout", "page", []string{"app_layout", "base_layout"}},
+		{"mail@admin", "mail@admin", nil},
+		{"mail@admin@layout", "mail@admin", []string{"layout"}},
+		{"ui.card@v1@layout", "ui.card@v1", []string{"layout"}},
+		{"ui.card@layout", "ui.card", []string{"layout"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.view, func(t *testing.T) {
+			name, layouts := engine.SplitView(tt.view)
+			assert.Equal(t, tt.name, name)
+			assert.Equal(t, tt.layouts, layouts)
+		})
+	}
+}
+
+type testFuncPack struct {
+	name    string
+	funcs   template.FuncMap
+	initErr error
+	engine  *templatex.Engine
+}
+
+func (p *testFuncPack) Name() string            { return p.name }
+func (p *testFuncPack) Funcs() template.FuncMap { return p.funcs }
+func (p *testFuncPack) Init(e *templatex.Engine) error {
+	p.engine = e
+	return p.initErr
+}
+
+func TestFuncPacks(t *testing.T) {
+	pages := fstest.MapFS{"page.gohtml": {Data: []byte(`{{ markdown "*hi*" }} {{ qr "x" }}`)}}
+
+	md := &testFuncPack{name: "markdown", funcs: template.FuncMap{"markdown": func(s string) string { return "md:" + s }}}
+	qr := &testFuncPack{name: "qr", funcs: template.FuncMap{"qr": func(s string) string { return "qr:" + s }}}
+	engine, err := templatex.NewFS(pages, ".", templatex.WithFuncPacks(md, qr))
+	require.NoError(t, err)
+	assert.Same(t, engine, md.engine)
+
+	out, err := engine.RenderString(context.Background(), "page", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "md:*hi* qr:x", out)
+
+	cfg := engine.Config()
+	assert.Equal(t, []string{"markdown", "qr"}, cfg.FuncPacks)
+	assert.Equal(t, []string{"markdown", "qr"}, cfg.CustomFuncs)
+
+	// Custom functions take precedence
+	engine, err = templatex.NewFS(pages, ".", templatex.WithFuncPacks(md, qr), templatex.WithFunc("qr", func(s string) string { return "custom:" + s }))
+	require.NoError(t, err)
+	out, err = engine.RenderString(context.Background(), "page", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "md:*hi* custom:x", out)
+
+	// Init errors
+	failing := &testFuncPack{name: "failing", initErr: errors.New("missing API key")}
+	_, err = templatex.NewFS(pages, ".", templatex.WithFuncPacks(failing))
+	require.ErrorIs(t, err, templatex.ErrFuncPackFailed)
+	assert.Contains(t, err.Error(), "pack failing: missing API key")
+
+	// Packs defining the same function
+	other := &testFuncPack{name: "other", funcs: template.FuncMap{"qr": func(s string) string { return s }}}
+	_, err = templatex.NewFS(pages, ".", templatex.WithFuncPacks(qr, other))
+	require.ErrorIs(t, err, templatex.ErrFuncPackFailed)
+	assert.Contains(t, err.Error(), "function qr is defined by packs qr and other")
+
+	// Pack functions replacing built-in ones require an override
+	upper := &testFuncPack{name: "strings", funcs: template.FuncMap{"upper": func(s string) string { return s }}}
+	pages = fstest.MapFS{"page.gohtml": {Data: []byte(`{{ upper "x" }}`)}}
+	_, err = templatex.NewFS(pages, ".", templatex.WithFuncPacks(upper))
+	require.ErrorIs(t, err, templatex.ErrBuiltinFuncOverride)
+	engine, err = templatex.NewFS(pages, ".", templatex.WithFuncPacks(upper), templatex.WithFuncOverride("upper"))
+	require.NoError(t, err)
+	out, err = engine.RenderString(context.Background(), "page", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "x", out)
+}
+
+func TestHandler(t *testing.T) {
+	engine, err := templatex.NewFS(fstest.MapFS{
+		"pages/about.gohtml":   {Data: []byte(`<h1>About{{ with . }} {{ .Name }}{{ end }}</h1>`)},
+		"base_layout.gohtml":   {Data: []byte(`<html>{{ embed }}</html>`)},
+		"pages/broken.gohtml":  {Data: []byte(`{{ template "missing" }}`)},
+		"pages/pricing.gohtml": {Data: []byte(`{{ .Plan }}`)},
+	}, ".", templatex.WithLogger(slog.New(slog.NewTextHandler(io.Discard, nil))))
+	require.NoError(t, err)
+
+	rec := httptest.NewRecorder()
+	engine.Handler("pages/about", "base_layout").ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/about", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "<html><h1>About</h1></html>", rec.Body.String())
+	assert.NotEmpty(t, rec.Header().Get("ETag"))
+
+	rec = httptest.NewRecorder()
+	handler := engine.HandlerWithData("pages/about", func(r *http.Request) (any, error) {
+		return map[string]string{"Name": r.URL.Query().Get("name")}, nil
+	})
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/about?name=John", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "<h1>About John</h1>", rec.Body.String())
+
+	rec = httptest.NewRecorder()
+	handler = engine.HandlerWithData("pages/pricing", func(*http.Request) (any, error) {
+		return nil, errors.New("database is down")
+	})
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/pricing", nil))
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+
+	rec = httptest.NewRecorder()
+	engine.Handler("pages/broken").ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/broken", nil))
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+	assert.NotContains(t, rec.Body.String(), "missing")
+}
+
+func TestNewMemory(t *testing.T) {
+	engine, err := templatex.NewMemory(map[string]string{
+		"pages/home.gohtml":   `<h1>{{ .Title }}</h1>`,
+		"/base_layout.gohtml": `<html>{{ embed }}</html>`,
+		`partials\nav.gohtml`: `<nav></nav>`,
+	})
+	require.NoError(t, err)
+
+	out, err := engine.RenderString(context.Background(), "pages/home", map[string]string{"Title": "Home"}, "base_layout")
+	require.NoError(t, err)
+	assert.Equal(t, "<html><h1>Home</h1></html>", out)
+
+	out, err = engine.RenderString(context.Background(), "partials/nav", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "<nav></nav>", out)
+
+	_, err = templatex.NewMemory(map[string]string{"page.gohtml": `{{ if }}`})
+	require.ErrorIs(t, err, templatex.ErrTemplateParsingFailed)
+}
+
+func TestCacheEviction(t *testing.T) {
+	pages := fstest.MapFS{"page.gohtml": {Data: []byte(`{{ .ID }}:{{ .Secret }}`)}}
+	ctx := context.Background()
+
+	render := func(t *testing.T, engine *templatex.Engine, id, secret string) string {
+		t.Helper()
+		out, err := engine.RenderString(ctx, "page", keyedPage{ID: id, Secret: secret})
+		require.NoError(t, err)
+		return out
+	}
+
+	t.Run("max entries", func(t *testing.T) {
+		engine, err := templatex.NewFS(pages, ".", templatex.WithCacheMaxEntries(2))
+		require.NoError(t, err)
+
+		render(t, engine, "1", "a")
+		render(t, engine, "2", "a")
+		assert.Equal(t, "1:a", render(t, engine, "1", "b")) // cached, now most recently used
+		render(t, engine, "3", "a")                         // evicts 2
+		assert.Equal(t, 2, engine.Report().CachedRenders)
+
+		assert.Equal(t, "1:a", render(t, engine, "1", "c"))
+		assert.Equal(t, "2:c", render(t, engine, "2", "c"))
+	})
+
+	t.Run("TTL", func(t *testing.T) {
+		now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+		engine, err := templatex.NewFS(pages, ".",
+			templatex.WithCacheTTL(time.Minute),
+			templatex.WithClock(func() time.Time { return now }),
+		)
+		require.NoError(t, err)
+
+		render(t, engine, "1", "a")
+		now = now.Add(30 * time.Second)
+		assert.Equal(t, "1:a", render(t, engine, "1", "b"))
+		render(t, engine, "2", "a")
+
+		now = now.Add(31 * time.Second)
+		assert.Equal(t, "1:c", render(t, engine, "1", "c"))
+
+		// Expired renders that are never requested again are swept
+		now = now.Add(2 * time.Minute)
+		render(t, engine, "3", "a")
+		assert.Equal(t, 1, engine.Report().CachedRenders)
+	})
+
+	t.Run("evicted renders are removed from tags", func(t *testing.T) {
+		engine, err := templatex.NewFS(pages, ".",
+			templatex.WithCacheMaxEntries(1),
+			templatex.WithCacheTags(func(_ string, binding any) []string {
+				return []string{"page:" + binding.(keyedPage).ID}
+			}),
+		)
+		require.NoError(t, err)
+
+		render(t, engine, "1", "a")
+		render(t, engine, "2", "a")
+		assert.Equal(t, 0, engine.InvalidateByTag("page:1"))
+		assert.Equal(t, 1, engine.InvalidateByTag("page:2"))
+	})
+
+	t.Run("config", func(t *testing.T) {
+		engine, err := templatex.NewFS(pages, ".", templatex.WithCacheTTL(time.Hour), templatex.WithCacheMaxEntries(100))
+		require.NoError(t, err)
+		cfg := engine.Config()
+		assert.Equal(t, time.Hour, cfg.CacheTTL)
+		assert.Equal(t, 100, cfg.CacheMaxEntries)
+	})
+}
+
+func TestParseString(t *testing.T) {
+	engine, err := templatex.NewMemory(map[string]string{"base_layout.gohtml": `<html>{{ embed }}</html>`})
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	require.NoError(t, engine.ParseString("pages/home.gohtml", `<h1>{{ .Title }}</h1>`))
+	out, err := engine.RenderString(ctx, "pages/home", map[string]string{"Title": "Home"}, "base_layout")
+	require.NoError(t, err)
+	assert.Equal(t, "<html><h1>Home</h1></html>", out)
+
+	// Registering replaces the template and clears the cache
+	require.NoError(t, engine.ParseString("pages/home", `<h2>{{ .Title }}</h2>`))
+	out, err = engine.RenderString(ctx, "pages/home", map[string]string{"Title": "Home"}, "base_layout")
+	require.NoError(t, err)
+	assert.Equal(t, "<html><h2>Home</h2></html>", out)
+
+	require.NoError(t, engine.ParseMap(map[string]string{
+		"emails/welcome": `Welcome, {{ template "emails/name" . }}`,
+		"emails/name":    `{{ .Name }}`,
+	}))
+	out, err = engine.RenderString(ctx, "emails/welcome", map[string]string{"Name": "John"})
+	require.NoError(t, err)
+	assert.Equal(t, "Welcome, John", out)
+
+	// Nothing is registered if parsing fails
+	err = engine.ParseMap(map[string]string{"valid": `ok`, "invalid": `{{ if }}`})
+	require.ErrorIs(t, err, templatex.ErrTemplateParsingFailed)
+	_, err = engine.RenderString(ctx, "valid", nil)
+	require.ErrorIs(t, err, templatex.ErrTemplateNotFound)
+
+	// Registered templates are kept on reload
+	require.NoError(t, engine.Reload())
+	out, err = engine.RenderString(ctx, "emails/name", map[string]string{"Name": "Jane"})
+	require.NoError(t, err)
+	assert.Equal(t, "Jane", out)
+}
+
+func TestPublishWindows(t *testing.T) {
+	now := time.Date(2024, 11, 30, 12, 0, 0, 0, time.UTC)
+	engine, err := templatex.NewMemory(map[string]string{
+		"banners/promo.gohtml":   "---\npublish_at: 2024-11-29T00:00:00Z\nunpublish_at: 2024-12-02\nfallback: banners/default\n---\n<b>Black Friday {{ .Discount }}%</b>",
+		"banners/default.gohtml": `<b>Welcome</b>`,
+		"pages/xmas.gohtml":      "---\npublish_at: 2024-12-01T00:00:00Z\n---\n<h1>Christmas</h1>",
+		"pages/home.gohtml":      `<main>{{ template "banners/promo" . }}</main>`,
+		"pages/about.gohtml":     "---\n---\n<h1>About</h1>",
+		"pages/rule.gohtml":      "---\nSee below\n---\n<p>x</p>",
+		"pages/open.gohtml":      "---\n<p>x</p>",
+	}, templatex.WithClock(func() time.Time { return now }))
+	require.NoError(t, err)
+	ctx := context.Background()
+	data := map[string]int{"Discount": 30}
+
+	out, err := engine.RenderString(ctx, "pages/home", data)
+	require.NoError(t, err)
+	assert.Equal(t, "<main><b>Black Friday 30%</b></main>", out)
+
+	out, err = engine.RenderString(ctx, "pages/about", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "<h1>About</h1>", out)
+
+	// Blocks without known keys aren't front matter, e.g. horizontal rules in text
+	out, err = engine.RenderString(ctx, "pages/rule", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "---\nSee below\n---\n<p>x</p>", out)
+
+	out, err = engine.RenderString(ctx, "pages/open", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "---\n<p>x</p>", out)
+
+	_, err = engine.RenderString(ctx, "pages/xmas", nil)
+	require.ErrorIs(t, err, templatex.ErrTemplateNotPublished)
+
+	// Cached renders change when windows open or close
+	now = time.Date(2024, 12, 2, 0, 0, 0, 0, time.UTC)
+	out, err = engine.RenderString(ctx, "pages/home", data)
+	require.NoError(t, err)
+	assert.Equal(t, "<main><b>Welcome</b></main>", out)
+
+	out, err = engine.RenderString(ctx, "banners/promo", data)
+	require.NoError(t, err)
+	assert.Equal(t, "<b>Welcome</b>", out)
+
+	out, err = engine.RenderString(ctx, "pages/xmas", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "<h1>Christmas</h1>", out)
+
+	tmpl, err := template.New("test").Funcs(engine.GetFuncMap()).Parse(`{{ isPublished "banners/promo" }} {{ isPublished "pages/xmas" }} {{ isPublished "pages/home" }}`)
+	require.NoError(t, err)
+	var buf bytes.Buffer
+	require.NoError(t, tmpl.Execute(&buf, nil))
+	assert.Equal(t, "false true true", buf.String())
+
+	// Invalid front matter
+	for name, src := range map[string]string{
+		"unknown field": "---\npublish: 2024-12-01\ntitle: Promo\n---\nx",
+		"invalid yaml":  "---\ntitle: [Promo\n---\nx",
+		"not closed":    "---\npublish_at: 2024-12-01\nx",
+		"empty window":  "---\npublish_at: 2024-12-02\nunpublish_at: 2024-12-01\n---\nx",
+		"define blocks": "---\npublish_at: 2024-12-01\n---\n{{ define \"x\" }}x{{ end }}",
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := templatex.NewMemory(map[string]string{"page.gohtml": src})
+			require.ErrorIs(t, err, templatex.ErrTemplateParsingFailed)
+		})
+	}
+}
+
+type viewOrder struct {
+	Total     float64   `view:"format=money,currency=USD"`
+	Refund    float64   `view:"format=money,currency=EUR"`
+	Fee       float64   `view:"format=money,currency=CHF,decimals=0"`
+	Items     int       `view:"format=number"`
+	Discount  float64   `view:"format=percent,decimals=1"`
+	CreatedAt time.Time `view:"format=date,layout=DateOnly"`
+	ShippedAt time.Time `view:"format=date"`
+	UpdatedAt time.Time `view:"format=humanize"`
+	Note      string
+	Broken    int `view:"format=stars"`
+}
+
+func TestFmtField(t *testing.T) {
+	now := time.Date(2024, 12, 31, 12, 0, 0, 0, time.UTC)
+	engine, err := templatex.NewMemory(map[string]string{"page.gohtml": `x`}, templatex.WithClock(func() time.Time { return now }))
+	require.NoError(t, err)
+
+	order := &viewOrder{
+		Total:     1234.5,
+		Refund:    -20,
+		Fee:       15.4,
+		Items:     12000,
+		Discount:  0.125,
+		CreatedAt: now,
+		ShippedAt: now,
+		UpdatedAt: now.Add(-2 * time.Hour),
+		Note:      "Leave at the door",
+	}
+	data := map[string]any{"Order": order}
+
+	tests := []struct {
+		template string
+		expected string
+	}{
+		{`{{ fmtField .Order "Total" }}`, "$1,234.50"},
+		{`{{ fmtField .Order "Refund" }}`, "-€20.00"},
+		{`{{ fmtField .Order "Fee" }}`, "CHF 15"},
+		{`{{ fmtField .Order "Items" }}`, "12,000"},
+		{`{{ fmtField .Order "Discount" }}`, "12.5%"},
+		{`{{ fmtField . "Order.CreatedAt" }}`, "2024-12-31"},
+		{`{{ fmtField . "Order.ShippedAt" }}`, "Dec 31, 2024"},
+		{`{{ fmtField .Order "UpdatedAt" }}`, "2 hours ago"},
+		{`{{ fmtField .Order "Note" }}`, "Leave at the door"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.template, func(t *testing.T) {
+			tmpl, err := template.New("test").Funcs(engine.GetFuncMap()).Parse(tt.template)
+			require.NoError(t, err)
+			var buf bytes.Buffer
+			require.NoError(t, tmpl.Execute(&buf, data))
+			assert.Equal(t, tt.expected, buf.String())
+		})
+	}
+
+	for _, src := range []string{`{{ fmtField .Order "Missing" }}`, `{{ fmtField .Order "Broken" }}`} {
+		tmpl, err := template.New("test").Funcs(engine.GetFuncMap()).Parse(src)
+		require.NoError(t, err)
+		require.Error(t, tmpl.Execute(io.Discard, data), src)
+	}
+}
+
+func TestPurge(t *testing.T) {
+	engine, err := templatex.NewMemory(map[string]string{
+		"pages/home.gohtml":  `home:{{ .ID }}`,
+		"pages/about.gohtml": `about:{{ .ID }}`,
+		"layout.gohtml":      `[{{ embed }}]`,
+	}, templatex.WithHardCache(true), templatex.WithLayoutCache(true))
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	render := func(name string, id int, layouts ...string) string {
+		out, err := engine.RenderString(ctx, name, map[string]int{"ID": id}, layouts...)
+		require.NoError(t, err)
+		return out
+	}
+
+	assert.Equal(t, "home:1", render("pages/home", 1))
+	assert.Equal(t, "about:1", render("pages/about", 1))
+	assert.Equal(t, "[about:1]", render("pages/about", 1, "layout"))
+	assert.Equal(t, "home:1", render("pages/home", 2)) // hard cache ignores the binding
+	assert.Equal(t, 1, engine.Report().CachedChains)
+
+	assert.Equal(t, 1, engine.PurgeTemplate("pages/home.gohtml"))
+	assert.Equal(t, "home:2", render("pages/home", 2))
+	assert.Equal(t, "about:1", render("pages/about", 2))
+
+	// Renders using the template as a layout are purged too
+	assert.Equal(t, 1, engine.PurgeTemplate("layout"))
+	assert.Equal(t, "[about:2]", render("pages/about", 2, "layout"))
+	assert.Equal(t, 0, engine.PurgeTemplate("missing"))
+
+	engine.PurgeCache()
+	assert.Equal(t, 0, engine.Report().CachedRenders)
+	assert.Equal(t, "about:2", render("pages/about", 2))
+
+	engine.PurgeLayoutCache()
+	assert.Equal(t, 0, engine.Report().CachedChains)
+}
+
+func TestAuditUnsafeSinks(t *testing.T) {
+	engine, err := templatex.NewMemory(map[string]string{
+		"pages/profile.gohtml": `<h1>{{ .Name }}</h1>
+<div>{{ htmlSafe .Bio }}</div>
+{{ htmlSafe "<hr>" }}{{ "<br>" | htmlSafe }}
+{{ if .Admin }}{{ .Notes | htmlSafe }}{{ end }}
+<script>var cfg = {{ toJS .Config }};</script>`,
+		"partials.gohtml":   `{{ define "partials/card" }}{{ range .Cards }}{{ htmlSafe (printf "<b>%s</b>" .Title) }}{{ end }}{{ end }}`,
+		"pages/safe.gohtml": `{{ htmlSafe ("<a>") }}{{ shout .Name }}{{ htmlSafe (print "<a>" .Name) }}{{ htmlSafe (sanitize .Name) }}{{ .Name | sanitize | htmlSafe }}`,
+	},
+		templatex.WithFunc("toJS", func(v any) template.JS { return template.JS(fmt.Sprint(v)) }),
+		templatex.WithFunc("shout", func(s string) string { return s }),
+	)
+	require.NoError(t, err)
+
+	// Audit works on templates that were already rendered (and escaped)
+	_, err = engine.RenderString(context.Background(), "pages/profile", map[string]any{"Bio": "<p>bio</p>", "Admin": true, "Notes": "x"})
+	require.NoError(t, err)
+
+	findings := engine.AuditUnsafeSinks()
+	require.Len(t, findings, 5)
+	assert.Equal(t, templatex.Finding{Template: "pages/profile", Location: "pages/profile:2:8", Func: "htmlSafe", Pipeline: "htmlSafe .Bio"}, findings[0])
+	assert.Equal(t, templatex.Finding{Template: "pages/profile", Location: "pages/profile:4:27", Func: "htmlSafe", Pipeline: ".Notes | htmlSafe"}, findings[1])
+	assert.Equal(t, templatex.Finding{Template: "pages/profile", Location: "pages/profile:5:21", Func: "toJS", Pipeline: "toJS .Config"}, findings[2])
+	assert.Equal(t, templatex.Finding{Template: "pages/safe", Location: "pages/safe:1:42", Func: "htmlSafe", Pipeline: `htmlSafe (print "<a>" .Name)`}, findings[3])
+	assert.Equal(t, templatex.Finding{Template: "partials/card", Location: "partials.gohtml:1:49", Func: "htmlSafe", Pipeline: `htmlSafe (printf "<b>%s</b>" .Title)`}, findings[4])
+}
+
+func TestCacheStats(t *testing.T) {
+	type lookup struct {
+		name string
+		hit  bool
+	}
+	var lookups []lookup
+	engine, err := templatex.NewMemory(map[string]string{"page.gohtml": `{{ .ID }}`},
+		templatex.WithCacheMaxEntries(2),
+		templatex.WithCacheCallback(func(_ context.Context, name string, hit bool) {
+			lookups = append(lookups, lookup{name, hit})
+		}),
+	)
+	require.NoError(t, err)
+
+	assert.Equal(t, templatex.CacheStats{}, engine.CacheStats())
+	assert.Zero(t, engine.CacheStats().HitRatio())
+
+	for _, id := range []string{"1", "1", "2", "3", "1"} {
+		_, err := engine.RenderString(context.Background(), "page", keyedPage{ID: id})
+		require.NoError(t, err)
+	}
+
+	stats := engine.CacheStats()
+	assert.Equal(t, int64(1), stats.Hits)
+	assert.Equal(t, int64(4), stats.Misses)
+	assert.Equal(t, int64(2), stats.Evictions)
+	assert.Equal(t, 2, stats.Entries)
+	assert.Positive(t, stats.Bytes)
+	assert.InDelta(t, 0.2, stats.HitRatio(), 0.001)
+	assert.Equal(t, []lookup{{"page", false}, {"page", true}, {"page", false}, {"page", false}, {"page", false}}, lookups)
+
+	// Purged renders are not evictions
+	assert.Equal(t, 2, engine.PurgeTemplate("page"))
+	stats = engine.CacheStats()
+	assert.Equal(t, int64(2), stats.Evictions)
+	assert.Zero(t, stats.Entries)
+	assert.Zero(t, stats.Bytes)
+}
+
+func TestStrictSinks(t *testing.T) {
+	files := map[string]string{
+		"raw.gohtml":       `{{ htmlSafe .HTML }}`,
+		"sanitized.gohtml": `{{ htmlSafe (sanitize .HTML) }}`,
+	}
+	comment := `<p onclick="x()">Hi <a href="javascript:alert(1)">there</a> <a href="/u/1">you</a><script>alert(1)</script><b>!</b><blink>ok</blink></p>`
+
+	t.Run("sanitize", func(t *testing.T) {
+		engine, err := templatex.NewMemory(files)
+		require.NoError(t, err)
+
+		out, err := engine.RenderString(context.Background(), "sanitized", map[string]any{"HTML": comment})
+		require.NoError(t, err)
+		assert.Equal(t, `<p>Hi <a rel="nofollow noopener noreferrer">there</a> <a href="/u/1" rel="nofollow noopener noreferrer">you</a><b>!</b>ok</p>`, out)
+
+		out, err = engine.RenderString(context.Background(), "sanitized", map[string]any{"HTML": `1 < 2 & <img src="data:x" alt="a">`})
+		require.NoError(t, err)
+		assert.Equal(t, `1 &lt; 2 &amp; <img alt="a">`, out)
+
+		// Strings are accepted when strict mode is off
+		out, err = engine.RenderString(context.Background(), "raw", map[string]any{"HTML": "<i>x</i>"})
+		require.NoError(t, err)
+		assert.Equal(t, "<i>x</i>", out)
+	})
+
+	t.Run("strict", func(t *testing.T) {
+		engine, err := templatex.NewMemory(files, templatex.WithStrictSinks(true))
+		require.NoError(t, err)
+
+		_, err = engine.RenderString(context.Background(), "raw", map[string]any{"HTML": "<i>x</i>"})
+		assert.ErrorIs(t, err, templatex.ErrUntrustedHTML)
+
+		for _, v := range []any{templatex.TrustHTML("<i>x</i>"), template.HTML("<i>x</i>")} {
+			out, err := engine.RenderString(context.Background(), "raw", map[string]any{"HTML": v})
+			require.NoError(t, err)
+			assert.Equal(t, "<i>x</i>", out)
+		}
+
+		out, err := engine.RenderString(context.Background(), "sanitized", map[string]any{"HTML": "<i>x</i><script>y</script>"})
+		require.NoError(t, err)
+		assert.Equal(t, "<i>x</i>", out)
+	})
+}
+
+func TestConcurrentRenderDeduplication(t *testing.T) {
+	var renders, lookups atomic.Int32
+	release := make(chan struct{})
+	engine, err := templatex.NewMemory(map[string]string{"page.gohtml": `{{ slow }}{{ .ID }}`},
+		templatex.WithFunc("slow", func() string {
+			renders.Add(1)
+			<-release
+			return ""
+		}),
+		templatex.WithCacheCallback(func(context.Context, string, bool) {
+			lookups.Add(1)
+		}),
+	)
+	require.NoError(t, err)
+
+	const callers = 10
+	var wg sync.WaitGroup
+	results := make([]string, callers)
+	for i := range callers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			out, err := engine.RenderString(context.Background(), "page", keyedPage{ID: "1"})
+			assert.NoError(t, err)
+			results[i] = out
+		}()
+	}
+
+	// Release the render once all callers missed the cache
+	require.Eventually(t, func() bool { return lookups.Load() == callers }, time.Second, time.Millisecond)
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	assert.Equal(t, int32(1), renders.Load())
+	for _, out := range results {
+		assert.Equal(t, "1", out)
+	}
+	assert.Equal(t, int64(callers), engine.CacheStats().Misses)
+}
+
+func TestConcurrentRenderPanic(t *testing.T) {
+	var lookups atomic.Int32
+	release := make(chan struct{})
+	engine, err := templatex.NewMemory(map[string]string{"page.gohtml": `{{ slow }}{{ .ID }}`},
+		templatex.WithFunc("slow", func() string {
+			<-release
+			time.Sleep(2 * time.Millisecond)
+			return ""
+		}),
+		templatex.WithCacheCallback(func(context.Context, string, bool) {
+			lookups.Add(1)
+		}),
+		templatex.WithSlowRenderThreshold(time.Millisecond, func(context.Context, templatex.SlowRender) {
+			panic("slow render callback")
+		}),
+	)
+	require.NoError(t, err)
+
+	// All callers of the shared render panic, none of them waits forever
+	const callers = 5
+	var wg sync.WaitGroup
+	var panics atomic.Int32
+	for range callers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() {
+				if recover() != nil {
+					panics.Add(1)
+				}
+			}()
+			_, _ = engine.RenderString(context.Background(), "page", keyedPage{ID: "1"})
+		}()
+	}
+
+	require.Eventually(t, func() bool { return lookups.Load() == callers }, time.Second, time.Millisecond)
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	assert.Equal(t, int32(callers), panics.Load())
+}
+
+func TestReloadDuringRender(t *testing.T) {
+	started, release := make(chan struct{}), make(chan struct{})
+	var once sync.Once
+	engine, err := templatex.NewMemory(map[string]string{"page.gohtml": `{{ slow }}v1`},
+		templatex.WithHardCache(true),
+		templatex.WithFunc("slow", func() string {
+			once.Do(func() {
+				close(started)
+				<-release
+			})
+			return ""
+		}),
+	)
+	require.NoError(t, err)
+
+	done := make(chan string)
+	go func() {
+		out, err := engine.RenderString(context.Background(), "page", nil)
+		assert.NoError(t, err)
+		done <- out
+	}()
+
+	// The render started before the reload completes after it
+	<-started
+	require.NoError(t, engine.ParseString("page", `{{ slow }}v2`))
+	close(release)
+	assert.Equal(t, "v1", <-done)
+
+	// Its output isn't cached, so the reloaded template is rendered
+	out, err := engine.RenderString(context.Background(), "page", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "v2", out)
+}
+
+func TestSourceVerification(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+
+	fsys := fstest.MapFS{
+		"templates/page.gohtml":   {Data: []byte(`<p>{{ .Name }}</p>`)},
+		"templates/layout.gohtml": {Data: []byte(`<main>{{ embed }}</main>`)},
+	}
+	manifest, sig, err := templatex.SignSources(fsys, "templates", priv)
+	require.NoError(t, err)
+	fsys["templates/"+templatex.SourceManifestFile] = &fstest.MapFile{Data: manifest}
+	fsys["templates/"+templatex.SourceSignatureFile] = &fstest.MapFile{Data: sig}
+
+	engine, err := templatex.NewFS(fsys, "templates", templatex.WithSourceVerification(pub))
+	require.NoError(t, err)
+	assert.True(t, engine.Config().SourceVerification)
+	out, err := engine.RenderString(context.Background(), "page", map[string]any{"Name": "x"}, "layout")
+	require.NoError(t, err)
+	assert.Equal(t, "<main><p>x</p></main>", out)
+
+	t.Run("modified template", func(t *testing.T) {
+		tampered := maps.Clone(fsys)
+		tampered["templates/page.gohtml"] = &fstest.MapFile{Data: []byte(`<p>{{ .Secret }}</p>`)}
+		_, err := templatex.NewFS(tampered, "templates", templatex.WithSourceVerification(pub))
+		assert.ErrorIs(t, err, templatex.ErrSourceVerificationFailed)
+	})
+
+	t.Run("injected template", func(t *testing.T) {
+		tampered := maps.Clone(fsys)
+		tampered["templates/evil.gohtml"] = &fstest.MapFile{Data: []byte(`{{ . }}`)}
+		_, err := templatex.NewFS(tampered, "templates", templatex.WithSourceVerification(pub))
+		assert.ErrorIs(t, err, templatex.ErrSourceVerificationFailed)
+	})
+
+	t.Run("wrong key", func(t *testing.T) {
+		other, _, err := ed25519.GenerateKey(nil)
+		require.NoError(t, err)
+		_, err = templatex.NewFS(fsys, "templates", templatex.WithSourceVerification(other))
+		assert.ErrorIs(t, err, templatex.ErrSourceVerificationFailed)
+	})
+
+	t.Run("missing manifest", func(t *testing.T) {
+		tampered := maps.Clone(fsys)
+		delete(tampered, "templates/"+templatex.SourceSignatureFile)
+		_, err := templatex.NewFS(tampered, "templates", templatex.WithSourceVerification(pub))
+		assert.ErrorIs(t, err, templatex.ErrSourceVerificationFailed)
+	})
+}
+
+func TestCacheKeyFunc(t *testing.T) {
+	type tenantKey struct{}
+	var keys []string
+	engine, err := templatex.NewMemory(map[string]string{
+		"page.gohtml":   `{{ .Title }} {{ .Now }}`,
+		"layout.gohtml": `[{{ embed }}]`,
+	}, templatex.WithCacheKeyFunc(func(ctx context.Context, name string, data any, layouts []string) string {
+		keys = append(keys, name+":"+strings.Join(layouts, ","))
+		// Exclude the volatile Now field, include the tenant
+		return fmt.Sprint(ctx.Value(tenantKey{}), ":", data.(map[string]any)["Title"])
+	}))
+	require.NoError(t, err)
+
+	render := func(tenant, title, now string) string {
+		ctx := context.WithValue(context.Background(), tenantKey{}, tenant)
+		out, err := engine.RenderString(ctx, "page", map[string]any{"Title": title, "Now": now}, "layout")
+		require.NoError(t, err)
+		return out
+	}
+
+	assert.Equal(t, "[a 1]", render("t1", "a", "1"))
+	assert.Equal(t, "[a 1]", render("t1", "a", "2"), "volatile field is excluded from the key")
+	assert.Equal(t, "[a 3]", render("t2", "a", "3"), "tenant is part of the key")
+	assert.Equal(t, "[b 4]", render("t1", "b", "4"))
+	assert.Equal(t, []string{"page:layout", "page:layout", "page:layout", "page:layout"}, keys)
+
+	// Template names are part of the key
+	out, err := engine.RenderString(context.WithValue(context.Background(), tenantKey{}, "t1"), "page", map[string]any{"Title": "a", "Now": "5"})
+	require.NoError(t, err)
+	assert.Equal(t, "a 5", out)
+}
+
+func TestAuthorizer(t *testing.T) {
+	type roleKey struct{}
+	engine, err := templatex.NewMemory(map[string]string{
+		"fragments/stats.gohtml":   "---\nrequires: admin\n---\n<b>{{ .Revenue }}</b>",
+		"admin/users.gohtml":       `users`,
+		"admin/public/help.gohtml": `help`,
+		"pages/dashboard.gohtml":   `<h1>Dashboard</h1>{{ template "fragments/stats" . }}{{ if authorized "admin" }}<a>admin</a>{{ end }}`,
+		"admin_layout.gohtml":      "---\nrequires: admin\n---\n<main>{{ embed }}</main>",
+	},
+		templatex.WithAuthorizer(func(ctx context.Context, permission string) bool {
+			return ctx.Value(roleKey{}) == permission
+		}),
+		templatex.WithDirectoryPermission("admin", "admin"),
+		templatex.WithDirectoryPermission("admin/public", "user"),
+	)
+	require.NoError(t, err)
+
+	admin := context.WithValue(context.Background(), roleKey{}, "admin")
+	user := context.WithValue(context.Background(), roleKey{}, "user")
+	data := map[string]any{"Revenue": 42}
+
+	out, err := engine.RenderString(admin, "pages/dashboard", data)
+	require.NoError(t, err)
+	assert.Equal(t, "<h1>Dashboard</h1><b>42</b><a>admin</a>", out)
+
+	// Included protected templates render nothing, and cached renders aren't shared
+	out, err = engine.RenderString(user, "pages/dashboard", data)
+	require.NoError(t, err)
+	assert.Equal(t, "<h1>Dashboard</h1>", out)
+
+	_, err = engine.RenderString(user, "fragments/stats", data)
+	assert.ErrorIs(t, err, templatex.ErrUnauthorized)
+	_, err = engine.RenderString(user, "admin/users", nil)
+	assert.ErrorIs(t, err, templatex.ErrUnauthorized)
+	_, err = engine.RenderString(user, "pages/dashboard", data, "admin_layout")
+	assert.ErrorIs(t, err, templatex.ErrUnauthorized)
+
+	out, err = engine.RenderString(user, "admin/public/help", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "help", out)
+
+	out, err = engine.RenderString(admin, "fragments/stats", data, "admin_layout")
+	require.NoError(t, err)
+	assert.Equal(t, "<main><b>42</b></main>", out)
+
+	t.Run("no authorizer", func(t *testing.T) {
+		engine, err := templatex.NewMemory(map[string]string{"stats.gohtml": "---\nrequires: admin\n---\nstats"})
+		require.NoError(t, err)
+		_, err = engine.RenderString(admin, "stats", nil)
+		assert.ErrorIs(t, err, templatex.ErrUnauthorized)
+	})
+}
+
+func TestConsent(t *testing.T) {
+	type consentKey struct{}
+	engine, err := templatex.NewMemory(map[string]string{
+		"page.gohtml": `<h1>{{ .Title }}</h1>{{ if consent "analytics" }}<script src="/a.js"></script>{{ end }}`,
+	},
+		templatex.WithHardCache(true),
+		templatex.WithConsentResolver(func(ctx context.Context, category string) bool {
+			granted, _ := ctx.Value(consentKey{}).([]string)
+			return slices.Contains(granted, category)
+		}),
+	)
+	require.NoError(t, err)
+	assert.True(t, engine.Config().Consent)
+
+	consented := context.WithValue(context.Background(), consentKey{}, []string{"analytics"})
+	data := map[string]any{"Title": "Home"}
+
+	// The first render discovers the category and isn't cached, so it can't leak
+	out, err := engine.RenderString(consented, "page", data)
+	require.NoError(t, err)
+	assert.Equal(t, `<h1>Home</h1><script src="/a.js"></script>`, out)
+	assert.Equal(t, 0, engine.CacheStats().Entries)
+
+	out, err = engine.RenderString(context.Background(), "page", data)
+	require.NoError(t, err)
+	assert.Equal(t, "<h1>Home</h1>", out)
+
+	out, err = engine.RenderString(consented, "page", data)
+	require.NoError(t, err)
+	assert.Equal(t, `<h1>Home</h1><script src="/a.js"></script>`, out)
+	assert.Equal(t, 2, engine.CacheStats().Entries)
+
+	t.Run("no resolver", func(t *testing.T) {
+		engine, err := templatex.NewMemory(map[string]string{"page.gohtml": `{{ if consent "analytics" }}tracked{{ end }}`})
+		require.NoError(t, err)
+		out, err := engine.RenderString(consented, "page", nil)
+		require.NoError(t, err)
+		assert.Empty(t, out)
+	})
+}
+
+func TestRenderAllLocales(t *testing.T) {
+	require.NoError(t, ctxi18n.LoadWithDefault(testTranslations, "en"))
+
+	files := map[string]string{
+		"greeting.gohtml": `{{ T "greeting" "name" .Name }}`,
+		"layout.gohtml":   `<title>{{ T "layout.title" }}</title>{{ embed }}`,
+	}
+	engine, err := templatex.NewMemory(files, templatex.WithLocales("en", "es"))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"en", "es"}, engine.Config().Locales)
+
+	out, err := engine.RenderAllLocales(context.Background(), "greeting", map[string]any{"Name": "Ann"}, "layout")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"en": "<title>Test Title</title>Hello, Ann",
+		"es": "<title>Título de Prueba</title>Hola, Ann",
+	}, out)
+
+	_, err = engine.RenderAllLocales(context.Background(), "missing", nil)
+	assert.ErrorIs(t, err, templatex.ErrTemplateNotFound)
+
+	engine, err = templatex.NewMemory(files, templatex.WithLocales("en", "fr"))
+	require.NoError(t, err)
+	_, err = engine.RenderAllLocales(context.Background(), "greeting", nil)
+	assert.ErrorIs(t, err, ctxi18n.ErrMissingLocale)
+
+	engine, err = templatex.NewMemory(files)
+	require.NoError(t, err)
+	_, err = engine.RenderAllLocales(context.Background(), "greeting", nil)
+	assert.ErrorIs(t, err, templatex.ErrNoLocales)
+}
+
+func TestSkipCache(t *testing.T) {
+	var renders int
+	engine, err := templatex.NewMemory(map[string]string{"page.gohtml": `{{ count }}`},
+		templatex.WithHardCache(true),
+		templatex.WithFunc("count", func() int { renders++; return renders }),
+	)
+	require.NoError(t, err)
+
+	render := func(ctx context.Context) string {
+		out, err := engine.RenderString(ctx, "page", nil)
+		require.NoError(t, err)
+		return out
+	}
+
+	assert.False(t, templatex.CacheSkipped(context.Background()))
+	fresh := templatex.SkipCache(context.Background())
+	assert.True(t, templatex.CacheSkipped(fresh))
+
+	assert.Equal(t, "1", render(context.Background()))
+	assert.Equal(t, "1", render(context.Background()))
+	// Fresh renders neither read nor write the cache
+	assert.Equal(t, "2", render(fresh))
+	assert.Equal(t, "3", render(fresh))
+	assert.Equal(t, "1", render(context.Background()))
+
+	stats := engine.CacheStats()
+	assert.Equal(t, int64(2), stats.Hits)
+	assert.Equal(t, int64(1), stats.Misses)
+}
+
+func TestPseudoLocale(t *testing.T) {
+	require.NoError(t, ctxi18n.LoadWithDefault(testTranslations, "en"))
+	ctx, err := ctxi18n.WithLocale(context.Background(), "en")
+	require.NoError(t, err)
+
+	engine, err := templatex.NewMemory(map[string]string{
+		"page.gohtml": `<h1>{{ T "greeting" "name" "Bo" }}</h1><p>Hardcoded</p>`,
+	}, templatex.WithPseudoLocale(true), templatex.WithEnvironment(templatex.EnvProduction))
+	require.NoError(t, err)
+
+	out, err := engine.RenderString(ctx, "page", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "<h1>⟦Ĥéļļö, Ɓö~~~~⟧</h1><p>Hardcoded</p>", out)
+	assert.Contains(t, engine.Report().Warnings, "pseudo-localization is enabled in production")
+}
+
+func TestRenderFuncsDoNotLeakBetweenRenders(t *testing.T) {
+	engine, err := templatex.NewMemory(map[string]string{
+		"page.gohtml": `{{ .Text }}`,
+		"wrap.gohtml": `[{{ embed }}]{{ isActive "/account" }}`,
+	})
+	require.NoError(t, err)
+
+	ctx := templatex.WithRequestPath(context.Background(), "/account")
+
+	// The same template is executed as a layout, then as a page
+	for i := 0; i < 3; i++ {
+		out, err := engine.RenderString(ctx, "page", map[string]any{"Text": "secret"}, "wrap")
+		require.NoError(t, err)
+		assert.Equal(t, "[secret]true", out)
+
+		out, err = engine.RenderString(context.Background(), "wrap", nil)
+		require.NoError(t, err)
+		assert.Equal(t, "[]false", out)
+	}
+}
+
+func TestLocaleCollation(t *testing.T) {
+	require.NoError(t, ctxi18n.LoadWithDefault(testTranslations, "en"))
+
+	type user struct{ Name string }
+	engine, err := templatex.NewMemory(map[string]string{
+		"names.gohtml": `{{ range sortLocale .Names }}{{ . }} {{ end }}`,
+		"users.gohtml": `{{ range sortLocale .Users "Name" }}{{ .Name }} {{ end }}`,
+		"cmp.gohtml":   `{{ compareLocale "ñu" "nube" }}`,
+	})
+	require.NoError(t, err)
+
+	names := []string{"zeta", "ñu", "nube", "árbol"}
+	users := []user{{"zeta"}, {"ñu"}, {"nube"}, {"árbol"}}
+	render := func(locale, name string, data any) string {
+		ctx, err := ctxi18n.WithLocale(context.Background(), locale)
+		require.NoError(t, err)
+		out, err := engine.RenderString(ctx, name, data)
+		require.NoError(t, err)
+		return out
+	}
+
+	// Spanish sorts ñ as a separate letter after n
+	assert.Equal(t, "árbol nube ñu zeta ", render("es", "names", map[string]any{"Names": names}))
+	assert.Equal(t, "árbol ñu nube zeta ", render("en", "names", map[string]any{"Names": names}))
+	assert.Equal(t, "árbol nube ñu zeta ", render("es", "users", map[string]any{"Users": users}))
+	assert.Equal(t, "1", render("es", "cmp", nil))
+	assert.Equal(t, "-1", render("en", "cmp", nil))
+
+	// The input is not modified
+	assert.Equal(t, []string{"zeta", "ñu", "nube", "árbol"}, names)
+
+	_, err = engine.RenderString(context.Background(), "names", map[string]any{"Names": "x"})
+	assert.ErrorIs(t, err, templatex.ErrTemplateExecutionFailed)
+}
+
+func TestFormatUnit(t *testing.T) {
+	engine, err := templatex.NewMemory(map[string]string{
+		"trip.gohtml": `{{ formatUnit .Distance "km" 1 }}, {{ formatUnit .Weight "lb" }}, {{ formatUnit .Temp "°C" }}`,
+		"bad.gohtml":  `{{ formatUnit 1 "parsec" }}`,
+	}, templatex.WithFormatConfig(templatex.FormatConfig{DecimalSeparator: ".", ThousandsSeparator: ",", Decimals: 0}))
+	require.NoError(t, err)
+
+	data := map[string]any{"Distance": 12, "Weight": 10, "Temp": 20}
+	render := func(ctx context.Context) string {
+		out, err := engine.RenderString(ctx, "trip", data)
+		require.NoError(t, err)
+		return out
+	}
+
+	assert.Equal(t, "12.0 km, 5 kg, 20 °C", render(context.Background()))
+	imperial := templatex.WithUnitSystem(context.Background(), templatex.UnitImperial)
+	assert.Equal(t, templatex.UnitImperial, templatex.UnitSystemFromContext(imperial))
+	assert.Equal(t, "7.5 mi, 10 lb, 68 °F", render(imperial))
+
+	_, err = engine.RenderString(context.Background(), "bad", nil)
+	assert.ErrorIs(t, err, templatex.ErrTemplateExecutionFailed)
+}
+
+func TestReadingTime(t *testing.T) {
+	require.NoError(t, ctxi18n.LoadWithDefault(testTranslations, "en"))
+
+	engine, err := templatex.NewMemory(map[string]string{
+		"count.gohtml": `{{ wordCount .Body }}`,
+		"time.gohtml":  `{{ readingTime .Body }}`,
+		"fast.gohtml":  `{{ readingTime .Body 1000 }}`,
+		"bad.gohtml":   `{{ readingTime .Body 0 }}`,
+	}, templatex.WithReadingSpeeds(map[string]int{"es": 100}))
+	require.NoError(t, err)
+
+	render := func(ctx context.Context, name string, body any) string {
+		out, err := engine.RenderString(ctx, name, map[string]any{"Body": body})
+		require.NoError(t, err)
+		return out
+	}
+	bg := context.Background()
+
+	// Markup, scripts and entities are not counted; inline elements don't split words
+	body := template.HTML(`<h1>Hello,&nbsp;world</h1><p>A <b>re</b>read &mdash; of <a href="/x">this</a> page.</p>` +
+		`<script>var ignored = "words";</script><ul><li>one</li><li>two</li></ul>`)
+	assert.Equal(t, "9", render(bg, "count", body))
+	assert.Equal(t, "3", render(bg, "count", "plain text here"))
+	assert.Equal(t, "4", render(bg, "count", "日本語 ok"))
+	assert.Equal(t, "0", render(bg, "count", nil))
+
+	words := template.HTML(strings.Repeat("<p>word word</p>", 250)) // 500 words
+	assert.Equal(t, "3", render(bg, "time", words))                 // 200 wpm
+	assert.Equal(t, "1", render(bg, "fast", words))
+	assert.Equal(t, "0", render(bg, "time", ""))
+
+	locale := func(code string) context.Context {
+		ctx, err := ctxi18n.WithLocale(bg, code)
+		require.NoError(t, err)
+		return ctx
+	}
+	assert.Equal(t, "3", render(locale("en"), "time", words)) // 228 wpm
+	assert.Equal(t, "5", render(locale("es"), "time", words)) // configured 100 wpm
+
+	_, err = engine.RenderString(bg, "bad", map[string]any{"Body": "x"})
+	assert.ErrorIs(t, err, templatex.ErrTemplateExecutionFailed)
+}
+
+func TestShareURL(t *testing.T) {
+	engine, err := templatex.NewMemory(map[string]string{
+		"share.gohtml": `<a href="{{ shareURL .Platform .URL .Title }}">Share</a>`,
+		"plain.gohtml": `{{ shareURL .Platform .URL .Title }}`,
+	})
+	require.NoError(t, err)
+
+	render := func(name, platform, link, title string) (string, error) {
+		return engine.RenderString(context.Background(), name, map[string]string{"Platform": platform, "URL": link, "Title": title})
+	}
+
+	tests := []struct {
+		platform, want string
+	}{
+		{"twitter", "https://x.com/intent/tweet?url=https%3A%2F%2Fexample.com%2Fposts%3Fid%3D1%26a%3Db&text=Tips%20%26%20Tricks%3A%20100%25%20%2B%20more"},
+		{"X", "https://x.com/intent/tweet?url=https%3A%2F%2Fexample.com%2Fposts%3Fid%3D1%26a%3Db&text=Tips%20%26%20Tricks%3A%20100%25%20%2B%20more"},
+		{"facebook", "https://www.facebook.com/sharer/sharer.php?u=https%3A%2F%2Fexample.com%2Fposts%3Fid%3D1%26a%3Db"},
+		{"reddit", "https://www.reddit.com/submit?url=https%3A%2F%2Fexample.com%2Fposts%3Fid%3D1%26a%3Db&title=Tips%20%26%20Tricks%3A%20100%25%20%2B%20more"},
+		{"whatsapp", "https://wa.me/?text=Tips%20%26%20Tricks%3A%20100%25%20%2B%20more%20https%3A%2F%2Fexample.com%2Fposts%3Fid%3D1%26a%3Db"},
+		{"email", "mailto:?body=Tips%20%26%20Tricks%3A%20100%25%20%2B%20more%20https%3A%2F%2Fexample.com%2Fposts%3Fid%3D1%26a%3Db&subject=Tips%20%26%20Tricks%3A%20100%25%20%2B%20more"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.platform, func(t *testing.T) {
+			out, err := render("plain", tt.platform, "https://example.com/posts?id=1&a=b", "Tips & Tricks: 100% + more")
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, html.UnescapeString(out))
+		})
+	}
+
+	// The title is optional, and the URL is kept intact in attributes
+	out, err := render("share", "telegram", "https://example.com/a b", "")
+	require.NoError(t, err)
+	assert.Equal(t, `<a href="https://t.me/share/url?url=https%3A%2F%2Fexample.com%2Fa%2520b">Share</a>`, out)
+
+	_, err = render("plain", "myspace", "https://example.com", "")
+	assert.ErrorIs(t, err, templatex.ErrTemplateExecutionFailed)
+	_, err = render("plain", "twitter", "/relative", "")
+	assert.ErrorIs(t, err, templatex.ErrTemplateExecutionFailed)
+}
+
+func TestAbsURL(t *testing.T) {
+	sources := map[string]string{
+		"og.gohtml":   `<meta property="og:image" content="{{ absURL .Image }}"><link rel="canonical" href="{{ canonical }}">`,
+		"feed.gohtml": `{{ absURL "/feed.xml?page=2#top" }} {{ absURL "https://cdn.example.net/a.png" }}`,
+	}
+
+	engine, err := templatex.NewMemory(sources, templatex.WithBaseURL("https://example.com/blog/"))
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.com/blog/", engine.Config().BaseURL)
+
+	ctx := templatex.WithRequestPath(context.Background(), "/blog/posts/hello")
+	out, err := engine.RenderString(ctx, "og", map[string]string{"Image": "img/cover.png"})
+	require.NoError(t, err)
+	assert.Equal(t, `<meta property="og:image" content="https://example.com/blog/img/cover.png"><link rel="canonical" href="https://example.com/blog/posts/hello">`, out)
+
+	out, err = engine.RenderString(context.Background(), "feed", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.com/blog/feed.xml?page=2#top https://cdn.example.net/a.png", out)
+
+	// Without a base URL the request host set by the middleware is used
+	engine, err = templatex.NewMemory(sources)
+	require.NoError(t, err)
+
+	var got string
+	handler := templatex.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got, err = engine.RenderString(r.Context(), "og", map[string]string{"Image": "/cover.png"})
+	}))
+	req := httptest.NewRequest(http.MethodGet, "/posts/hello?utm_source=x", nil)
+	req.Host = "example.org"
+	req.Header.Set("X-Forwarded-Proto", "https")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	require.NoError(t, err)
+	assert.Equal(t, `<meta property="og:image" content="https://example.org/cover.png"><link rel="canonical" href="https://example.org/posts/hello">`, got)
+
+	// Renders for other hosts aren't served from the cache
+	req.Host = "example.net"
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	require.NoError(t, err)
+	assert.Contains(t, got, "https://example.net/cover.png")
+
+	_, err = engine.RenderString(context.Background(), "feed", nil)
+	assert.ErrorIs(t, err, templatex.ErrTemplateExecutionFailed)
+
+	_, err = templatex.NewMemory(sources, templatex.WithBaseURL("example.com"))
+	assert.ErrorIs(t, err, templatex.ErrInvalidBaseURL)
+}
+
+func TestRenderEmail(t *testing.T) {
+	engine, err := templatex.NewMemory(map[string]string{
+		"emails/layout.gohtml":          `<html><head><title>Layout title</title><style>p{color:red}</style></head><body>{{ embed }}</body></html>`,
+		"emails/welcome.gohtml":         `<h1>Welcome, {{ .Name }}!</h1><p>Thanks for   joining.<br>Next steps:</p><ol><li>Verify</li><li>Explore</li></ol><p><a href="{{ .Link }}">Get started</a></p>`,
+		"emails/welcome.subject.gohtml": "\n  Welcome to {{ .Site }}, {{ .Name }}\n",
+		"emails/welcome.txt":            `Hi {{ .Name }} & welcome to {{ .Site }}: {{ .Link }}`,
+		"emails/reset.gohtml": `<html><head><title>Reset &amp; recover</title></head><body><p>Hello</p><ul><li><a href="https://example.com/reset?a=1&amp;b=2">Reset</a></li><li><a href="https://example.com">https://example.com</a></li></ul><table><tr><td>A</td><td>B</td></tr></table>` +
+			"<pre>  x\n  y</pre></body></html>",
+	}, templatex.WithEmailText(true))
+	require.NoError(t, err)
+
+	data := map[string]string{"Name": "Ann & Bob", "Site": "Acme", "Link": "https://example.com/start?x=1&y=2"}
+	email, err := engine.RenderEmail(context.Background(), "emails/welcome", data, "emails/layout")
+	require.NoError(t, err)
+	assert.Equal(t, "Welcome to Acme, Ann & Bob", email.Subject)
+	assert.Contains(t, email.HTML, "<h1>Welcome, Ann &amp; Bob!</h1>")
+	assert.Contains(t, email.HTML, "<title>Layout title</title>")
+	assert.Equal(t, "Hi Ann & Bob & welcome to Acme: https://example.com/start?x=1&y=2", email.Text)
+
+	// The subject falls back to the title and the text is derived from the HTML
+	email, err = engine.RenderEmail(context.Background(), "emails/reset", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "Reset & recover", email.Subject)
+	assert.Equal(t, "Hello\n\n- Reset (https://example.com/reset?a=1&b=2)\n- https://example.com\n\nA B\n\n  x\n  y", email.Text)
+
+	// Templates without a plaintext sibling get a derived text part
+	engine, err = templatex.NewMemory(map[string]string{
+		"welcome.gohtml": `<h1>Welcome, {{ .Name }}!</h1><p>Thanks for   joining.<br>Next steps:</p><ol><li>Verify</li><li><b>Ex</b>plore</li></ol><p><a href="{{ .Link }}">Get started</a></p>`,
+	})
+	require.NoError(t, err)
+	email, err = engine.RenderEmail(context.Background(), "welcome", data)
+	require.NoError(t, err)
+	assert.Empty(t, email.Subject)
+	assert.Equal(t, "Welcome, Ann & Bob!\n\nThanks for joining.\nNext steps:\n\n1. Verify\n2. Explore\n\nGet started (https://example.com/start?x=1&y=2)", email.Text)
+
+	_, err = engine.RenderEmail(context.Background(), "missing", nil)
+	assert.ErrorIs(t, err, templatex.ErrTemplateNotFound)
+
+	_, err = templatex.NewMemory(map[string]string{"a.gohtml": "a", "a.txt": "{{ .Broken"}, templatex.WithEmailText(true))
+	assert.ErrorIs(t, err, templatex.ErrTemplateParsingFailed)
+
+	// Without WithEmailText, .txt files aren't plaintext templates
+	engine, err = templatex.NewMemory(map[string]string{"a.gohtml": "<p>HTML</p>", "a.txt": "{{ .Broken"})
+	require.NoError(t, err)
+	email, err = engine.RenderEmail(context.Background(), "a", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "HTML", email.Text)
+}
+
+func TestEmailTextFrontMatter(t *testing.T) {
+	type roleKey struct{}
+	now := time.Date(2024, time.November, 30, 0, 0, 0, 0, time.UTC)
+	fsys := fstest.MapFS{
+		"emails/promo.gohtml":   {Data: []byte(`<p>Promo</p>`)},
+		"emails/promo.txt":      {Data: []byte("---\npublish_at: 2024-11-29T00:00:00Z\nunpublish_at: 2024-12-02T00:00:00Z\n---\nPromo text")},
+		"emails/teaser.gohtml":  {Data: []byte(`<p>Teaser</p>`)},
+		"emails/teaser.txt":     {Data: []byte("---\npublish_at: 2024-12-01T00:00:00Z\n---\nTeaser text")},
+		"emails/invoice.gohtml": {Data: []byte(`<p>Invoice</p>`)},
+		"emails/invoice.txt":    {Data: []byte("---\nrequires: billing\n---\nInvoice text")},
+		"admin/report.gohtml":   {Data: []byte(`<p>Report</p>`)},
+		"admin/report.txt":      {Data: []byte("Report text")},
+	}
+	engine, err := templatex.NewFS(fsys, ".",
+		templatex.WithEmailText(true),
+		templatex.WithClock(func() time.Time { return now }),
+		templatex.WithAuthorizer(func(ctx context.Context, permission string) bool {
+			return ctx.Value(roleKey{}) == permission
+		}),
+		templatex.WithDirectoryPermission("admin", "admin"),
+	)
+	require.NoError(t, err)
+
+	// The front matter is stripped from the text
+	email, err := engine.RenderEmail(context.Background(), "emails/promo", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "Promo text", email.Text)
+
+	// Before its publish window, the text is derived from the HTML part
+	email, err = engine.RenderEmail(context.Background(), "emails/teaser", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "Teaser", email.Text)
+
+	// Permissions of the front matter and directory apply to the text
+	_, err = engine.RenderEmail(context.Background(), "emails/invoice", nil)
+	assert.ErrorIs(t, err, templatex.ErrUnauthorized)
+	email, err = engine.RenderEmail(context.WithValue(context.Background(), roleKey{}, "billing"), "emails/invoice", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "Invoice text", email.Text)
+
+	_, err = engine.RenderEmail(context.Background(), "admin/report", nil)
+	assert.ErrorIs(t, err, templatex.ErrUnauthorized)
+	email, err = engine.RenderEmail(context.WithValue(context.Background(), roleKey{}, "admin"), "admin/report", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "Report text", email.Text)
+}
+
+func TestCSSInlining(t *testing.T) {
+	style := `<style>
+		/* Base */
+		p { color: #333; margin: 0 }
+		.note { color: blue }
+		td > a, #cta { font-weight: bold !important }
+		table .cell[align="right"] { text-align: right }
+		a:hover { color: red }
+		@media (max-width: 600px) { p { font-size: 18px } }
+	</style>`
+	body := `<p>Hi</p><p class="note" style="margin: 4px; font-weight: normal">Note</p>` +
+		`<table><tr><td class="cell" align="right"><a href="/x" id="cta" style="font-weight: normal">Go</a></td></tr></table>`
+	engine, err := templatex.NewMemory(map[string]string{
+		"emails/layout.gohtml":  `<!DOCTYPE html><html><head>` + style + `</head><body>{{ embed }}</body></html>`,
+		"emails/welcome.gohtml": body,
+		"emails/plain.gohtml":   `<style>p { color: red }</style><p>Fragment</p>`,
+		"pages/home.gohtml":     style + body,
+	}, templatex.WithCSSInlining())
+	require.NoError(t, err)
+	assert.Equal(t, []string{"emails"}, engine.Config().CSSInlining)
+
+	out, err := engine.RenderString(context.Background(), "emails/welcome", nil, "emails/layout")
+	require.NoError(t, err)
+	assert.Contains(t, out, `<p style="color: #333; margin: 0">Hi</p>`)
+	assert.Contains(t, out, `<p class="note" style="color: blue; margin: 4px; font-weight: normal">Note</p>`)
+	assert.Contains(t, out, `<td class="cell" align="right" style="text-align: right">`)
+	assert.Contains(t, out, `<a href="/x" id="cta" style="font-weight: bold">Go</a>`)
+
+	// Rules that can't be inlined are kept
+	assert.Contains(t, out, "a:hover { color: red }")
+	assert.Contains(t, out, "@media (max-width: 600px) {")
+	assert.NotContains(t, out, ".note")
+	assert.Contains(t, out, "<!DOCTYPE html>")
+
+	// Fragments aren't wrapped in a document, and emptied style elements are removed
+	out, err = engine.RenderString(context.Background(), "emails/plain", nil)
+	require.NoError(t, err)
+	assert.Equal(t, `<p style="color: red">Fragment</p>`, out)
+
+	// Templates outside of the directories are left alone
+	out, err = engine.RenderString(context.Background(), "pages/home", nil)
+	require.NoError(t, err)
+	assert.Contains(t, out, "<p>Hi</p>")
+}
+
+func TestSignedURL(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	clock := func() time.Time { return now }
+	sources := map[string]string{
+		"link.gohtml":    `{{ signedURL "/download/report.pdf" "exp" "3600" "user" .User }}`,
+		"forever.gohtml": `{{ signedURL "/files/a b.txt?v=2" }}`,
+		"bad.gohtml":     `{{ signedURL "/x" "exp" }}`,
+	}
+	engine, err := templatex.NewMemory(sources, templatex.WithURLSigner([]byte("secret"), crypto.SHA256), templatex.WithClock(clock))
+	require.NoError(t, err)
+	assert.True(t, engine.Config().SignedURLs)
+
+	parse := func(name string, data any) *url.URL {
+		out, err := engine.RenderString(context.Background(), name, data)
+		require.NoError(t, err)
+		u, err := url.Parse(html.UnescapeString(out))
+		require.NoError(t, err)
+		return u
+	}
+
+	u := parse("link", map[string]string{"User": "42"})
+	assert.Equal(t, "/download/report.pdf", u.Path)
+	assert.Equal(t, "42", u.Query().Get("user"))
+	assert.Equal(t, strconv.FormatInt(now.Add(time.Hour).Unix(), 10), u.Query().Get(templatex.ExpiresParam))
+	assert.NoError(t, engine.VerifySignedURL(u))
+
+	// Tampered parameters and paths are rejected
+	tampered := *u
+	q := tampered.Query()
+	q.Set("user", "43")
+	tampered.RawQuery = q.Encode()
+	assert.ErrorIs(t, engine.VerifySignedURL(&tampered), templatex.ErrInvalidURLSignature)
+	tampered = *u
+	tampered.Path = "/download/other.pdf"
+	assert.ErrorIs(t, engine.VerifySignedURL(&tampered), templatex.ErrInvalidURLSignature)
+
+	// Links without a lifetime don't expire
+	forever := parse("forever", nil)
+	assert.Equal(t, "2", forever.Query().Get("v"))
+	now = now.Add(time.Hour)
+	assert.ErrorIs(t, engine.VerifySignedURL(u), templatex.ErrSignedURLExpired)
+	assert.NoError(t, engine.VerifySignedURL(forever))
+
+	// Other engines don't accept the signature
+	other, err := templatex.NewMemory(sources, templatex.WithURLSigner([]byte("other"), crypto.SHA256))
+	require.NoError(t, err)
+	assert.ErrorIs(t, other.VerifySignedURL(forever), templatex.ErrInvalidURLSignature)
+
+	_, err = engine.RenderString(context.Background(), "bad", nil)
+	assert.ErrorIs(t, err, templatex.ErrTemplateExecutionFailed)
+
+	_, err = templatex.NewMemory(sources, templatex.WithURLSigner(nil, crypto.SHA256))
+	assert.ErrorIs(t, err, templatex.ErrInvalidURLSigner)
+
+	// The function fails without a signer
+	unsigned, err := templatex.NewMemory(sources)
+	require.NoError(t, err)
+	_, err = unsigned.RenderString(context.Background(), "forever", nil)
+	assert.ErrorIs(t, err, templatex.ErrTemplateExecutionFailed)
+}
+
+// testMarkdown converts "# " lines to headings and other lines to escaped paragraphs,
+// like a Markdown converter escaping quotes and dropping raw HTML
+func testMarkdown(src []byte) ([]byte, error) {
+	var out strings.Builder
+	for _, line := range strings.Split(strings.TrimSpace(string(src)), "\n") {
+		if heading, ok := strings.CutPrefix(line, "# "); ok {
+			out.WriteString("<h1>" + html.EscapeString(heading) + "</h1>\n")
+		} else if line != "" {
+			out.WriteString("<p>" + html.EscapeString(line) + "</p>\n")
+		}
+	}
+	return []byte(out.String()), nil
+}
+
+func TestMarkdown(t *testing.T) {
+	sources := map[string]string{
+		"layout.gohtml":    `<title>{{ pageTitle }}</title>{{ metaTags }}<main>{{ embed }}</main>`,
+		"docs/intro.md":    "---\ntitle: Intro\ndescription: Getting started\n---\n# Hello {{ .Name }}\nSee \"{{ T \"docs.more\" }}\" & more\n",
+		"post.gohtml":      `{{ markdown .Body }}`,
+		"docs/draft.md":    "---\nunpublish_at: 2000-01-01T00:00:00Z\n---\n# Draft\n",
+		"docs/page.gohtml": `<p>{{ .Name }}</p>`,
+		"blog/post.md":     "---\ntitle: Post\ndate: 2024-11-29\ntags: [go, html]\nlayout: post\n---\n# Post\n",
+		"blog/note.md":     "---\ndate: 2024-11-29\nauthor: Ann\n---\n# Note\n",
+		"blog/rule.md":     "---\nSee below\n---\n",
+	}
+	engine, err := templatex.NewMemory(sources, templatex.WithMarkdown(testMarkdown))
+	require.NoError(t, err)
+	assert.True(t, engine.Config().Markdown)
+
+	out, err := engine.RenderString(context.Background(), "docs/intro", map[string]string{"Name": "<Ann>"}, "layout")
+	require.NoError(t, err)
+	assert.Equal(t, `<title>Intro</title><meta name="description" content="Getting started"><main><h1>Hello &lt;Ann&gt;</h1>`+"\n"+
+		`<p>See &#34;docs.more&#34; &amp; more</p>`+"\n</main>", out)
+
+	// Keys of other tools are ignored, and blocks that aren't mappings are Markdown
+	out, err = engine.RenderString(context.Background(), "blog/post", nil, "layout")
+	require.NoError(t, err)
+	assert.Equal(t, "<title>Post</title><main><h1>Post</h1>\n</main>", out)
+
+	out, err = engine.RenderString(context.Background(), "blog/note", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "<h1>Note</h1>\n", out)
+
+	out, err = engine.RenderString(context.Background(), "blog/rule", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "<p>---</p>\n<p>See below</p>\n<p>---</p>\n", out)
+
+	// Front matter publish windows apply
+	_, err = engine.RenderString(context.Background(), "docs/draft", nil)
+	assert.ErrorIs(t, err, templatex.ErrTemplateNotPublished)
+
+	// Markdown strings from data are converted
+	out, err = engine.RenderString(context.Background(), "post", map[string]string{"Body": "# Title\n<script>"})
+	require.NoError(t, err)
+	assert.Equal(t, "<h1>Title</h1>\n<p>&lt;script&gt;</p>\n", out)
+
+	// Without a converter, .md files are not templates and markdown is not built in
+	engine, err = templatex.NewMemory(sources)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "markdown")
+
+	delete(sources, "post.gohtml")
+	engine, err = templatex.NewMemory(sources)
+	require.NoError(t, err)
+	_, err = engine.RenderString(context.Background(), "docs/intro", nil)
+	assert.ErrorIs(t, err, templatex.ErrTemplateNotFound)
+
+	// Broken conversions fail the load
+	_, err = templatex.NewMemory(map[string]string{"a.md": "{{ .X"}, templatex.WithMarkdown(testMarkdown))
+	assert.ErrorIs(t, err, templatex.ErrTemplateParsingFailed)
+}
+
+func TestObfuscateEmail(t *testing.T) {
+	engine, err := templatex.NewMemory(map[string]string{
+		"email.gohtml": `{{ obfuscateEmail .Email }}`,
+		"js.gohtml":    `{{ obfuscateEmail .Email "js" }}`,
+		"bad.gohtml":   `{{ obfuscateEmail .Email "rot13" }}`,
+	})
+	require.NoError(t, err)
+
+	data := map[string]string{"Email": "ann@example.com"}
+	out, err := engine.RenderString(context.Background(), "email", data)
+	require.NoError(t, err)
+	assert.NotContains(t, out, "ann@example.com")
+	assert.Contains(t, out, "&#97;&#110;&#110;&#64;")
+	assert.Equal(t, `<a href="mailto:ann@example.com">ann@example.com</a>`, html.UnescapeString(out))
+
+	out, err = engine.RenderString(context.Background(), "js", data)
+	require.NoError(t, err)
+	assert.NotContains(t, out, "ann@example.com")
+	assert.Contains(t, out, `"moc.elpmaxe\u0040nna"`)
+	assert.Contains(t, out, "<noscript>ann [at] example [dot] com</noscript>")
+
+	_, err = engine.RenderString(context.Background(), "bad", data)
+	assert.ErrorIs(t, err, templatex.ErrTemplateExecutionFailed)
+	_, err = engine.RenderString(context.Background(), "email", map[string]string{"Email": "nope"})
+	assert.ErrorIs(t, err, templatex.ErrTemplateExecutionFailed)
+}
+
+func TestMaskString(t *testing.T) {
+	engine, err := templatex.NewMemory(map[string]string{
+		"mask.gohtml": `{{ maskString .Value .Start .End }}`,
+	})
+	require.NoError(t, err)
+
+	tests := []struct {
+		value      any
+		start, end int
+		want       string
+	}{
+		{"4242 4242 4242 4242", 0, 4, "**** **** **** 4242"},
+		{"sk_live_abc123xyz", 2, 3, "sk_****_******xyz"},
+		{"ABCD", 2, 2, "****"},
+		{"Jöhn", 1, 0, "J***"},
+		{123456, 0, 2, "****56"},
+		{nil, 1, 1, ""},
+	}
+	for _, tt := range tests {
+		out, err := engine.RenderString(context.Background(), "mask", map[string]any{"Value": tt.value, "Start": tt.start, "End": tt.end})
+		require.NoError(t, err)
+		assert.Equal(t, tt.want, out)
+	}
+}
+
+func TestFormatPhone(t *testing.T) {
+	engine, err := templatex.NewMemory(map[string]string{
+		"phone.gohtml": `{{ formatPhone .Number .Region }}`,
+	})
+	require.NoError(t, err)
+
+	tests := []struct {
+		number, region, want string
+	}{
+		{"4155550123", "US", "(415) 555-0123"},
+		{"+1 415-555-0123", "us", "(415) 555-0123"},
+		{"1 (415) 555 0123", "US", "(415) 555-0123"},
+		{"+1 415 555 0123", "CA", "(415) 555-0123"},
+		{"+1 415 555 0123", "GB", "+1 415 555 0123"},
+		{"+1 415 555 0123", "", "+1 415 555 0123"},
+		{"020 7946 0958", "GB", "02079 460958"},
+		{"0044 20 7946 0958", "US", "+44 2079 460958"},
+		{"01.23.45.67.89", "FR", "01 23 45 67 89"},
+		{"84951234567", "RU", "8 (495) 123-45-67"},
+		{"+7 495 123 45 67", "DE", "+7 495 123 45 67"},
+		{"030 12345678", "DE", "03012345678"},
+		{"+49 30 12345678", "FR", "+49 3012345678"},
+		{"+999 123", "US", "+999123"},
+		{"", "US", ""},
+	}
+	for _, tt := range tests {
+		out, err := engine.RenderString(context.Background(), "phone", map[string]string{"Number": tt.number, "Region": tt.region})
+		require.NoError(t, err, tt.number)
+		assert.Equal(t, tt.want, html.UnescapeString(out), "%s in %q", tt.number, tt.region)
+	}
+
+	for _, data := range []map[string]string{
+		{"Number": "call me", "Region": "US"},
+		{"Number": "4155550123", "Region": "XX"},
+		{"Number": "4155550123", "Region": ""},
+	} {
+		_, err := engine.RenderString(context.Background(), "phone", data)
+		assert.ErrorIs(t, err, templatex.ErrTemplateExecutionFailed, data["Number"])
+	}
+}
+
+func TestFormatAddress(t *testing.T) {
+	type Address struct {
+		Name       string
+		Street     []string
+		City       string
+		Region     string
+		PostalCode string
+		Country    string
+	}
+
+	engine, err := templatex.NewMemory(map[string]string{
+		"address.gohtml": `{{ formatAddress . }}`,
+		"inline.gohtml":  `{{ formatAddress . ", " }}`,
+	}, templatex.WithAddressFormats(map[string]string{
+		"nz": "{Name}\n{Street}\n{City} {PostalCode}\nNEW ZEALAND",
+	}))
+	require.NoError(t, err)
+
+	render := func(name string, addr any) string {
+		out, err := engine.RenderString(context.Background(), name, addr)
+		require.NoError(t, err)
+		return out
+	}
+
+	us := Address{Name: "Jane Doe", Street: []string{"1600 Amphitheatre Pkwy", "Suite 100"}, City: "Mountain View", Region: "CA", PostalCode: "94043", Country: "US"}
+	assert.Equal(t, "Jane Doe\n1600 Amphitheatre Pkwy\nSuite 100\nMountain View, CA 94043", render("address", us))
+	assert.Equal(t, "Jane Doe, 1600 Amphitheatre Pkwy, Suite 100, Mountain View, CA 94043", render("inline", &us))
+
+	us.Region = ""
+	assert.Equal(t, "Mountain View 94043", strings.Split(render("address", us), "\n")[3])
+
+	de := map[string]any{"name": "Max Mustermann", "street": "Musterstraße 1", "postalCode": "10115", "city": "Berlin", "country": "de"}
+	assert.Equal(t, "Max Mustermann, Musterstraße 1, 10115 Berlin", render("inline", de))
+
+	jp := Address{Name: "山田太郎", Street: []string{"丸の内1-1"}, City: "千代田区", Region: "東京都", PostalCode: "100-0005", Country: "JP"}
+	assert.Equal(t, "〒100-0005, 東京都千代田区, 丸の内1-1, 山田太郎", render("inline", jp))
+	jp.PostalCode = ""
+	assert.Equal(t, "東京都千代田区, 丸の内1-1, 山田太郎", render("inline", jp))
+
+	nz := Address{Name: "Kiri", Street: []string{"1 Queen St"}, City: "Auckland", PostalCode: "1010", Country: "NZ"}
+	assert.Equal(t, "Kiri, 1 Queen St, Auckland 1010, NEW ZEALAND", render("inline", nz))
+
+	unknown := Address{Street: []string{"1 Main Rd"}, City: "Springfield", PostalCode: "12345", Country: "ZZ"}
+	assert.Equal(t, "1 Main Rd, Springfield 12345", render("inline", unknown))
+
+	_, err = engine.RenderString(context.Background(), "address", "1 Main Rd")
+	assert.ErrorIs(t, err, templatex.ErrTemplateExecutionFailed)
+}
+
+func TestPrecompiledChains(t *testing.T) {
+	sources := map[string]string{
+		"base.gohtml":          `<html>{{ mark "base" }}{{ embed }}</html>`,
+		"app.gohtml":           `<div class="{{ .Class }}">{{ mark "app" }}{{ if .Class }}{{ embed }}{{ end }}</div>`,
+		"page.gohtml":          `{{ mark "page" }}<p>{{ .Name }}</p>`,
+		"titled.gohtml":        `{{ setTitle .Name }}{{ mark "page" }}<p>{{ .Name }}</p>`,
+		"head.gohtml":          `<title>{{ pageTitle }}</title>{{ mark "head" }}{{ embed }}`,
+		"nested.gohtml":        `{{ mark "page" }}{{ template "partials/name" . }}`,
+		"partials/name.gohtml": `<b>{{ .Name }}</b>`,
+	}
+	var mu sync.Mutex
+	var order []string
+	newEngine := func(precompiled bool) *templatex.Engine {
+		engine, err := templatex.NewMemory(sources,
+			templatex.WithPrecompiledChains(precompiled),
+			templatex.WithFunc("mark", func(name string) string {
+				mu.Lock()
+				defer mu.Unlock()
+				order = append(order, name)
+				return ""
+			}),
+			templatex.WithLayoutDataFunc("app", func(binding any) any {
+				return map[string]string{"Class": "app-" + binding.(map[string]string)["Name"]}
+			}),
+		)
+		require.NoError(t, err)
+		return engine
+	}
+	regular, precompiled := newEngine(false), newEngine(true)
+	assert.True(t, precompiled.Config().PrecompiledChains)
+
+	render := func(engine *templatex.Engine, name string, layouts ...templatex.LayoutBinding) (string, []string) {
+		mu.Lock()
+		order = nil
+		mu.Unlock()
+		var buf bytes.Buffer
+		require.NoError(t, engine.RenderWithLayouts(templatex.SkipCache(context.Background()), &buf, name, map[string]string{"Name": "<Bo>"}, layouts...))
+		return buf.String(), order
+	}
+
+	layouts := []templatex.LayoutBinding{{Name: "app"}, {Name: "base"}}
+	want, wantOrder := render(regular, "page", layouts...)
+	assert.Equal(t, `<html><div class="app-&lt;Bo&gt;"><p>&lt;Bo&gt;</p></div></html>`, want)
+	assert.Equal(t, []string{"page", "app", "base"}, wantOrder)
+
+	// Layouts are executed first, with the page executed at the embed action
+	got, gotOrder := render(precompiled, "page", layouts...)
+	assert.Equal(t, want, got)
+	assert.Equal(t, []string{"base", "app", "page"}, gotOrder)
+
+	got, _ = render(precompiled, "page", templatex.Layout("app", map[string]string{"Class": "x"}), templatex.Layout("base", nil))
+	assert.Equal(t, `<html><div class="x"><p>&lt;Bo&gt;</p></div></html>`, got)
+
+	// Templates included by the page are part of the chain
+	got, gotOrder = render(precompiled, "nested", layouts...)
+	assert.Equal(t, `<html><div class="app-&lt;Bo&gt;"><b>&lt;Bo&gt;</b></div></html>`, got)
+	assert.Equal(t, []string{"base", "app", "page"}, gotOrder)
+
+	var buf bytes.Buffer
+	require.NoError(t, precompiled.RenderStream(context.Background(), &buf, "page", map[string]string{"Name": "Al"}, "app", "base"))
+	assert.Equal(t, `<html><div class="app-Al"><p>Al</p></div></html>`, buf.String())
+
+	// Chains using the render state keep the page first
+	got, gotOrder = render(precompiled, "titled", templatex.Layout("head", nil))
+	assert.Equal(t, `<title>&lt;Bo&gt;</title><p>&lt;Bo&gt;</p>`, got)
+	assert.Equal(t, []string{"page", "head"}, gotOrder)
+
+	// Precompiled chains are rebuilt after the layout cache is purged
+	precompiled.PurgeLayoutCache()
+	_, gotOrder = render(precompiled, "page", layouts...)
+	assert.Equal(t, []string{"base", "app", "page"}, gotOrder)
+}
+
+func TestTextDiff(t *testing.T) {
+	engine, err := templatex.NewMemory(map[string]string{
+		"words.gohtml": `<p>{{ diffWords .Old .New }}</p>`,
+		"lines.gohtml": `<pre>{{ diffLines .Old .New }}</pre>`,
+	})
+	require.NoError(t, err)
+
+	render := func(name string, old, new any) string {
+		out, err := engine.RenderString(context.Background(), name, map[string]any{"Old": old, "New": new})
+		require.NoError(t, err)
+		return out
+	}
+
+	assert.Equal(t, "<p>The <del>quick</del><ins>slow</ins> brown fox</p>",
+		render("words", "The quick brown fox", "The slow brown fox"))
+	assert.Equal(t, "<p>Price: <del>&lt;b&gt;10&lt;/b&gt;</del><ins>&lt;b&gt;12&lt;/b&gt;</ins> USD<ins> incl. VAT</ins></p>",
+		render("words", "Price: <b>10</b> USD", "Price: <b>12</b> USD incl. VAT"))
+	assert.Equal(t, "<p>same text</p>", render("words", "same text", "same text"))
+	assert.Equal(t, "<p><ins>new</ins></p>", render("words", nil, "new"))
+	assert.Equal(t, "<p><del>42</del><ins>43</ins></p>", render("words", 42, 43))
+
+	assert.Equal(t, "<pre>a\n<del>b\n</del><ins>B\nb2\n</ins>c</pre>",
+		render("lines", "a\nb\nc", "a\nB\nb2\nc"))
+	assert.Equal(t, "<pre><del>x\n</del>y\n</pre>", render("lines", "x\ny\n", "y\n"))
+}
+
+func TestMinifyOutput(t *testing.T) {
+	engine, err := templatex.NewMemory(map[string]string{
+		"page.gohtml": `<!DOCTYPE html>
+<html>
+  <!-- navigation -->
+  <body>
+    <p>
+      Hello,   <b>{{ .Name }}</b> !
+    </p>
+    <pre>
+  keep   this
+    </pre>
+    <script>var  a =  1;</script>
+    {{ .Widget }}
+  </body>
+</html>`,
+	}, templatex.WithMinifyOutput(true))
+	require.NoError(t, err)
+	assert.True(t, engine.Config().MinifyOutput)
+
+	// Templates are parsed without comments, but inserted HTML may contain them
+	data := map[string]any{"Name": "Bo", "Widget": template.HTML("<!-- widget --><!--[if IE]><p>IE</p><![endif]-->")}
+	out, err := engine.RenderString(context.Background(), "page", data)
+	require.NoError(t, err)
+	assert.Equal(t, "<!DOCTYPE html>\n<html>\n<body>\n<p>\nHello, <b>Bo</b> !\n</p>\n<pre>\n  keep   this\n    </pre>\n<script>var  a =  1;</script>\n<!--[if IE]><p>IE</p><![endif]-->\n</body>\n</html>", out)
+
+	// Minification is skipped per render, and raw output is cached separately
+	raw, err := engine.RenderString(templatex.SkipMinify(context.Background()), "page", data)
+	require.NoError(t, err)
+	assert.Contains(t, raw, "<!-- widget -->")
+	assert.Contains(t, raw, "Hello,   <b>Bo</b>")
+}
+
+func TestRenderCompressed(t *testing.T) {
+	var encoded atomic.Int32
+	engine, err := templatex.NewMemory(map[string]string{
+		"page.gohtml": `<h1>{{ .Title }}</h1>`,
+	},
+		templatex.WithHardCache(true),
+		templatex.WithCacheEncoding("gzip", func(content []byte) ([]byte, error) {
+			encoded.Add(1)
+			return templatex.GzipEncode(content)
+		}),
+		templatex.WithCacheEncoding("deflate", func(content []byte) ([]byte, error) {
+			return nil, errors.New("unsupported")
+		}),
+	)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"gzip", "deflate"}, engine.Config().CacheEncodings)
+
+	render := func(acceptEncoding string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		require.NoError(t, engine.RenderCompressed(context.Background(), rec, acceptEncoding, "page", map[string]string{"Title": "Hi"}))
+		return rec
+	}
+
+	for range 3 {
+		rec := render("br;q=1.0, gzip;q=0.8")
+		assert.Equal(t, "gzip", rec.Header().Get("Content-Encoding"))
+		assert.Equal(t, "Accept-Encoding", rec.Header().Get("Vary"))
+		assert.Equal(t, strconv.Itoa(rec.Body.Len()), rec.Header().Get("Content-Length"))
+
+		zr, err := gzip.NewReader(rec.Body)
+		require.NoError(t, err)
+		body, err := io.ReadAll(zr)
+		require.NoError(t, err)
+		assert.Equal(t, "<h1>Hi</h1>", string(body))
+	}
+	assert.Equal(t, int32(1), encoded.Load(), "cached content is compressed once")
+
+	for _, accept := range []string{"", "identity", "gzip;q=0, br", "*;q=0"} {
+		rec := render(accept)
+		assert.Empty(t, rec.Header().Get("Content-Encoding"), accept)
+		assert.Equal(t, "<h1>Hi</h1>", rec.Body.String(), accept)
+	}
+
+	// Uncached renders are compressed on every request
+	rec := httptest.NewRecorder()
+	require.NoError(t, engine.RenderCompressed(templatex.SkipCache(context.Background()), rec, "*", "page", map[string]string{"Title": "Hi"}))
+	assert.Equal(t, "gzip", rec.Header().Get("Content-Encoding"))
+	assert.Equal(t, int32(2), encoded.Load())
+
+	err = engine.RenderCompressed(context.Background(), httptest.NewRecorder(), "deflate", "page", map[string]string{"Title": "Hi"})
+	assert.ErrorIs(t, err, templatex.ErrEncodingFailed)
+}
+
+func TestHeadingAnchors(t *testing.T) {
+	engine, err := templatex.NewMemory(map[string]string{
+		"docs/guide.gohtml": `<div id="setup"></div><h1>Guide</h1>` +
+			`<h2>Getting Started!</h2><h3 class="x">Setup</h3><h3>Setup</h3>` +
+			`<h2 id="custom">Custom <em>id</em></h2><h4><a href="/faq">FAQ</a></h4><h5>Deep</h5><h2>{{ .Title }}</h2>`,
+		"blog/post.gohtml": `<h2>Title</h2>`,
+	}, templatex.WithHeadingAnchors(templatex.HeadingAnchors{Templates: []string{"docs"}}))
+	require.NoError(t, err)
+
+	out, err := engine.RenderString(context.Background(), "docs/guide", map[string]string{"Title": "Q&A"})
+	require.NoError(t, err)
+	assert.Equal(t, `<div id="setup"></div><h1>Guide</h1>`+
+		`<h2 id="getting-started">Getting Started! <a class="anchor" href="#getting-started" aria-label="Link to Getting Started!">#</a></h2>`+
+		`<h3 class="x" id="setup-2">Setup <a class="anchor" href="#setup-2" aria-label="Link to Setup">#</a></h3>`+
+		`<h3 id="setup-3">Setup <a class="anchor" href="#setup-3" aria-label="Link to Setup">#</a></h3>`+
+		`<h2 id="custom">Custom <em>id</em> <a class="anchor" href="#custom" aria-label="Link to Custom id">#</a></h2>`+
+		`<h4 id="faq"><a href="/faq">FAQ</a></h4><h5>Deep</h5>`+
+		`<h2 id="q-a">Q&amp;A <a class="anchor" href="#q-a" aria-label="Link to Q&amp;A">#</a></h2>`, out)
+
+	out, err = engine.RenderString(context.Background(), "blog/post", nil)
+	require.NoError(t, err)
+	assert.Equal(t, `<h2>Title</h2>`, out)
+}
+
+func TestLazyParsing(t *testing.T) {
+	fsys := fstest.MapFS{
+		"pages/home.gohtml":     {Data: []byte(`<h1>{{ .Title }}</h1>{{ template "partials/nav" . }}`)},
+		"partials/nav.gohtml":   {Data: []byte(`<nav>{{ template "icons/logo" }}</nav>`)},
+		"icons.gohtml":          {Data: []byte(`{{ define "icons/logo" }}<svg></svg>{{ end }}`)},
+		"pages/broken.gohtml":   {Data: []byte(`{{ if }}`)},
+		"layouts/base.gohtml":   {Data: []byte(`<main>{{ embed }}</main>`)},
+		"layouts/unused.gohtml": {Data: []byte(`{{ embed }}`)},
+	}
+
+	// Eager parsing fails on the broken template
+	_, err := templatex.NewFS(fsys, ".")
+	require.ErrorIs(t, err, templatex.ErrTemplateParsingFailed)
+
+	engine, err := templatex.NewFS(fsys, ".", templatex.WithLazyParsing(true), templatex.WithLayouts("layouts/base"))
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"layouts/base"}, engine.Report().TemplateNames)
+
+	out, err := engine.RenderString(context.Background(), "pages/home", map[string]any{"Title": "Hi"}, "layouts/base")
+	require.NoError(t, err)
+	assert.Equal(t, "<main><h1>Hi</h1><nav><svg></svg></nav></main>", out)
+
+	_, err = engine.RenderString(context.Background(), "pages/broken", nil)
+	assert.ErrorIs(t, err, templatex.ErrTemplateParsingFailed)
+
+	_, err = engine.RenderString(context.Background(), "pages/missing", nil)
+	assert.ErrorIs(t, err, templatex.ErrTemplateNotFound)
+
+	t.Run("parsed on demand", func(t *testing.T) {
+		fsys := maps.Clone(fsys)
+		delete(fsys, "pages/broken.gohtml")
+		engine, err := templatex.NewFS(fsys, ".", templatex.WithLazyParsing(true))
+		require.NoError(t, err)
+		assert.Empty(t, engine.Report().TemplateNames)
+
+		out, err := engine.RenderString(context.Background(), "partials/nav", nil)
+		require.NoError(t, err)
+		assert.Equal(t, "<nav><svg></svg></nav>", out)
+		assert.NotContains(t, engine.Report().TemplateNames, "pages/home")
+
+		// Inspecting the whole set parses everything
+		assert.Contains(t, engine.Checksums(), "pages/home")
+	})
+}
@@ -0,0 +1,128 @@
+// Package templatextest provides helpers for testing templates rendered by templatex.
+package templatextest
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/dmitrymomot/templatex"
+)
+
+// Spec describes a declarative template test. Specs are written in YAML or JSON:
+//
+//	name: greeter shows the user name
+//	template: greeter
+//	layouts: [app_layout]
+//	data:
+//	  Username: John
+//	contains:
+//	  - John
+//	notContains:
+//	  - "<no value>"
+type Spec struct {
+	Name        string   `yaml:"name"`        // test name, defaults to the spec file name
+	Template    string   `yaml:"template"`    // name of the template to render
+	Layouts     []string `yaml:"layouts"`     // layouts wrapping the content
+	Data        any      `yaml:"data"`        // binding data
+	DataFile    string   `yaml:"dataFile"`    // YAML or JSON file with the binding data, relative to the spec
+	RequestPath string   `yaml:"requestPath"` // request path stored in the render context
+	Contains    []string `yaml:"contains"`    // substrings the output must contain
+	NotContains []string `yaml:"notContains"` // substrings the output must not contain
+	Error       string   `yaml:"error"`       // substring of the expected render error
+}
+
+// RunSpecs runs the specs in files matching the glob pattern as subtests,
+// e.g. RunSpecs(t, engine, "testdata/specs/*.yaml"). It fails the test if no files
+// match the pattern or a spec can't be loaded.
+func RunSpecs(t *testing.T, engine *templatex.Engine, pattern string) {
+	t.Helper()
+
+	files, err := filepath.Glob(pattern)
+	if err != nil {
+		t.Fatalf("templatextest: invalid pattern %q: %v", pattern, err)
+	}
+	if len(files) == 0 {
+		t.Fatalf("templatextest: no spec files match %q", pattern)
+	}
+
+	for _, file := range files {
+		spec, err := LoadSpec(file)
+		if err != nil {
+			t.Errorf("templatextest: %v", err)
+			continue
+		}
+		t.Run(spec.Name, func(t *testing.T) {
+			RunSpec(t, engine, spec)
+		})
+	}
+}
+
+// LoadSpec reads a spec from a YAML or JSON file.
+// The spec name defaults to the file name without extension.
+func LoadSpec(file string) (Spec, error) {
+	var spec Spec
+	if err := decodeFile(file, &spec); err != nil {
+		return spec, err
+	}
+	if spec.Name == "" {
+		spec.Name = strings.TrimSuffix(filepath.Base(file), filepath.Ext(file))
+	}
+	if spec.DataFile != "" {
+		if err := decodeFile(filepath.Join(filepath.Dir(file), spec.DataFile), &spec.Data); err != nil {
+			return spec, err
+		}
+	}
+	return spec, nil
+}
+
+// RunSpec renders the spec template and checks the output against the expectations
+func RunSpec(t *testing.T, engine *templatex.Engine, spec Spec) {
+	t.Helper()
+
+	ctx := context.Background()
+	if spec.RequestPath != "" {
+		ctx = templatex.WithRequestPath(ctx, spec.RequestPath)
+	}
+
+	out, err := engine.RenderString(ctx, spec.Template, spec.Data, spec.Layouts...)
+	if spec.Error != "" {
+		if err == nil {
+			t.Fatalf("expected error containing %q, got none", spec.Error)
+		}
+		if !strings.Contains(err.Error(), spec.Error) {
+			t.Fatalf("expected error containing %q, got %q", spec.Error, err.Error())
+		}
+		return
+	}
+	if err != nil {
+		t.Fatalf("render %s: %v", spec.Template, err)
+	}
+
+	for _, s := range spec.Contains {
+		if !strings.Contains(out, s) {
+			t.Errorf("output doesn't contain %q\noutput:\n%s", s, out)
+		}
+	}
+	for _, s := range spec.NotContains {
+		if strings.Contains(out, s) {
+			t.Errorf("output contains %q\noutput:\n%s", s, out)
+		}
+	}
+}
+
+// decodeFile decodes a YAML or JSON file into v
+func decodeFile(file string, v any) error {
+	b, err := os.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	if err := yaml.Unmarshal(b, v); err != nil {
+		return &os.PathError{Op: "decode", Path: file, Err: err}
+	}
+	return nil
+}
@@ -0,0 +1,24 @@
+package templatextest_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/dmitrymomot/templatex"
+	"github.com/dmitrymomot/templatex/templatextest"
+)
+
+func TestRunSpecs(t *testing.T) {
+	engine, err := templatex.New("../example/templates/")
+	require.NoError(t, err)
+
+	templatextest.RunSpecs(t, engine, "testdata/specs/*")
+}
+
+func TestLoadSpec(t *testing.T) {
+	spec, err := templatextest.LoadSpec("testdata/specs/greeter_data_file.json")
+	require.NoError(t, err)
+	require.Equal(t, "greeter_data_file", spec.Name)
+	require.Equal(t, map[string]any{"Username": "Jane"}, spec.Data)
+}
@@ -0,0 +1,175 @@
+package templatex
+
+import (
+	"fmt"
+	"html"
+	"html/template"
+	"strings"
+	"unicode"
+)
+
+// maxDiffCells limits the size of the comparison table of text diffs. Larger texts
+// are shown as fully deleted and inserted.
+const maxDiffCells = 4 << 20
+
+// diffOp is an operation of a text diff
+type diffOp int
+
+// Text diff operations
+const (
+	diffEqual diffOp = iota
+	diffDelete
+	diffInsert
+)
+
+// diffWords returns the changes between two texts word by word, with deleted words
+// wrapped in <del> and inserted words in <ins>. The texts are HTML-escaped.
+// Usage: {{ diffWords .Old.Body .New.Body }}
+func diffWords(old, new any) template.HTML {
+	return renderDiff(diffTokens(splitWords(diffText(old)), splitWords(diffText(new))))
+}
+
+// diffLines returns the changes between two texts line by line, with deleted lines
+// wrapped in <del> and inserted lines in <ins>. The texts are HTML-escaped, so the
+// result is meant for a <pre> element or an element with white-space: pre-wrap.
+// Usage: <pre>{{ diffLines .Old.Config .New.Config }}</pre>
+func diffLines(old, new any) template.HTML {
+	return renderDiff(diffTokens(splitLines(diffText(old)), splitLines(diffText(new))))
+}
+
+// diffText converts the value to the compared text; nil is empty
+func diffText(v any) string {
+	if v == nil {
+		return ""
+	}
+	return fmt.Sprint(v)
+}
+
+// diffChunk is a run of tokens with the same operation
+type diffChunk struct {
+	op   diffOp
+	text string
+}
+
+// diffTokens returns the chunks turning the old tokens into the new ones,
+// based on their longest common subsequence
+func diffTokens(a, b []string) []diffChunk {
+	// Common prefix and suffix don't need the comparison table
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	var chunks []diffChunk
+	add := func(op diffOp, token string) {
+		if n := len(chunks); n > 0 && chunks[n-1].op == op {
+			chunks[n-1].text += token
+			return
+		}
+		chunks = append(chunks, diffChunk{op: op, text: token})
+	}
+
+	for _, t := range a[:prefix] {
+		add(diffEqual, t)
+	}
+	ma, mb := a[prefix:len(a)-suffix], b[prefix:len(b)-suffix]
+	if (len(ma)+1)*(len(mb)+1) > maxDiffCells {
+		for _, t := range ma {
+			add(diffDelete, t)
+		}
+		for _, t := range mb {
+			add(diffInsert, t)
+		}
+	} else {
+		// lcs[i][j] is the length of the common subsequence of ma[i:] and mb[j:]
+		lcs := make([][]int, len(ma)+1)
+		for i := range lcs {
+			lcs[i] = make([]int, len(mb)+1)
+		}
+		for i := len(ma) - 1; i >= 0; i-- {
+			for j := len(mb) - 1; j >= 0; j-- {
+				if ma[i] == mb[j] {
+					lcs[i][j] = lcs[i+1][j+1] + 1
+				} else {
+					lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+				}
+			}
+		}
+		i, j := 0, 0
+		for i < len(ma) && j < len(mb) {
+			switch {
+			case ma[i] == mb[j]:
+				add(diffEqual, ma[i])
+				i++
+				j++
+			case lcs[i+1][j] >= lcs[i][j+1]:
+				add(diffDelete, ma[i])
+				i++
+			default:
+				add(diffInsert, mb[j])
+				j++
+			}
+		}
+		for ; i < len(ma); i++ {
+			add(diffDelete, ma[i])
+		}
+		for ; j < len(mb); j++ {
+			add(diffInsert, mb[j])
+		}
+	}
+	for _, t := range a[len(a)-suffix:] {
+		add(diffEqual, t)
+	}
+	return chunks
+}
+
+// renderDiff returns the escaped chunks with deletions and insertions marked up
+func renderDiff(chunks []diffChunk) template.HTML {
+	var sb strings.Builder
+	for _, c := range chunks {
+		text := html.EscapeString(c.text)
+		switch c.op {
+		case diffDelete:
+			sb.WriteString("<del>" + text + "</del>")
+		case diffInsert:
+			sb.WriteString("<ins>" + text + "</ins>")
+		default:
+			sb.WriteString(text)
+		}
+	}
+	return template.HTML(sb.String())
+}
+
+// splitWords splits the text into words and runs of whitespace
+func splitWords(s string) []string {
+	var tokens []string
+	start, space := 0, false
+	for i, r := range s {
+		sp := unicode.IsSpace(r)
+		if i > 0 && sp != space {
+			tokens = append(tokens, s[start:i])
+			start = i
+		}
+		space = sp
+	}
+	if start < len(s) {
+		tokens = append(tokens, s[start:])
+	}
+	return tokens
+}
+
+// splitLines splits the text into lines, keeping their line breaks
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	lines := strings.SplitAfter(s, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
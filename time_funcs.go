@@ -0,0 +1,47 @@
+package templatex
+
+import (
+	"fmt"
+	"html/template"
+	"time"
+)
+
+// timeFuncs returns date and time functions bound to the given clock.
+// Usage: {{ now.Year }}, {{ formatTime .CreatedAt "2006-01-02" }}, {{ since .CreatedAt }}
+func timeFuncs(clock func() time.Time) template.FuncMap {
+	return template.FuncMap{
+		"now":        clock,
+		"formatTime": formatTime,
+		"since": func(t time.Time) time.Duration {
+			return clock().Sub(t).Round(time.Second)
+		},
+	}
+}
+
+// formatTime formats the time using the given layout (time.RFC3339 by default).
+// It accepts time.Time and *time.Time values; zero and nil times are formatted
+// as an empty string.
+// Usage: {{ formatTime .CreatedAt "Jan 2, 2006" }}
+func formatTime(v interface{}, layout ...string) (string, error) {
+	var t time.Time
+	switch val := v.(type) {
+	case time.Time:
+		t = val
+	case *time.Time:
+		if val == nil {
+			return "", nil
+		}
+		t = *val
+	case nil:
+		return "", nil
+	default:
+		return "", fmt.Errorf("formatTime: unsupported type %T", v)
+	}
+	if t.IsZero() {
+		return "", nil
+	}
+	if len(layout) > 0 && layout[0] != "" {
+		return t.Format(layout[0]), nil
+	}
+	return t.Format(time.RFC3339), nil
+}
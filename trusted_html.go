@@ -0,0 +1,171 @@
+package templatex
+
+import (
+	"errors"
+	"fmt"
+	"html"
+	"html/template"
+	"strings"
+
+	nethtml "golang.org/x/net/html"
+)
+
+// TrustedHTML is HTML known to be safe: either sanitized by the sanitize function
+// or explicitly trusted by application code with TrustHTML. With strict sinks
+// (see WithStrictSinks), htmlSafe only accepts TrustedHTML and template.HTML values.
+// Printing TrustedHTML directly escapes it; pass it to htmlSafe to insert it as HTML.
+type TrustedHTML struct {
+	html string
+}
+
+// TrustHTML marks HTML produced by application code as trusted, e.g. HTML rendered
+// from markdown by a sanitizing renderer. Never use it for user input.
+func TrustHTML(s string) TrustedHTML {
+	return TrustedHTML{html: s}
+}
+
+// String returns the HTML source
+func (h TrustedHTML) String() string {
+	return h.html
+}
+
+// sanitizedElements are elements kept by sanitize
+var sanitizedElements = map[string]bool{
+	"a": true, "abbr": true, "b": true, "blockquote": true, "br": true, "code": true,
+	"del": true, "div": true, "em": true, "h1": true, "h2": true, "h3": true, "h4": true,
+	"h5": true, "h6": true, "hr": true, "i": true, "img": true, "li": true, "ol": true,
+	"p": true, "pre": true, "s": true, "small": true, "span": true, "strong": true,
+	"sub": true, "sup": true, "table": true, "tbody": true, "td": true, "th": true,
+	"thead": true, "tr": true, "u": true, "ul": true,
+}
+
+// sanitizedDroppedElements are elements removed by sanitize together with their content
+var sanitizedDroppedElements = map[string]bool{
+	"script": true, "style": true, "iframe": true, "object": true, "embed": true,
+	"template": true, "noscript": true, "textarea": true, "select": true, "svg": true,
+	"math": true, "title": true,
+}
+
+// sanitizedAttrs are attributes kept by sanitize, per element ("*" for all elements)
+var sanitizedAttrs = map[string]map[string]bool{
+	"*":   {"title": true},
+	"a":   {"href": true},
+	"img": {"src": true, "alt": true, "width": true, "height": true},
+	"td":  {"colspan": true, "rowspan": true},
+	"th":  {"colspan": true, "rowspan": true},
+}
+
+// safeURL reports whether the URL is relative or uses a safe scheme
+func safeURL(attr, u string) bool {
+	u = strings.TrimSpace(u)
+	scheme, _, ok := strings.Cut(u, ":")
+	if !ok || strings.ContainsAny(scheme, "/?#") {
+		return true
+	}
+	switch strings.ToLower(scheme) {
+	case "http", "https":
+		return true
+	case "mailto":
+		return attr == "href"
+	}
+	return false
+}
+
+// sanitizeHTML removes all elements and attributes from the HTML that are not
+// explicitly allowed, so untrusted HTML like user comments can be displayed safely:
+// scripts, styles, event handlers, and URLs with schemes other than http, https
+// and mailto are removed. Text is kept and escaped.
+// Usage: {{ htmlSafe (sanitize .Comment) }}
+func sanitizeHTML(s string) TrustedHTML {
+	var sb strings.Builder
+	sb.Grow(len(s))
+
+	dropTag := ""
+	dropDepth := 0
+
+	z := nethtml.NewTokenizer(strings.NewReader(s))
+	for {
+		tt := z.Next()
+		if tt == nethtml.ErrorToken {
+			break
+		}
+		tok := z.Token()
+
+		if dropDepth > 0 {
+			if tok.Data == dropTag {
+				switch tt {
+				case nethtml.StartTagToken:
+					dropDepth++
+				case nethtml.EndTagToken:
+					dropDepth--
+				}
+			}
+			continue
+		}
+
+		switch tt {
+		case nethtml.TextToken:
+			sb.WriteString(html.EscapeString(tok.Data))
+		case nethtml.StartTagToken, nethtml.SelfClosingTagToken:
+			if sanitizedDroppedElements[tok.Data] {
+				if tt == nethtml.StartTagToken {
+					dropTag, dropDepth = tok.Data, 1
+				}
+				continue
+			}
+			if !sanitizedElements[tok.Data] {
+				continue
+			}
+			sb.WriteString("<" + tok.Data)
+			for _, attr := range tok.Attr {
+				name := strings.ToLower(attr.Key)
+				if attr.Namespace != "" || (!sanitizedAttrs["*"][name] && !sanitizedAttrs[tok.Data][name]) {
+					continue
+				}
+				if (name == "href" || name == "src") && !safeURL(name, attr.Val) {
+					continue
+				}
+				sb.WriteString(" " + name + `="` + html.EscapeString(attr.Val) + `"`)
+			}
+			if tok.Data == "a" {
+				sb.WriteString(` rel="nofollow noopener noreferrer"`)
+			}
+			sb.WriteString(">")
+		case nethtml.EndTagToken:
+			if sanitizedElements[tok.Data] {
+				sb.WriteString("</" + tok.Data + ">")
+			}
+		}
+	}
+	return TrustedHTML{html: sb.String()}
+}
+
+// htmlSafe marks the value as safe HTML, so it's inserted without escaping.
+// Usage: {{ htmlSafe .Content }}
+func htmlSafe(v any) (template.HTML, error) {
+	switch h := v.(type) {
+	case string:
+		return template.HTML(h), nil
+	case TrustedHTML:
+		return template.HTML(h.html), nil
+	case template.HTML:
+		return h, nil
+	case nil:
+		return "", nil
+	}
+	return "", fmt.Errorf("htmlSafe: unsupported type %T", v)
+}
+
+// strictHTMLSafe is htmlSafe accepting only trusted values: TrustedHTML produced by
+// sanitize or TrustHTML, and template.HTML produced by engine components or Go code
+func strictHTMLSafe(v any) (template.HTML, error) {
+	switch h := v.(type) {
+	case TrustedHTML:
+		return template.HTML(h.html), nil
+	case template.HTML:
+		return h, nil
+	case nil:
+		return "", nil
+	}
+	return "", errors.Join(ErrUntrustedHTML, fmt.Errorf("htmlSafe: %T value must be sanitized first, e.g. htmlSafe (sanitize .Content)", v))
+}
@@ -0,0 +1,102 @@
+package templatex
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/invopop/ctxi18n"
+	"golang.org/x/text/language"
+)
+
+// UnitSystem is a system of measurement units used by formatUnit
+type UnitSystem string
+
+// Unit systems
+const (
+	UnitMetric   UnitSystem = "metric"
+	UnitImperial UnitSystem = "imperial"
+)
+
+// imperialRegions are regions using imperial (US customary) units by default
+var imperialRegions = map[string]bool{"US": true, "LR": true, "MM": true}
+
+// unitConversion converts a value to another unit: value*factor + offset
+type unitConversion struct {
+	unit   string
+	factor float64
+	offset float64
+}
+
+// unitConversions maps metric units to imperial ones and back
+var unitConversions = map[string]unitConversion{
+	"mm":    {"in", 1 / 25.4, 0},
+	"cm":    {"in", 1 / 2.54, 0},
+	"m":     {"ft", 1 / 0.3048, 0},
+	"km":    {"mi", 1 / 1.609344, 0},
+	"g":     {"oz", 1 / 28.349523125, 0},
+	"kg":    {"lb", 1 / 0.45359237, 0},
+	"ml":    {"fl oz", 1 / 29.5735295625, 0},
+	"l":     {"gal", 1 / 3.785411784, 0},
+	"km/h":  {"mph", 1 / 1.609344, 0},
+	"°C":    {"°F", 1.8, 32},
+	"in":    {"cm", 2.54, 0},
+	"ft":    {"m", 0.3048, 0},
+	"mi":    {"km", 1.609344, 0},
+	"oz":    {"g", 28.349523125, 0},
+	"lb":    {"kg", 0.45359237, 0},
+	"fl oz": {"ml", 29.5735295625, 0},
+	"gal":   {"l", 3.785411784, 0},
+	"mph":   {"km/h", 1.609344, 0},
+	"°F":    {"°C", 1 / 1.8, -32 / 1.8},
+}
+
+// unitSystems maps units to their system
+var unitSystems = map[string]UnitSystem{
+	"mm": UnitMetric, "cm": UnitMetric, "m": UnitMetric, "km": UnitMetric, "g": UnitMetric,
+	"kg": UnitMetric, "ml": UnitMetric, "l": UnitMetric, "km/h": UnitMetric, "°C": UnitMetric,
+	"in": UnitImperial, "ft": UnitImperial, "mi": UnitImperial, "oz": UnitImperial, "lb": UnitImperial,
+	"fl oz": UnitImperial, "gal": UnitImperial, "mph": UnitImperial, "°F": UnitImperial,
+}
+
+// contextUnitSystem returns the unit system set by WithUnitSystem, or the one used in
+// the region of the context locale, e.g. imperial for "en-US". Defaults to metric.
+func contextUnitSystem(ctx context.Context) UnitSystem {
+	if s := UnitSystemFromContext(ctx); s != "" {
+		return s
+	}
+	if l := ctxi18n.Locale(ctx); l != nil {
+		if tag, err := language.Parse(l.Code().String()); err == nil {
+			if region, conf := tag.Region(); conf == language.Exact && imperialRegions[region.String()] {
+				return UnitImperial
+			}
+		}
+	}
+	return UnitMetric
+}
+
+// formatUnit returns a function formatting a measurement in the unit system of the
+// context, converting it if needed, e.g. 12 "km" renders "7.5 mi" for imperial users.
+// The number is formatted like formatNumber.
+// Usage: {{ formatUnit .Distance "km" 1 }}, {{ formatUnit .Weight "kg" }}
+func formatUnit(ctx context.Context, cfg FormatConfig) func(v any, unit string, decimals ...int) (string, error) {
+	system := contextUnitSystem(ctx)
+	return func(v any, unit string, decimals ...int) (string, error) {
+		f, err := toFloat(v)
+		if err != nil {
+			return "", err
+		}
+		from, ok := unitSystems[unit]
+		if !ok {
+			return "", fmt.Errorf("formatUnit: unknown unit %q", unit)
+		}
+		if from != system {
+			c := unitConversions[unit]
+			f, unit = f*c.factor+c.offset, c.unit
+		}
+		s, err := cfg.formatNumber(f, decimals...)
+		if err != nil {
+			return "", err
+		}
+		return s + " " + unit, nil
+	}
+}
@@ -0,0 +1,176 @@
+package templatex
+
+import (
+	"context"
+	"fmt"
+	"html/template"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Upload is the props of the built-in upload component, rendering a labelled file input
+// with an accept list, a size limit and a hint describing them:
+//
+//	{{ component "upload" (props "Name" "avatar" "Label" "Avatar" "Accept" (split "png,jpg" ",") "MaxSize" 5242880) }}
+//
+// The size limit is rendered as the data-max-size attribute for client-side checks, and
+// in the hint formatted by humanizeBytes. Validation errors are taken from the form state
+// like those of the field component (see WithFormState).
+type Upload struct {
+	Name     string `prop:"required"`
+	Label    string
+	Accept   []string // MIME types, wildcards and extensions (see acceptTypes)
+	MaxSize  int64    // size limit of a file in bytes; zero if unlimited
+	Multiple bool
+	Required bool
+	Hint     string // help text replacing the generated accept and size hint
+	ID       string // id of the input; derived from the name if empty
+	Class    string // class of the wrapping element
+}
+
+// acceptTypes returns the value of the accept attribute of file inputs from MIME
+// types, wildcards and extensions, given as strings or string slices. Extensions
+// get a leading dot and values are lowercased and deduplicated.
+// Usage: <input type="file" accept="{{ acceptTypes "image/*" "pdf" }}"> renders
+// accept="image/*,.pdf"
+func acceptTypes(types ...any) (string, error) {
+	var list []string
+	for _, t := range types {
+		switch v := t.(type) {
+		case string:
+			list = append(list, v)
+		case []string:
+			list = append(list, v...)
+		default:
+			rv := reflect.ValueOf(t)
+			if rv.Kind() != reflect.Slice {
+				return "", fmt.Errorf("acceptTypes: unsupported type %T", t)
+			}
+			for i := 0; i < rv.Len(); i++ {
+				s, ok := rv.Index(i).Interface().(string)
+				if !ok {
+					return "", fmt.Errorf("acceptTypes: unsupported type %T", rv.Index(i).Interface())
+				}
+				list = append(list, s)
+			}
+		}
+	}
+	return strings.Join(normalizeAccept(list), ","), nil
+}
+
+// normalizeAccept lowercases and deduplicates the accepted types and adds a leading
+// dot to extensions
+func normalizeAccept(types []string) []string {
+	seen := make(map[string]bool, len(types))
+	out := make([]string, 0, len(types))
+	for _, t := range types {
+		t = strings.ToLower(strings.TrimSpace(t))
+		if t == "" {
+			continue
+		}
+		if !strings.Contains(t, "/") && !strings.HasPrefix(t, ".") {
+			t = "." + t
+		}
+		if !seen[t] {
+			seen[t] = true
+			out = append(out, t)
+		}
+	}
+	return out
+}
+
+// acceptLabel returns the name of an accepted type shown in hints, e.g. "PNG" for
+// "image/png" and ".png", or "image files" for "image/*"
+func acceptLabel(t string) string {
+	if kind, ok := strings.CutSuffix(t, "/*"); ok {
+		return kind + " files"
+	}
+	if _, sub, ok := strings.Cut(t, "/"); ok {
+		t = sub
+	}
+	return strings.ToUpper(strings.TrimPrefix(t, "."))
+}
+
+// renderUpload renders the upload component
+func (e *Engine) renderUpload(ctx context.Context, props map[string]any) (template.HTML, error) {
+	var u Upload
+	if err := decodeProps(props, &u); err != nil {
+		return "", fmt.Errorf("upload: %w", err)
+	}
+	if u.MaxSize < 0 {
+		return "", fmt.Errorf("upload: negative max size %d", u.MaxSize)
+	}
+	if u.ID == "" {
+		u.ID = fieldID(u.Name)
+	}
+	accept := normalizeAccept(u.Accept)
+
+	hint := u.Hint
+	if hint == "" {
+		var parts []string
+		if len(accept) > 0 {
+			labels := make([]string, len(accept))
+			for i, t := range accept {
+				labels[i] = acceptLabel(t)
+			}
+			parts = append(parts, strings.Join(labels, ", "))
+		}
+		if u.MaxSize > 0 {
+			size, err := e.formatConfig.humanizeBytes(u.MaxSize)
+			if err != nil {
+				return "", fmt.Errorf("upload: %w", err)
+			}
+			parts = append(parts, "up to "+size)
+		}
+		hint = strings.Join(parts, ", ")
+	}
+
+	state, _ := FormStateFromContext(ctx)
+	errs := state.Errors[u.Name]
+	var describedBy []string
+	if hint != "" {
+		describedBy = append(describedBy, u.ID+"-hint")
+	}
+	if len(errs) > 0 {
+		describedBy = append(describedBy, u.ID+"-error")
+	}
+
+	var sb strings.Builder
+	class := strings.TrimSpace("field upload " + u.Class)
+	if len(errs) > 0 {
+		class += " has-error"
+	}
+	sb.WriteString(`<div`)
+	writeClass(&sb, class)
+	sb.WriteString(`>`)
+	if u.Label != "" {
+		sb.WriteString(`<label for="` + template.HTMLEscapeString(u.ID) + `">` + template.HTMLEscapeString(u.Label) + `</label>`)
+	}
+	sb.WriteString(`<input type="file" id="` + template.HTMLEscapeString(u.ID) + `" name="` + template.HTMLEscapeString(u.Name) + `"`)
+	writeAttr(&sb, "accept", strings.Join(accept, ","))
+	if u.MaxSize > 0 {
+		sb.WriteString(` data-max-size="` + strconv.FormatInt(u.MaxSize, 10) + `"`)
+	}
+	if u.Multiple {
+		sb.WriteString(` multiple`)
+	}
+	if u.Required {
+		sb.WriteString(` required`)
+	}
+	if len(errs) > 0 {
+		sb.WriteString(` aria-invalid="true"`)
+	}
+	writeAttr(&sb, "aria-describedby", strings.Join(describedBy, " "))
+	sb.WriteString(`>`)
+	if hint != "" {
+		sb.WriteString(`<p class="field-hint" id="` + template.HTMLEscapeString(u.ID) + `-hint">` + template.HTMLEscapeString(hint) + `</p>`)
+	}
+	if len(errs) > 0 {
+		sb.WriteString(`<div class="field-errors" id="` + template.HTMLEscapeString(u.ID) + `-error">`)
+		writeErrors(&sb, errs)
+		sb.WriteString(`</div>`)
+	}
+	sb.WriteString(`</div>`)
+	return template.HTML(sb.String()), nil
+}
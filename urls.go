@@ -0,0 +1,103 @@
+package templatex
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// parseBaseURL parses an absolute http(s) base URL, e.g. "https://example.com/app"
+func parseBaseURL(raw string) (*url.URL, error) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil, err
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("%q is not an absolute http(s) URL", raw)
+	}
+	u.RawQuery, u.Fragment = "", ""
+	return u, nil
+}
+
+// requestBaseURL returns the scheme and host the request was made to. The scheme is
+// https for TLS connections and requests forwarded by a proxy with
+// "X-Forwarded-Proto: https".
+func requestBaseURL(r *http.Request) string {
+	if r.Host == "" {
+		return ""
+	}
+	scheme := "http"
+	if r.TLS != nil || strings.EqualFold(r.Header.Get("X-Forwarded-Proto"), "https") {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host
+}
+
+// contextBaseURL returns the base URL set by WithBaseURL, or the request base URL
+// stored in ctx
+func (e *Engine) contextBaseURL(ctx context.Context) (*url.URL, error) {
+	if e.baseURL != nil {
+		return e.baseURL, nil
+	}
+	raw := RequestBaseURL(ctx)
+	if raw == "" {
+		return nil, errors.New("no base URL: set WithBaseURL or render with templatex.Middleware")
+	}
+	return parseBaseURL(raw)
+}
+
+// resolveURL returns the reference as an absolute URL. Paths are resolved against the
+// base URL including its path, e.g. "/feed.xml" against "https://example.com/blog"
+// is "https://example.com/blog/feed.xml". Absolute URLs are returned as is.
+func resolveURL(base *url.URL, ref string) (string, error) {
+	r, err := url.Parse(ref)
+	if err != nil {
+		return "", err
+	}
+	if r.Scheme != "" || r.Host != "" {
+		return base.ResolveReference(r).String(), nil
+	}
+	u := *base
+	u.Path = strings.TrimSuffix(base.Path, "/") + "/" + strings.TrimPrefix(r.Path, "/")
+	u.RawPath = ""
+	u.RawQuery, u.Fragment = r.RawQuery, r.Fragment
+	return u.String(), nil
+}
+
+// absURL returns a function converting a path to an absolute URL on the base URL
+// (see WithBaseURL) or the request host.
+// Usage: <meta property="og:image" content="{{ absURL .Image }}">
+func (e *Engine) absURL(ctx context.Context) func(path any) (string, error) {
+	return func(path any) (string, error) {
+		base, err := e.contextBaseURL(ctx)
+		if err != nil {
+			return "", fmt.Errorf("absURL: %w", err)
+		}
+		s, err := resolveURL(base, fmt.Sprint(path))
+		if err != nil {
+			return "", fmt.Errorf("absURL: %w", err)
+		}
+		return s, nil
+	}
+}
+
+// canonical returns a function returning the absolute URL of the request path without
+// its query string, for canonical links and og:url tags. The request path is the full
+// path, so the path of the base URL isn't prepended.
+// Usage: <link rel="canonical" href="{{ canonical }}">
+func (e *Engine) canonical(ctx context.Context) func() (string, error) {
+	return func() (string, error) {
+		base, err := e.contextBaseURL(ctx)
+		if err != nil {
+			return "", fmt.Errorf("canonical: %w", err)
+		}
+		u := url.URL{Scheme: base.Scheme, Host: base.Host, Path: RequestPath(ctx)}
+		if u.Path == "" {
+			u.Path = "/"
+		}
+		return u.String(), nil
+	}
+}
@@ -0,0 +1,142 @@
+package templatex
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// viewTag is the struct tag describing how a field is displayed by fmtField,
+// e.g. `view:"format=money,currency=USD"`
+const viewTag = "view"
+
+// currencySymbols are the symbols of common currencies used by the money format.
+// Other currencies are prefixed with their code.
+var currencySymbols = map[string]string{
+	"USD": "$", "EUR": "€", "GBP": "£", "JPY": "¥", "CNY": "¥", "INR": "₹", "RUB": "₽", "UAH": "₴",
+}
+
+// namedLayouts are time layouts that can be referenced by name in view tags,
+// since tag options can't contain commas
+var namedLayouts = map[string]string{
+	"DateOnly": time.DateOnly, "DateTime": time.DateTime, "TimeOnly": time.TimeOnly,
+	"RFC3339": time.RFC3339, "RFC1123": time.RFC1123, "RFC822": time.RFC822,
+	"Kitchen": time.Kitchen, "Stamp": time.Stamp,
+}
+
+// parseViewTag parses a view tag into its options
+func parseViewTag(tag string) map[string]string {
+	opts := make(map[string]string)
+	for _, part := range strings.Split(tag, ",") {
+		key, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+		if key != "" {
+			opts[key] = value
+		}
+	}
+	return opts
+}
+
+// fmtField formats the field at the path (see getPath) as described by its view tag:
+//   - format=number,decimals=2: number with the configured separators
+//   - format=money,currency=USD,decimals=2: amount with the currency symbol
+//   - format=percent,decimals=0: ratio as percentage, e.g. 0.25 as "25%"
+//   - format=date,layout=DateOnly: time with a Go layout or a layout name like RFC3339,
+//     the configured date layout by default
+//   - format=humanize: time relative to now, e.g. "5 minutes ago"
+//
+// Fields without a view tag, and map values, are printed as is.
+// Usage: {{ fmtField .Order "Total" }}, {{ fmtField . "Order.CreatedAt" }}
+func (cfg FormatConfig) fmtField(data any, path string, now time.Time) (string, error) {
+	parentPath, field := "", path
+	if i := strings.LastIndex(path, "."); i >= 0 {
+		parentPath, field = path[:i], path[i+1:]
+	}
+	parent, ok := lookupPath(data, parentPath)
+	if !ok {
+		return "", fmt.Errorf("fmtField: field %s not found", path)
+	}
+	pv := indirect(reflect.ValueOf(parent))
+	fv, ok := lookupKey(pv, field)
+	if !ok {
+		return "", fmt.Errorf("fmtField: field %s not found", path)
+	}
+	value := indirect(fv)
+	if !value.IsValid() {
+		return "", nil
+	}
+
+	var opts map[string]string
+	if pv.Kind() == reflect.Struct {
+		if sf, ok := pv.Type().FieldByName(field); ok {
+			opts = parseViewTag(sf.Tag.Get(viewTag))
+		}
+	}
+	if opts["format"] == "" {
+		return fmt.Sprint(value.Interface()), nil
+	}
+
+	decimals := func(def int) (int, error) {
+		if s, ok := opts["decimals"]; ok {
+			return strconv.Atoi(s)
+		}
+		return def, nil
+	}
+
+	v := value.Interface()
+	switch opts["format"] {
+	case "number":
+		d, err := decimals(cfg.Decimals)
+		if err != nil {
+			return "", fmt.Errorf("fmtField: %s: invalid decimals: %w", path, err)
+		}
+		return cfg.formatNumber(v, d)
+	case "money":
+		d, err := decimals(2)
+		if err != nil {
+			return "", fmt.Errorf("fmtField: %s: invalid decimals: %w", path, err)
+		}
+		f, err := toFloat(v)
+		if err != nil {
+			return "", fmt.Errorf("fmtField: %s: %w", path, err)
+		}
+		s, err := cfg.formatNumber(f, d)
+		if err != nil {
+			return "", err
+		}
+		symbol := opts["currency"]
+		if sym, ok := currencySymbols[strings.ToUpper(symbol)]; ok {
+			symbol = sym
+		} else if symbol != "" {
+			symbol += " "
+		}
+		if neg, ok := strings.CutPrefix(s, "-"); ok {
+			return "-" + symbol + neg, nil
+		}
+		return symbol + s, nil
+	case "percent":
+		d, err := decimals(0)
+		if err != nil {
+			return "", fmt.Errorf("fmtField: %s: invalid decimals: %w", path, err)
+		}
+		f, err := toFloat(v)
+		if err != nil {
+			return "", fmt.Errorf("fmtField: %s: %w", path, err)
+		}
+		s, err := cfg.formatNumber(f*100, d)
+		return s + "%", err
+	case "date":
+		layout := cfg.DateLayout
+		if l, ok := opts["layout"]; ok {
+			layout = l
+			if named, ok := namedLayouts[l]; ok {
+				layout = named
+			}
+		}
+		return formatTime(cfg.inLocation(v), layout)
+	case "humanize":
+		return cfg.humanizeTime(v, now)
+	}
+	return "", fmt.Errorf("fmtField: %s: unknown format %q", path, opts["format"])
+}
@@ -0,0 +1,77 @@
+//go:build !js && !wasip1
+
+package templatex
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// reloadDelay is the time to wait for more changes before reloading templates,
+// so saving several files at once triggers a single reload
+const reloadDelay = 100 * time.Millisecond
+
+// watch starts watching the template directory and its subdirectories for changes
+func (e *Engine) watch(dir string) error {
+	w, err := fsnotify.NewWatcher()
+	if err != nil {
+		return errors.Join(ErrAutoReloadFailed, err)
+	}
+
+	err = filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || !d.IsDir() {
+			return err
+		}
+		return w.Add(path)
+	})
+	if err != nil {
+		_ = w.Close()
+		return errors.Join(ErrAutoReloadFailed, err)
+	}
+
+	e.watcher = w
+	go e.watchLoop(w)
+	return nil
+}
+
+// watchLoop reloads templates after changes until the watcher is closed
+func (e *Engine) watchLoop(w *fsnotify.Watcher) {
+	timer := time.NewTimer(reloadDelay)
+	timer.Stop()
+	defer timer.Stop()
+
+	for {
+		select {
+		case event, ok := <-w.Events:
+			if !ok {
+				return
+			}
+			if event.Has(fsnotify.Chmod) && !event.Has(fsnotify.Write) {
+				continue
+			}
+			// Watch new subdirectories
+			if event.Has(fsnotify.Create) {
+				if info, err := os.Stat(event.Name); err == nil && info.IsDir() {
+					_ = w.Add(event.Name)
+				}
+			}
+			timer.Reset(reloadDelay)
+		case err, ok := <-w.Errors:
+			if !ok {
+				return
+			}
+			e.logger.Error("templatex: template watcher error", "error", err)
+		case <-timer.C:
+			if err := e.Reload(); err != nil {
+				e.logger.Error("templatex: failed to reload templates", "error", err)
+				continue
+			}
+			e.logger.Info("templatex: templates reloaded")
+		}
+	}
+}
@@ -0,0 +1,13 @@
+//go:build js || wasip1
+
+package templatex
+
+import (
+	"errors"
+	"runtime"
+)
+
+// watch is not supported on WebAssembly, which has no file system notifications
+func (e *Engine) watch(string) error {
+	return errors.Join(ErrAutoReloadFailed, errors.New("file watching is not supported on "+runtime.GOOS))
+}
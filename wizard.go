@@ -0,0 +1,158 @@
+package templatex
+
+import (
+	"context"
+	"html/template"
+	"net/url"
+	"strings"
+)
+
+var wizardKey = &contextKey{"wizard"}
+
+// wizardStepPlaceholder is replaced with the step name in Wizard.URL
+const wizardStepPlaceholder = "{step}"
+
+// WizardStep is a step of a multi-step form flow
+type WizardStep struct {
+	Name  string // step identifier used in URLs, e.g. "account"
+	Title string // step title shown in progress indicators
+}
+
+// Wizard is the state of a multi-step form flow, read by the wizardStep, isCurrentStep
+// and stepURL template functions. It's taken from the context (see WithWizard) or from
+// a Wizard field or map key of the binding.
+type Wizard struct {
+	Steps   []WizardStep
+	Current string // name of the current step
+	URL     string // URL of a step with a {step} placeholder; "?step={step}" if empty
+}
+
+// WizardProgress describes the current step of a wizard and the state of all steps
+// (see the wizardStep template function)
+type WizardProgress struct {
+	WizardStepState
+	Total int               // number of steps
+	First bool              // the current step is the first one
+	Last  bool              // the current step is the last one
+	Prev  string            // name of the previous step, empty for the first step
+	Next  string            // name of the next step, empty for the last step
+	Steps []WizardStepState // all steps, in order
+}
+
+// WizardStepState is a step of a wizard with its position relative to the current step
+type WizardStepState struct {
+	WizardStep
+	Number  int    // 1-based position of the step
+	URL     string // URL of the step
+	Current bool   // the step is the current one
+	Done    bool   // the step is before the current one
+}
+
+// WithWizard returns a copy of ctx that carries the wizard state
+func WithWizard(ctx context.Context, w Wizard) context.Context {
+	return context.WithValue(ctx, wizardKey, w)
+}
+
+// WizardFromContext returns the wizard state stored in ctx by WithWizard
+func WizardFromContext(ctx context.Context) (Wizard, bool) {
+	if ctx == nil {
+		return Wizard{}, false
+	}
+	w, ok := ctx.Value(wizardKey).(Wizard)
+	return w, ok
+}
+
+// String returns a string representation of the wizard state.
+// It's used as a part of the render cache key.
+func (w Wizard) String() string {
+	var sb strings.Builder
+	sb.WriteString(w.Current)
+	sb.WriteByte('@')
+	sb.WriteString(w.URL)
+	for _, s := range w.Steps {
+		sb.WriteByte(';')
+		sb.WriteString(s.Name)
+		sb.WriteByte('=')
+		sb.WriteString(s.Title)
+	}
+	return sb.String()
+}
+
+// stepURL returns the URL of the step
+func (w Wizard) stepURL(name string) string {
+	pattern := w.URL
+	if pattern == "" {
+		pattern = "?step=" + wizardStepPlaceholder
+	}
+	return strings.ReplaceAll(pattern, wizardStepPlaceholder, url.PathEscape(name))
+}
+
+// progress returns the progress of the wizard, or nil if the current step is unknown
+func (w Wizard) progress() *WizardProgress {
+	current := -1
+	for i, s := range w.Steps {
+		if s.Name == w.Current {
+			current = i
+			break
+		}
+	}
+	if current < 0 {
+		return nil
+	}
+
+	p := &WizardProgress{
+		Total: len(w.Steps),
+		First: current == 0,
+		Last:  current == len(w.Steps)-1,
+		Steps: make([]WizardStepState, len(w.Steps)),
+	}
+	for i, s := range w.Steps {
+		p.Steps[i] = WizardStepState{WizardStep: s, Number: i + 1, URL: w.stepURL(s.Name), Current: i == current, Done: i < current}
+	}
+	p.WizardStepState = p.Steps[current]
+	if !p.First {
+		p.Prev = w.Steps[current-1].Name
+	}
+	if !p.Last {
+		p.Next = w.Steps[current+1].Name
+	}
+	return p
+}
+
+// withBindingWizard returns a copy of ctx that carries the Wizard field or map key of
+// the binding (a Wizard or *Wizard), unless ctx already carries a wizard state
+func withBindingWizard(ctx context.Context, binding any) context.Context {
+	if _, ok := WizardFromContext(ctx); ok || binding == nil {
+		return ctx
+	}
+	if v, _ := lookupPath(binding, "Wizard"); v != nil {
+		if w, ok := v.(Wizard); ok {
+			return WithWizard(ctx, w)
+		}
+	}
+	return ctx
+}
+
+// wizardFuncs returns the wizard functions bound to the wizard state stored in ctx:
+//   - wizardStep returns the progress of the wizard (see WizardProgress), or nil
+//     if there is no wizard or the current step is unknown
+//   - isCurrentStep reports whether the step is the current one
+//   - stepURL returns the URL of the step
+//
+// Usage:
+//
+//	{{ with wizardStep }}
+//	<ol class="wizard">{{ range .Steps }}
+//	  <li{{ if .Current }} aria-current="step"{{ end }}>{{ if .Done }}<a href="{{ .URL }}">{{ .Title }}</a>{{ else }}{{ .Title }}{{ end }}</li>
+//	{{ end }}</ol>
+//	<p>Step {{ .Number }} of {{ .Total }}</p>
+//	{{ if not .First }}<a href="{{ stepURL .Prev }}">Back</a>{{ end }}
+//	{{ end }}
+func wizardFuncs(ctx context.Context) template.FuncMap {
+	w, _ := WizardFromContext(ctx)
+	return template.FuncMap{
+		"wizardStep":    w.progress,
+		"isCurrentStep": func(name string) bool { return w.Current != "" && w.Current == name },
+		"stepURL":       w.stepURL,
+	}
+}